@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -59,18 +61,35 @@ func paramName(ctx *gin.Context) (string, bool) {
 func recordingsOfPath(
 	pathConf *conf.Path,
 	pathName string,
+	index *recordstore.Index,
 ) *defs.APIRecording {
 	ret := &defs.APIRecording{
 		Name: pathName,
 	}
 
-	segments, _ := recordstore.FindSegments(pathConf, pathName)
+	segments, _ := recordstore.FindSegments(pathConf, pathName, index)
 
 	ret.Segments = make([]*defs.APIRecordingSegment, len(segments))
 
 	for i, seg := range segments {
 		ret.Segments[i] = &defs.APIRecordingSegment{
-			Start: seg.Start,
+			Start:   seg.Start,
+			Labels:  []string{},
+			Markers: []*defs.APIRecordingMarker{},
+		}
+
+		if md, err := seg.ReadMetadata(); err == nil {
+			if md.Labels != nil {
+				ret.Segments[i].Labels = md.Labels
+			}
+
+			for _, m := range md.Markers {
+				ret.Segments[i].Markers = append(ret.Segments[i].Markers, &defs.APIRecordingMarker{
+					Time:  m.Time,
+					Label: m.Label,
+					Data:  m.Data,
+				})
+			}
 		}
 	}
 
@@ -81,6 +100,12 @@ func recordingsOfPath(
 type PathManager interface {
 	APIPathsList() (*defs.APIPathList, error)
 	APIPathsGet(string) (*defs.APIPath, error)
+	APIPathsSwitch(string, string) error
+	APIRecordingsTrigger(string, []string) error
+	APIRecordingsStart(string, defs.APIRecordingStartReq) error
+	APIRecordingsStop(string) error
+	APIReplaySave(string, string, bool) (*defs.APIReplayClip, error)
+	APIRecordingsAddMarker(string, *recordstore.SegmentMarker) error
 }
 
 // HLSServer contains methods used by the API and Metrics server.
@@ -147,6 +172,7 @@ type API struct {
 	HLSServer      HLSServer
 	WebRTCServer   WebRTCServer
 	SRTServer      SRTServer
+	RecordIndex    *recordstore.Index
 	Parent         apiParent
 
 	httpServer *httpp.Server
@@ -178,6 +204,7 @@ func (a *API) Initialize() error {
 
 	group.GET("/paths/list", a.onPathsList)
 	group.GET("/paths/get/*name", a.onPathsGet)
+	group.POST("/paths/switch/*name", a.onPathsSwitch)
 
 	if !interfaceIsEmpty(a.HLSServer) {
 		group.GET("/hlsmuxers/list", a.onHLSMuxersList)
@@ -227,6 +254,15 @@ func (a *API) Initialize() error {
 	group.GET("/recordings/list", a.onRecordingsList)
 	group.GET("/recordings/get/*name", a.onRecordingsGet)
 	group.DELETE("/recordings/deletesegment", a.onRecordingDeleteSegment)
+	group.POST("/recordings/trigger/*name", a.onRecordingsTrigger)
+	group.POST("/recordings/start/*name", a.onRecordingsStart)
+	group.POST("/recordings/stop/*name", a.onRecordingsStop)
+	group.POST("/recordings/addmarker/*name", a.onRecordingsAddMarker)
+	group.POST("/recordings/repair", a.onRecordingsRepair)
+	group.GET("/recordings/verify/*name", a.onRecordingsVerify)
+
+	group.POST("/replay/save/*name", a.onReplaySave)
+	group.GET("/replay/get/*name", a.onReplayGet)
 
 	network, address := restrictnetwork.Restrict("tcp", a.Address)
 
@@ -614,6 +650,33 @@ func (a *API) onPathsGet(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, data)
 }
 
+func (a *API) onPathsSwitch(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	var req defs.APIPathSwitchReq
+	err := json.NewDecoder(ctx.Request.Body).Decode(&req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = a.PathManager.APIPathsSwitch(pathName, req.Input)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
 func (a *API) onRTSPConnsList(ctx *gin.Context) {
 	data, err := a.RTSPServer.APIConnsList()
 	if err != nil {
@@ -1081,7 +1144,7 @@ func (a *API) onRecordingsList(ctx *gin.Context) {
 	c := a.Conf
 	a.mutex.RUnlock()
 
-	pathNames := recordstore.FindAllPathsWithSegments(c.Paths)
+	pathNames := recordstore.FindAllPathsWithSegments(c.Paths, a.RecordIndex)
 
 	data := defs.APIRecordingList{}
 
@@ -1097,7 +1160,7 @@ func (a *API) onRecordingsList(ctx *gin.Context) {
 
 	for i, pathName := range pathNames {
 		pathConf, _, _ := conf.FindPathConf(c.Paths, pathName)
-		data.Items[i] = recordingsOfPath(pathConf, pathName)
+		data.Items[i] = recordingsOfPath(pathConf, pathName, a.RecordIndex)
 	}
 
 	ctx.JSON(http.StatusOK, data)
@@ -1120,7 +1183,157 @@ func (a *API) onRecordingsGet(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, recordingsOfPath(pathConf, pathName))
+	ctx.JSON(http.StatusOK, recordingsOfPath(pathConf, pathName, a.RecordIndex))
+}
+
+func (a *API) onRecordingsRepair(ctx *gin.Context) {
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	var pathNames []string
+
+	if pathName := ctx.Query("path"); pathName != "" {
+		_, _, err := conf.FindPathConf(c.Paths, pathName)
+		if err != nil {
+			a.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		pathNames = []string{pathName}
+	} else {
+		pathNames = recordstore.FindAllPathsWithSegments(c.Paths, a.RecordIndex)
+	}
+
+	data := &defs.APIRecordingRepair{
+		Segments: []*defs.APIRecordingRepairedSegment{},
+	}
+
+	for _, pathName := range pathNames {
+		pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+		if err != nil || pathConf.RecordFormat != conf.RecordFormatFMP4 {
+			continue
+		}
+
+		segments, err := recordstore.FindSegments(pathConf, pathName, a.RecordIndex)
+		if err != nil {
+			continue
+		}
+
+		// the newest segment is in use when the path is being recorded,
+		// otherwise it is the one that may have been truncated by a crash.
+		if a.isRecording(pathName) {
+			segments = segments[:len(segments)-1]
+		}
+
+		for _, seg := range segments {
+			if seg.InBucket() {
+				continue
+			}
+
+			data.ScannedCount++
+
+			res, err := recordstore.RepairFMP4(seg)
+			if err != nil {
+				a.Log(logger.Warn, "unable to repair %s: %v", seg.Fpath, err)
+				data.FailedCount++
+				continue
+			}
+
+			if res != nil {
+				a.Log(logger.Info, "repaired %s", seg.Fpath)
+
+				data.Segments = append(data.Segments, &defs.APIRecordingRepairedSegment{
+					Path:         pathName,
+					Start:        res.Start,
+					OriginalSize: res.OriginalSize,
+					Size:         res.Size,
+					PartCount:    res.PartCount,
+					Removed:      res.Removed,
+					Quarantined:  res.Quarantined,
+				})
+			}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+// isRecording checks whether a path is being recorded.
+func (a *API) isRecording(pathName string) bool {
+	pa, err := a.PathManager.APIPathsGet(pathName)
+	return err == nil && pa.Recording != nil
+}
+
+func (a *API) onRecordingsVerify(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	var start time.Time
+	if s := ctx.Query("start"); s != "" {
+		var err error
+		start, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+			return
+		}
+	}
+
+	var end time.Time
+	if s := ctx.Query("end"); s != "" {
+		var err error
+		end, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+			return
+		}
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordVerifyKey == "" && pathConf.RecordSignKey == "" {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("'recordVerifyKey' is not set"))
+		return
+	}
+
+	results, err := recordstore.VerifyRecordings(pathConf, pathName, start, end, a.RecordIndex)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	data := &defs.APIRecordingVerify{
+		Valid:    true,
+		Segments: make([]*defs.APIRecordingVerifiedSegment, len(results)),
+	}
+
+	for i, res := range results {
+		data.Segments[i] = &defs.APIRecordingVerifiedSegment{
+			Start:  res.Segment.Start,
+			SHA256: res.SHA256,
+			Status: defs.APIRecordingVerifyStatus(res.Status),
+		}
+
+		if res.Status != recordstore.SegmentVerifyStatusValid {
+			data.Valid = false
+		}
+	}
+
+	ctx.JSON(http.StatusOK, data)
 }
 
 func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
@@ -1151,7 +1364,15 @@ func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
 		Start: start,
 	}.Encode(pathFormat)
 
-	err = os.Remove(segmentPath)
+	err = recordstore.RemoveSegment(segmentPath)
+
+	// the segment may have been moved into a bucket
+	if errors.Is(err, os.ErrNotExist) && pathConf.RecordS3Bucket != "" {
+		err = removeBucketSegment(pathConf, pathName, start, a.RecordIndex)
+	} else if err == nil && a.RecordIndex != nil {
+		a.RecordIndex.Remove(segmentPath) //nolint:errcheck
+	}
+
 	if err != nil {
 		a.writeError(ctx, http.StatusBadRequest, err)
 		return
@@ -1160,6 +1381,269 @@ func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+func removeBucketSegment(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	index *recordstore.Index,
+) error {
+	segments, err := recordstore.FindSegments(pathConf, pathName, index)
+	if err != nil {
+		return err
+	}
+
+	for _, seg := range segments {
+		if seg.InBucket() && seg.Start.Equal(start) {
+			return seg.Remove()
+		}
+	}
+
+	return recordstore.ErrNoSegmentsFound
+}
+
+func (a *API) onRecordingsTrigger(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	// labels can be provided in the body or in the query,
+	// in order to support clients that are unable to send a body.
+	var req defs.APIRecordingTriggerReq
+	err := json.NewDecoder(ctx.Request.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Labels = append(req.Labels, ctx.QueryArray("label")...)
+
+	err = a.PathManager.APIRecordingsTrigger(pathName, req.Labels)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) onRecordingsStart(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	// the record format can't be chosen, since segments are found by using
+	// the record format of the path, therefore unknown fields are rejected.
+	var req defs.APIRecordingStartReq
+	d := json.NewDecoder(ctx.Request.Body)
+	d.DisallowUnknownFields()
+	err := d.Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Duration < 0 {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration"))
+		return
+	}
+
+	err = a.PathManager.APIRecordingsStart(pathName, req)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) onRecordingsStop(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	err := a.PathManager.APIRecordingsStop(pathName)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) onRecordingsAddMarker(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	var req defs.APIRecordingAddMarkerReq
+	err := json.NewDecoder(ctx.Request.Body).Decode(&req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Label == "" {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("label is missing"))
+		return
+	}
+
+	if string(req.Data) == "null" {
+		req.Data = nil
+	}
+
+	if req.Data != nil && !json.Valid(req.Data) {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid data"))
+		return
+	}
+
+	marker := &recordstore.SegmentMarker{
+		Label: req.Label,
+		Data:  req.Data,
+	}
+
+	if req.Time != nil {
+		marker.Time = *req.Time
+	} else {
+		marker.Time = time.Now()
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, a.RecordIndex)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	// the marker is stored into the metadata of the last segment that starts before it.
+	var seg *recordstore.Segment
+	for _, s := range segments {
+		if !s.Start.After(marker.Time) {
+			seg = s
+		}
+	}
+
+	if seg == nil {
+		a.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	err = seg.UpdateMetadata(func(md *recordstore.SegmentMetadata) {
+		md.AddMarker(marker)
+	})
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the marker is embedded into the recording only when the path is being recorded
+	// with a format that supports it.
+	res := &defs.APIRecordingAddMarkerRes{}
+
+	if !interfaceIsEmpty(a.PathManager) {
+		err = a.PathManager.APIRecordingsAddMarker(pathName, marker)
+		if err != nil {
+			a.Log(logger.Debug, "marker of path '%s' has not been embedded into the recording: %v", pathName, err)
+		} else {
+			res.Embedded = true
+		}
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (a *API) onReplaySave(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusNotFound, err)
+		return
+	}
+
+	clip, err := a.PathManager.APIReplaySave(pathName, pathConf.ReplayPath, true)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, clip)
+}
+
+func (a *API) onReplayGet(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	// the clip is written into a temporary file, that is removed once sent.
+	// The file is not encrypted, since it is sent to the client in clear text.
+	dir, err := os.MkdirTemp("", "mediamtx-replay")
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	clip, err := a.PathManager.APIReplaySave(pathName, filepath.Join(dir, "clip"), false)
+	if err != nil {
+		if errors.Is(err, conf.ErrPathNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.Header("Content-Disposition", "attachment; filename=\""+filepath.Base(pathName)+".mp4\"")
+	ctx.File(clip.Path)
+}
+
 // ReloadConf is called by core.
 func (a *API) ReloadConf(conf *conf.Conf) {
 	a.mutex.Lock()
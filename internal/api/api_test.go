@@ -2,7 +2,10 @@ package api
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,7 +15,9 @@ import (
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/stretchr/testify/require"
 )
@@ -24,6 +29,22 @@ func (testParent) Log(_ logger.Level, _ string, _ ...interface{}) {
 
 func (testParent) APIConfigSet(_ *conf.Conf) {}
 
+type testPathManager struct {
+	PathManager
+	recording map[string]bool
+}
+
+func (pm *testPathManager) APIPathsGet(name string) (*defs.APIPath, error) {
+	if !pm.recording[name] {
+		return nil, conf.ErrPathNotFound
+	}
+
+	return &defs.APIPath{
+		Name:      name,
+		Recording: &defs.APIPathRecording{},
+	}, nil
+}
+
 func tempConf(t *testing.T, cnt string) *conf.Conf {
 	fi, err := test.CreateTempFile([]byte(cnt))
 	require.NoError(t, err)
@@ -603,10 +624,14 @@ func TestRecordingsList(t *testing.T) {
 				"name": "mypath1",
 				"segments": []interface{}{
 					map[string]interface{}{
-						"start": time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+						"start":   time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+						"labels":  []interface{}{},
+						"markers": []interface{}{},
 					},
 					map[string]interface{}{
-						"start": time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+						"start":   time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+						"labels":  []interface{}{},
+						"markers": []interface{}{},
 					},
 				},
 			},
@@ -614,7 +639,9 @@ func TestRecordingsList(t *testing.T) {
 				"name": "mypath2",
 				"segments": []interface{}{
 					map[string]interface{}{
-						"start": time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+						"start":   time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+						"labels":  []interface{}{},
+						"markers": []interface{}{},
 					},
 				},
 			},
@@ -652,6 +679,10 @@ func TestRecordingsGet(t *testing.T) {
 	err = os.WriteFile(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-900000.mp4"), []byte(""), 0o644)
 	require.NoError(t, err)
 
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-900000.json"),
+		[]byte(`{"labels":["motion"]}`), 0o644)
+	require.NoError(t, err)
+
 	tr := &http.Transport{}
 	defer tr.CloseIdleConnections()
 	hc := &http.Client{Transport: tr}
@@ -662,10 +693,14 @@ func TestRecordingsGet(t *testing.T) {
 		"name": "mypath1",
 		"segments": []interface{}{
 			map[string]interface{}{
-				"start": time.Date(2008, 11, 0o7, 11, 22, 0, 0, time.Local).Format(time.RFC3339Nano),
+				"start":   time.Date(2008, 11, 0o7, 11, 22, 0, 0, time.Local).Format(time.RFC3339Nano),
+				"labels":  []interface{}{},
+				"markers": []interface{}{},
 			},
 			map[string]interface{}{
-				"start": time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+				"start":   time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+				"labels":  []interface{}{"motion"},
+				"markers": []interface{}{},
 			},
 		},
 	}, out)
@@ -718,3 +753,262 @@ func TestRecordingsDeleteSegment(t *testing.T) {
 	defer res.Body.Close()
 	require.Equal(t, http.StatusOK, res.StatusCode)
 }
+
+func TestRecordingsAddMarker(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cnf := tempConf(t, "pathDefaults:\n"+
+		"  recordPath: "+filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")+"\n"+
+		"paths:\n"+
+		"  all_others:\n")
+
+	api := API{
+		Address:     "localhost:9997",
+		ReadTimeout: conf.StringDuration(10 * time.Second),
+		Conf:        cnf,
+		AuthManager: test.NilAuthManager,
+		Parent:      &testParent{},
+	}
+	err = api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	err = os.Mkdir(filepath.Join(dir, "mypath1"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-000000.mp4"), []byte(""), 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-000000.mp4"), []byte(""), 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-000000.json"),
+		[]byte(`{"labels":["motion"]}`), 0o644)
+	require.NoError(t, err)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, ca := range []struct {
+		t     time.Time
+		label string
+		data  interface{}
+	}{
+		{time.Date(2009, 11, 0o7, 11, 25, 0, 0, time.UTC), "incident 42 ended", nil},
+		{time.Date(2008, 11, 0o7, 11, 23, 0, 0, time.UTC), "door opened", map[string]interface{}{"door": 3}},
+		{time.Date(2009, 11, 0o7, 11, 24, 0, 0, time.UTC), "incident 42 started", nil},
+	} {
+		var res interface{}
+		httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/addmarker/mypath1", map[string]interface{}{
+			"time":  ca.t.Format(time.RFC3339),
+			"label": ca.label,
+			"data":  ca.data,
+		}, &res)
+
+		// the path is not being recorded, therefore the marker is stored in metadata only
+		require.Equal(t, map[string]interface{}{"embedded": false}, res)
+	}
+
+	var out interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/get/mypath1", nil, &out)
+	require.Equal(t, map[string]interface{}{
+		"name": "mypath1",
+		"segments": []interface{}{
+			map[string]interface{}{
+				"start":  time.Date(2008, 11, 0o7, 11, 22, 0, 0, time.Local).Format(time.RFC3339Nano),
+				"labels": []interface{}{},
+				"markers": []interface{}{
+					map[string]interface{}{
+						"time":  "2008-11-07T11:23:00Z",
+						"label": "door opened",
+						"data":  map[string]interface{}{"door": float64(3)},
+					},
+				},
+			},
+			map[string]interface{}{
+				"start":  time.Date(2009, 11, 0o7, 11, 22, 0, 0, time.Local).Format(time.RFC3339Nano),
+				"labels": []interface{}{"motion"},
+				"markers": []interface{}{
+					map[string]interface{}{
+						"time":  "2009-11-07T11:24:00Z",
+						"label": "incident 42 started",
+						"data":  nil,
+					},
+					map[string]interface{}{
+						"time":  "2009-11-07T11:25:00Z",
+						"label": "incident 42 ended",
+						"data":  nil,
+					},
+				},
+			},
+		},
+	}, out)
+}
+
+func TestRecordingsRepair(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cnf := tempConf(t, "pathDefaults:\n"+
+		"  recordPath: "+filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")+"\n"+
+		"paths:\n"+
+		"  all_others:\n")
+
+	api := API{
+		Address:     "localhost:9997",
+		ReadTimeout: conf.StringDuration(10 * time.Second),
+		Conf:        cnf,
+		AuthManager: test.NilAuthManager,
+		PathManager: &testPathManager{recording: map[string]bool{"mypath1": true}},
+		Parent:      &testParent{},
+	}
+	err = api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	err = os.Mkdir(filepath.Join(dir, "mypath1"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-900000.mp4"), []byte("abc"), 0o644)
+	require.NoError(t, err)
+
+	// the newest segment is skipped, since the path is being recorded
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-900000.mp4"), []byte("abc"), 0o644)
+	require.NoError(t, err)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out interface{}
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/repair?path=mypath1", nil, &out)
+	require.Equal(t, map[string]interface{}{
+		"scannedCount": float64(1),
+		"failedCount":  float64(0),
+		"segments": []interface{}{
+			map[string]interface{}{
+				"path":         "mypath1",
+				"start":        time.Date(2008, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+				"originalSize": float64(3),
+				"size":         float64(0),
+				"partCount":    float64(0),
+				"removed":      false,
+				"quarantined":  true,
+			},
+		},
+	}, out)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-900000.mp4"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-900000.mp4.corrupted"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-900000.mp4"))
+	require.NoError(t, err)
+
+	// the newest segment is repaired when the path is not being recorded
+	err = os.Mkdir(filepath.Join(dir, "mypath2"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath2", "2009-11-07_11-22-00-900000.mp4"), []byte("abc"), 0o644)
+	require.NoError(t, err)
+
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/repair?path=mypath2", nil, &out)
+	require.Equal(t, float64(1), out.(map[string]interface{})["scannedCount"])
+
+	_, err = os.Stat(filepath.Join(dir, "mypath2", "2009-11-07_11-22-00-900000.mp4.corrupted"))
+	require.NoError(t, err)
+}
+
+func TestRecordingsVerify(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	byts, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "sign.key"),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: byts}), 0o644)
+	require.NoError(t, err)
+
+	// signatures are verified with the public key only
+	byts, err = x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "verify.key"),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: byts}), 0o644)
+	require.NoError(t, err)
+
+	cnf := tempConf(t, "pathDefaults:\n"+
+		"  recordPath: "+filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")+"\n"+
+		"  recordVerifyKey: "+filepath.Join(dir, "verify.key")+"\n"+
+		"paths:\n"+
+		"  all_others:\n")
+
+	api := API{
+		Address:     "localhost:9997",
+		ReadTimeout: conf.StringDuration(10 * time.Second),
+		Conf:        cnf,
+		AuthManager: test.NilAuthManager,
+		Parent:      &testParent{},
+	}
+	err = api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	err = os.Mkdir(filepath.Join(dir, "mypath1"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-900000.mp4")
+
+	err = os.WriteFile(fpath, []byte("abc"), 0o644)
+	require.NoError(t, err)
+
+	sha256, err := (&recordstore.Segment{Fpath: fpath}).Hash()
+	require.NoError(t, err)
+
+	err = recordstore.UpdateSegmentMetadata(fpath, func(md *recordstore.SegmentMetadata) {
+		md.Signature = recordstore.SignSegment(key, "mypath1",
+			time.Date(2008, 11, 7, 11, 22, 0, 900000000, time.Local), sha256, "")
+	})
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath1", "2009-11-07_11-22-00-900000.mp4"), []byte("abc"), 0o644)
+	require.NoError(t, err)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/verify/mypath1", nil, &out)
+	require.Equal(t, map[string]interface{}{
+		"valid": false,
+		"segments": []interface{}{
+			map[string]interface{}{
+				"start":  time.Date(2008, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+				"sha256": sha256,
+				"status": "valid",
+			},
+			map[string]interface{}{
+				"start":  time.Date(2009, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano),
+				"sha256": sha256,
+				"status": "unsigned",
+			},
+		},
+	}, out)
+
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/verify/mypath1?"+
+		"start="+url.QueryEscape(time.Date(2008, 11, 0o7, 11, 22, 0, 0, time.Local).Format(time.RFC3339))+
+		"&end="+url.QueryEscape(time.Date(2008, 11, 0o7, 11, 23, 0, 0, time.Local).Format(time.RFC3339)), nil, &out)
+	require.Equal(t, true, out.(map[string]interface{})["valid"])
+}
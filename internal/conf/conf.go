@@ -215,6 +215,13 @@ type Conf struct {
 	PlaybackAllowOrigin    string     `json:"playbackAllowOrigin"`
 	PlaybackTrustedProxies IPNetworks `json:"playbackTrustedProxies"`
 
+	// Record cleaner
+	RecordMaxTotalSize StringSize `json:"recordMaxTotalSize"`
+	RecordMinFreeSpace StringSize `json:"recordMinFreeSpace"`
+
+	// Record index
+	RecordIndexPath string `json:"recordIndexPath"`
+
 	// RTSP server
 	RTSP              bool             `json:"rtsp"`
 	RTSPDisable       *bool            `json:"rtspDisable,omitempty"` // deprecated
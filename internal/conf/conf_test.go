@@ -52,11 +52,20 @@ func TestConfFromFile(t *testing.T) {
 			Source:                     "publisher",
 			SourceOnDemandStartTimeout: 10 * StringDuration(time.Second),
 			SourceOnDemandCloseAfter:   10 * StringDuration(time.Second),
+			Tracks:                     TrackFilter{},
+			TimestampMaxJump:           StringDuration(1 * time.Second),
 			RecordPath:                 "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f",
 			RecordFormat:               RecordFormatFMP4,
 			RecordPartDuration:         StringDuration(1 * time.Second),
 			RecordSegmentDuration:      3600000000000,
+			RecordPreRoll:              StringDuration(10 * time.Second),
+			RecordPostRoll:             StringDuration(10 * time.Second),
 			RecordDeleteAfter:          86400000000000,
+			RecordS3Region:             "us-east-1",
+			RecordS3Key:                "%path/%Y-%m-%d_%H-%M-%S-%f",
+			RecordSchedule:             RecordSchedule{},
+			RecordScheduleExceptions:   RecordScheduleExceptions{},
+			ReplayPath:                 "./replays/%path/%Y-%m-%d_%H-%M-%S-%f",
 			OverridePublisher:          true,
 			RPICameraWidth:             1920,
 			RPICameraHeight:            1080,
@@ -367,6 +376,70 @@ func TestConfErrors(t *testing.T) {
 			`record path './recordings/%path/%Y-%m-%d_%H-%M-%S' is missing one of the` +
 				` mandatory elements for the playback server to work: %Y %m %d %H %M %S %f`,
 		},
+		{
+			"record segment align",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordSegmentDuration: 7m\n" +
+				"    recordSegmentAlign: yes\n",
+			"'recordSegmentDuration' must be a divisor of 24h when 'recordSegmentAlign' is enabled",
+		},
+		{
+			"record thin after",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordDeleteAfter: 24h\n" +
+				"    recordThinAfter: 48h\n",
+			"'recordThinAfter' must be less than 'recordDeleteAfter'",
+		},
+		{
+			"replay buffer duration negative",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    replayBufferDuration: -1s\n",
+			"'replayBufferDuration' must not be negative",
+		},
+		{
+			"replay buffer duration too long",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    replayBufferDuration: 2h\n",
+			"'replayBufferDuration' must be less than or equal to 1h0m0s",
+		},
+		{
+			"record thin format",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordFormat: mpegts\n" +
+				"    recordThinAfter: 1h\n",
+			"'recordThinAfter' is supported by the fmp4 format only",
+		},
+		{
+			"record schedule time",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordSchedule:\n" +
+				"      - start: \"08:00\"\n" +
+				"        end: \"25:00\"\n",
+			"invalid 'recordSchedule': invalid time: '25:00'",
+		},
+		{
+			"record schedule day",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordSchedule:\n" +
+				"      - days: [mon, holiday]\n" +
+				"        start: \"08:00\"\n" +
+				"        end: \"18:00\"\n",
+			"invalid 'recordSchedule': invalid day: 'holiday'",
+		},
+		{
+			"record schedule time zone",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordScheduleTimeZone: Mars/Olympus\n",
+			"invalid 'recordScheduleTimeZone': unknown time zone Mars/Olympus",
+		},
 		{
 			"jwt claim key empty",
 			"authMethod: jwt\n" +
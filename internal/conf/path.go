@@ -13,6 +13,11 @@ import (
 	"github.com/bluenviron/gortsplib/v4/pkg/base"
 )
 
+const (
+	// the replay buffer is kept in memory, therefore its duration is limited.
+	replayBufferMaxDuration = 1 * time.Hour
+)
+
 var rePathName = regexp.MustCompile(`^[0-9a-zA-Z_\-/\.~]+$`)
 
 func isValidPathName(name string) error {
@@ -95,15 +100,42 @@ type Path struct {
 	MaxReaders                 int            `json:"maxReaders"`
 	SRTReadPassphrase          string         `json:"srtReadPassphrase"`
 	Fallback                   string         `json:"fallback"`
+	Tracks                     TrackFilter    `json:"tracks"`
+	NormalizeTimestamps        bool           `json:"normalizeTimestamps"`
+	TimestampMaxJump           StringDuration `json:"timestampMaxJump"`
+	SmoothTimestamps           bool           `json:"smoothTimestamps"`
 
 	// Record
-	Record                bool           `json:"record"`
-	Playback              *bool          `json:"playback,omitempty"` // deprecated
-	RecordPath            string         `json:"recordPath"`
-	RecordFormat          RecordFormat   `json:"recordFormat"`
-	RecordPartDuration    StringDuration `json:"recordPartDuration"`
-	RecordSegmentDuration StringDuration `json:"recordSegmentDuration"`
-	RecordDeleteAfter     StringDuration `json:"recordDeleteAfter"`
+	Record                   bool                     `json:"record"`
+	Playback                 *bool                    `json:"playback,omitempty"` // deprecated
+	RecordPath               string                   `json:"recordPath"`
+	RecordFormat             RecordFormat             `json:"recordFormat"`
+	RecordPartDuration       StringDuration           `json:"recordPartDuration"`
+	RecordSegmentDuration    StringDuration           `json:"recordSegmentDuration"`
+	RecordSegmentAlign       bool                     `json:"recordSegmentAlign"`
+	RecordMode               RecordMode               `json:"recordMode"`
+	RecordPreRoll            StringDuration           `json:"recordPreRoll"`
+	RecordPostRoll           StringDuration           `json:"recordPostRoll"`
+	RecordDeleteAfter        StringDuration           `json:"recordDeleteAfter"`
+	RecordThinAfter          StringDuration           `json:"recordThinAfter"`
+	RecordMaxSize            StringSize               `json:"recordMaxSize"`
+	RecordS3Bucket           string                   `json:"recordS3Bucket"`
+	RecordS3Endpoint         string                   `json:"recordS3Endpoint"`
+	RecordS3Region           string                   `json:"recordS3Region"`
+	RecordS3AccessKeyID      string                   `json:"recordS3AccessKeyID"`
+	RecordS3SecretAccessKey  string                   `json:"recordS3SecretAccessKey"`
+	RecordS3PathStyle        bool                     `json:"recordS3PathStyle"`
+	RecordS3Key              string                   `json:"recordS3Key"`
+	RecordSignKey            string                   `json:"recordSignKey"`
+	RecordVerifyKey          string                   `json:"recordVerifyKey"`
+	RecordEncryptionKey      string                   `json:"recordEncryptionKey"`
+	RecordSchedule           RecordSchedule           `json:"recordSchedule"`
+	RecordScheduleTimeZone   string                   `json:"recordScheduleTimeZone"`
+	RecordScheduleExceptions RecordScheduleExceptions `json:"recordScheduleExceptions"`
+
+	// Replay
+	ReplayBufferDuration StringDuration `json:"replayBufferDuration"`
+	ReplayPath           string         `json:"replayPath"`
 
 	// Authentication (deprecated)
 	PublishUser *Credential `json:"publishUser,omitempty"` // deprecated
@@ -182,6 +214,7 @@ type Path struct {
 	RunOnUnread                string         `json:"runOnUnread"`
 	RunOnRecordSegmentCreate   string         `json:"runOnRecordSegmentCreate"`
 	RunOnRecordSegmentComplete string         `json:"runOnRecordSegmentComplete"`
+	RunOnRecordSegmentPurge    string         `json:"runOnRecordSegmentPurge"`
 }
 
 func (pconf *Path) setDefaults() {
@@ -189,13 +222,25 @@ func (pconf *Path) setDefaults() {
 	pconf.Source = "publisher"
 	pconf.SourceOnDemandStartTimeout = 10 * StringDuration(time.Second)
 	pconf.SourceOnDemandCloseAfter = 10 * StringDuration(time.Second)
+	pconf.Tracks = TrackFilter{}
+	pconf.TimestampMaxJump = StringDuration(1 * time.Second)
 
 	// Record
 	pconf.RecordPath = "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f"
 	pconf.RecordFormat = RecordFormatFMP4
 	pconf.RecordPartDuration = StringDuration(1 * time.Second)
 	pconf.RecordSegmentDuration = 3600 * StringDuration(time.Second)
+	pconf.RecordMode = RecordModeAlways
+	pconf.RecordPreRoll = 10 * StringDuration(time.Second)
+	pconf.RecordPostRoll = 10 * StringDuration(time.Second)
 	pconf.RecordDeleteAfter = 24 * 3600 * StringDuration(time.Second)
+	pconf.RecordS3Region = "us-east-1"
+	pconf.RecordS3Key = "%path/%Y-%m-%d_%H-%M-%S-%f"
+	pconf.RecordSchedule = RecordSchedule{}
+	pconf.RecordScheduleExceptions = RecordScheduleExceptions{}
+
+	// Replay
+	pconf.ReplayPath = "./replays/%path/%Y-%m-%d_%H-%M-%S-%f"
 
 	// Publisher source
 	pconf.OverridePublisher = true
@@ -349,6 +394,28 @@ func (pconf *Path) validate(
 			return fmt.Errorf("'%s' is not a valid URL", pconf.Source)
 		}
 
+	case strings.HasPrefix(pconf.Source, "path://") ||
+		strings.HasPrefix(pconf.Source, "switcher://"):
+		_, inputs, _ := strings.Cut(pconf.Source, "://")
+
+		for _, part := range strings.Split(inputs, "|") {
+			name, _, _ := strings.Cut(part, "?")
+
+			// names can contain regular expression groups
+			if strings.Contains(name, "$G") {
+				continue
+			}
+
+			err := isValidPathName(name)
+			if err != nil {
+				return fmt.Errorf("'%s' is not a valid path source: %w", pconf.Source, err)
+			}
+
+			if name == pconf.Name {
+				return fmt.Errorf("a path cannot use itself as source")
+			}
+		}
+
 	case pconf.Source == "redirect":
 
 	case pconf.Source == "rpiCamera":
@@ -380,9 +447,62 @@ func (pconf *Path) validate(
 			}
 		}
 	}
+	if pconf.NormalizeTimestamps && pconf.TimestampMaxJump <= 0 {
+		return fmt.Errorf("'timestampMaxJump' must be greater than zero")
+	}
+	if pconf.SmoothTimestamps && !pconf.NormalizeTimestamps {
+		return fmt.Errorf("'smoothTimestamps' requires 'normalizeTimestamps'")
+	}
 
 	// Record
 
+	if pconf.RecordSegmentAlign &&
+		(pconf.RecordSegmentDuration <= 0 || (24*time.Hour)%time.Duration(pconf.RecordSegmentDuration) != 0) {
+		return fmt.Errorf("'recordSegmentDuration' must be a divisor of 24h when 'recordSegmentAlign' is enabled")
+	}
+
+	if pconf.RecordMode == RecordModeEvent && pconf.RecordPostRoll <= 0 {
+		return fmt.Errorf("'recordPostRoll' must be greater than zero")
+	}
+
+	err := pconf.validateRecordSchedule()
+	if err != nil {
+		return err
+	}
+
+	if pconf.RecordThinAfter != 0 {
+		if pconf.RecordFormat != RecordFormatFMP4 {
+			return fmt.Errorf("'recordThinAfter' is supported by the fmp4 format only")
+		}
+		if pconf.RecordDeleteAfter != 0 && pconf.RecordThinAfter >= pconf.RecordDeleteAfter {
+			return fmt.Errorf("'recordThinAfter' must be less than 'recordDeleteAfter'")
+		}
+		if pconf.RecordSignKey != "" {
+			return fmt.Errorf("'recordThinAfter' can't be used together with 'recordSignKey'," +
+				" since thinned segments would not match their signatures")
+		}
+	}
+
+	if pconf.RecordS3Bucket != "" {
+		if !strings.Contains(pconf.RecordS3Key, "%Y") ||
+			!strings.Contains(pconf.RecordS3Key, "%m") ||
+			!strings.Contains(pconf.RecordS3Key, "%d") ||
+			!strings.Contains(pconf.RecordS3Key, "%H") ||
+			!strings.Contains(pconf.RecordS3Key, "%M") ||
+			!strings.Contains(pconf.RecordS3Key, "%S") ||
+			!strings.Contains(pconf.RecordS3Key, "%f") {
+			return fmt.Errorf("record S3 key '%s' is missing one of the mandatory elements:"+
+				" %%Y %%m %%d %%H %%M %%S %%f",
+				pconf.RecordS3Key)
+		}
+		if strings.HasPrefix(pconf.RecordS3Key, "/") {
+			return fmt.Errorf("record S3 key must not start with a slash")
+		}
+		if pconf.RecordS3Region == "" {
+			return fmt.Errorf("'recordS3Region' must be set")
+		}
+	}
+
 	if conf.Playback {
 		if !strings.Contains(pconf.RecordPath, "%Y") ||
 			!strings.Contains(pconf.RecordPath, "%m") ||
@@ -397,6 +517,15 @@ func (pconf *Path) validate(
 		}
 	}
 
+	// Instant replay
+
+	if pconf.ReplayBufferDuration < 0 {
+		return fmt.Errorf("'replayBufferDuration' must not be negative")
+	}
+	if pconf.ReplayBufferDuration > StringDuration(replayBufferMaxDuration) {
+		return fmt.Errorf("'replayBufferDuration' must be less than or equal to %v", replayBufferMaxDuration)
+	}
+
 	// Authentication (deprecated)
 
 	if deprecatedCredentialsMode {
@@ -12,6 +12,8 @@ type RecordFormat int
 const (
 	RecordFormatFMP4 RecordFormat = iota
 	RecordFormatMPEGTS
+	RecordFormatMatroska
+	RecordFormatWebM
 )
 
 // MarshalJSON implements json.Marshaler.
@@ -22,6 +24,12 @@ func (d RecordFormat) MarshalJSON() ([]byte, error) {
 	case RecordFormatMPEGTS:
 		out = "mpegts"
 
+	case RecordFormatMatroska:
+		out = "matroska"
+
+	case RecordFormatWebM:
+		out = "webm"
+
 	default:
 		out = "fmp4"
 	}
@@ -43,6 +51,12 @@ func (d *RecordFormat) UnmarshalJSON(b []byte) error {
 	case "fmp4":
 		*d = RecordFormatFMP4
 
+	case "matroska":
+		*d = RecordFormatMatroska
+
+	case "webm":
+		*d = RecordFormatWebM
+
 	default:
 		return fmt.Errorf("invalid record format '%s'", in)
 	}
@@ -0,0 +1,56 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RecordMode is the recordMode parameter.
+type RecordMode int
+
+// supported values.
+const (
+	RecordModeAlways RecordMode = iota
+	RecordModeEvent
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d RecordMode) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case RecordModeEvent:
+		out = "event"
+
+	default:
+		out = "always"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RecordMode) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "event":
+		*d = RecordModeEvent
+
+	case "always":
+		*d = RecordModeAlways
+
+	default:
+		return fmt.Errorf("invalid record mode '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *RecordMode) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
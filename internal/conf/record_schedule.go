@@ -0,0 +1,265 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var recordScheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseRecordScheduleDay(v string) (time.Weekday, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if len(v) >= 3 {
+		if day, ok := recordScheduleDays[v[:3]]; ok &&
+			strings.HasPrefix(strings.ToLower(day.String()), v) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day: '%s'", v)
+}
+
+// parseRecordScheduleTime parses a time of day in the HH:MM format.
+func parseRecordScheduleTime(v string) (int, int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time: '%s'", v)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// RecordScheduleWindow is a weekly time window in which the path is recorded.
+type RecordScheduleWindow struct {
+	// days of the week in which the window is applied. Empty means every day.
+	Days []string `json:"days"`
+
+	// start and end of the window, in HH:MM format.
+	// When end is not after start, the window ends on the next day.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (w RecordScheduleWindow) validate() error {
+	for _, day := range w.Days {
+		_, err := parseRecordScheduleDay(day)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, _, err := parseRecordScheduleTime(w.Start)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = parseRecordScheduleTime(w.End)
+	return err
+}
+
+func (w RecordScheduleWindow) appliesTo(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, v := range w.Days {
+		if d, _ := parseRecordScheduleDay(v); d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordSchedule is a list of RecordScheduleWindow.
+type RecordSchedule []RecordScheduleWindow
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *RecordSchedule) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	return json.Unmarshal(b, (*[]RecordScheduleWindow)(s))
+}
+
+// RecordScheduleException is a day in which weekly windows are not applied.
+type RecordScheduleException struct {
+	// day, in YYYY-MM-DD format.
+	Date string `json:"date"`
+
+	// time window in which the path is recorded during the day, in HH:MM format.
+	// When empty, the path is not recorded during the day.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (e RecordScheduleException) validate() error {
+	_, err := time.Parse(time.DateOnly, e.Date)
+	if err != nil {
+		return fmt.Errorf("invalid date: '%s'", e.Date)
+	}
+
+	if e.Start == "" && e.End == "" {
+		return nil
+	}
+
+	_, _, err = parseRecordScheduleTime(e.Start)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = parseRecordScheduleTime(e.End)
+	return err
+}
+
+// RecordScheduleExceptions is a list of RecordScheduleException.
+type RecordScheduleExceptions []RecordScheduleException
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *RecordScheduleExceptions) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	return json.Unmarshal(b, (*[]RecordScheduleException)(s))
+}
+
+type recordScheduleInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+func newRecordScheduleInterval(day time.Time, start string, end string) recordScheduleInterval {
+	startHour, startMinute, _ := parseRecordScheduleTime(start)
+	endHour, endMinute, _ := parseRecordScheduleTime(end)
+
+	iv := recordScheduleInterval{
+		start: time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, day.Location()),
+		end:   time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, day.Location()),
+	}
+
+	if !iv.end.After(iv.start) {
+		iv.end = time.Date(day.Year(), day.Month(), day.Day()+1, endHour, endMinute, 0, 0, day.Location())
+	}
+
+	return iv
+}
+
+// HasRecordSchedule checks whether the path is recorded according to a schedule.
+func (pconf Path) HasRecordSchedule() bool {
+	return len(pconf.RecordSchedule) != 0 || len(pconf.RecordScheduleExceptions) != 0
+}
+
+func (pconf Path) recordScheduleLocation() *time.Location {
+	if pconf.RecordScheduleTimeZone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(pconf.RecordScheduleTimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// recordScheduleDay returns intervals that start in given day.
+func (pconf Path) recordScheduleDay(day time.Time) []recordScheduleInterval {
+	date := day.Format(time.DateOnly)
+
+	for _, e := range pconf.RecordScheduleExceptions {
+		if e.Date == date {
+			if e.Start == "" {
+				return nil
+			}
+			return []recordScheduleInterval{newRecordScheduleInterval(day, e.Start, e.End)}
+		}
+	}
+
+	var ret []recordScheduleInterval
+
+	for _, w := range pconf.RecordSchedule {
+		if w.appliesTo(day.Weekday()) {
+			ret = append(ret, newRecordScheduleInterval(day, w.Start, w.End))
+		}
+	}
+
+	return ret
+}
+
+// RecordScheduleState returns whether the record schedule is active at given time,
+// and the time of the next transition, that is zero when there are no transitions in the next year.
+func (pconf Path) RecordScheduleState(t time.Time) (bool, time.Time) {
+	tl := t.In(pconf.recordScheduleLocation())
+
+	var intervals []recordScheduleInterval
+
+	// windows of the previous day may end in the current day
+	for i := -1; i <= 366; i++ {
+		day := time.Date(tl.Year(), tl.Month(), tl.Day()+i, 0, 0, 0, 0, tl.Location())
+		intervals = append(intervals, pconf.recordScheduleDay(day)...)
+	}
+
+	horizon := time.Date(tl.Year(), tl.Month(), tl.Day()+366, 0, 0, 0, 0, tl.Location())
+
+	sort.SliceStable(intervals, func(i, j int) bool {
+		return intervals[i].start.Before(intervals[j].start)
+	})
+
+	// merge overlapping and adjacent intervals
+	var merged []recordScheduleInterval
+	for _, iv := range intervals {
+		if len(merged) != 0 && !iv.start.After(merged[len(merged)-1].end) {
+			if iv.end.After(merged[len(merged)-1].end) {
+				merged[len(merged)-1].end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+
+	for _, iv := range merged {
+		if t.Before(iv.start) {
+			return false, iv.start
+		}
+		if t.Before(iv.end) {
+			if iv.end.After(horizon) {
+				return true, time.Time{}
+			}
+			return true, iv.end
+		}
+	}
+
+	return false, time.Time{}
+}
+
+func (pconf Path) validateRecordSchedule() error {
+	if pconf.RecordScheduleTimeZone != "" {
+		_, err := time.LoadLocation(pconf.RecordScheduleTimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid 'recordScheduleTimeZone': %w", err)
+		}
+	}
+
+	for _, w := range pconf.RecordSchedule {
+		err := w.validate()
+		if err != nil {
+			return fmt.Errorf("invalid 'recordSchedule': %w", err)
+		}
+	}
+
+	for _, e := range pconf.RecordScheduleExceptions {
+		err := e.validate()
+		if err != nil {
+			return fmt.Errorf("invalid 'recordScheduleExceptions': %w", err)
+		}
+	}
+
+	return nil
+}
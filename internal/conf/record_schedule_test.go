@@ -0,0 +1,95 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordScheduleState(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+
+	pconf := Path{
+		RecordSchedule: RecordSchedule{
+			{
+				Days:  []string{"mon", "tue", "wed", "thu", "friday"},
+				Start: "08:00",
+				End:   "18:00",
+			},
+			{
+				Days:  []string{"sat"},
+				Start: "22:00",
+				End:   "02:00",
+			},
+		},
+		RecordScheduleTimeZone: "Europe/Rome",
+		RecordScheduleExceptions: RecordScheduleExceptions{
+			{
+				Date: "2024-12-25",
+			},
+			{
+				Date:  "2024-12-24",
+				Start: "08:00",
+				End:   "12:00",
+			},
+		},
+	}
+
+	for _, ca := range []struct {
+		name   string
+		t      time.Time
+		active bool
+		next   time.Time
+	}{
+		{
+			"inside window",
+			time.Date(2024, 12, 2, 10, 0, 0, 0, loc), // monday
+			true,
+			time.Date(2024, 12, 2, 18, 0, 0, 0, loc),
+		},
+		{
+			"end of window",
+			time.Date(2024, 12, 2, 18, 0, 0, 0, loc),
+			false,
+			time.Date(2024, 12, 3, 8, 0, 0, 0, loc),
+		},
+		{
+			"different time zone",
+			time.Date(2024, 12, 2, 6, 30, 0, 0, time.UTC),
+			false,
+			time.Date(2024, 12, 2, 8, 0, 0, 0, loc),
+		},
+		{
+			"weekend",
+			time.Date(2024, 12, 6, 19, 0, 0, 0, loc), // friday
+			false,
+			time.Date(2024, 12, 7, 22, 0, 0, 0, loc),
+		},
+		{
+			"window that crosses midnight",
+			time.Date(2024, 12, 8, 1, 0, 0, 0, loc), // sunday
+			true,
+			time.Date(2024, 12, 8, 2, 0, 0, 0, loc),
+		},
+		{
+			"exception with window",
+			time.Date(2024, 12, 24, 11, 0, 0, 0, loc), // tuesday
+			true,
+			time.Date(2024, 12, 24, 12, 0, 0, 0, loc),
+		},
+		{
+			"exception without window",
+			time.Date(2024, 12, 24, 13, 0, 0, 0, loc),
+			false,
+			time.Date(2024, 12, 26, 8, 0, 0, 0, loc),
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			active, next := pconf.RecordScheduleState(ca.t)
+			require.Equal(t, ca.active, active)
+			require.True(t, ca.next.Equal(next), next.String())
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+)
+
+func trackSelectorMatches(sel string, i int, medi *description.Media) bool {
+	if n, err := strconv.ParseUint(sel, 10, 31); err == nil {
+		return int(n) == (i + 1)
+	}
+
+	if strings.EqualFold(sel, string(medi.Type)) {
+		return true
+	}
+
+	for _, forma := range medi.Formats {
+		if strings.EqualFold(sel, forma.Codec()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkTrackSelector(sel string) error {
+	sel = strings.TrimPrefix(sel, "-")
+
+	if sel == "" {
+		return fmt.Errorf("empty track selector")
+	}
+
+	if n, err := strconv.ParseUint(sel, 10, 31); err == nil && n == 0 {
+		return fmt.Errorf("track indexes start from 1")
+	}
+
+	return nil
+}
+
+// TrackFilter is a list of track selectors.
+// A selector is a media type (video, audio, application), a codec name or a track index (starting from 1).
+// Selectors that begin with a minus exclude tracks.
+type TrackFilter []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *TrackFilter) UnmarshalJSON(b []byte) error {
+	var in []string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	*f = TrackFilter{}
+
+	for _, sel := range in {
+		sel = strings.TrimSpace(sel)
+
+		err := checkTrackSelector(sel)
+		if err != nil {
+			return err
+		}
+
+		*f = append(*f, sel)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (f *TrackFilter) UnmarshalEnv(_ string, v string) error {
+	var in []string
+	if v != "" {
+		in = strings.Split(v, ",")
+	}
+
+	byts, _ := json.Marshal(in)
+	return f.UnmarshalJSON(byts)
+}
+
+// Filter returns a description that contains only the tracks selected by the filter.
+// Medias are not copied, therefore they can be used to read from a stream that uses the original description.
+func (f TrackFilter) Filter(desc *description.Session) *description.Session {
+	if len(f) == 0 {
+		return desc
+	}
+
+	hasIncludes := false
+	for _, sel := range f {
+		if !strings.HasPrefix(sel, "-") {
+			hasIncludes = true
+			break
+		}
+	}
+
+	out := *desc
+	out.Medias = nil
+
+outer:
+	for i, medi := range desc.Medias {
+		if hasIncludes {
+			included := false
+			for _, sel := range f {
+				if !strings.HasPrefix(sel, "-") && trackSelectorMatches(sel, i, medi) {
+					included = true
+					break
+				}
+			}
+			if !included {
+				continue
+			}
+		}
+
+		for _, sel := range f {
+			if strings.HasPrefix(sel, "-") && trackSelectorMatches(sel[1:], i, medi) {
+				continue outer
+			}
+		}
+
+		out.Medias = append(out.Medias, medi)
+	}
+
+	return &out
+}
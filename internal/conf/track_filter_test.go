@@ -0,0 +1,83 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackFilter(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{PayloadTyp: 96, PacketizationMode: 1}},
+		},
+		{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{&format.Opus{PayloadTyp: 97, ChannelCount: 2}},
+		},
+		{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{&format.G711{PayloadTyp: 8, MULaw: false, SampleRate: 8000, ChannelCount: 1}},
+		},
+	}}
+
+	for _, ca := range []struct {
+		name   string
+		in     string
+		medias []*description.Media
+	}{
+		{
+			"empty",
+			`[]`,
+			desc.Medias,
+		},
+		{
+			"type",
+			`["video"]`,
+			[]*description.Media{desc.Medias[0]},
+		},
+		{
+			"codec",
+			`["opus"]`,
+			[]*description.Media{desc.Medias[1]},
+		},
+		{
+			"index",
+			`["1", "3"]`,
+			[]*description.Media{desc.Medias[0], desc.Medias[2]},
+		},
+		{
+			"exclude",
+			`["-audio"]`,
+			[]*description.Media{desc.Medias[0]},
+		},
+		{
+			"include and exclude",
+			`["audio", "-G711"]`,
+			[]*description.Media{desc.Medias[1]},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var f TrackFilter
+			err := f.UnmarshalJSON([]byte(ca.in))
+			require.NoError(t, err)
+			require.Equal(t, ca.medias, f.Filter(desc).Medias)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var f TrackFilter
+		err := f.UnmarshalJSON([]byte(`["0"]`))
+		require.EqualError(t, err, "track indexes start from 1")
+	})
+
+	t.Run("env", func(t *testing.T) {
+		var f TrackFilter
+		err := f.UnmarshalEnv("", "video,-2")
+		require.NoError(t, err)
+		require.Equal(t, TrackFilter{"video", "-2"}, f)
+	})
+}
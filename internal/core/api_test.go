@@ -16,7 +16,9 @@ import (
 	"time"
 
 	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
 	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
 	srt "github.com/datarhei/gosrt"
 	"github.com/google/uuid"
@@ -342,6 +344,111 @@ func TestAPIPathsGet(t *testing.T) {
 	}
 }
 
+func TestAPIPathsSwitch(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  cam1:\n" +
+		"  cam2:\n" +
+		"  switched:\n" +
+		"    source: switcher://cam1|cam2\n" +
+		"    sourceOnDemand: yes\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for i, name := range []string{"cam1", "cam2"} {
+		medi := test.UniqueMediaH264()
+
+		source := gortsplib.Client{}
+		err := source.StartRecording("rtsp://localhost:8554/"+name,
+			&description.Session{Medias: []*description.Media{medi}})
+		require.NoError(t, err)
+		defer source.Close()
+
+		go func(source *gortsplib.Client, medi *description.Media, payload []byte) {
+			i := uint16(0)
+			for {
+				err2 := source.WritePacketRTP(medi, &rtp.Packet{
+					Header: rtp.Header{
+						Version:        2,
+						Marker:         true,
+						PayloadType:    96,
+						SequenceNumber: 123 + i,
+						Timestamp:      45343 + uint32(i)*3000,
+						SSRC:           563423,
+					},
+					Payload: payload,
+				})
+				if err2 != nil {
+					return
+				}
+				i++
+				time.Sleep(50 * time.Millisecond)
+			}
+		}(&source, medi, []byte{5, byte(i + 1), byte(i + 1), byte(i + 1)})
+	}
+
+	u, err := base.ParseURL("rtsp://localhost:8554/switched")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{}
+	err = reader.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	desc, _, err := reader.Describe(u)
+	require.NoError(t, err)
+
+	err = reader.SetupAll(desc.BaseURL, desc.Medias)
+	require.NoError(t, err)
+
+	received := make(chan []byte, 64)
+
+	reader.OnPacketRTPAny(func(_ *description.Media, _ format.Format, pkt *rtp.Packet) {
+		select {
+		case received <- pkt.Payload:
+		default:
+		}
+	})
+
+	_, err = reader.Play(nil)
+	require.NoError(t, err)
+
+	waitPayload := func(payload []byte) {
+		for {
+			select {
+			case byts := <-received:
+				if bytes.Contains(byts, payload) {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("payload not received")
+				return
+			}
+		}
+	}
+
+	waitPayload([]byte{5, 1, 1, 1})
+
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/paths/switch/switched",
+		map[string]interface{}{"input": "cam2"}, nil)
+
+	waitPayload([]byte{5, 2, 2, 2})
+
+	byts, err := json.Marshal(map[string]interface{}{"input": "cam3"})
+	require.NoError(t, err)
+
+	res, err := hc.Post("http://localhost:9997/v3/paths/switch/switched", "application/json", bytes.NewReader(byts))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	checkError(t, "'cam3' is not an input of the switcher", res.Body)
+}
+
 func TestAPIProtocolListGet(t *testing.T) {
 	serverCertFpath, err := test.CreateTempFile(test.TLSCertPub)
 	require.NoError(t, err)
@@ -620,6 +727,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"query":         "key=val",
 							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
 							"state":         "publish",
+							"latency":       nil,
 							"transport":     "UDP",
 						},
 					},
@@ -654,6 +762,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"query":         "key=val",
 							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
 							"state":         "publish",
+							"latency":       nil,
 							"transport":     "TCP",
 						},
 					},
@@ -673,6 +782,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"query":         "key=val",
 							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
 							"state":         "publish",
+							"latency":       nil,
 						},
 					},
 				}, out1)
@@ -691,6 +801,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"query":         "key=val",
 							"remoteAddr":    out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
 							"state":         "publish",
+							"latency":       nil,
 						},
 					},
 				}, out1)
@@ -704,6 +815,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"bytesSent":   out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["bytesSent"],
 							"created":     out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
 							"lastRequest": out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["lastRequest"],
+							"latency":     out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["latency"],
 							"path":        "mypath",
 						},
 					},
@@ -726,6 +838,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"remoteAddr":                out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteAddr"],
 							"remoteCandidate":           out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["remoteCandidate"],
 							"state":                     "read",
+							"latency":                   out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["latency"],
 						},
 					},
 				}, out1)
@@ -754,6 +867,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 							"bytesSentUnique":               float64(0),
 							"created":                       out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["created"],
 							"id":                            out1.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["id"],
+							"latency":                       nil,
 							"mbpsLinkCapacity":              float64(0),
 							"mbpsMaxBW":                     float64(-1),
 							"mbpsReceiveRate":               float64(0),
@@ -26,6 +26,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/playback"
 	"github.com/bluenviron/mediamtx/internal/pprof"
 	"github.com/bluenviron/mediamtx/internal/recordcleaner"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/rlimit"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/servers/rtmp"
@@ -47,8 +48,10 @@ var defaultConfPaths = []string{
 }
 
 var cli struct {
-	Version  bool   `help:"print version"`
-	Confpath string `arg:"" default:""`
+	Version            bool   `help:"print version"`
+	RebuildRecordIndex bool   `help:"rebuild the recording index with segments stored on disk, then exit"`
+	VerifyRecordings   string `help:"verify signatures of recordings of a path, then exit" placeholder:"PATH"`
+	Confpath           string `arg:"" default:""`
 }
 
 // Core is an instance of MediaMTX.
@@ -62,6 +65,7 @@ type Core struct {
 	authManager     *auth.Manager
 	metrics         *metrics.Metrics
 	pprof           *pprof.PPROF
+	recordIndex     *recordstore.Index
 	recordCleaner   *recordcleaner.Cleaner
 	playbackServer  *playback.Server
 	pathManager     *pathManager
@@ -83,6 +87,8 @@ type Core struct {
 }
 
 // New allocates a Core.
+// When the command line requests a single operation, the operation is performed
+// and a nil Core is returned, together with the outcome of the operation.
 func New(args []string) (*Core, bool) {
 	parser, err := kong.New(&cli,
 		kong.Description("MediaMTX "+string(version)),
@@ -105,7 +111,7 @@ func New(args []string) (*Core, bool) {
 
 	if cli.Version {
 		fmt.Println(string(version))
-		os.Exit(0)
+		return nil, true
 	}
 
 	ctx, ctxCancel := context.WithCancel(context.Background())
@@ -123,6 +129,24 @@ func New(args []string) (*Core, bool) {
 		return nil, false
 	}
 
+	if cli.RebuildRecordIndex {
+		err = rebuildRecordIndex(p.conf)
+		if err != nil {
+			fmt.Printf("ERR: %s\n", err)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if cli.VerifyRecordings != "" {
+		valid, err2 := verifyRecordings(p.conf, cli.VerifyRecordings)
+		if err2 != nil {
+			fmt.Printf("ERR: %s\n", err2)
+			return nil, false
+		}
+		return nil, valid
+	}
+
 	err = p.createResources(true)
 	if err != nil {
 		if p.logger != nil {
@@ -139,6 +163,111 @@ func New(args []string) (*Core, bool) {
 	return p, true
 }
 
+func rebuildRecordIndex(c *conf.Conf) error {
+	if c.RecordIndexPath == "" {
+		return fmt.Errorf("'recordIndexPath' is not set")
+	}
+
+	i := &recordstore.Index{
+		Path: c.RecordIndexPath,
+	}
+	err := i.Initialize()
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	n, err := i.Rebuild(c.Paths)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("recording index rebuilt, %d segments indexed\n", n)
+	return nil
+}
+
+func verifyRecordings(c *conf.Conf, pathName string) (bool, error) {
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		return false, err
+	}
+
+	var index *recordstore.Index
+	if c.RecordIndexPath != "" {
+		index = &recordstore.Index{
+			Path: c.RecordIndexPath,
+		}
+		err = index.Initialize()
+		if err != nil {
+			return false, err
+		}
+		defer index.Close()
+	}
+
+	results, err := recordstore.VerifyRecordings(pathConf, pathName, time.Time{}, time.Time{}, index)
+	if err != nil {
+		return false, err
+	}
+
+	valid := true
+
+	for _, res := range results {
+		fmt.Printf("%s %s %s\n", res.Segment.Start.Format(time.RFC3339Nano), res.Status, res.Segment.Fpath)
+
+		if res.Status != recordstore.SegmentVerifyStatusValid {
+			valid = false
+		}
+	}
+
+	if valid {
+		fmt.Printf("%d segments verified, chain is valid\n", len(results))
+	} else {
+		fmt.Printf("%d segments verified, chain is NOT valid\n", len(results))
+	}
+
+	return valid, nil
+}
+
+// repairRecordings repairs segments that have been truncated by a crash.
+// Only the newest segment of each path is checked, since it is the one
+// that was being written when the crash happened.
+func (p *Core) repairRecordings() {
+	for _, pathName := range recordstore.FindAllPathsWithSegments(p.conf.Paths, p.recordIndex) {
+		pathConf, _, err := conf.FindPathConf(p.conf.Paths, pathName)
+		if err != nil || pathConf.RecordFormat != conf.RecordFormatFMP4 {
+			continue
+		}
+
+		segments, err := recordstore.FindSegments(pathConf, pathName, p.recordIndex)
+		if err != nil {
+			continue
+		}
+
+		seg := segments[len(segments)-1]
+
+		res, err := recordstore.RepairFMP4(seg)
+		if err != nil {
+			p.Log(logger.Warn, "unable to repair %s: %v", seg.Fpath, err)
+			continue
+		}
+
+		switch {
+		case res == nil:
+
+		case res.Removed:
+			p.Log(logger.Warn, "segment %s did not contain any complete part and has been removed", seg.Fpath)
+
+		case res.Quarantined:
+			p.Log(logger.Warn, "segment %s did not contain a complete initialization section and has been renamed into %s",
+				seg.Fpath, seg.Fpath+recordstore.RepairQuarantineSuffix)
+
+		default:
+			p.Log(logger.Warn, "segment %s was truncated and has been repaired (%d parts kept, %d bytes removed)",
+				seg.Fpath, res.PartCount, res.OriginalSize-res.Size)
+		}
+	}
+}
+
 // Close closes Core and waits for all goroutines to return.
 func (p *Core) Close() {
 	p.ctxCancel()
@@ -303,10 +432,42 @@ func (p *Core) createResources(initial bool) error {
 		p.pprof = i
 	}
 
+	if p.conf.RecordIndexPath != "" &&
+		p.recordIndex == nil {
+		i := &recordstore.Index{
+			Path: p.conf.RecordIndexPath,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+
+		// the index is built from existing segments when it is used for the first time
+		if i.Missing() {
+			var n int
+			n, err = i.Rebuild(p.conf.Paths)
+			if err != nil {
+				i.Close()
+				return err
+			}
+			p.Log(logger.Info, "record index created, %d segments indexed", n)
+		}
+
+		p.recordIndex = i
+	}
+
+	if initial {
+		p.repairRecordings()
+	}
+
 	if p.recordCleaner == nil {
 		p.recordCleaner = &recordcleaner.Cleaner{
-			PathConfs: p.conf.Paths,
-			Parent:    p,
+			PathConfs:       p.conf.Paths,
+			MaxTotalSize:    uint64(p.conf.RecordMaxTotalSize),
+			MinFreeSpace:    uint64(p.conf.RecordMinFreeSpace),
+			ExternalCmdPool: p.externalCmdPool,
+			Index:           p.recordIndex,
+			Parent:          p,
 		}
 		p.recordCleaner.Initialize()
 	}
@@ -322,6 +483,7 @@ func (p *Core) createResources(initial bool) error {
 			TrustedProxies: p.conf.PlaybackTrustedProxies,
 			ReadTimeout:    p.conf.ReadTimeout,
 			PathConfs:      p.conf.Paths,
+			RecordIndex:    p.recordIndex,
 			AuthManager:    p.authManager,
 			Parent:         p,
 		}
@@ -343,6 +505,7 @@ func (p *Core) createResources(initial bool) error {
 			udpMaxPayloadSize: p.conf.UDPMaxPayloadSize,
 			pathConfs:         p.conf.Paths,
 			externalCmdPool:   p.externalCmdPool,
+			recordIndex:       p.recordIndex,
 			parent:            p,
 		}
 		p.pathManager.initialize()
@@ -608,6 +771,7 @@ func (p *Core) createResources(initial bool) error {
 			HLSServer:      p.hlsServer,
 			WebRTCServer:   p.webRTCServer,
 			SRTServer:      p.srtServer,
+			RecordIndex:    p.recordIndex,
 			Parent:         p,
 		}
 		err = i.Initialize()
@@ -669,7 +833,13 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeAuthManager ||
 		closeLogger
 
+	closeRecordIndex := newConf == nil ||
+		newConf.RecordIndexPath != p.conf.RecordIndexPath
+
 	closeRecorderCleaner := newConf == nil ||
+		newConf.RecordMaxTotalSize != p.conf.RecordMaxTotalSize ||
+		newConf.RecordMinFreeSpace != p.conf.RecordMinFreeSpace ||
+		closeRecordIndex ||
 		closeLogger
 	if !closeRecorderCleaner && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
 		p.recordCleaner.ReloadPathConfs(newConf.Paths)
@@ -684,6 +854,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.PlaybackAllowOrigin != p.conf.PlaybackAllowOrigin ||
 		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.conf.PlaybackTrustedProxies) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
+		closeRecordIndex ||
 		closeAuthManager ||
 		closeLogger
 	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
@@ -698,6 +869,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.WriteQueueSize != p.conf.WriteQueueSize ||
 		newConf.UDPMaxPayloadSize != p.conf.UDPMaxPayloadSize ||
+		closeRecordIndex ||
 		closeMetrics ||
 		closeAuthManager ||
 		closeLogger
@@ -949,6 +1121,11 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.recordCleaner = nil
 	}
 
+	if closeRecordIndex && p.recordIndex != nil {
+		p.recordIndex.Close()
+		p.recordIndex = nil
+	}
+
 	if closePPROF && p.pprof != nil {
 		p.pprof.Close()
 		p.pprof = nil
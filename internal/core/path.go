@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"net"
 	"strconv"
@@ -18,6 +19,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recorder"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -29,6 +31,7 @@ func emptyTimer() *time.Timer {
 
 type pathParent interface {
 	logger.Writer
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
 	pathReady(*path)
 	pathNotReady(*path)
 	closePath(*path)
@@ -63,6 +66,46 @@ type pathAPIPathsGetReq struct {
 	res  chan pathAPIPathsGetRes
 }
 
+type pathAPIPathsSwitchReq struct {
+	input string
+	res   chan error
+}
+
+type pathAPIRecordingsAddMarkerReq struct {
+	marker *recordstore.SegmentMarker
+	res    chan error
+}
+
+type pathAPIRecordingsTriggerReq struct {
+	labels []string
+	res    chan error
+}
+
+type pathAPIRecordingsStartReq struct {
+	req defs.APIRecordingStartReq
+	res chan error
+}
+
+type pathAPIRecordingsStopReq struct {
+	res chan error
+}
+
+type pathAPIReplaySnapshotRes struct {
+	snapshot *recorder.ReplaySnapshot
+	err      error
+}
+
+type pathAPIReplaySnapshotReq struct {
+	res chan pathAPIReplaySnapshotRes
+}
+
+// pathAPIRecording is a recording started through the API.
+type pathAPIRecording struct {
+	until  time.Time
+	format conf.RecordFormat
+	label  string
+}
+
 type path struct {
 	parentCtx         context.Context
 	logLevel          conf.LogLevel
@@ -76,6 +119,7 @@ type path struct {
 	matches           []string
 	wg                *sync.WaitGroup
 	externalCmdPool   *externalcmd.Pool
+	recordIndex       *recordstore.Index
 	parent            pathParent
 
 	ctx                            context.Context
@@ -85,6 +129,8 @@ type path struct {
 	publisherQuery                 string
 	stream                         *stream.Stream
 	recorder                       *recorder.Recorder
+	recorderStart                  time.Time
+	replayBuffer                   *recorder.ReplayBuffer
 	readyTime                      time.Time
 	onUnDemandHook                 func(string)
 	onNotReadyHook                 func()
@@ -97,6 +143,11 @@ type path struct {
 	onDemandPublisherState         pathOnDemandState
 	onDemandPublisherReadyTimer    *time.Timer
 	onDemandPublisherCloseTimer    *time.Timer
+	recordScheduleActive           bool
+	recordScheduleNext             time.Time
+	recordScheduleTimer            *time.Timer
+	apiRecording                   *pathAPIRecording
+	apiRecordingTimer              *time.Timer
 
 	// in
 	chReloadConf              chan *conf.Path
@@ -110,6 +161,12 @@ type path struct {
 	chAddReader               chan defs.PathAddReaderReq
 	chRemoveReader            chan defs.PathRemoveReaderReq
 	chAPIPathsGet             chan pathAPIPathsGetReq
+	chAPIPathsSwitch          chan pathAPIPathsSwitchReq
+	chAPIRecordingsTrigger    chan pathAPIRecordingsTriggerReq
+	chAPIRecordingsAddMarker  chan pathAPIRecordingsAddMarkerReq
+	chAPIRecordingsStart      chan pathAPIRecordingsStartReq
+	chAPIRecordingsStop       chan pathAPIRecordingsStopReq
+	chAPIReplaySnapshot       chan pathAPIReplaySnapshotReq
 
 	// out
 	done chan struct{}
@@ -125,6 +182,8 @@ func (pa *path) initialize() {
 	pa.onDemandStaticSourceCloseTimer = emptyTimer()
 	pa.onDemandPublisherReadyTimer = emptyTimer()
 	pa.onDemandPublisherCloseTimer = emptyTimer()
+	pa.recordScheduleTimer = emptyTimer()
+	pa.apiRecordingTimer = emptyTimer()
 	pa.chReloadConf = make(chan *conf.Path)
 	pa.chStaticSourceSetReady = make(chan defs.PathSourceStaticSetReadyReq)
 	pa.chStaticSourceSetNotReady = make(chan defs.PathSourceStaticSetNotReadyReq)
@@ -136,6 +195,12 @@ func (pa *path) initialize() {
 	pa.chAddReader = make(chan defs.PathAddReaderReq)
 	pa.chRemoveReader = make(chan defs.PathRemoveReaderReq)
 	pa.chAPIPathsGet = make(chan pathAPIPathsGetReq)
+	pa.chAPIPathsSwitch = make(chan pathAPIPathsSwitchReq)
+	pa.chAPIRecordingsTrigger = make(chan pathAPIRecordingsTriggerReq)
+	pa.chAPIRecordingsAddMarker = make(chan pathAPIRecordingsAddMarkerReq)
+	pa.chAPIRecordingsStart = make(chan pathAPIRecordingsStartReq)
+	pa.chAPIRecordingsStop = make(chan pathAPIRecordingsStopReq)
+	pa.chAPIReplaySnapshot = make(chan pathAPIReplaySnapshotReq)
 	pa.done = make(chan struct{})
 
 	pa.Log(logger.Debug, "created")
@@ -175,6 +240,7 @@ func (pa *path) run() {
 			writeTimeout:   pa.writeTimeout,
 			writeQueueSize: pa.writeQueueSize,
 			matches:        pa.matches,
+			pathManager:    pa.parent,
 			parent:         pa,
 		}
 		pa.source.(*staticSourceHandler).initialize()
@@ -191,6 +257,8 @@ func (pa *path) run() {
 		ExternalCmdEnv:  pa.ExternalCmdEnv(),
 	})
 
+	pa.updateRecordSchedule()
+
 	err := pa.runInner()
 
 	// call before destroying context
@@ -202,6 +270,8 @@ func (pa *path) run() {
 	pa.onDemandStaticSourceCloseTimer.Stop()
 	pa.onDemandPublisherReadyTimer.Stop()
 	pa.onDemandPublisherCloseTimer.Stop()
+	pa.recordScheduleTimer.Stop()
+	pa.apiRecordingTimer.Stop()
 
 	onUnInitHook()
 
@@ -261,6 +331,12 @@ func (pa *path) runInner() error {
 		case <-pa.onDemandPublisherCloseTimer.C:
 			pa.doOnDemandPublisherCloseTimer()
 
+		case <-pa.recordScheduleTimer.C:
+			pa.doRecordScheduleTimer()
+
+		case <-pa.apiRecordingTimer.C:
+			pa.doAPIRecordingTimer()
+
 		case newConf := <-pa.chReloadConf:
 			pa.doReloadConf(newConf)
 
@@ -314,6 +390,24 @@ func (pa *path) runInner() error {
 		case req := <-pa.chAPIPathsGet:
 			pa.doAPIPathsGet(req)
 
+		case req := <-pa.chAPIPathsSwitch:
+			pa.doAPIPathsSwitch(req)
+
+		case req := <-pa.chAPIRecordingsTrigger:
+			pa.doAPIRecordingsTrigger(req)
+
+		case req := <-pa.chAPIRecordingsStart:
+			pa.doAPIRecordingsStart(req)
+
+		case req := <-pa.chAPIRecordingsStop:
+			pa.doAPIRecordingsStop(req)
+
+		case req := <-pa.chAPIReplaySnapshot:
+			pa.doAPIReplaySnapshot(req)
+
+		case req := <-pa.chAPIRecordingsAddMarker:
+			pa.doAPIRecordingsAddMarker(req)
+
 		case <-pa.ctx.Done():
 			return fmt.Errorf("terminated")
 		}
@@ -357,6 +451,21 @@ func (pa *path) doOnDemandPublisherCloseTimer() {
 	pa.onDemandPublisherStop("not needed by anyone")
 }
 
+func (pa *path) doRecordScheduleTimer() {
+	pa.updateRecordSchedule()
+
+	if pa.shouldRecord() {
+		if pa.stream != nil && pa.recorder == nil && !pa.checkContainLiveStream(pa.name) {
+			pa.Log(logger.Info, "record schedule is active, starting recording")
+			pa.startRecording()
+		}
+	} else if pa.recorder != nil && pa.apiRecording == nil {
+		pa.Log(logger.Info, "record schedule is not active, stopping recording")
+		pa.recorder.Close()
+		pa.recorder = nil
+	}
+}
+
 func (pa *path) doReloadConf(newConf *conf.Path) {
 	pa.confMutex.Lock()
 	pa.conf = newConf
@@ -366,7 +475,9 @@ func (pa *path) doReloadConf(newConf *conf.Path) {
 		pa.source.(*staticSourceHandler).reloadConf(newConf)
 	}
 
-	if pa.conf.Record {
+	pa.updateRecordSchedule()
+
+	if pa.shouldRecord() {
 
 		pathName := pa.name
 
@@ -380,10 +491,24 @@ func (pa *path) doReloadConf(newConf *conf.Path) {
 		if pa.stream != nil && pa.recorder == nil && !checkNotRecord {
 			pa.startRecording()
 		}
-	} else if pa.recorder != nil {
+	} else if pa.recorder != nil && pa.apiRecording == nil {
 		pa.recorder.Close()
 		pa.recorder = nil
 	}
+
+	if pa.replayBuffer != nil && pa.replayBuffer.Duration != time.Duration(pa.conf.ReplayBufferDuration) {
+		pa.replayBuffer.Close()
+		pa.replayBuffer = nil
+	}
+
+	if pa.stream != nil && pa.replayBuffer == nil && pa.conf.ReplayBufferDuration > 0 {
+		pa.startReplayBuffer()
+	}
+}
+
+func (pa *path) doAPIRecordingTimer() {
+	pa.Log(logger.Info, "recording duration has elapsed, stopping recording")
+	pa.stopAPIRecording()
 }
 
 func (pa *path) doSourceStaticSetReady(req defs.PathSourceStaticSetReadyReq) {
@@ -577,6 +702,124 @@ func (pa *path) doRemoveReader(req defs.PathRemoveReaderReq) {
 	}
 }
 
+func (pa *path) doAPIPathsSwitch(req pathAPIPathsSwitchReq) {
+	source, ok := pa.source.(*staticSourceHandler)
+	if !ok || !source.isSwitcher() {
+		req.res <- fmt.Errorf("path '%s' is not a switcher", pa.name)
+		return
+	}
+
+	source.switchInput(req.input, req.res)
+}
+
+func (pa *path) doAPIRecordingsTrigger(req pathAPIRecordingsTriggerReq) {
+	if pa.conf.RecordMode != conf.RecordModeEvent {
+		req.res <- fmt.Errorf("path '%s' is not recorded in event mode", pa.name)
+		return
+	}
+
+	if pa.recorder == nil {
+		req.res <- fmt.Errorf("path '%s' is not being recorded", pa.name)
+		return
+	}
+
+	pa.recorder.Trigger(req.labels)
+	req.res <- nil
+}
+
+func (pa *path) doAPIRecordingsStart(req pathAPIRecordingsStartReq) {
+	if pa.stream == nil {
+		req.res <- fmt.Errorf("path '%s' is not ready", pa.name)
+		return
+	}
+
+	if pa.apiRecording != nil {
+		req.res <- fmt.Errorf("path '%s' is already being recorded through the API", pa.name)
+		return
+	}
+
+	if pa.recorder != nil {
+		req.res <- fmt.Errorf("path '%s' is already being recorded", pa.name)
+		return
+	}
+
+	var labels []string
+	if req.req.Label != "" {
+		labels = []string{req.req.Label}
+	}
+
+	pa.startRecorder(conf.RecordModeAlways, labels)
+	if pa.recorder == nil {
+		req.res <- fmt.Errorf("unable to start recording")
+		return
+	}
+
+	pa.apiRecording = &pathAPIRecording{
+		format: pa.conf.RecordFormat,
+		label:  req.req.Label,
+	}
+
+	if req.req.Duration > 0 {
+		pa.apiRecording.until = pa.recorderStart.Add(time.Duration(req.req.Duration))
+		pa.apiRecordingTimer = time.NewTimer(time.Duration(req.req.Duration))
+	}
+
+	pa.Log(logger.Info, "recording started through the API")
+
+	req.res <- nil
+}
+
+func (pa *path) doAPIRecordingsStop(req pathAPIRecordingsStopReq) {
+	if pa.apiRecording == nil {
+		req.res <- fmt.Errorf("path '%s' is not being recorded through the API", pa.name)
+		return
+	}
+
+	pa.Log(logger.Info, "recording stopped through the API")
+	pa.stopAPIRecording()
+
+	req.res <- nil
+}
+
+// stopAPIRecording stops a recording started through the API,
+// and restores the recording required by the configuration.
+func (pa *path) stopAPIRecording() {
+	pa.apiRecordingTimer.Stop()
+	pa.apiRecordingTimer = emptyTimer()
+	pa.apiRecording = nil
+
+	pa.recorder.Close()
+	pa.recorder = nil
+
+	if pa.shouldRecord() && !pa.checkContainLiveStream(pa.name) {
+		pa.startRecording()
+	}
+}
+
+func (pa *path) doAPIReplaySnapshot(req pathAPIReplaySnapshotReq) {
+	if pa.conf.ReplayBufferDuration <= 0 {
+		req.res <- pathAPIReplaySnapshotRes{err: fmt.Errorf("path '%s' has no replay buffer", pa.name)}
+		return
+	}
+
+	if pa.replayBuffer == nil {
+		req.res <- pathAPIReplaySnapshotRes{err: fmt.Errorf("path '%s' is not ready", pa.name)}
+		return
+	}
+
+	// the snapshot is taken inside the path goroutine, since the buffer may be closed at any time.
+	req.res <- pathAPIReplaySnapshotRes{snapshot: pa.replayBuffer.Snapshot()}
+}
+
+func (pa *path) doAPIRecordingsAddMarker(req pathAPIRecordingsAddMarkerReq) {
+	if pa.recorder == nil {
+		req.res <- fmt.Errorf("path '%s' is not being recorded", pa.name)
+		return
+	}
+
+	req.res <- pa.recorder.AddMarker(req.marker)
+}
+
 func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 	req.res <- pathAPIPathsGetRes{
 		data: &defs.APIPath{
@@ -622,6 +865,67 @@ func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 				}
 				return ret
 			}(),
+			TimestampNormalizer: func() *defs.APIPathTimestampNormalizer {
+				if pa.stream == nil {
+					return nil
+				}
+				stats := pa.stream.TimestampNormalizerStats()
+				if stats == nil {
+					return nil
+				}
+				return &defs.APIPathTimestampNormalizer{
+					Discontinuities: stats.Discontinuities,
+					LastDiscontinuityTime: func() *time.Time {
+						if stats.LastDiscontinuityTime.IsZero() {
+							return nil
+						}
+						return &stats.LastDiscontinuityTime
+					}(),
+					SmoothedUnits: stats.SmoothedUnits,
+				}
+			}(),
+			Latency: func() *defs.APILatency {
+				if pa.stream == nil {
+					return nil
+				}
+				return defs.NewAPILatency(pa.stream.Latency(), nil)
+			}(),
+			RecordSchedule: func() *defs.APIPathRecordSchedule {
+				if !pa.conf.HasRecordSchedule() {
+					return nil
+				}
+				v := &defs.APIPathRecordSchedule{
+					Active: pa.recordScheduleActive,
+				}
+				if !pa.recordScheduleNext.IsZero() {
+					next := pa.recordScheduleNext
+					v.NextTransition = &next
+				}
+				return v
+			}(),
+			Recording: func() *defs.APIPathRecording {
+				if pa.recorder == nil {
+					return nil
+				}
+				if pa.apiRecording == nil {
+					return &defs.APIPathRecording{
+						Source: defs.APIPathRecordingSourceConfig,
+						Start:  pa.recorderStart,
+						Format: pa.conf.RecordFormat,
+					}
+				}
+				v := &defs.APIPathRecording{
+					Source: defs.APIPathRecordingSourceAPI,
+					Start:  pa.recorderStart,
+					Format: pa.apiRecording.format,
+					Label:  pa.apiRecording.label,
+				}
+				if !pa.apiRecording.until.IsZero() {
+					until := pa.apiRecording.until
+					v.Until = &until
+				}
+				return v
+			}(),
 		},
 	}
 }
@@ -719,19 +1023,33 @@ func (pa *path) onDemandPublisherStop(reason string) {
 }
 
 func (pa *path) setReady(desc *description.Session, allocateEncoder bool) error {
+	desc = pa.conf.Tracks.Filter(desc)
+	if len(desc.Medias) == 0 {
+		return fmt.Errorf("no tracks match the 'tracks' filter")
+	}
+
+	decodeErrLogger := logger.NewLimitedLogger(pa.source)
+
 	var err error
 	pa.stream, err = stream.New(
 		pa.writeQueueSize,
 		pa.udpMaxPayloadSize,
 		desc,
 		allocateEncoder,
-		logger.NewLimitedLogger(pa.source),
+		decodeErrLogger,
 	)
 	if err != nil {
 		return err
 	}
 
-	if pa.conf.Record {
+	if pa.conf.NormalizeTimestamps {
+		pa.stream.EnableTimestampNormalizer(
+			time.Duration(pa.conf.TimestampMaxJump),
+			pa.conf.SmoothTimestamps,
+			decodeErrLogger)
+	}
+
+	if pa.shouldRecord() {
 
 		pathName := pa.name
 
@@ -749,6 +1067,10 @@ func (pa *path) setReady(desc *description.Session, allocateEncoder bool) error
 
 	}
 
+	if pa.conf.ReplayBufferDuration > 0 {
+		pa.startReplayBuffer()
+	}
+
 	pa.readyTime = time.Now()
 
 	pa.onNotReadyHook = hooks.OnReady(hooks.OnReadyParams{
@@ -794,18 +1116,89 @@ func (pa *path) setNotReady() {
 		pa.recorder = nil
 	}
 
+	// recordings started through the API end with the stream.
+	pa.apiRecordingTimer.Stop()
+	pa.apiRecordingTimer = emptyTimer()
+	pa.apiRecording = nil
+
+	if pa.replayBuffer != nil {
+		pa.replayBuffer.Close()
+		pa.replayBuffer = nil
+	}
+
 	if pa.stream != nil {
 		pa.stream.Close()
 		pa.stream = nil
 	}
 }
 
+// updateRecordSchedule computes the state of the record schedule
+// and schedules its next evaluation.
+func (pa *path) updateRecordSchedule() {
+	pa.recordScheduleTimer.Stop()
+	pa.recordScheduleTimer = emptyTimer()
+
+	if !pa.conf.HasRecordSchedule() {
+		pa.recordScheduleActive = false
+		pa.recordScheduleNext = time.Time{}
+		return
+	}
+
+	now := time.Now()
+	pa.recordScheduleActive, pa.recordScheduleNext = pa.conf.RecordScheduleState(now)
+
+	if !pa.recordScheduleNext.IsZero() {
+		pa.recordScheduleTimer = time.NewTimer(pa.recordScheduleNext.Sub(now))
+	}
+}
+
+// shouldRecord checks whether the path has to be recorded.
+func (pa *path) shouldRecord() bool {
+	return pa.conf.Record && (!pa.conf.HasRecordSchedule() || pa.recordScheduleActive)
+}
+
 func (pa *path) startRecording() {
+	pa.startRecorder(pa.conf.RecordMode, nil)
+}
+
+func (pa *path) startRecorder(mode conf.RecordMode, labels []string) {
+	var encryptionKey *recordstore.EncryptionKey
+	if pa.conf.RecordEncryptionKey != "" {
+		keys, err := recordstore.LoadEncryptionKeys(pa.conf.RecordEncryptionKey)
+		if err != nil {
+			// segments are not written in clear text when encryption is requested.
+			pa.Log(logger.Error, "unable to load recordEncryptionKey, recording is disabled: %v", err)
+			return
+		}
+		encryptionKey = keys.Current()
+	}
+
+	var signKey ed25519.PrivateKey
+	if pa.conf.RecordSignKey != "" {
+		var err error
+		signKey, err = recordstore.LoadSignKey(pa.conf.RecordSignKey)
+		if err != nil {
+			// segments are not written without signatures when signing is requested.
+			pa.Log(logger.Error, "unable to load recordSignKey, recording is disabled: %v", err)
+			return
+		}
+	}
+
 	pa.recorder = &recorder.Recorder{
 		PathFormat:      pa.conf.RecordPath,
 		Format:          pa.conf.RecordFormat,
 		PartDuration:    time.Duration(pa.conf.RecordPartDuration),
 		SegmentDuration: time.Duration(pa.conf.RecordSegmentDuration),
+		SegmentAlign:    pa.conf.RecordSegmentAlign,
+		Mode:            mode,
+		PreRoll:         time.Duration(pa.conf.RecordPreRoll),
+		PostRoll:        time.Duration(pa.conf.RecordPostRoll),
+		S3:              recordstore.S3FromPathConf(pa.conf),
+		S3Key:           pa.conf.RecordS3Key,
+		SignKey:         signKey,
+		EncryptionKey:   encryptionKey,
+		Index:           pa.recordIndex,
+		Labels:          labels,
 		PathName:        pa.name,
 		Stream:          pa.stream,
 		OnSegmentCreate: func(segmentPath string) {
@@ -840,6 +1233,30 @@ func (pa *path) startRecording() {
 		Parent: pa,
 	}
 	pa.recorder.Initialize()
+	pa.recorderStart = time.Now()
+}
+
+func (pa *path) startReplayBuffer() {
+	var encryptionKey *recordstore.EncryptionKey
+	if pa.conf.RecordEncryptionKey != "" {
+		keys, err := recordstore.LoadEncryptionKeys(pa.conf.RecordEncryptionKey)
+		if err != nil {
+			// clips are not written in clear text when encryption is requested.
+			pa.Log(logger.Error, "unable to load recordEncryptionKey, replay buffer is disabled: %v", err)
+			return
+		}
+		encryptionKey = keys.Current()
+	}
+
+	pa.replayBuffer = &recorder.ReplayBuffer{
+		Duration:      time.Duration(pa.conf.ReplayBufferDuration),
+		PartDuration:  time.Duration(pa.conf.RecordPartDuration),
+		EncryptionKey: encryptionKey,
+		PathName:      pa.name,
+		Stream:        pa.stream,
+		Parent:        pa,
+	}
+	pa.replayBuffer.Initialize()
 }
 
 func (pa *path) executeRemoveReader(r defs.Reader) {
@@ -1021,6 +1438,101 @@ func (pa *path) APIPathsGet(req pathAPIPathsGetReq) (*defs.APIPath, error) {
 	}
 }
 
+// APIPathsSwitch is called by api.
+func (pa *path) APIPathsSwitch(input string) error {
+	req := pathAPIPathsSwitchReq{
+		input: input,
+		res:   make(chan error),
+	}
+
+	select {
+	case pa.chAPIPathsSwitch <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsTrigger is called by api.
+func (pa *path) APIRecordingsTrigger(labels []string) error {
+	req := pathAPIRecordingsTriggerReq{
+		labels: labels,
+		res:    make(chan error),
+	}
+
+	select {
+	case pa.chAPIRecordingsTrigger <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsStart is called by api.
+func (pa *path) APIRecordingsStart(r defs.APIRecordingStartReq) error {
+	req := pathAPIRecordingsStartReq{
+		req: r,
+		res: make(chan error),
+	}
+
+	select {
+	case pa.chAPIRecordingsStart <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsStop is called by api.
+func (pa *path) APIRecordingsStop() error {
+	req := pathAPIRecordingsStopReq{
+		res: make(chan error),
+	}
+
+	select {
+	case pa.chAPIRecordingsStop <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIReplaySnapshot is called by api.
+func (pa *path) APIReplaySnapshot() (*recorder.ReplaySnapshot, error) {
+	req := pathAPIReplaySnapshotReq{
+		res: make(chan pathAPIReplaySnapshotRes),
+	}
+
+	select {
+	case pa.chAPIReplaySnapshot <- req:
+		res := <-req.res
+		return res.snapshot, res.err
+
+	case <-pa.ctx.Done():
+		return nil, fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsAddMarker is called by api.
+func (pa *path) APIRecordingsAddMarker(marker *recordstore.SegmentMarker) error {
+	req := pathAPIRecordingsAddMarkerReq{
+		marker: marker,
+		res:    make(chan error),
+	}
+
+	select {
+	case pa.chAPIRecordingsAddMarker <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
 func (pa *path) checkContainLiveStream(pathName string) bool {
 	if strings.Contains(strings.ToLower(pathName), "playback") {
 		return true
@@ -11,6 +11,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -18,6 +19,12 @@ func pathConfCanBeUpdated(oldPathConf *conf.Path, newPathConf *conf.Path) bool {
 	clone := oldPathConf.Clone()
 
 	clone.Record = newPathConf.Record
+	clone.RecordSchedule = newPathConf.RecordSchedule
+	clone.RecordScheduleTimeZone = newPathConf.RecordScheduleTimeZone
+	clone.RecordScheduleExceptions = newPathConf.RecordScheduleExceptions
+	clone.RecordVerifyKey = newPathConf.RecordVerifyKey
+	clone.ReplayBufferDuration = newPathConf.ReplayBufferDuration
+	clone.ReplayPath = newPathConf.ReplayPath
 
 	clone.RPICameraBrightness = newPathConf.RPICameraBrightness
 	clone.RPICameraContrast = newPathConf.RPICameraContrast
@@ -58,6 +65,7 @@ type pathManager struct {
 	udpMaxPayloadSize int
 	pathConfs         map[string]*conf.Path
 	externalCmdPool   *externalcmd.Pool
+	recordIndex       *recordstore.Index
 	parent            pathManagerParent
 
 	ctx         context.Context
@@ -351,6 +359,7 @@ func (pm *pathManager) createPath(
 		matches:           matches,
 		wg:                &pm.wg,
 		externalCmdPool:   pm.externalCmdPool,
+		recordIndex:       pm.recordIndex,
 		parent:            pm,
 	}
 	pa.initialize()
@@ -537,3 +546,144 @@ func (pm *pathManager) APIPathsGet(name string) (*defs.APIPath, error) {
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIPathsSwitch is called by api.
+func (pm *pathManager) APIPathsSwitch(name string, input string) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIPathsSwitch(input)
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsAddMarker is called by api.
+func (pm *pathManager) APIRecordingsAddMarker(name string, marker *recordstore.SegmentMarker) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIRecordingsAddMarker(marker)
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsStart is called by api.
+func (pm *pathManager) APIRecordingsStart(name string, r defs.APIRecordingStartReq) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIRecordingsStart(r)
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsStop is called by api.
+func (pm *pathManager) APIRecordingsStop(name string) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIRecordingsStop()
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIReplaySave is called by api.
+func (pm *pathManager) APIReplaySave(name string, pathFormat string, encrypt bool) (*defs.APIReplayClip, error) {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return nil, res.err
+		}
+
+		snapshot, err := res.path.APIReplaySnapshot()
+		if err != nil {
+			return nil, err
+		}
+
+		// the clip is written outside the path goroutine,
+		// in order not to block the path.
+		clip, err := snapshot.Save(pathFormat, encrypt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &defs.APIReplayClip{
+			Path:     clip.Path,
+			Duration: clip.Duration.Seconds(),
+		}, nil
+
+	case <-pm.ctx.Done():
+		return nil, fmt.Errorf("terminated")
+	}
+}
+
+// APIRecordingsTrigger is called by api.
+func (pm *pathManager) APIRecordingsTrigger(name string, labels []string) error {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res.err
+		}
+
+		return res.path.APIRecordingsTrigger(labels)
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
@@ -2,8 +2,11 @@ package core
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -636,6 +639,294 @@ func TestPathRecord(t *testing.T) {
 	require.Equal(t, 2, len(files))
 }
 
+func TestPathRecordSchedule(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rtsp-path-record")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+
+	p, ok := newInstance("api: yes\n" +
+		"recordPath: " + filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f") + "\n" +
+		"paths:\n" +
+		"  all_others:\n" +
+		"    record: yes\n" +
+		"    recordScheduleTimeZone: UTC\n" +
+		"    recordScheduleExceptions:\n" +
+		"      - date: " + tomorrow + "\n" +
+		"        start: \"00:00\"\n" +
+		"        end: \"00:00\"\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	media0 := test.UniqueMediaH264()
+
+	source := gortsplib.Client{}
+
+	err = source.StartRecording(
+		"rtsp://localhost:8554/mystream",
+		&description.Session{Medias: []*description.Media{media0}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	writeUnits := func(start int) {
+		for i := start; i < start+4; i++ {
+			err = source.WritePacketRTP(media0, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: 1123 + uint16(i),
+					Timestamp:      45343 + 90000*uint32(i),
+					SSRC:           563423,
+				},
+				Payload: []byte{5},
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	writeUnits(0)
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.ReadDir(filepath.Join(dir, "mystream"))
+	require.Error(t, err)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out map[string]interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/mystream", nil, &out)
+
+	nextTransition, err := time.Parse(time.RFC3339, tomorrow+"T00:00:00Z")
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]interface{}{
+		"active":         false,
+		"nextTransition": nextTransition.Local().Format(time.RFC3339Nano),
+	}, out["recordSchedule"])
+
+	httpRequest(t, hc, http.MethodPatch, "http://localhost:9997/v3/config/paths/patch/all_others", map[string]interface{}{
+		"recordSchedule": []map[string]interface{}{{
+			"start": "00:00",
+			"end":   "00:00",
+		}},
+	}, nil)
+
+	time.Sleep(500 * time.Millisecond)
+
+	writeUnits(4)
+
+	time.Sleep(500 * time.Millisecond)
+
+	files, err := os.ReadDir(filepath.Join(dir, "mystream"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+}
+
+func TestPathRecordAPI(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rtsp-path-record")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p, ok := newInstance("api: yes\n" +
+		"recordPath: " + filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f") + "\n" +
+		"paths:\n" +
+		"  all_others:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	media0 := test.UniqueMediaH264()
+
+	source := gortsplib.Client{}
+
+	err = source.StartRecording(
+		"rtsp://localhost:8554/mystream",
+		&description.Session{Medias: []*description.Media{media0}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	writeUnits := func(start int) {
+		for i := start; i < start+4; i++ {
+			err = source.WritePacketRTP(media0, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: 1123 + uint16(i),
+					Timestamp:      45343 + 90000*uint32(i),
+					SSRC:           563423,
+				},
+				Payload: []byte{5},
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	// the record format can't be chosen, since segments are found by using recordFormat
+	byts, err := json.Marshal(map[string]interface{}{"format": "mpegts"})
+	require.NoError(t, err)
+
+	res, err := hc.Post("http://localhost:9997/v3/recordings/start/mystream", "application/json", bytes.NewReader(byts))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	checkError(t, "json: unknown field \"format\"", res.Body)
+
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/start/mystream", map[string]interface{}{
+		"label": "manual",
+	}, nil)
+
+	var out map[string]interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/mystream", nil, &out)
+
+	recording := out["recording"].(map[string]interface{})
+	require.Equal(t, "api", recording["source"])
+	require.Equal(t, "fmp4", recording["format"])
+	require.Equal(t, "manual", recording["label"])
+	require.Equal(t, nil, recording["until"])
+
+	writeUnits(0)
+
+	time.Sleep(500 * time.Millisecond)
+
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/stop/mystream", nil, nil)
+
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/mystream", nil, &out)
+	require.Equal(t, nil, out["recording"])
+
+	files, err := os.ReadDir(filepath.Join(dir, "mystream"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(files))
+	require.Equal(t, ".mp4", filepath.Ext(files[1].Name()))
+
+	buf, err := os.ReadFile(filepath.Join(dir, "mystream", files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(buf), `"manual"`)
+
+	// the recording can be listed and deleted
+	var list map[string]interface{}
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/recordings/get/mystream", nil, &list)
+
+	segments := list["segments"].([]interface{})
+	require.Equal(t, 1, len(segments))
+	require.Equal(t, []interface{}{"manual"}, segments[0].(map[string]interface{})["labels"])
+
+	v := url.Values{}
+	v.Set("path", "mystream")
+	v.Set("start", segments[0].(map[string]interface{})["start"].(string))
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:9997/v3/recordings/deletesegment?"+v.Encode(), nil)
+	require.NoError(t, err)
+
+	res2, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res2.Body.Close()
+	require.Equal(t, http.StatusOK, res2.StatusCode)
+
+	files, err = os.ReadDir(filepath.Join(dir, "mystream"))
+	require.NoError(t, err)
+	require.Equal(t, 0, len(files))
+
+	// the configuration is left untouched.
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/config/paths/get/all_others", nil, &out)
+	require.Equal(t, false, out["record"])
+
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/recordings/start/mystream", map[string]interface{}{
+		"duration": "500ms",
+	}, nil)
+
+	writeUnits(4)
+
+	time.Sleep(1 * time.Second)
+
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/mystream", nil, &out)
+	require.Equal(t, nil, out["recording"])
+
+	files, err = os.ReadDir(filepath.Join(dir, "mystream"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+}
+
+func TestPathReplayBuffer(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rtsp-path-replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  all_others:\n" +
+		"    replayBufferDuration: 10s\n" +
+		"    replayPath: " + filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f") + "\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	media0 := test.UniqueMediaH264()
+
+	source := gortsplib.Client{}
+
+	err = source.StartRecording(
+		"rtsp://localhost:8554/mystream",
+		&description.Session{Medias: []*description.Media{media0}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	for i := 0; i < 4; i++ {
+		err = source.WritePacketRTP(media0, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         true,
+				PayloadType:    96,
+				SequenceNumber: 1123 + uint16(i),
+				Timestamp:      45343 + 90000*uint32(i),
+				SSRC:           563423,
+			},
+			Payload: []byte{5},
+		})
+		require.NoError(t, err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out map[string]interface{}
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/replay/save/mystream", nil, &out)
+
+	require.Equal(t, float64(3), out["duration"])
+
+	clipPath := out["path"].(string)
+	require.Equal(t, filepath.Join(dir, "mystream"), filepath.Dir(clipPath))
+
+	saved, err := os.ReadFile(clipPath)
+	require.NoError(t, err)
+
+	res, err := hc.Get("http://localhost:9997/v3/replay/get/mystream")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "video/mp4", res.Header.Get("Content-Type"))
+
+	byts, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, saved, byts)
+
+	// the buffer is not written to disk by itself.
+	files, err := os.ReadDir(filepath.Join(dir, "mystream"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+}
+
 func TestPathFallback(t *testing.T) {
 	for _, ca := range []string{
 		"absolute",
@@ -691,6 +982,99 @@ func TestPathFallback(t *testing.T) {
 	}
 }
 
+func TestPathTracks(t *testing.T) {
+	for _, ca := range []string{
+		"path",
+		"reader",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			var conf string
+			var query string
+
+			switch ca {
+			case "path":
+				conf = "paths:\n" +
+					"  all_others:\n" +
+					"    tracks: [video]\n"
+
+			case "reader":
+				conf = "paths:\n" +
+					"  all_others:\n"
+				query = "?audio=false"
+			}
+
+			p1, ok := newInstance(conf)
+			require.Equal(t, true, ok)
+			defer p1.Close()
+
+			source := gortsplib.Client{}
+			err := source.StartRecording("rtsp://localhost:8554/mystream",
+				&description.Session{Medias: []*description.Media{
+					test.UniqueMediaH264(),
+					test.UniqueMediaMPEG4Audio(),
+				}})
+			require.NoError(t, err)
+			defer source.Close()
+
+			u, err := base.ParseURL("rtsp://localhost:8554/mystream" + query)
+			require.NoError(t, err)
+
+			dest := gortsplib.Client{}
+			err = dest.Start(u.Scheme, u.Host)
+			require.NoError(t, err)
+			defer dest.Close()
+
+			desc, _, err := dest.Describe(u)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(desc.Medias))
+			require.Equal(t, description.MediaTypeVideo, desc.Medias[0].Type)
+
+			err = dest.SetupAll(desc.BaseURL, desc.Medias)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPathSourcePath(t *testing.T) {
+	p1, ok := newInstance("paths:\n" +
+		"  cam1:\n" +
+		"  mic1:\n" +
+		"  merged:\n" +
+		"    source: path://cam1?tracks=video|mic1\n" +
+		"    sourceOnDemand: yes\n")
+	require.Equal(t, true, ok)
+	defer p1.Close()
+
+	source1 := gortsplib.Client{}
+	err := source1.StartRecording("rtsp://localhost:8554/cam1",
+		&description.Session{Medias: []*description.Media{
+			test.UniqueMediaH264(),
+			test.UniqueMediaMPEG4Audio(),
+		}})
+	require.NoError(t, err)
+	defer source1.Close()
+
+	source2 := gortsplib.Client{}
+	err = source2.StartRecording("rtsp://localhost:8554/mic1",
+		&description.Session{Medias: []*description.Media{test.UniqueMediaMPEG4Audio()}})
+	require.NoError(t, err)
+	defer source2.Close()
+
+	u, err := base.ParseURL("rtsp://localhost:8554/merged")
+	require.NoError(t, err)
+
+	dest := gortsplib.Client{}
+	err = dest.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer dest.Close()
+
+	desc, _, err := dest.Describe(u)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(desc.Medias))
+	require.Equal(t, description.MediaTypeVideo, desc.Medias[0].Type)
+	require.Equal(t, description.MediaTypeAudio, desc.Medias[1].Type)
+}
+
 func TestPathResolveSource(t *testing.T) {
 	var stream *gortsplib.ServerStream
 
@@ -11,12 +11,15 @@ import (
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	hlssource "github.com/bluenviron/mediamtx/internal/staticsources/hls"
+	pathsource "github.com/bluenviron/mediamtx/internal/staticsources/path"
 	rpicamerasource "github.com/bluenviron/mediamtx/internal/staticsources/rpicamera"
 	rtmpsource "github.com/bluenviron/mediamtx/internal/staticsources/rtmp"
 	rtspsource "github.com/bluenviron/mediamtx/internal/staticsources/rtsp"
 	srtsource "github.com/bluenviron/mediamtx/internal/staticsources/srt"
+	switchersource "github.com/bluenviron/mediamtx/internal/staticsources/switcher"
 	udpsource "github.com/bluenviron/mediamtx/internal/staticsources/udp"
 	webrtcsource "github.com/bluenviron/mediamtx/internal/staticsources/webrtc"
+	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
 const (
@@ -35,6 +38,19 @@ func resolveSource(s string, matches []string, query string) string {
 	return s
 }
 
+type staticSourceHandlerPathManager interface {
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
+type staticSourceSwitcher interface {
+	Switch(input string) error
+}
+
+type staticSourceHandlerSwitchInputReq struct {
+	input string
+	res   chan error
+}
+
 type staticSourceHandlerParent interface {
 	logger.Writer
 	staticSourceHandlerSetReady(context.Context, defs.PathSourceStaticSetReadyReq)
@@ -49,6 +65,7 @@ type staticSourceHandler struct {
 	writeTimeout   conf.StringDuration
 	writeQueueSize int
 	matches        []string
+	pathManager    staticSourceHandlerPathManager
 	parent         staticSourceHandlerParent
 
 	ctx       context.Context
@@ -61,6 +78,7 @@ type staticSourceHandler struct {
 	chReloadConf          chan *conf.Path
 	chInstanceSetReady    chan defs.PathSourceStaticSetReadyReq
 	chInstanceSetNotReady chan defs.PathSourceStaticSetNotReadyReq
+	chSwitchInput         chan staticSourceHandlerSwitchInputReq
 
 	// out
 	done chan struct{}
@@ -70,6 +88,7 @@ func (s *staticSourceHandler) initialize() {
 	s.chReloadConf = make(chan *conf.Path)
 	s.chInstanceSetReady = make(chan defs.PathSourceStaticSetReadyReq)
 	s.chInstanceSetNotReady = make(chan defs.PathSourceStaticSetNotReadyReq)
+	s.chSwitchInput = make(chan staticSourceHandlerSwitchInputReq)
 
 	switch {
 	case strings.HasPrefix(s.conf.Source, "rtsp://") ||
@@ -115,6 +134,18 @@ func (s *staticSourceHandler) initialize() {
 			Parent:      s,
 		}
 
+	case strings.HasPrefix(s.conf.Source, "path://"):
+		s.instance = &pathsource.Source{
+			PathManager: s.pathManager,
+			Parent:      s,
+		}
+
+	case strings.HasPrefix(s.conf.Source, "switcher://"):
+		s.instance = &switchersource.Source{
+			PathManager: s.pathManager,
+			Parent:      s,
+		}
+
 	case s.conf.Source == "rpiCamera":
 		s.instance = &rpicamerasource.Source{
 			LogLevel: s.logLevel,
@@ -166,6 +197,30 @@ func (s *staticSourceHandler) stop(reason string) {
 	<-s.done
 }
 
+func (s *staticSourceHandler) isSwitcher() bool {
+	_, ok := s.instance.(staticSourceSwitcher)
+	return ok
+}
+
+// switchInput is called by path.
+// The response is sent to res asynchronously.
+func (s *staticSourceHandler) switchInput(input string, res chan error) {
+	if !s.running {
+		res <- fmt.Errorf("source is not running")
+		return
+	}
+
+	ctx := s.ctx
+
+	go func() {
+		select {
+		case s.chSwitchInput <- staticSourceHandlerSwitchInputReq{input: input, res: res}:
+		case <-ctx.Done():
+			res <- fmt.Errorf("terminated")
+		}
+	}()
+}
+
 // Log implements logger.Writer.
 func (s *staticSourceHandler) Log(level logger.Level, format string, args ...interface{}) {
 	s.parent.Log(level, format, args...)
@@ -212,6 +267,19 @@ func (s *staticSourceHandler) run() {
 		case req := <-s.chInstanceSetNotReady:
 			s.parent.staticSourceHandlerSetNotReady(s.ctx, req)
 
+		case req := <-s.chSwitchInput:
+			if recreating {
+				req.res <- fmt.Errorf("source is not ready")
+				continue
+			}
+
+			// the switcher needs other paths in order to complete the request,
+			// therefore do not block the handler.
+			sw := s.instance.(staticSourceSwitcher)
+			go func() {
+				req.res <- sw.Switch(req.input)
+			}()
+
 		case newConf := <-s.chReloadConf:
 			s.conf = newConf
 			if !recreating {
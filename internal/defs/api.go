@@ -1,6 +1,7 @@
 package defs
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,15 +29,67 @@ type APIPathSourceOrReader struct {
 
 // APIPath is a path.
 type APIPath struct {
-	Name          string                  `json:"name"`
-	ConfName      string                  `json:"confName"`
-	Source        *APIPathSourceOrReader  `json:"source"`
-	Ready         bool                    `json:"ready"`
-	ReadyTime     *time.Time              `json:"readyTime"`
-	Tracks        []string                `json:"tracks"`
-	BytesReceived uint64                  `json:"bytesReceived"`
-	BytesSent     uint64                  `json:"bytesSent"`
-	Readers       []APIPathSourceOrReader `json:"readers"`
+	Name                string                      `json:"name"`
+	ConfName            string                      `json:"confName"`
+	Source              *APIPathSourceOrReader      `json:"source"`
+	Ready               bool                        `json:"ready"`
+	ReadyTime           *time.Time                  `json:"readyTime"`
+	Tracks              []string                    `json:"tracks"`
+	BytesReceived       uint64                      `json:"bytesReceived"`
+	BytesSent           uint64                      `json:"bytesSent"`
+	Readers             []APIPathSourceOrReader     `json:"readers"`
+	TimestampNormalizer *APIPathTimestampNormalizer `json:"timestampNormalizer"`
+	Latency             *APILatency                 `json:"latency"`
+	RecordSchedule      *APIPathRecordSchedule      `json:"recordSchedule"`
+	Recording           *APIPathRecording           `json:"recording"`
+}
+
+// APIPathRecordSchedule is the state of the record schedule of a path.
+type APIPathRecordSchedule struct {
+	Active         bool       `json:"active"`
+	NextTransition *time.Time `json:"nextTransition"`
+}
+
+// APIPathRecordingSource is the source of a recording.
+type APIPathRecordingSource string
+
+// sources.
+const (
+	APIPathRecordingSourceConfig APIPathRecordingSource = "config"
+	APIPathRecordingSourceAPI    APIPathRecordingSource = "api"
+)
+
+// APIPathRecording is the state of the recording of a path.
+type APIPathRecording struct {
+	Source APIPathRecordingSource `json:"source"`
+	Start  time.Time              `json:"start"`
+	Until  *time.Time             `json:"until"`
+	Format conf.RecordFormat      `json:"format"`
+	Label  string                 `json:"label"`
+}
+
+// APILatency is the delay between the NTP timestamp of units and the time
+// they are written to the path or to the transport of a reader, in seconds.
+// RoundTripTimeEstimated is true when the round trip time is not measured but estimated.
+type APILatency struct {
+	Last                   float64  `json:"last"`
+	Average                float64  `json:"average"`
+	Max                    float64  `json:"max"`
+	RoundTripTime          *float64 `json:"roundTripTime"`
+	RoundTripTimeEstimated bool     `json:"roundTripTimeEstimated"`
+	GlassToGlass           *float64 `json:"glassToGlass"`
+}
+
+// APIPathTimestampNormalizer contains corrections performed by the timestamp normalizer.
+type APIPathTimestampNormalizer struct {
+	Discontinuities       uint64     `json:"discontinuities"`
+	LastDiscontinuityTime *time.Time `json:"lastDiscontinuityTime"`
+	SmoothedUnits         uint64     `json:"smoothedUnits"`
+}
+
+// APIPathSwitchReq is a request to switch the input of a switcher path.
+type APIPathSwitchReq struct {
+	Input string `json:"input"`
 }
 
 // APIPathList is a list of paths.
@@ -48,10 +101,11 @@ type APIPathList struct {
 
 // APIHLSMuxer is an HLS muxer.
 type APIHLSMuxer struct {
-	Path        string    `json:"path"`
-	Created     time.Time `json:"created"`
-	LastRequest time.Time `json:"lastRequest"`
-	BytesSent   uint64    `json:"bytesSent"`
+	Path        string      `json:"path"`
+	Created     time.Time   `json:"created"`
+	LastRequest time.Time   `json:"lastRequest"`
+	BytesSent   uint64      `json:"bytesSent"`
+	Latency     *APILatency `json:"latency"`
 }
 
 // APIHLSMuxerList is a list of HLS muxers.
@@ -81,6 +135,7 @@ type APIRTMPConn struct {
 	Query         string           `json:"query"`
 	BytesReceived uint64           `json:"bytesReceived"`
 	BytesSent     uint64           `json:"bytesSent"`
+	Latency       *APILatency      `json:"latency"`
 }
 
 // APIRTMPConnList is a list of RTMP connections.
@@ -127,6 +182,7 @@ type APIRTSPSession struct {
 	Transport     *string             `json:"transport"`
 	BytesReceived uint64              `json:"bytesReceived"`
 	BytesSent     uint64              `json:"bytesSent"`
+	Latency       *APILatency         `json:"latency"`
 }
 
 // APIRTSPSessionList is a list of RTSP sessions.
@@ -275,6 +331,8 @@ type APISRTConn struct {
 	PacketsSendLossRate float64 `json:"packetsSendLossRate"`
 	// Percentage of retransmitted data vs. received data
 	PacketsReceivedLossRate float64 `json:"packetsReceivedLossRate"`
+
+	Latency *APILatency `json:"latency"`
 }
 
 // APISRTConnList is a list of SRT connections.
@@ -306,6 +364,7 @@ type APIWebRTCSession struct {
 	Query                     string                `json:"query"`
 	BytesReceived             uint64                `json:"bytesReceived"`
 	BytesSent                 uint64                `json:"bytesSent"`
+	Latency                   *APILatency           `json:"latency"`
 }
 
 // APIWebRTCSessionList is a list of WebRTC sessions.
@@ -315,9 +374,93 @@ type APIWebRTCSessionList struct {
 	Items     []*APIWebRTCSession `json:"items"`
 }
 
+// APIRecordingMarker is a named point in time of a recording.
+type APIRecordingMarker struct {
+	Time  time.Time       `json:"time"`
+	Label string          `json:"label"`
+	Data  json.RawMessage `json:"data"`
+}
+
 // APIRecordingSegment is a recording segment.
 type APIRecordingSegment struct {
-	Start time.Time `json:"start"`
+	Start   time.Time             `json:"start"`
+	Labels  []string              `json:"labels"`
+	Markers []*APIRecordingMarker `json:"markers"`
+}
+
+// APIRecordingTriggerReq is a request to trigger an event recording.
+type APIRecordingTriggerReq struct {
+	Labels []string `json:"labels"`
+}
+
+// APIRecordingStartReq is a request to start a recording.
+type APIRecordingStartReq struct {
+	// when zero, the recording lasts until it is stopped.
+	Duration conf.StringDuration `json:"duration"`
+	Label    string              `json:"label"`
+}
+
+// APIReplayClip is a clip saved from the replay buffer of a path.
+type APIReplayClip struct {
+	Path     string  `json:"path"`
+	Duration float64 `json:"duration"`
+}
+
+// APIRecordingAddMarkerReq is a request to add a marker to a recording.
+type APIRecordingAddMarkerReq struct {
+	// defaults to the current time.
+	Time  *time.Time      `json:"time"`
+	Label string          `json:"label"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// APIRecordingAddMarkerRes is the result of the addition of a marker.
+type APIRecordingAddMarkerRes struct {
+	// whether the marker has been embedded into the recording, in addition to metadata.
+	Embedded bool `json:"embedded"`
+}
+
+// APIRecordingRepairedSegment is a recording segment that has been repaired.
+type APIRecordingRepairedSegment struct {
+	Path         string    `json:"path"`
+	Start        time.Time `json:"start"`
+	OriginalSize int64     `json:"originalSize"`
+	Size         int64     `json:"size"`
+	PartCount    int       `json:"partCount"`
+	Removed      bool      `json:"removed"`
+	Quarantined  bool      `json:"quarantined"`
+}
+
+// APIRecordingRepair is the result of a repair of recording segments.
+type APIRecordingRepair struct {
+	ScannedCount int                            `json:"scannedCount"`
+	FailedCount  int                            `json:"failedCount"`
+	Segments     []*APIRecordingRepairedSegment `json:"segments"`
+}
+
+// APIRecordingVerifyStatus is the verification status of a recording segment.
+type APIRecordingVerifyStatus string
+
+// verification statuses.
+const (
+	APIRecordingVerifyStatusValid            APIRecordingVerifyStatus = "valid"
+	APIRecordingVerifyStatusUnsigned         APIRecordingVerifyStatus = "unsigned"
+	APIRecordingVerifyStatusModified         APIRecordingVerifyStatus = "modified"
+	APIRecordingVerifyStatusInvalidSignature APIRecordingVerifyStatus = "invalidSignature"
+	APIRecordingVerifyStatusBrokenLink       APIRecordingVerifyStatus = "brokenLink"
+)
+
+// APIRecordingVerifiedSegment is a recording segment that has been verified.
+type APIRecordingVerifiedSegment struct {
+	Start  time.Time                `json:"start"`
+	SHA256 string                   `json:"sha256"`
+	Status APIRecordingVerifyStatus `json:"status"`
+}
+
+// APIRecordingVerify is the result of a verification of recording signatures.
+type APIRecordingVerify struct {
+	Valid    bool                           `json:"valid"`
+	Segments []*APIRecordingVerifiedSegment `json:"segments"`
 }
 
 // APIRecording is a recording.
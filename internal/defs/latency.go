@@ -0,0 +1,31 @@
+package defs
+
+import (
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/latency"
+)
+
+// NewAPILatency converts latency statistics into their API representation.
+// rtt is the round trip time between the server and a reader, if available,
+// and is used to estimate the glass-to-glass latency.
+func NewAPILatency(l *latency.Latency, rtt *time.Duration) *APILatency {
+	if l == nil {
+		return nil
+	}
+
+	ret := &APILatency{
+		Last:    l.Last.Seconds(),
+		Average: l.Average.Seconds(),
+		Max:     l.Max.Seconds(),
+	}
+
+	if rtt != nil {
+		v1 := rtt.Seconds()
+		ret.RoundTripTime = &v1
+		v2 := (l.Average + *rtt/2).Seconds()
+		ret.GlassToGlass = &v2
+	}
+
+	return ret
+}
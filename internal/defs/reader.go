@@ -1,7 +1,67 @@
 package defs
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
 // Reader is an entity that can read a stream.
 type Reader interface {
 	Close()
 	APIReaderDescribe() APIPathSourceOrReader
 }
+
+// ReaderTrackFilter returns the track filter requested by a reader through the query.
+// Supported parameters are "tracks", that contains a comma-separated list of selectors,
+// and "video" or "audio", that can be set to false in order to remove a media type.
+func ReaderTrackFilter(rawQuery string) (conf.TrackFilter, error) {
+	// malformed parameters are ignored, since the query is shared with other features
+	q, _ := url.ParseQuery(rawQuery)
+
+	var in []string
+
+	if v := q.Get("tracks"); v != "" {
+		in = strings.Split(v, ",")
+	}
+
+	for _, typ := range []string{"video", "audio"} {
+		switch q.Get(typ) {
+		case "", "true", "1":
+
+		case "false", "0":
+			in = append(in, "-"+typ)
+
+		default:
+			return nil, fmt.Errorf("invalid value of parameter '%s': %s", typ, q.Get(typ))
+		}
+	}
+
+	var f conf.TrackFilter
+	err := f.UnmarshalEnv("", strings.Join(in, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ReaderDesc returns the part of a stream description that a reader requested through the query.
+func ReaderDesc(desc *description.Session, rawQuery string) (*description.Session, error) {
+	f, err := ReaderTrackFilter(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	desc = f.Filter(desc)
+
+	if len(desc.Medias) == 0 {
+		return nil, fmt.Errorf("no tracks match the requested filter")
+	}
+
+	return desc, nil
+}
@@ -0,0 +1,105 @@
+// Package latency contains utilities to measure latency.
+package latency
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// weight of the last sample in the average is 1/smoothingFactor.
+	smoothingFactor = 16
+
+	// Max covers samples of the last maxWindow at least, and of the last 2*maxWindow at most.
+	maxWindow = 1 * time.Minute
+)
+
+// Latency contains statistics about the delay between the NTP timestamp of units
+// and the time they are processed.
+// Max is the maximum of recent samples, in order not to be stuck on spikes that happened long ago.
+type Latency struct {
+	Last    time.Duration
+	Average time.Duration
+	Max     time.Duration
+}
+
+// Meter measures latency.
+type Meter struct {
+	timeNow func() time.Time
+
+	mutex    sync.Mutex
+	samples  uint64
+	stats    Latency
+	maxStart time.Time
+	curMax   time.Duration
+	prevMax  time.Duration
+}
+
+func (m *Meter) now() time.Time {
+	if m.timeNow != nil {
+		return m.timeNow()
+	}
+	return time.Now()
+}
+
+// rotateMax discards maximums that are older than the window.
+func (m *Meter) rotateMax(now time.Time) {
+	elapsed := now.Sub(m.maxStart)
+	if elapsed < maxWindow {
+		return
+	}
+
+	if elapsed < 2*maxWindow {
+		m.prevMax = m.curMax
+	} else {
+		m.prevMax = 0
+	}
+
+	m.curMax = 0
+	m.maxStart = now
+}
+
+// Update adds a sample, computed from the NTP timestamp of a unit.
+func (m *Meter) Update(ntp time.Time) {
+	if ntp.IsZero() {
+		return
+	}
+
+	now := m.now()
+	d := now.Sub(ntp)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.stats.Last = d
+
+	if m.samples == 0 {
+		m.stats.Average = d
+	} else {
+		m.stats.Average += (d - m.stats.Average) / smoothingFactor
+	}
+
+	m.rotateMax(now)
+
+	if d > m.curMax {
+		m.curMax = d
+	}
+
+	m.samples++
+}
+
+// Get returns the statistics, or nil if there are no samples.
+func (m *Meter) Get() *Latency {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.samples == 0 {
+		return nil
+	}
+
+	m.rotateMax(m.now())
+
+	v := m.stats
+	v.Max = max(m.curMax, m.prevMax)
+	return &v
+}
@@ -0,0 +1,49 @@
+package latency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyMeter(t *testing.T) {
+	var m Meter
+	require.Nil(t, m.Get())
+
+	m.Update(time.Time{})
+	require.Nil(t, m.Get())
+
+	m.Update(time.Now().Add(-2 * time.Second))
+	m.Update(time.Now().Add(-1 * time.Second))
+
+	l := m.Get()
+	require.NotNil(t, l)
+	require.InDelta(t, float64(1*time.Second), float64(l.Last), float64(100*time.Millisecond))
+	require.InDelta(t, float64(2*time.Second), float64(l.Max), float64(100*time.Millisecond))
+	require.Greater(t, l.Average, l.Last)
+	require.Less(t, l.Average, l.Max)
+}
+
+func TestLatencyMeterMaxWindow(t *testing.T) {
+	now := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+	m := Meter{timeNow: func() time.Time { return now }}
+
+	m.Update(now.Add(-5 * time.Second))
+	m.Update(now.Add(-1 * time.Second))
+	require.Equal(t, 5*time.Second, m.Get().Max)
+
+	// the spike is still reported within the window
+	now = now.Add(90 * time.Second)
+	m.Update(now.Add(-1 * time.Second))
+	require.Equal(t, 5*time.Second, m.Get().Max)
+
+	// the spike is discarded after the window
+	now = now.Add(90 * time.Second)
+	m.Update(now.Add(-1 * time.Second))
+	require.Equal(t, 1*time.Second, m.Get().Max)
+
+	// without samples, the maximum is zero
+	now = now.Add(5 * time.Minute)
+	require.Equal(t, time.Duration(0), m.Get().Max)
+}
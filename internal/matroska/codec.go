@@ -0,0 +1,415 @@
+package matroska
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+)
+
+// codec IDs.
+const (
+	CodecIDAV1        = "V_AV1"
+	CodecIDVP9        = "V_VP9"
+	CodecIDVP8        = "V_VP8"
+	CodecIDH265       = "V_MPEGH/ISO/HEVC"
+	CodecIDH264       = "V_MPEG4/ISO/AVC"
+	CodecIDMJPEG      = "V_MJPEG"
+	CodecIDOpus       = "A_OPUS"
+	CodecIDAAC        = "A_AAC"
+	CodecIDAC3        = "A_AC3"
+	CodecIDLPCMBig    = "A_PCM/INT/BIG"
+	CodecIDLPCMLittle = "A_PCM/INT/LIT"
+)
+
+// IDs of VP9 codec features.
+// Specification: https://www.webmproject.org/docs/container/#vp9-codec-feature-metadata-codecprivate
+const (
+	vp9FeatureProfile           = 1
+	vp9FeatureBitDepth          = 3
+	vp9FeatureChromaSubsampling = 4
+)
+
+func boolToUint8(v bool) uint8 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func marshalBox(box mp4.IImmutableBox) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := mp4.Marshal(&buf, box, mp4.Context{})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalBox(buf []byte, box mp4.IBox) error {
+	_, err := mp4.Unmarshal(bytes.NewReader(buf), uint64(len(buf)), box, mp4.Context{})
+	return err
+}
+
+// TrackFromFMP4Codec creates a track from a fMP4 codec.
+func TrackFromFMP4Codec(number int, codec fmp4.Codec) (*Track, error) {
+	switch codec := codec.(type) {
+	case *fmp4.CodecAV1:
+		var sh av1.SequenceHeader
+		err := sh.Unmarshal(codec.SequenceHeader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse AV1 sequence header: %w", err)
+		}
+
+		bs, err := av1.BitstreamMarshal([][]byte{codec.SequenceHeader})
+		if err != nil {
+			return nil, err
+		}
+
+		// CodecPrivate is the content of the av1C box
+		priv, err := marshalBox(&mp4.Av1C{
+			Marker:               1,
+			Version:              1,
+			SeqProfile:           sh.SeqProfile,
+			SeqLevelIdx0:         sh.SeqLevelIdx[0],
+			SeqTier0:             boolToUint8(sh.SeqTier[0]),
+			HighBitdepth:         boolToUint8(sh.ColorConfig.HighBitDepth),
+			TwelveBit:            boolToUint8(sh.ColorConfig.TwelveBit),
+			Monochrome:           boolToUint8(sh.ColorConfig.MonoChrome),
+			ChromaSubsamplingX:   boolToUint8(sh.ColorConfig.SubsamplingX),
+			ChromaSubsamplingY:   boolToUint8(sh.ColorConfig.SubsamplingY),
+			ChromaSamplePosition: uint8(sh.ColorConfig.ChromaSamplePosition),
+			ConfigOBUs:           bs,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &Track{
+			Number:       number,
+			CodecID:      CodecIDAV1,
+			CodecPrivate: priv,
+			Width:        sh.Width(),
+			Height:       sh.Height(),
+		}, nil
+
+	case *fmp4.CodecVP9:
+		return &Track{
+			Number:  number,
+			CodecID: CodecIDVP9,
+			CodecPrivate: []byte{
+				vp9FeatureProfile, 1, codec.Profile,
+				vp9FeatureBitDepth, 1, codec.BitDepth,
+				vp9FeatureChromaSubsampling, 1, codec.ChromaSubsampling,
+			},
+			Width:  codec.Width,
+			Height: codec.Height,
+		}, nil
+
+	case *fmp4.CodecH265:
+		var sps h265.SPS
+		err := sps.Unmarshal(codec.SPS)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse H265 SPS: %w", err)
+		}
+
+		// CodecPrivate is the content of the hvcC box
+		priv, err := marshalBox(&mp4.HvcC{
+			ConfigurationVersion:        1,
+			GeneralProfileIdc:           sps.ProfileTierLevel.GeneralProfileIdc,
+			GeneralProfileCompatibility: sps.ProfileTierLevel.GeneralProfileCompatibilityFlag,
+			GeneralConstraintIndicator: [6]uint8{
+				codec.SPS[7], codec.SPS[8], codec.SPS[9],
+				codec.SPS[10], codec.SPS[11], codec.SPS[12],
+			},
+			GeneralLevelIdc:      sps.ProfileTierLevel.GeneralLevelIdc,
+			ChromaFormatIdc:      uint8(sps.ChromaFormatIdc),
+			BitDepthLumaMinus8:   uint8(sps.BitDepthLumaMinus8),
+			BitDepthChromaMinus8: uint8(sps.BitDepthChromaMinus8),
+			NumTemporalLayers:    1,
+			LengthSizeMinusOne:   3,
+			NumOfNaluArrays:      3,
+			NaluArrays: []mp4.HEVCNaluArray{
+				{
+					NaluType: byte(h265.NALUType_VPS_NUT),
+					NumNalus: 1,
+					Nalus:    []mp4.HEVCNalu{{Length: uint16(len(codec.VPS)), NALUnit: codec.VPS}},
+				},
+				{
+					NaluType: byte(h265.NALUType_SPS_NUT),
+					NumNalus: 1,
+					Nalus:    []mp4.HEVCNalu{{Length: uint16(len(codec.SPS)), NALUnit: codec.SPS}},
+				},
+				{
+					NaluType: byte(h265.NALUType_PPS_NUT),
+					NumNalus: 1,
+					Nalus:    []mp4.HEVCNalu{{Length: uint16(len(codec.PPS)), NALUnit: codec.PPS}},
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &Track{
+			Number:       number,
+			CodecID:      CodecIDH265,
+			CodecPrivate: priv,
+			Width:        sps.Width(),
+			Height:       sps.Height(),
+		}, nil
+
+	case *fmp4.CodecH264:
+		var sps h264.SPS
+		err := sps.Unmarshal(codec.SPS)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse H264 SPS: %w", err)
+		}
+
+		// CodecPrivate is the content of the avcC box
+		priv, err := marshalBox(&mp4.AVCDecoderConfiguration{
+			AnyTypeBox: mp4.AnyTypeBox{
+				Type: mp4.BoxTypeAvcC(),
+			},
+			ConfigurationVersion:       1,
+			Profile:                    sps.ProfileIdc,
+			ProfileCompatibility:       codec.SPS[2],
+			Level:                      sps.LevelIdc,
+			LengthSizeMinusOne:         3,
+			NumOfSequenceParameterSets: 1,
+			SequenceParameterSets:      []mp4.AVCParameterSet{{Length: uint16(len(codec.SPS)), NALUnit: codec.SPS}},
+			NumOfPictureParameterSets:  1,
+			PictureParameterSets:       []mp4.AVCParameterSet{{Length: uint16(len(codec.PPS)), NALUnit: codec.PPS}},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &Track{
+			Number:       number,
+			CodecID:      CodecIDH264,
+			CodecPrivate: priv,
+			Width:        sps.Width(),
+			Height:       sps.Height(),
+		}, nil
+
+	case *fmp4.CodecMJPEG:
+		return &Track{
+			Number:  number,
+			CodecID: CodecIDMJPEG,
+			Width:   codec.Width,
+			Height:  codec.Height,
+		}, nil
+
+	case *fmp4.CodecOpus:
+		// CodecPrivate is the Opus identification header
+		// Specification: RFC 7845, 5.1
+		priv := []byte("OpusHead")
+		priv = append(priv, 1, byte(codec.ChannelCount))
+		priv = binary.LittleEndian.AppendUint16(priv, 0)     // pre-skip
+		priv = binary.LittleEndian.AppendUint32(priv, 48000) // input sample rate
+		priv = binary.LittleEndian.AppendUint16(priv, 0)     // output gain
+		priv = append(priv, 0)                               // channel mapping family
+
+		return &Track{
+			Number:       number,
+			CodecID:      CodecIDOpus,
+			CodecPrivate: priv,
+			SampleRate:   48000,
+			ChannelCount: codec.ChannelCount,
+		}, nil
+
+	case *fmp4.CodecMPEG4Audio:
+		priv, err := codec.Config.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Track{
+			Number:       number,
+			CodecID:      CodecIDAAC,
+			CodecPrivate: priv,
+			SampleRate:   codec.SampleRate,
+			ChannelCount: codec.ChannelCount,
+		}, nil
+
+	case *fmp4.CodecAC3:
+		return &Track{
+			Number:       number,
+			CodecID:      CodecIDAC3,
+			SampleRate:   codec.SampleRate,
+			ChannelCount: codec.ChannelCount,
+		}, nil
+
+	case *fmp4.CodecLPCM:
+		codecID := CodecIDLPCMBig
+		if codec.LittleEndian {
+			codecID = CodecIDLPCMLittle
+		}
+
+		return &Track{
+			Number:       number,
+			CodecID:      codecID,
+			SampleRate:   codec.SampleRate,
+			ChannelCount: codec.ChannelCount,
+			BitDepth:     codec.BitDepth,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported codec: %T", codec)
+	}
+}
+
+// FMP4Codec returns the fMP4 codec of the track.
+func (t *Track) FMP4Codec() (fmp4.Codec, error) {
+	switch t.CodecID {
+	case CodecIDAV1:
+		var box mp4.Av1C
+		err := unmarshalBox(t.CodecPrivate, &box)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AV1 configuration: %w", err)
+		}
+
+		obus, err := av1.BitstreamUnmarshal(box.ConfigOBUs, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obu := range obus {
+			var h av1.OBUHeader
+			err = h.Unmarshal(obu)
+			if err != nil {
+				return nil, err
+			}
+
+			if h.Type == av1.OBUTypeSequenceHeader {
+				return &fmp4.CodecAV1{
+					SequenceHeader: obu,
+				}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("AV1 sequence header not found")
+
+	case CodecIDVP9:
+		codec := &fmp4.CodecVP9{
+			Width:             t.Width,
+			Height:            t.Height,
+			BitDepth:          8,
+			ChromaSubsampling: 1,
+		}
+
+		priv := t.CodecPrivate
+		for len(priv) >= 3 {
+			if priv[1] != 1 {
+				return nil, fmt.Errorf("invalid VP9 codec features")
+			}
+
+			switch priv[0] {
+			case vp9FeatureProfile:
+				codec.Profile = priv[2]
+
+			case vp9FeatureBitDepth:
+				codec.BitDepth = priv[2]
+
+			case vp9FeatureChromaSubsampling:
+				codec.ChromaSubsampling = priv[2]
+			}
+
+			priv = priv[3:]
+		}
+
+		return codec, nil
+
+	case CodecIDH265:
+		var box mp4.HvcC
+		err := unmarshalBox(t.CodecPrivate, &box)
+		if err != nil {
+			return nil, fmt.Errorf("invalid H265 configuration: %w", err)
+		}
+
+		codec := &fmp4.CodecH265{}
+
+		for _, arr := range box.NaluArrays {
+			if len(arr.Nalus) == 0 {
+				continue
+			}
+
+			switch h265.NALUType(arr.NaluType) {
+			case h265.NALUType_VPS_NUT:
+				codec.VPS = arr.Nalus[0].NALUnit
+
+			case h265.NALUType_SPS_NUT:
+				codec.SPS = arr.Nalus[0].NALUnit
+
+			case h265.NALUType_PPS_NUT:
+				codec.PPS = arr.Nalus[0].NALUnit
+			}
+		}
+
+		if codec.VPS == nil || codec.SPS == nil || codec.PPS == nil {
+			return nil, fmt.Errorf("H265 parameters not found")
+		}
+
+		return codec, nil
+
+	case CodecIDH264:
+		box := mp4.AVCDecoderConfiguration{
+			AnyTypeBox: mp4.AnyTypeBox{
+				Type: mp4.BoxTypeAvcC(),
+			},
+		}
+		err := unmarshalBox(t.CodecPrivate, &box)
+		if err != nil {
+			return nil, fmt.Errorf("invalid H264 configuration: %w", err)
+		}
+
+		if len(box.SequenceParameterSets) == 0 || len(box.PictureParameterSets) == 0 {
+			return nil, fmt.Errorf("H264 parameters not found")
+		}
+
+		return &fmp4.CodecH264{
+			SPS: box.SequenceParameterSets[0].NALUnit,
+			PPS: box.PictureParameterSets[0].NALUnit,
+		}, nil
+
+	case CodecIDMJPEG:
+		return &fmp4.CodecMJPEG{
+			Width:  t.Width,
+			Height: t.Height,
+		}, nil
+
+	case CodecIDOpus:
+		return &fmp4.CodecOpus{
+			ChannelCount: t.ChannelCount,
+		}, nil
+
+	case CodecIDAAC:
+		var conf mpeg4audio.Config
+		err := conf.Unmarshal(t.CodecPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MPEG-4 audio configuration: %w", err)
+		}
+
+		return &fmp4.CodecMPEG4Audio{
+			Config: conf,
+		}, nil
+
+	case CodecIDLPCMBig, CodecIDLPCMLittle:
+		return &fmp4.CodecLPCM{
+			LittleEndian: t.CodecID == CodecIDLPCMLittle,
+			BitDepth:     t.BitDepth,
+			SampleRate:   t.SampleRate,
+			ChannelCount: t.ChannelCount,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("codec %s is not supported", t.CodecID)
+	}
+}
@@ -0,0 +1,229 @@
+package matroska
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// unknownSize is the size of elements whose size is not known when they are written.
+const unknownSize = -1
+
+func idSize(id uint32) int {
+	switch {
+	case id > 0xFFFFFF:
+		return 4
+	case id > 0xFFFF:
+		return 3
+	case id > 0xFF:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func appendID(buf []byte, id uint32) []byte {
+	for i := idSize(id) - 1; i >= 0; i-- {
+		buf = append(buf, byte(id>>(8*i)))
+	}
+	return buf
+}
+
+func vintSize(v uint64) int {
+	n := 1
+	for v >= (1<<(7*n))-1 {
+		n++
+	}
+	return n
+}
+
+func appendVintFixed(buf []byte, v uint64, n int) []byte {
+	v |= 1 << (7 * n)
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+func appendSize(buf []byte, size int64) []byte {
+	if size == unknownSize {
+		return append(buf, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	}
+	return appendVintFixed(buf, uint64(size), vintSize(uint64(size)))
+}
+
+func appendElement(buf []byte, id uint32, content []byte) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, int64(len(content)))
+	return append(buf, content...)
+}
+
+func appendUint(buf []byte, id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && (v>>(8*n)) != 0 {
+		n++
+	}
+
+	buf = appendID(buf, id)
+	buf = appendSize(buf, int64(n))
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+func appendUintFixed(buf []byte, id uint32, v uint64) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, 8)
+	return binary.BigEndian.AppendUint64(buf, v)
+}
+
+func appendFloat(buf []byte, id uint32, v float64) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, 8)
+	return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+}
+
+func appendString(buf []byte, id uint32, v string) []byte {
+	return appendElement(buf, id, []byte(v))
+}
+
+func appendVoid(buf []byte, totalSize int) []byte {
+	// Void elements must be at least 2 bytes long.
+	contentSize := totalSize - 2
+	if contentSize > 126 {
+		contentSize = totalSize - 9
+		buf = appendID(buf, idVoid)
+		buf = appendVintFixed(buf, uint64(contentSize), 8)
+	} else {
+		buf = appendID(buf, idVoid)
+		buf = appendVintFixed(buf, uint64(contentSize), 1)
+	}
+	return append(buf, make([]byte, contentSize)...)
+}
+
+var errInvalidVint = errors.New("invalid variable-size integer")
+
+// readVint reads a variable-size integer.
+// When keepMarker is true, the length marker is kept (i.e. in element IDs).
+func readVint(r io.Reader, keepMarker bool, maxLen int) (uint64, int, error) {
+	var buf [8]byte
+	_, err := io.ReadFull(r, buf[:1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n := 1
+	for n <= 8 && (buf[0]&(0x80>>(n-1))) == 0 {
+		n++
+	}
+	if n > maxLen {
+		return 0, 0, errInvalidVint
+	}
+
+	_, err = io.ReadFull(r, buf[1:n])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<8 | uint64(buf[i])
+	}
+
+	if !keepMarker {
+		v &^= 1 << (7 * n)
+	}
+
+	return v, n, nil
+}
+
+// elementHeader is the header of an element.
+type elementHeader struct {
+	id uint32
+
+	// unknownSize when the size is not known.
+	size int64
+
+	// size of the header.
+	len int
+}
+
+func readElementHeader(r io.Reader) (*elementHeader, error) {
+	id, idLen, err := readVint(r, true, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	size, sizeLen, err := readVint(r, false, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &elementHeader{
+		id:   uint32(id),
+		size: int64(size),
+		len:  idLen + sizeLen,
+	}
+
+	// all bits set to one means unknown size
+	if size == (1<<(7*sizeLen))-1 {
+		h.size = unknownSize
+	}
+
+	return h, nil
+}
+
+func readUint(buf []byte) (uint64, error) {
+	if len(buf) > 8 {
+		return 0, fmt.Errorf("invalid unsigned integer size: %d", len(buf))
+	}
+
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func readFloat(buf []byte) (float64, error) {
+	switch len(buf) {
+	case 0:
+		return 0, nil
+
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+
+	default:
+		return 0, fmt.Errorf("invalid float size: %d", len(buf))
+	}
+}
+
+// forEachChild calls cb for each child element of a buffer that contains the content of a master element.
+func forEachChild(buf []byte, cb func(id uint32, content []byte) error) error {
+	for len(buf) != 0 {
+		h, err := readElementHeader(bytes.NewReader(buf))
+		if err != nil {
+			return err
+		}
+
+		if h.size == unknownSize || int64(len(buf)-h.len) < h.size {
+			return fmt.Errorf("invalid size of element 0x%X", h.id)
+		}
+
+		err = cb(h.id, buf[h.len:int64(h.len)+h.size])
+		if err != nil {
+			return err
+		}
+
+		buf = buf[int64(h.len)+h.size:]
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package matroska contains a Matroska / WebM writer and reader.
+package matroska
+
+import (
+	"time"
+)
+
+// IDs of elements.
+// Specification: RFC 9559
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+	idVoid               = 0xEC
+
+	idSegment            = 0x18538067
+	idSeekHead           = 0x114D9B74
+	idSeek               = 0x4DBB
+	idSeekID             = 0x53AB
+	idSeekPosition       = 0x53AC
+	idInfo               = 0x1549A966
+	idTimestampScale     = 0x2AD7B1
+	idDuration           = 0x4489
+	idDateUTC            = 0x4461
+	idMuxingApp          = 0x4D80
+	idWritingApp         = 0x5741
+	idTracks             = 0x1654AE6B
+	idTrackEntry         = 0xAE
+	idTrackNumber        = 0xD7
+	idTrackUID           = 0x73C5
+	idTrackType          = 0x83
+	idFlagLacing         = 0x9C
+	idCodecID            = 0x86
+	idCodecPrivate       = 0x63A2
+	idSeekPreRoll        = 0x56BB
+	idVideo              = 0xE0
+	idPixelWidth         = 0xB0
+	idPixelHeight        = 0xBA
+	idAudio              = 0xE1
+	idSamplingFrequency  = 0xB5
+	idChannels           = 0x9F
+	idBitDepth           = 0x6264
+	idCluster            = 0x1F43B675
+	idTimestamp          = 0xE7
+	idSimpleBlock        = 0xA3
+	idBlockGroup         = 0xA0
+	idBlock              = 0xA1
+	idReferenceBlock     = 0xFB
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+)
+
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+)
+
+// DocType is the type of a document.
+type DocType string
+
+// document types.
+const (
+	DocTypeMatroska DocType = "matroska"
+	DocTypeWebM     DocType = "webm"
+)
+
+// TimestampScale is the resolution of timestamps.
+const TimestampScale = time.Millisecond
+
+// MaxClusterDuration is the maximum duration of a cluster,
+// since timestamps of blocks are relative to the one of the cluster and are 16-bit wide.
+const MaxClusterDuration = 30 * time.Second
+
+// Track is a track.
+type Track struct {
+	// number, starts from 1.
+	Number int
+
+	CodecID      string
+	CodecPrivate []byte
+
+	// video tracks only.
+	Width  int
+	Height int
+
+	// audio tracks only.
+	SampleRate   int
+	ChannelCount int
+	BitDepth     int
+}
+
+// IsVideo returns whether the track is a video track.
+func (t *Track) IsVideo() bool {
+	return t.CodecID[:2] == "V_"
+}
+
+// Block is a frame of a track.
+type Block struct {
+	Track int
+
+	// timestamp relative to the beginning of the segment.
+	Timestamp time.Duration
+	Keyframe  bool
+	Payload   []byte
+}
+
+// Cluster is a group of blocks.
+type Cluster struct {
+	// timestamp relative to the beginning of the segment.
+	Timestamp time.Duration
+	Blocks    []*Block
+}
@@ -0,0 +1,224 @@
+package matroska
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
+	"github.com/stretchr/testify/require"
+)
+
+var testSPS = []byte{
+	0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+	0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+	0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+}
+
+func writeTestFile(t *testing.T, closeWriter bool) ([]byte, []*Track) {
+	h264Track, err := TrackFromFMP4Codec(1, &fmp4.CodecH264{
+		SPS: testSPS,
+		PPS: []byte{0x08},
+	})
+	require.NoError(t, err)
+
+	aacTrack, err := TrackFromFMP4Codec(2, &fmp4.CodecMPEG4Audio{
+		Config: mpeg4audio.Config{
+			Type:         2,
+			SampleRate:   44100,
+			ChannelCount: 2,
+		},
+	})
+	require.NoError(t, err)
+
+	var buf seekablebuffer.Buffer
+
+	w := &Writer{
+		W:       &buf,
+		DocType: DocTypeMatroska,
+		Tracks:  []*Track{h264Track, aacTrack},
+		Date:    time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+	}
+	err = w.Initialize()
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = w.WriteCluster(&Cluster{
+			Timestamp: time.Duration(i) * time.Second,
+			Blocks: []*Block{
+				{
+					Track:     1,
+					Timestamp: time.Duration(i) * time.Second,
+					Keyframe:  true,
+					Payload:   []byte{0, 0, 0, 1, 5},
+				},
+				{
+					Track:     2,
+					Timestamp: time.Duration(i)*time.Second + 10*time.Millisecond,
+					Keyframe:  true,
+					Payload:   []byte{1, 2, 3, 4},
+				},
+				{
+					Track:     1,
+					Timestamp: time.Duration(i)*time.Second + 500*time.Millisecond,
+					Payload:   []byte{0, 0, 0, 1, 1},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	if closeWriter {
+		err = w.Close(3 * time.Second)
+		require.NoError(t, err)
+	}
+
+	return buf.Bytes(), w.Tracks
+}
+
+func TestWriteRead(t *testing.T) {
+	for _, ca := range []string{"closed", "not closed", "truncated"} {
+		t.Run(ca, func(t *testing.T) {
+			byts, tracks := writeTestFile(t, ca == "closed")
+
+			if ca == "truncated" {
+				byts = byts[:len(byts)-5]
+			}
+
+			r := &Reader{R: bytes.NewReader(byts)}
+			err := r.Initialize()
+			require.NoError(t, err)
+
+			require.Equal(t, DocTypeMatroska, r.DocType)
+			require.Equal(t, tracks, r.Tracks)
+			require.Equal(t, time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), r.Date.UTC())
+
+			if ca == "closed" {
+				require.Equal(t, 3*time.Second, r.Duration)
+			} else {
+				require.Equal(t, time.Duration(0), r.Duration)
+			}
+
+			var blocks []*Block
+			err = r.ReadBlocks(func(b *Block) error {
+				blocks = append(blocks, b)
+				return nil
+			})
+			require.NoError(t, err)
+
+			maxTimestamp, err := r.MaxTimestamp()
+			require.NoError(t, err)
+
+			if ca == "truncated" {
+				require.Len(t, blocks, 6)
+				require.Equal(t, 1500*time.Millisecond, maxTimestamp)
+			} else {
+				require.Len(t, blocks, 9)
+				require.Equal(t, 2500*time.Millisecond, maxTimestamp)
+			}
+
+			require.Equal(t, &Block{
+				Track:     2,
+				Timestamp: 1010 * time.Millisecond,
+				Keyframe:  true,
+				Payload:   []byte{1, 2, 3, 4},
+			}, blocks[4])
+			require.Equal(t, &Block{
+				Track:     1,
+				Timestamp: 1500 * time.Millisecond,
+				Payload:   []byte{0, 0, 0, 1, 1},
+			}, blocks[5])
+		})
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, ca := range []struct {
+		name  string
+		codec fmp4.Codec
+	}{
+		{
+			"av1",
+			&fmp4.CodecAV1{
+				SequenceHeader: []byte{
+					8, 0, 0, 0, 66, 167, 191, 228, 96, 13, 0, 64,
+				},
+			},
+		},
+		{
+			"vp9",
+			&fmp4.CodecVP9{
+				Width:             1920,
+				Height:            1080,
+				Profile:           1,
+				BitDepth:          8,
+				ChromaSubsampling: 1,
+			},
+		},
+		{
+			"h265",
+			&fmp4.CodecH265{
+				VPS: []byte{
+					0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x02, 0x20,
+					0x00, 0x00, 0x03, 0x00, 0xb0, 0x00, 0x00, 0x03,
+					0x00, 0x00, 0x03, 0x00, 0x7b, 0x18, 0xb0, 0x24,
+				},
+				SPS: []byte{
+					0x42, 0x01, 0x01, 0x02, 0x20, 0x00, 0x00, 0x03,
+					0x00, 0xb0, 0x00, 0x00, 0x03, 0x00, 0x00, 0x03,
+					0x00, 0x7b, 0xa0, 0x07, 0x82, 0x00, 0x88, 0x7d,
+					0xb6, 0x71, 0x8b, 0x92, 0x44, 0x80, 0x53, 0x88,
+					0x88, 0x92, 0xcf, 0x24, 0xa6, 0x92, 0x72, 0xc9,
+					0x12, 0x49, 0x22, 0xdc, 0x91, 0xaa, 0x48, 0xfc,
+					0xa2, 0x23, 0xff, 0x00, 0x01, 0x00, 0x01, 0x6a,
+					0x02, 0x02, 0x02, 0x01,
+				},
+				PPS: []byte{
+					0x44, 0x01, 0xc0, 0x25, 0x2f, 0x05, 0x32, 0x40,
+				},
+			},
+		},
+		{
+			"h264",
+			&fmp4.CodecH264{
+				SPS: testSPS,
+				PPS: []byte{0x08},
+			},
+		},
+		{
+			"opus",
+			&fmp4.CodecOpus{
+				ChannelCount: 2,
+			},
+		},
+		{
+			"mpeg-4 audio",
+			&fmp4.CodecMPEG4Audio{
+				Config: mpeg4audio.Config{
+					Type:         2,
+					SampleRate:   44100,
+					ChannelCount: 2,
+				},
+			},
+		},
+		{
+			"lpcm",
+			&fmp4.CodecLPCM{
+				BitDepth:     16,
+				SampleRate:   44100,
+				ChannelCount: 2,
+			},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			track, err := TrackFromFMP4Codec(1, ca.codec)
+			require.NoError(t, err)
+
+			codec, err := track.FMP4Codec()
+			require.NoError(t, err)
+			require.Equal(t, ca.codec, codec)
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package matroska
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNoBlocks is returned when a segment does not contain any complete cluster.
+var ErrNoBlocks = errors.New("no blocks found")
+
+func isEOF(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Reader is a Matroska reader.
+// It supports files that have not been closed properly,
+// by ignoring the last cluster when it is incomplete.
+type Reader struct {
+	R io.ReadSeeker
+
+	DocType DocType
+	Tracks  []*Track
+
+	// absolute time of the beginning of the segment.
+	Date time.Time
+
+	// zero when the segment has not been closed properly.
+	Duration time.Duration
+
+	timestampScale time.Duration
+	segmentEnd     int64
+	clustersPos    int64
+}
+
+// Initialize initializes Reader and reads the header.
+func (r *Reader) Initialize() error {
+	r.timestampScale = TimestampScale
+
+	h, err := readElementHeader(r.R)
+	if err != nil {
+		return err
+	}
+
+	if h.id != idEBML || h.size == unknownSize {
+		return fmt.Errorf("EBML header not found")
+	}
+
+	header, err := r.readContent(h)
+	if err != nil {
+		return err
+	}
+
+	err = forEachChild(header, func(id uint32, content []byte) error {
+		if id == idDocType {
+			r.DocType = DocType(content)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.DocType != DocTypeMatroska && r.DocType != DocTypeWebM {
+		return fmt.Errorf("unsupported document type: '%s'", r.DocType)
+	}
+
+	h, err = readElementHeader(r.R)
+	if err != nil {
+		return err
+	}
+
+	if h.id != idSegment {
+		return fmt.Errorf("segment not found")
+	}
+
+	segmentDataPos, err := r.R.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if h.size == unknownSize {
+		r.segmentEnd = -1
+	} else {
+		r.segmentEnd = segmentDataPos + h.size
+	}
+
+	for {
+		r.clustersPos, err = r.R.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+
+		if r.segmentEnd >= 0 && r.clustersPos >= r.segmentEnd {
+			break
+		}
+
+		h, err = readElementHeader(r.R)
+		if err != nil {
+			if isEOF(err) {
+				break
+			}
+			return err
+		}
+
+		if h.id == idCluster {
+			break
+		}
+
+		if h.size == unknownSize {
+			return fmt.Errorf("unknown size of element 0x%X is not supported", h.id)
+		}
+
+		switch h.id {
+		case idInfo:
+			var content []byte
+			content, err = r.readContent(h)
+			if err != nil {
+				return err
+			}
+
+			err = r.parseInfo(content)
+			if err != nil {
+				return err
+			}
+
+		case idTracks:
+			var content []byte
+			content, err = r.readContent(h)
+			if err != nil {
+				return err
+			}
+
+			err = r.parseTracks(content)
+			if err != nil {
+				return err
+			}
+
+		default:
+			_, err = r.R.Seek(h.size, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.Tracks == nil {
+		return fmt.Errorf("no tracks found")
+	}
+
+	return nil
+}
+
+func (r *Reader) readContent(h *elementHeader) ([]byte, error) {
+	content := make([]byte, h.size)
+	_, err := io.ReadFull(r.R, content)
+	return content, err
+}
+
+func (r *Reader) parseInfo(buf []byte) error {
+	var duration float64
+
+	err := forEachChild(buf, func(id uint32, content []byte) error {
+		switch id {
+		case idTimestampScale:
+			v, err := readUint(content)
+			if err != nil {
+				return err
+			}
+			r.timestampScale = time.Duration(v)
+
+		case idDuration:
+			var err error
+			duration, err = readFloat(content)
+			if err != nil {
+				return err
+			}
+
+		case idDateUTC:
+			v, err := readUint(content)
+			if err != nil {
+				return err
+			}
+			r.Date = dateUTCEpoch.Add(time.Duration(int64(v)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Duration = time.Duration(duration * float64(r.timestampScale))
+
+	return nil
+}
+
+func (r *Reader) parseTracks(buf []byte) error {
+	return forEachChild(buf, func(id uint32, content []byte) error {
+		if id != idTrackEntry {
+			return nil
+		}
+
+		track := &Track{}
+
+		err := forEachChild(content, func(id uint32, content []byte) error {
+			switch id {
+			case idTrackNumber:
+				v, err := readUint(content)
+				if err != nil {
+					return err
+				}
+				track.Number = int(v)
+
+			case idCodecID:
+				track.CodecID = string(content)
+
+			case idCodecPrivate:
+				track.CodecPrivate = content
+
+			case idVideo:
+				return forEachChild(content, func(id uint32, content []byte) error {
+					v, err := readUint(content)
+					if err != nil {
+						return err
+					}
+
+					switch id {
+					case idPixelWidth:
+						track.Width = int(v)
+
+					case idPixelHeight:
+						track.Height = int(v)
+					}
+					return nil
+				})
+
+			case idAudio:
+				return forEachChild(content, func(id uint32, content []byte) error {
+					switch id {
+					case idSamplingFrequency:
+						v, err := readFloat(content)
+						if err != nil {
+							return err
+						}
+						track.SampleRate = int(v)
+
+					case idChannels:
+						v, err := readUint(content)
+						if err != nil {
+							return err
+						}
+						track.ChannelCount = int(v)
+
+					case idBitDepth:
+						v, err := readUint(content)
+						if err != nil {
+							return err
+						}
+						track.BitDepth = int(v)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if track.Number == 0 || len(track.CodecID) < 2 {
+			return fmt.Errorf("invalid track entry")
+		}
+
+		r.Tracks = append(r.Tracks, track)
+		return nil
+	})
+}
+
+// nextCluster returns the header of the next complete cluster.
+// It returns nil when there are no other complete clusters.
+func (r *Reader) nextCluster(fileSize int64) (*elementHeader, int64, error) {
+	for {
+		pos, err := r.R.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if r.segmentEnd >= 0 && pos >= r.segmentEnd {
+			return nil, 0, nil
+		}
+
+		h, err := readElementHeader(r.R)
+		if err != nil {
+			if isEOF(err) {
+				return nil, 0, nil
+			}
+			return nil, 0, err
+		}
+
+		if h.size == unknownSize {
+			return nil, 0, fmt.Errorf("unknown size of element 0x%X is not supported", h.id)
+		}
+
+		// the last element is incomplete
+		if (pos + int64(h.len) + h.size) > fileSize {
+			return nil, 0, nil
+		}
+
+		if h.id == idCluster {
+			return h, pos, nil
+		}
+
+		_, err = r.R.Seek(h.size, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+func (r *Reader) fileSize() (int64, error) {
+	size, err := r.R.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.R.Seek(r.clustersPos, io.SeekStart)
+	return size, err
+}
+
+// ReadBlocks calls cb for each block of each complete cluster.
+func (r *Reader) ReadBlocks(cb func(b *Block) error) error {
+	fileSize, err := r.fileSize()
+	if err != nil {
+		return err
+	}
+
+	for {
+		h, _, err := r.nextCluster(fileSize)
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			return nil
+		}
+
+		content, err := r.readContent(h)
+		if err != nil {
+			return err
+		}
+
+		err = r.parseCluster(content, cb)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// MaxTimestamp returns the greatest timestamp of blocks.
+// Only the last complete cluster is read.
+func (r *Reader) MaxTimestamp() (time.Duration, error) {
+	fileSize, err := r.fileSize()
+	if err != nil {
+		return 0, err
+	}
+
+	var last *elementHeader
+	var lastPos int64
+
+	for {
+		h, pos, err := r.nextCluster(fileSize)
+		if err != nil {
+			return 0, err
+		}
+		if h == nil {
+			break
+		}
+
+		last = h
+		lastPos = pos
+
+		_, err = r.R.Seek(h.size, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if last == nil {
+		return 0, ErrNoBlocks
+	}
+
+	_, err = r.R.Seek(lastPos+int64(last.len), io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := r.readContent(last)
+	if err != nil {
+		return 0, err
+	}
+
+	var maxTimestamp time.Duration
+	found := false
+
+	err = r.parseCluster(content, func(b *Block) error {
+		if !found || b.Timestamp > maxTimestamp {
+			maxTimestamp = b.Timestamp
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if !found {
+		return 0, ErrNoBlocks
+	}
+
+	return maxTimestamp, nil
+}
+
+func (r *Reader) parseCluster(buf []byte, cb func(b *Block) error) error {
+	var clusterTime uint64
+
+	return forEachChild(buf, func(id uint32, content []byte) error {
+		switch id {
+		case idTimestamp:
+			var err error
+			clusterTime, err = readUint(content)
+			return err
+
+		case idSimpleBlock:
+			b, flags, err := r.parseBlock(content, clusterTime)
+			if err != nil {
+				return err
+			}
+
+			b.Keyframe = (flags & 0x80) != 0
+			return cb(b)
+
+		case idBlockGroup:
+			var b *Block
+			hasReference := false
+
+			err := forEachChild(content, func(id uint32, content []byte) error {
+				switch id {
+				case idBlock:
+					var err error
+					b, _, err = r.parseBlock(content, clusterTime)
+					return err
+
+				case idReferenceBlock:
+					hasReference = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if b == nil {
+				return fmt.Errorf("block group without block")
+			}
+
+			b.Keyframe = !hasReference
+			return cb(b)
+		}
+		return nil
+	})
+}
+
+func (r *Reader) parseBlock(buf []byte, clusterTime uint64) (*Block, byte, error) {
+	br := bytes.NewReader(buf)
+
+	track, n, err := readVint(br, false, 8)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(buf) < (n + 3) {
+		return nil, 0, fmt.Errorf("block is too short")
+	}
+
+	rel := int16(uint16(buf[n])<<8 | uint16(buf[n+1]))
+	flags := buf[n+2]
+
+	if (flags & 0x06) != 0 {
+		return nil, 0, fmt.Errorf("laced blocks are not supported")
+	}
+
+	return &Block{
+		Track:     int(track),
+		Timestamp: time.Duration(int64(clusterTime)+int64(rel)) * r.timestampScale,
+		Payload:   buf[n+3:],
+	}, flags, nil
+}
@@ -0,0 +1,283 @@
+package matroska
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// space reserved for the seek head, that is written when the segment is closed.
+	seekHeadReservedSize = 96
+
+	muxingApp = "mediamtx"
+)
+
+var dateUTCEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type cuePoint struct {
+	time            uint64
+	track           int
+	clusterPosition uint64
+}
+
+// Writer is a Matroska writer.
+// The segment is written with an unknown size and clusters are written one at a time,
+// therefore the file is readable even when the writer is not closed (i.e. in case of a crash).
+// When the writer is closed, cues are appended and size, seek head and duration of the segment are updated.
+type Writer struct {
+	W       io.WriteSeeker
+	DocType DocType
+	Tracks  []*Track
+
+	// absolute time of the beginning of the segment.
+	Date time.Time
+
+	pos            int64
+	segmentSizePos int64
+	segmentDataPos int64
+	seekHeadPos    int64
+	infoPos        int64
+	tracksPos      int64
+	durationPos    int64
+	hasVideo       bool
+	cuePoints      []cuePoint
+}
+
+// Initialize initializes Writer and writes the header.
+func (w *Writer) Initialize() error {
+	var header []byte
+	header = appendUint(header, idEBMLVersion, 1)
+	header = appendUint(header, idEBMLReadVersion, 1)
+	header = appendUint(header, idEBMLMaxIDLength, 4)
+	header = appendUint(header, idEBMLMaxSizeLength, 8)
+	header = appendString(header, idDocType, string(w.DocType))
+	header = appendUint(header, idDocTypeVersion, 4)
+	header = appendUint(header, idDocTypeReadVersion, 2)
+
+	buf := appendElement(nil, idEBML, header)
+
+	buf = appendID(buf, idSegment)
+	w.segmentSizePos = int64(len(buf))
+	buf = appendSize(buf, unknownSize)
+	w.segmentDataPos = int64(len(buf))
+
+	w.seekHeadPos = int64(len(buf))
+	buf = appendVoid(buf, seekHeadReservedSize)
+
+	var info []byte
+	info = appendUint(info, idTimestampScale, uint64(TimestampScale))
+	if !w.Date.IsZero() {
+		info = appendUintFixed(info, idDateUTC, uint64(w.Date.Sub(dateUTCEpoch)))
+	}
+	info = appendString(info, idMuxingApp, muxingApp)
+	info = appendString(info, idWritingApp, muxingApp)
+	durationPos := len(info) + idSize(idDuration) + 1
+	info = appendFloat(info, idDuration, 0)
+
+	w.infoPos = int64(len(buf))
+	buf = appendElement(buf, idInfo, info)
+	w.durationPos = int64(len(buf)-len(info)) + int64(durationPos)
+
+	var tracks []byte
+	for _, track := range w.Tracks {
+		tracks = appendElement(tracks, idTrackEntry, marshalTrack(track))
+		if track.IsVideo() {
+			w.hasVideo = true
+		}
+	}
+
+	w.tracksPos = int64(len(buf))
+	buf = appendElement(buf, idTracks, tracks)
+
+	_, err := w.W.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	w.pos = int64(len(buf))
+
+	return nil
+}
+
+func marshalTrack(track *Track) []byte {
+	var buf []byte
+	buf = appendUint(buf, idTrackNumber, uint64(track.Number))
+	buf = appendUint(buf, idTrackUID, uint64(track.Number))
+
+	if track.IsVideo() {
+		buf = appendUint(buf, idTrackType, trackTypeVideo)
+	} else {
+		buf = appendUint(buf, idTrackType, trackTypeAudio)
+	}
+
+	buf = appendUint(buf, idFlagLacing, 0)
+	buf = appendString(buf, idCodecID, track.CodecID)
+
+	if track.CodecPrivate != nil {
+		buf = appendElement(buf, idCodecPrivate, track.CodecPrivate)
+	}
+
+	if track.CodecID == CodecIDOpus {
+		buf = appendUint(buf, idSeekPreRoll, uint64(80*time.Millisecond))
+	}
+
+	if track.IsVideo() {
+		var video []byte
+		video = appendUint(video, idPixelWidth, uint64(track.Width))
+		video = appendUint(video, idPixelHeight, uint64(track.Height))
+		buf = appendElement(buf, idVideo, video)
+	} else {
+		var audio []byte
+		audio = appendFloat(audio, idSamplingFrequency, float64(track.SampleRate))
+		audio = appendUint(audio, idChannels, uint64(track.ChannelCount))
+		if track.BitDepth != 0 {
+			audio = appendUint(audio, idBitDepth, uint64(track.BitDepth))
+		}
+		buf = appendElement(buf, idAudio, audio)
+	}
+
+	return buf
+}
+
+func (w *Writer) findTrack(number int) *Track {
+	for _, track := range w.Tracks {
+		if track.Number == number {
+			return track
+		}
+	}
+	return nil
+}
+
+// WriteCluster writes a cluster.
+func (w *Writer) WriteCluster(c *Cluster) error {
+	clusterPosition := uint64(w.pos - w.segmentDataPos)
+	clusterTime := int64(c.Timestamp / TimestampScale)
+
+	content := appendUint(nil, idTimestamp, uint64(clusterTime))
+	cuedTracks := make(map[int]struct{})
+
+	for _, b := range c.Blocks {
+		track := w.findTrack(b.Track)
+		if track == nil {
+			return fmt.Errorf("track %d not found", b.Track)
+		}
+
+		blockTime := int64(b.Timestamp / TimestampScale)
+		rel := blockTime - clusterTime
+		if rel < math.MinInt16 || rel > math.MaxInt16 {
+			return fmt.Errorf("block timestamp is too far from cluster timestamp")
+		}
+
+		var flags byte
+		if b.Keyframe {
+			flags |= 0x80
+		}
+
+		data := appendVintFixed(nil, uint64(b.Track), vintSize(uint64(b.Track)))
+		data = binary.BigEndian.AppendUint16(data, uint16(int16(rel)))
+		data = append(data, flags)
+		data = append(data, b.Payload...)
+		content = appendElement(content, idSimpleBlock, data)
+
+		// index keyframes of video tracks, or of all tracks when there are no video tracks
+		if b.Keyframe && (track.IsVideo() || !w.hasVideo) {
+			if _, ok := cuedTracks[b.Track]; !ok {
+				cuedTracks[b.Track] = struct{}{}
+				w.cuePoints = append(w.cuePoints, cuePoint{
+					time:            uint64(blockTime),
+					track:           b.Track,
+					clusterPosition: clusterPosition,
+				})
+			}
+		}
+	}
+
+	buf := appendElement(nil, idCluster, content)
+
+	_, err := w.W.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	w.pos += int64(len(buf))
+
+	return nil
+}
+
+// Close writes cues and updates size, seek head and duration of the segment.
+func (w *Writer) Close(duration time.Duration) error {
+	cuesPos := w.pos
+
+	if len(w.cuePoints) != 0 {
+		var cues []byte
+		for _, cp := range w.cuePoints {
+			var positions []byte
+			positions = appendUint(positions, idCueTrack, uint64(cp.track))
+			positions = appendUint(positions, idCueClusterPosition, cp.clusterPosition)
+
+			var point []byte
+			point = appendUint(point, idCueTime, cp.time)
+			point = appendElement(point, idCueTrackPositions, positions)
+
+			cues = appendElement(cues, idCuePoint, point)
+		}
+
+		buf := appendElement(nil, idCues, cues)
+
+		_, err := w.W.Write(buf)
+		if err != nil {
+			return err
+		}
+
+		w.pos += int64(len(buf))
+	}
+
+	err := w.writeAt(w.segmentSizePos, appendVintFixed(nil, uint64(w.pos-w.segmentDataPos), 8))
+	if err != nil {
+		return err
+	}
+
+	var seekHead []byte
+	seekHead = w.appendSeek(seekHead, idInfo, w.infoPos)
+	seekHead = w.appendSeek(seekHead, idTracks, w.tracksPos)
+	if len(w.cuePoints) != 0 {
+		seekHead = w.appendSeek(seekHead, idCues, cuesPos)
+	}
+
+	buf := appendElement(nil, idSeekHead, seekHead)
+	buf = appendVoid(buf, seekHeadReservedSize-len(buf))
+
+	err = w.writeAt(w.seekHeadPos, buf)
+	if err != nil {
+		return err
+	}
+
+	err = w.writeAt(w.durationPos,
+		binary.BigEndian.AppendUint64(nil, math.Float64bits(float64(duration)/float64(TimestampScale))))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(w.pos, io.SeekStart)
+	return err
+}
+
+func (w *Writer) appendSeek(buf []byte, id uint32, pos int64) []byte {
+	var seek []byte
+	seek = appendElement(seek, idSeekID, appendID(nil, id))
+	seek = appendUintFixed(seek, idSeekPosition, uint64(pos-w.segmentDataPos))
+	return appendElement(buf, idSeek, seek)
+}
+
+func (w *Writer) writeAt(pos int64, buf []byte) error {
+	_, err := w.W.Seek(pos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Write(buf)
+	return err
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/api"
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
 	"github.com/bluenviron/mediamtx/internal/restrictnetwork"
@@ -32,6 +33,22 @@ func metricFloat(key string, tags string, value float64) string {
 	return key + tags + " " + strconv.FormatFloat(value, 'f', -1, 64) + "\n"
 }
 
+func metricLatency(key string, tags string, l *defs.APILatency) string {
+	if l == nil {
+		return ""
+	}
+
+	out := metricFloat(key+"_latency_seconds", tags, l.Average)
+	out += metricFloat(key+"_latency_max_seconds", tags, l.Max)
+	if l.RoundTripTime != nil {
+		out += metricFloat(key+"_round_trip_time_seconds", tags, *l.RoundTripTime)
+	}
+	if l.GlassToGlass != nil {
+		out += metricFloat(key+"_glass_to_glass_latency_seconds", tags, *l.GlassToGlass)
+	}
+	return out
+}
+
 type metricsAuthManager interface {
 	Authenticate(req *auth.Request) error
 }
@@ -159,6 +176,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 			out += metric("paths", tags, 1)
 			out += metric("paths_bytes_received", tags, int64(i.BytesReceived))
 			out += metric("paths_bytes_sent", tags, int64(i.BytesSent))
+			out += metricLatency("paths", tags, i.Latency)
 		}
 	} else {
 		out += metric("paths", "", 0)
@@ -203,6 +221,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 					out += metric("rtsp_sessions", tags, 1)
 					out += metric("rtsp_sessions_bytes_received", tags, int64(i.BytesReceived))
 					out += metric("rtsp_sessions_bytes_sent", tags, int64(i.BytesSent))
+					out += metricLatency("rtsp_sessions", tags, i.Latency)
 				}
 			} else {
 				out += metric("rtsp_sessions", "", 0)
@@ -237,6 +256,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 					out += metric("rtsps_sessions", tags, 1)
 					out += metric("rtsps_sessions_bytes_received", tags, int64(i.BytesReceived))
 					out += metric("rtsps_sessions_bytes_sent", tags, int64(i.BytesSent))
+					out += metricLatency("rtsps_sessions", tags, i.Latency)
 				}
 			} else {
 				out += metric("rtsps_sessions", "", 0)
@@ -254,6 +274,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 				out += metric("rtmp_conns", tags, 1)
 				out += metric("rtmp_conns_bytes_received", tags, int64(i.BytesReceived))
 				out += metric("rtmp_conns_bytes_sent", tags, int64(i.BytesSent))
+				out += metricLatency("rtmp_conns", tags, i.Latency)
 			}
 		} else {
 			out += metric("rtmp_conns", "", 0)
@@ -270,6 +291,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 				out += metric("rtmps_conns", tags, 1)
 				out += metric("rtmps_conns_bytes_received", tags, int64(i.BytesReceived))
 				out += metric("rtmps_conns_bytes_sent", tags, int64(i.BytesSent))
+				out += metricLatency("rtmps_conns", tags, i.Latency)
 			}
 		} else {
 			out += metric("rtmps_conns", "", 0)
@@ -335,6 +357,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 				out += metric("srt_conns_packets_received_avg_belated_time", tags, int64(i.PacketsReceivedAvgBelatedTime))
 				out += metricFloat("srt_conns_packets_send_loss_rate", tags, i.PacketsSendLossRate)
 				out += metricFloat("srt_conns_packets_received_loss_rate", tags, i.PacketsReceivedLossRate)
+				out += metricLatency("srt_conns", tags, i.Latency)
 			}
 		} else {
 			out += metric("srt_conns", "", 0)
@@ -351,6 +374,7 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 				out += metric("webrtc_sessions", tags, 1)
 				out += metric("webrtc_sessions_bytes_received", tags, int64(i.BytesReceived))
 				out += metric("webrtc_sessions_bytes_sent", tags, int64(i.BytesSent))
+				out += metricLatency("webrtc_sessions", tags, i.Latency)
 			}
 		} else {
 			out += metric("webrtc_sessions", "", 0)
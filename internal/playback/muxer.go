@@ -15,3 +15,10 @@ type muxer interface {
 	writeFinalDTS(dts int64)
 	flush() error
 }
+
+// muxerPayloadStorer is implemented by muxers that keep samples until flush().
+// Payloads that have been decoded in memory are stored by the muxer
+// in order not to keep the whole requested timespan in RAM.
+type muxerPayloadStorer interface {
+	storePayload(payload []byte) (func() ([]byte, error), error)
+}
@@ -1,7 +1,10 @@
 package playback
 
 import (
+	"errors"
 	"io"
+	"math"
+	"os"
 
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/pkg/formats/pmp4"
@@ -21,11 +24,92 @@ func findTrackMP4(tracks []*muxerMP4Track, id int) *muxerMP4Track {
 	return nil
 }
 
+var errRangeWritten = errors.New("range written")
+
+// rangeWriter writes the bytes of a stream that fall inside a range, and discards the others.
+type rangeWriter struct {
+	w     io.Writer
+	start uint64
+	end   uint64 // exclusive
+
+	pos uint64
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	pStart := w.pos
+	pEnd := w.pos + uint64(len(p))
+
+	if pStart >= w.end {
+		return 0, errRangeWritten
+	}
+
+	w.pos = pEnd
+
+	if pEnd > w.start {
+		_, err := w.w.Write(p[max(w.start, pStart)-pStart : min(w.end, pEnd)-pStart])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (w *rangeWriter) inRange(size uint32) bool {
+	return (w.pos+uint64(size)) > w.start && w.pos < w.end
+}
+
 type muxerMP4 struct {
 	w io.Writer
 
+	// when set, it is called with the total size of the file,
+	// and returns the byte range that has to be written.
+	onSize func(size uint64) (uint64, uint64, error)
+
 	tracks   []*muxerMP4Track
 	curTrack *muxerMP4Track
+
+	// temporary file that contains payloads decoded in memory.
+	spool     *os.File
+	spoolSize int64
+}
+
+// close removes the temporary file that contains payloads.
+func (w *muxerMP4) close() {
+	if w.spool != nil {
+		w.spool.Close()
+		os.Remove(w.spool.Name())
+	}
+}
+
+// storePayload implements muxerPayloadStorer.
+// Payloads are moved into a temporary file and read again when the file is written.
+func (w *muxerMP4) storePayload(payload []byte) (func() ([]byte, error), error) {
+	if w.spool == nil {
+		var err error
+		w.spool, err = os.CreateTemp("", "mediamtx-playback-")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := w.spool.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := w.spoolSize
+	size := len(payload)
+	w.spoolSize += int64(size)
+
+	return func() ([]byte, error) {
+		buf := make([]byte, size)
+		_, err2 := w.spool.ReadAt(buf, offset)
+		if err2 != nil {
+			return nil, err2
+		}
+		return buf, nil
+	}, nil
 }
 
 func (w *muxerMP4) writeInit(init *fmp4.Init) {
@@ -93,13 +177,70 @@ func (w *muxerMP4) writeFinalDTS(dts int64) {
 }
 
 func (w *muxerMP4) flush() error {
-	h := pmp4.Presentation{
-		Tracks: make([]*pmp4.Track, len(w.tracks)),
+	var h pmp4.Presentation
+
+	// tracks without samples can't be written
+	for _, track := range w.tracks {
+		if len(track.Samples) != 0 {
+			h.Tracks = append(h.Tracks, &track.Track)
+		}
+	}
+
+	if w.onSize == nil {
+		return h.Marshal(w.w)
+	}
+
+	size, err := marshalRange(&h, io.Discard, math.MaxUint64, math.MaxUint64)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := w.onSize(size)
+	if err != nil {
+		return err
+	}
+
+	_, err = marshalRange(&h, w.w, start, end)
+	return err
+}
+
+// marshalRange writes a byte range of a presentation. Payloads of samples outside of the range are not read.
+// It returns the total size of the presentation.
+func marshalRange(h *pmp4.Presentation, w io.Writer, start uint64, end uint64) (uint64, error) {
+	rw := &rangeWriter{
+		w:     w,
+		start: start,
+		end:   end,
+	}
+
+	var dummy []byte
+
+	for _, track := range h.Tracks {
+		for _, sample := range track.Samples {
+			getPayload := sample.GetPayload
+			payloadSize := sample.PayloadSize
+
+			sample.GetPayload = func() ([]byte, error) {
+				if rw.inRange(payloadSize) {
+					return getPayload()
+				}
+
+				if uint32(len(dummy)) < payloadSize {
+					dummy = make([]byte, payloadSize)
+				}
+				return dummy[:payloadSize], nil
+			}
+
+			defer func() {
+				sample.GetPayload = getPayload
+			}()
+		}
 	}
 
-	for i, track := range w.tracks {
-		h.Tracks[i] = &track.Track
+	err := h.Marshal(rw)
+	if err != nil && !errors.Is(err, errRangeWritten) {
+		return 0, err
 	}
 
-	return h.Marshal(w.w)
+	return rw.pos, nil
 }
@@ -0,0 +1,66 @@
+package playback
+
+import (
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/pmp4"
+)
+
+// muxerMP4Path is a muxer that adds tracks of a path to a muxerMP4
+// shared with other paths, in order to produce multi-path MP4 files.
+// Tracks are renumbered in order to avoid conflicts between paths.
+type muxerMP4Path struct {
+	m *muxerMP4
+
+	// delay of the path with respect to the start of the export.
+	offset time.Duration
+
+	tracks    map[int]*muxerMP4Track
+	curOffset int64
+}
+
+func (w *muxerMP4Path) writeInit(init *fmp4.Init) {
+	w.tracks = make(map[int]*muxerMP4Track)
+
+	for _, track := range init.Tracks {
+		mt := &muxerMP4Track{
+			Track: pmp4.Track{
+				ID:        len(w.m.tracks) + 1,
+				TimeScale: track.TimeScale,
+				Codec:     track.Codec,
+			},
+		}
+		w.m.tracks = append(w.m.tracks, mt)
+		w.tracks[track.ID] = mt
+	}
+}
+
+func (w *muxerMP4Path) setTrack(trackID int) {
+	w.m.curTrack = w.tracks[trackID]
+	w.curOffset = durationGoToMp4(w.offset, w.m.curTrack.TimeScale)
+}
+
+func (w *muxerMP4Path) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	return w.m.writeSample(dts+w.curOffset, ptsOffset, isNonSyncSample, payloadSize, getPayload)
+}
+
+// storePayload implements muxerPayloadStorer.
+func (w *muxerMP4Path) storePayload(payload []byte) (func() ([]byte, error), error) {
+	return w.m.storePayload(payload)
+}
+
+func (w *muxerMP4Path) writeFinalDTS(dts int64) {
+	w.m.writeFinalDTS(dts + w.curOffset)
+}
+
+func (w *muxerMP4Path) flush() error {
+	// the shared muxer is flushed once all paths have been written.
+	return nil
+}
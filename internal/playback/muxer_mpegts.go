@@ -0,0 +1,240 @@
+package playback
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+)
+
+const (
+	// PCR is computed by subtracting a fixed amount from DTS,
+	// therefore timestamps must be shifted in order to keep PCR positive.
+	muxerMPEGTSTimestampOffset = 1 * mpegtsClockRate
+)
+
+func h265ContainsParams(au [][]byte) bool {
+	for _, nalu := range au {
+		if h265.NALUType((nalu[0]>>1)&0b111111) == h265.NALUType_SPS_NUT {
+			return true
+		}
+	}
+	return false
+}
+
+func h264ContainsParams(au [][]byte) bool {
+	for _, nalu := range au {
+		if h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeSPS {
+			return true
+		}
+	}
+	return false
+}
+
+type muxerMPEGTSSample struct {
+	isNonSyncSample bool
+	getPayload      func() ([]byte, error)
+}
+
+type muxerMPEGTSTrack struct {
+	id        int
+	timeScale uint32
+	codec     fmp4.Codec
+	track     *mpegts.Track
+
+	started bool
+	gop     []*muxerMPEGTSSample
+}
+
+type muxerMPEGTS struct {
+	w io.Writer
+
+	bw       *bufio.Writer
+	mw       *mpegts.Writer
+	tracks   []*muxerMPEGTSTrack
+	curTrack *muxerMPEGTSTrack
+}
+
+func (w *muxerMPEGTS) writeInit(init *fmp4.Init) {
+	var tsTracks []*mpegts.Track
+
+	for _, initTrack := range init.Tracks {
+		track := &muxerMPEGTSTrack{
+			id:        initTrack.ID,
+			timeScale: initTrack.TimeScale,
+			codec:     initTrack.Codec,
+		}
+
+		var tsCodec mpegts.Codec
+
+		switch codec := initTrack.Codec.(type) {
+		case *fmp4.CodecH265:
+			tsCodec = &mpegts.CodecH265{}
+
+		case *fmp4.CodecH264:
+			tsCodec = &mpegts.CodecH264{}
+
+		case *fmp4.CodecMPEG4Video:
+			tsCodec = &mpegts.CodecMPEG4Video{}
+
+		case *fmp4.CodecMPEG1Video:
+			tsCodec = &mpegts.CodecMPEG1Video{}
+
+		case *fmp4.CodecOpus:
+			tsCodec = &mpegts.CodecOpus{
+				ChannelCount: codec.ChannelCount,
+			}
+
+		case *fmp4.CodecMPEG4Audio:
+			tsCodec = &mpegts.CodecMPEG4Audio{
+				Config: codec.Config,
+			}
+
+		case *fmp4.CodecMPEG1Audio:
+			tsCodec = &mpegts.CodecMPEG1Audio{}
+
+		case *fmp4.CodecAC3:
+			tsCodec = &mpegts.CodecAC3{
+				SampleRate:   codec.SampleRate,
+				ChannelCount: codec.ChannelCount,
+			}
+		}
+
+		// tracks with codecs that are not supported by MPEG-TS are skipped
+		if tsCodec != nil {
+			track.track = &mpegts.Track{Codec: tsCodec}
+			tsTracks = append(tsTracks, track.track)
+		}
+
+		w.tracks = append(w.tracks, track)
+	}
+
+	w.bw = bufio.NewWriterSize(w.w, mpegtsReadBufferSize)
+	w.mw = mpegts.NewWriter(w.bw, tsTracks)
+}
+
+func (w *muxerMPEGTS) setTrack(trackID int) {
+	for _, track := range w.tracks {
+		if track.id == trackID {
+			w.curTrack = track
+			return
+		}
+	}
+}
+
+func (w *muxerMPEGTS) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	_ uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if w.curTrack.track == nil {
+		return nil
+	}
+
+	if dts < 0 {
+		// store GOP of the first frame, it will be written with DTS = 0
+		if !isNonSyncSample {
+			w.curTrack.gop = nil
+		}
+		w.curTrack.gop = append(w.curTrack.gop, &muxerMPEGTSSample{
+			isNonSyncSample: isNonSyncSample,
+			getPayload:      getPayload,
+		})
+		return nil
+	}
+
+	if !w.curTrack.started {
+		w.curTrack.started = true
+
+		if isNonSyncSample {
+			for _, sample := range w.curTrack.gop {
+				err := w.writeTrackSample(w.curTrack, 0, 0, sample.isNonSyncSample, sample.getPayload)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		w.curTrack.gop = nil
+	}
+
+	return w.writeTrackSample(w.curTrack, dts, ptsOffset, isNonSyncSample, getPayload)
+}
+
+func (w *muxerMPEGTS) writeTrackSample(
+	track *muxerMPEGTSTrack,
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	getPayload func() ([]byte, error),
+) error {
+	payload, err := getPayload()
+	if err != nil {
+		return err
+	}
+
+	tsDTS := multiplyAndDivide(dts, mpegtsClockRate, int64(track.timeScale)) + muxerMPEGTSTimestampOffset
+	tsPTS := tsDTS + multiplyAndDivide(int64(ptsOffset), mpegtsClockRate, int64(track.timeScale))
+
+	switch codec := track.codec.(type) {
+	case *fmp4.CodecH265:
+		var au [][]byte
+		au, err = h264.AVCCUnmarshal(payload)
+		if err != nil {
+			return err
+		}
+
+		// make sure that parameters are available to decoders
+		if !isNonSyncSample && !h265ContainsParams(au) {
+			au = append([][]byte{codec.VPS, codec.SPS, codec.PPS}, au...)
+		}
+
+		return w.mw.WriteH265(track.track, tsPTS, tsDTS, h265.IsRandomAccess(au), au)
+
+	case *fmp4.CodecH264:
+		var au [][]byte
+		au, err = h264.AVCCUnmarshal(payload)
+		if err != nil {
+			return err
+		}
+
+		// make sure that parameters are available to decoders
+		if !isNonSyncSample && !h264ContainsParams(au) {
+			au = append([][]byte{codec.SPS, codec.PPS}, au...)
+		}
+
+		return w.mw.WriteH264(track.track, tsPTS, tsDTS, h264.IDRPresent(au), au)
+
+	case *fmp4.CodecMPEG4Video:
+		return w.mw.WriteMPEG4Video(track.track, tsPTS, payload)
+
+	case *fmp4.CodecMPEG1Video:
+		return w.mw.WriteMPEG1Video(track.track, tsPTS, payload)
+
+	case *fmp4.CodecOpus:
+		return w.mw.WriteOpus(track.track, tsPTS, [][]byte{payload})
+
+	case *fmp4.CodecMPEG4Audio:
+		return w.mw.WriteMPEG4Audio(track.track, tsPTS, [][]byte{payload})
+
+	case *fmp4.CodecMPEG1Audio:
+		return w.mw.WriteMPEG1Audio(track.track, tsPTS, [][]byte{payload})
+
+	case *fmp4.CodecAC3:
+		return w.mw.WriteAC3(track.track, tsPTS, payload)
+	}
+
+	return nil
+}
+
+func (w *muxerMPEGTS) writeFinalDTS(_ int64) {
+}
+
+func (w *muxerMPEGTS) flush() error {
+	return w.bw.Flush()
+}
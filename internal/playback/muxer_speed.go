@@ -0,0 +1,172 @@
+package playback
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+)
+
+const (
+	// an hour of recording is reduced to a second.
+	speedMax = 3600
+)
+
+type speedMode int
+
+const (
+	// keep keyframes only, and divide timestamps by the speed.
+	speedModeKeyframes speedMode = iota
+
+	// keep one GOP every N GOPs, and concatenate them.
+	speedModeGOPs
+)
+
+type muxerSpeedTrack struct {
+	id int
+
+	// a sample has been passed to the wrapped muxer
+	written bool
+
+	// speedModeGOPs
+	gopCount int
+	keeping  bool
+	started  bool
+	shift    int64
+	prevEnd  int64
+}
+
+// muxerSpeed is a muxer that wraps another muxer in order to produce
+// fast-forward recordings. Only video tracks are kept.
+type muxerSpeed struct {
+	m     muxer
+	speed float64
+	mode  speedMode
+
+	tracks   []*muxerSpeedTrack
+	curTrack *muxerSpeedTrack
+}
+
+// gopStep returns the number of GOPs that are replaced by a single GOP in speedModeGOPs.
+func (w *muxerSpeed) gopStep() int {
+	return max(1, int(math.Round(w.speed)))
+}
+
+func (w *muxerSpeed) writeInit(init *fmp4.Init) {
+	videoInit := &fmp4.Init{}
+
+	for _, track := range init.Tracks {
+		if track.Codec.IsVideo() {
+			videoInit.Tracks = append(videoInit.Tracks, track)
+			w.tracks = append(w.tracks, &muxerSpeedTrack{id: track.ID})
+		}
+	}
+
+	if len(w.tracks) != 0 {
+		w.m.writeInit(videoInit)
+	}
+}
+
+func (w *muxerSpeed) setTrack(trackID int) {
+	w.curTrack = nil
+
+	for _, track := range w.tracks {
+		if track.id == trackID {
+			w.curTrack = track
+			w.m.setTrack(trackID)
+			return
+		}
+	}
+}
+
+func (w *muxerSpeed) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	// samples that precede the start are not needed,
+	// since the output starts with a keyframe anyway.
+	if w.curTrack == nil || dts < 0 {
+		return nil
+	}
+
+	switch w.mode {
+	case speedModeKeyframes:
+		if isNonSyncSample {
+			return nil
+		}
+
+		w.curTrack.written = true
+
+		// B-frames are discarded, therefore the PTS offset is not needed.
+		return w.m.writeSample(int64(float64(dts)/w.speed), 0, false, payloadSize, getPayload)
+
+	default:
+		track := w.curTrack
+
+		if !isNonSyncSample {
+			keep := (track.gopCount % w.gopStep()) == 0
+			track.gopCount++
+
+			switch {
+			case keep && !track.started:
+				track.shift = int64(float64(dts)/w.speed) - dts
+				track.started = true
+
+			case keep:
+				track.shift = track.prevEnd - dts
+
+			case track.keeping:
+				track.prevEnd = dts + track.shift
+				w.m.writeFinalDTS(track.prevEnd)
+			}
+
+			track.keeping = keep
+		}
+
+		if !track.keeping {
+			return nil
+		}
+
+		track.written = true
+
+		return w.m.writeSample(dts+track.shift, ptsOffset, isNonSyncSample, payloadSize, getPayload)
+	}
+}
+
+// storePayload implements muxerPayloadStorer.
+func (w *muxerSpeed) storePayload(payload []byte) (func() ([]byte, error), error) {
+	if ps, ok := w.m.(muxerPayloadStorer); ok {
+		return ps.storePayload(payload)
+	}
+
+	return func() ([]byte, error) {
+		return payload, nil
+	}, nil
+}
+
+func (w *muxerSpeed) writeFinalDTS(dts int64) {
+	if w.curTrack == nil || !w.curTrack.written || dts < 0 {
+		return
+	}
+
+	switch w.mode {
+	case speedModeKeyframes:
+		w.m.writeFinalDTS(int64(float64(dts) / w.speed))
+
+	default:
+		if w.curTrack.keeping {
+			w.m.writeFinalDTS(dts + w.curTrack.shift)
+		}
+	}
+}
+
+func (w *muxerSpeed) flush() error {
+	if len(w.tracks) == 0 {
+		return fmt.Errorf("speed can be changed only when there is at least a video track")
+	}
+
+	return w.m.flush()
+}
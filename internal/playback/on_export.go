@@ -0,0 +1,252 @@
+package playback
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type exportPath struct {
+	name     string
+	conf     *conf.Path
+	segments []*recordstore.Segment
+	offset   time.Duration
+}
+
+// findExportSegments finds segments of a path in the time range of the export.
+// When recordings of the path begin after the start of the export,
+// it returns the delay of their beginning, in order to keep them aligned with other paths.
+func findExportSegments(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	duration time.Duration,
+	index *recordstore.Index,
+) ([]*recordstore.Segment, time.Duration, error) {
+	segments, err := recordstore.FindSegmentsInTimespan(pathConf, pathName, start, duration, index)
+	if err == nil || !errors.Is(err, recordstore.ErrNoSegmentsFound) {
+		return segments, 0, err
+	}
+
+	allSegments, err := recordstore.FindSegments(pathConf, pathName, index)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	end := start.Add(duration)
+
+	for _, seg := range allSegments {
+		if seg.Start.After(start) && seg.Start.Before(end) {
+			segments, err = recordstore.FindSegmentsInTimespan(pathConf, pathName, seg.Start, end.Sub(seg.Start), index)
+			return segments, seg.Start.Sub(start), err
+		}
+	}
+
+	return nil, 0, recordstore.ErrNoSegmentsFound
+}
+
+// uniquePathNames removes repeated paths, that would produce duplicate tracks or files.
+func uniquePathNames(pathNames []string) []string {
+	seen := make(map[string]struct{}, len(pathNames))
+	var ret []string
+
+	for _, pathName := range pathNames {
+		if _, ok := seen[pathName]; !ok {
+			seen[pathName] = struct{}{}
+			ret = append(ret, pathName)
+		}
+	}
+
+	return ret
+}
+
+// onExport exports recordings of several paths in the same time range,
+// into a single multi-track MP4 file or into a ZIP archive that contains a MP4 file for each path.
+// Timestamps of all paths are relative to the same start time, therefore exported recordings are aligned.
+func (s *Server) onExport(ctx *gin.Context) {
+	pathNames := uniquePathNames(ctx.QueryArray("path"))
+	if len(pathNames) == 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("no paths provided"))
+		return
+	}
+
+	for _, pathName := range pathNames {
+		if !s.doAuth(ctx, pathName) {
+			return
+		}
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	format := ctx.Query("format")
+	if format != "" && format != "mp4" && format != "zip" {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
+		return
+	}
+
+	var paths []*exportPath
+
+	for _, pathName := range pathNames {
+		var pathConf *conf.Path
+		pathConf, err = s.safeFindPathConf(pathName)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		var segments []*recordstore.Segment
+		var offset time.Duration
+		segments, offset, err = findExportSegments(pathConf, pathName, start, duration, s.RecordIndex)
+		if err != nil {
+			// paths without recordings in the time range are skipped
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				continue
+			}
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		paths = append(paths, &exportPath{
+			name:     pathName,
+			conf:     pathConf,
+			segments: segments,
+			offset:   offset,
+		})
+	}
+
+	if len(paths) == 0 {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	ww := &writerWrapper{
+		ctx:         ctx,
+		contentType: "video/mp4",
+	}
+
+	if format == "zip" {
+		ww.contentType = "application/zip"
+		ctx.Header("Content-Disposition", `attachment; filename="export.zip"`)
+		err = exportZIP(ww, paths, start, duration)
+	} else {
+		err = exportMP4(ctx, ww, paths, start, duration)
+	}
+
+	if err != nil {
+		// user aborted the download
+		var neterr *net.OpError
+		if errors.As(err, &neterr) {
+			return
+		}
+
+		// nothing has been written yet; send back JSON
+		if !ww.written {
+			if errors.Is(err, errRangeNotSatisfiable) {
+				s.writeError(ctx, http.StatusRequestedRangeNotSatisfiable, err)
+			} else {
+				s.writeError(ctx, http.StatusBadRequest, err)
+			}
+			return
+		}
+
+		// something has already been written: abort and write logs only
+		s.Log(logger.Error, err.Error())
+		return
+	}
+}
+
+func exportMP4(
+	ctx *gin.Context,
+	ww *writerWrapper,
+	paths []*exportPath,
+	start time.Time,
+	duration time.Duration,
+) error {
+	m := &muxerMP4{
+		w:      ww,
+		onSize: onSizeMP4(ctx),
+	}
+	defer m.close()
+
+	// payloads are read when the file is written, after all paths have been processed.
+	var opened openedSegments
+	defer opened.close()
+
+	for _, path := range paths {
+		err := seekAndMuxKeepOpen(path.conf.RecordFormat, path.segments, start.Add(path.offset), duration-path.offset,
+			&muxerMP4Path{m: m, offset: path.offset}, &opened)
+		if err != nil {
+			return fmt.Errorf("path '%s': %w", path.name, err)
+		}
+	}
+
+	return m.flush()
+}
+
+func exportZIP(
+	ww *writerWrapper,
+	paths []*exportPath,
+	start time.Time,
+	duration time.Duration,
+) error {
+	zw := zip.NewWriter(ww)
+
+	for _, path := range paths {
+		// MP4 files are already compressed
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     path.name + ".mp4",
+			Method:   zip.Store,
+			Modified: start,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = exportZIPPath(w, path, start, duration)
+		if err != nil {
+			return fmt.Errorf("path '%s': %w", path.name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func exportZIPPath(
+	w io.Writer,
+	path *exportPath,
+	start time.Time,
+	duration time.Duration,
+) error {
+	m := &muxerMP4{w: w}
+	defer m.close()
+
+	var opened openedSegments
+	defer opened.close()
+
+	err := seekAndMuxKeepOpen(path.conf.RecordFormat, path.segments, start.Add(path.offset), duration-path.offset,
+		&muxerMP4Path{m: m, offset: path.offset}, &opened)
+	if err != nil {
+		return err
+	}
+
+	return m.flush()
+}
@@ -3,10 +3,12 @@ package playback
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
@@ -17,19 +19,91 @@ import (
 )
 
 type writerWrapper struct {
-	ctx     *gin.Context
-	written bool
+	ctx         *gin.Context
+	contentType string
+	written     bool
 }
 
 func (w *writerWrapper) Write(p []byte) (int, error) {
 	if !w.written {
 		w.written = true
-		w.ctx.Header("Accept-Ranges", "none")
-		w.ctx.Header("Content-Type", "video/mp4")
+		if w.ctx.Writer.Header().Get("Accept-Ranges") == "" {
+			w.ctx.Header("Accept-Ranges", "none")
+		}
+		w.ctx.Header("Content-Type", w.contentType)
 	}
 	return w.ctx.Writer.Write(p)
 }
 
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// parseRange parses the Range header of a request.
+// It returns the start and the end (exclusive) of the range, and whether the range is partial.
+// Multiple ranges are not supported and cause the whole content to be returned.
+func parseRange(header string, size uint64) (uint64, uint64, bool, error) {
+	if header == "" || strings.Contains(header, ",") {
+		return 0, size, false, nil
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+
+	rawStart, rawEnd, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+
+	// suffix range
+	if rawStart == "" {
+		n, err := strconv.ParseUint(rawEnd, 10, 64)
+		if err != nil || n == 0 {
+			return 0, 0, false, errRangeNotSatisfiable
+		}
+		return size - min(n, size), size, true, nil
+	}
+
+	start, err := strconv.ParseUint(rawStart, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+
+	if rawEnd == "" {
+		return start, size, true, nil
+	}
+
+	end, err := strconv.ParseUint(rawEnd, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+
+	return start, min(end+1, size), true, nil
+}
+
+// onSizeMP4 returns a callback that handles Range requests, once the size of the MP4 file is known.
+func onSizeMP4(ctx *gin.Context) func(size uint64) (uint64, uint64, error) {
+	return func(size uint64) (uint64, uint64, error) {
+		ctx.Header("Accept-Ranges", "bytes")
+
+		start, end, partial, err := parseRange(ctx.GetHeader("Range"), size)
+		if err != nil {
+			ctx.Header("Content-Range", "bytes */"+strconv.FormatUint(size, 10))
+			return 0, 0, err
+		}
+
+		if partial {
+			ctx.Header("Content-Range", "bytes "+strconv.FormatUint(start, 10)+"-"+
+				strconv.FormatUint(end-1, 10)+"/"+strconv.FormatUint(size, 10))
+			ctx.Status(http.StatusPartialContent)
+		}
+
+		ctx.Header("Content-Length", strconv.FormatUint(end-start, 10))
+
+		return start, end, nil
+	}
+}
+
 func parseDuration(raw string) (time.Duration, error) {
 	// seconds
 	if secs, err := strconv.ParseFloat(raw, 64); err == nil {
@@ -40,6 +114,25 @@ func parseDuration(raw string) (time.Duration, error) {
 	return time.ParseDuration(raw)
 }
 
+// openedSegments is a list of open segments.
+type openedSegments []recordstore.SegmentReader
+
+func (o *openedSegments) open(seg *recordstore.Segment) (recordstore.SegmentReader, error) {
+	f, err := seg.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	*o = append(*o, f)
+	return f, nil
+}
+
+func (o openedSegments) close() {
+	for _, f := range o {
+		f.Close()
+	}
+}
+
 func seekAndMux(
 	recordFormat conf.RecordFormat,
 	segments []*recordstore.Segment,
@@ -47,69 +140,83 @@ func seekAndMux(
 	duration time.Duration,
 	m muxer,
 ) error {
-	if recordFormat == conf.RecordFormatFMP4 {
-		var firstInit *fmp4.Init
-		var segmentEnd time.Time
+	var opened openedSegments
+	defer opened.close()
+
+	return seekAndMuxKeepOpen(recordFormat, segments, start, duration, m, &opened)
+}
+
+// seekAndMuxKeepOpen is like seekAndMux, but segments are kept open after returning,
+// in order to allow muxers to read payloads after that.
+func seekAndMuxKeepOpen(
+	recordFormat conf.RecordFormat,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+	m muxer,
+	opened *openedSegments,
+) error {
+	sf := segmentFormatFromRecordFormat(recordFormat)
+
+	f, err := opened.open(segments[0])
+	if err != nil {
+		return err
+	}
 
-		f, err := os.Open(segments[0].Fpath)
+	firstInit, err := sf.readInit(f)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	m.writeInit(firstInit)
+
+	segmentStartOffset := start.Sub(segments[0].Start)
+
+	segmentMaxElapsed, err := sf.mux(f, true, segmentStartOffset, duration, firstInit, m)
+	if err != nil {
+		return err
+	}
+
+	segmentEnd := start.Add(segmentMaxElapsed)
+
+	for _, seg := range segments[1:] {
+		f, err = opened.open(seg)
 		if err != nil {
 			return err
 		}
-		defer f.Close()
 
-		firstInit, err = segmentFMP4ReadInit(f)
+		var init *fmp4.Init
+		init, err = sf.readInit(f)
 		if err != nil {
 			return err
 		}
 
-		m.writeInit(firstInit)
-
-		segmentStartOffset := start.Sub(segments[0].Start)
+		if !segmentFMP4CanBeConcatenated(firstInit, segmentEnd, init, seg.Start) {
+			break
+		}
 
-		segmentMaxElapsed, err := segmentFMP4SeekAndMuxParts(f, segmentStartOffset, duration, firstInit, m)
+		_, err = f.Seek(0, io.SeekStart)
 		if err != nil {
 			return err
 		}
 
-		segmentEnd = start.Add(segmentMaxElapsed)
-
-		for _, seg := range segments[1:] {
-			f, err = os.Open(seg.Fpath)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			var init *fmp4.Init
-			init, err = segmentFMP4ReadInit(f)
-			if err != nil {
-				return err
-			}
-
-			if !segmentFMP4CanBeConcatenated(firstInit, segmentEnd, init, seg.Start) {
-				break
-			}
-
-			segmentStartOffset := seg.Start.Sub(start)
+		segmentStartOffset := seg.Start.Sub(start)
 
-			var segmentMaxElapsed time.Duration
-			segmentMaxElapsed, err = segmentFMP4MuxParts(f, segmentStartOffset, duration, firstInit, m)
-			if err != nil {
-				return err
-			}
-
-			segmentEnd = start.Add(segmentMaxElapsed)
-		}
-
-		err = m.flush()
+		var segmentMaxElapsed time.Duration
+		segmentMaxElapsed, err = sf.mux(f, false, segmentStartOffset, duration, firstInit, m)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		segmentEnd = start.Add(segmentMaxElapsed)
 	}
 
-	return fmt.Errorf("MPEG-TS format is not supported yet")
+	return m.flush()
 }
 
 func (s *Server) onGet(ctx *gin.Context) {
@@ -131,7 +238,32 @@ func (s *Server) onGet(ctx *gin.Context) {
 		return
 	}
 
-	ww := &writerWrapper{ctx: ctx}
+	speed := float64(1)
+	if raw := ctx.Query("speed"); raw != "" {
+		speed, err = strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(speed) || speed < 1 || speed > speedMax {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid speed: %s", raw))
+			return
+		}
+	}
+
+	var mode speedMode
+	switch raw := ctx.Query("speedMode"); raw {
+	case "", "keyframes":
+		mode = speedModeKeyframes
+
+	case "gops":
+		mode = speedModeGOPs
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid speed mode: %s", raw))
+		return
+	}
+
+	ww := &writerWrapper{
+		ctx:         ctx,
+		contentType: "video/mp4",
+	}
 	var m muxer
 
 	format := ctx.Query("format")
@@ -140,20 +272,43 @@ func (s *Server) onGet(ctx *gin.Context) {
 		m = &muxerFMP4{w: ww}
 
 	case "mp4":
-		m = &muxerMP4{w: ww}
+		mp4 := &muxerMP4{
+			w:      ww,
+			onSize: onSizeMP4(ctx),
+		}
+		defer mp4.close()
+		m = mp4
+
+	case "ts":
+		ww.contentType = "video/MP2T"
+		m = &muxerMPEGTS{w: ww}
+
+	case "hls":
+		if speed != 1 {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("speed is not supported by the HLS format"))
+			return
+		}
 
 	default:
 		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
 		return
 	}
 
+	if speed != 1 {
+		m = &muxerSpeed{
+			m:     m,
+			speed: speed,
+			mode:  mode,
+		}
+	}
+
 	pathConf, err := s.safeFindPathConf(pathName)
 	if err != nil {
 		s.writeError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	segments, err := recordstore.FindSegmentsInTimespan(pathConf, pathName, start, duration)
+	segments, err := recordstore.FindSegmentsInTimespan(pathConf, pathName, start, duration, s.RecordIndex)
 	if err != nil {
 		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
 			s.writeError(ctx, http.StatusNotFound, err)
@@ -163,6 +318,11 @@ func (s *Server) onGet(ctx *gin.Context) {
 		return
 	}
 
+	if format == "hls" {
+		s.onGetHLS(ctx, pathConf, segments, start, duration)
+		return
+	}
+
 	err = seekAndMux(pathConf.RecordFormat, segments, start, duration, m)
 	if err != nil {
 		// user aborted the download
@@ -173,9 +333,12 @@ func (s *Server) onGet(ctx *gin.Context) {
 
 		// nothing has been written yet; send back JSON
 		if !ww.written {
-			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			switch {
+			case errors.Is(err, errRangeNotSatisfiable):
+				s.writeError(ctx, http.StatusRequestedRangeNotSatisfiable, err)
+			case errors.Is(err, recordstore.ErrNoSegmentsFound):
 				s.writeError(ctx, http.StatusNotFound, err)
-			} else {
+			default:
 				s.writeError(ctx, http.StatusBadRequest, err)
 			}
 			return
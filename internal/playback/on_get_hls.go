@@ -0,0 +1,314 @@
+package playback
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/gohlslib/v2/pkg/playlist"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	hlsSegmentMinDuration = 2 * time.Second
+)
+
+func segmentURL(ctx *gin.Context, seg *recordstore.Segment) string {
+	// keep additional query parameters, like credentials
+	v := ctx.Request.URL.Query()
+	v.Del("duration")
+	v.Del("format")
+	v.Set("start", seg.Start.Format(time.RFC3339Nano))
+
+	u := &url.URL{
+		Path:     "segment",
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// trimmedSegmentURL returns the URL of a part of a segment, remuxed into MPEG-TS.
+func trimmedSegmentURL(ctx *gin.Context, start time.Time, duration time.Duration) string {
+	// keep additional query parameters, like credentials
+	v := ctx.Request.URL.Query()
+	v.Set("start", start.Format(time.RFC3339Nano))
+	v.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	v.Set("format", "ts")
+
+	u := &url.URL{
+		Path:     "get",
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+func generateHLSPlaylistFMP4(
+	ctx *gin.Context,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+) (*playlist.Media, error) {
+	end := start.Add(duration)
+	pl := &playlist.Media{}
+	var firstInit *fmp4.Init
+	var prevEnd time.Time
+
+	for _, seg := range segments {
+		stop, err := func() (bool, error) {
+			f, err := seg.Open()
+			if err != nil {
+				return false, err
+			}
+			defer f.Close()
+
+			init, err := segmentFMP4ReadInit(f)
+			if err != nil {
+				return false, err
+			}
+
+			if firstInit == nil {
+				firstInit = init
+			} else if !reflect.DeepEqual(firstInit, init) {
+				// a playlist can contain a single initialization section
+				return true, nil
+			}
+
+			initSize, parts, err := segmentFMP4ReadParts(f, init)
+			if err != nil {
+				return false, err
+			}
+
+			// segments are continuous when they can be concatenated to the previous one.
+			continuous := len(pl.Segments) == 0 ||
+				segmentFMP4CanBeConcatenated(firstInit, prevEnd, init, seg.Start)
+
+			if len(parts) != 0 {
+				last := parts[len(parts)-1]
+				prevEnd = seg.Start.Add(last.start + last.duration)
+			}
+
+			// remove parts outside of the requested timespan,
+			// but keep the GOP of the first frame.
+			firstPart := -1
+			lastPart := -1
+
+			for j, part := range parts {
+				partStart := seg.Start.Add(part.start)
+				partEnd := partStart.Add(part.duration)
+
+				if firstPart < 0 && partEnd.After(start) {
+					firstPart = j
+				}
+				if partStart.Before(end) {
+					lastPart = j
+				}
+			}
+
+			if firstPart < 0 || lastPart < firstPart {
+				return false, nil
+			}
+
+			for firstPart > 0 && !parts[firstPart].randomAccess {
+				firstPart--
+			}
+
+			uri := segmentURL(ctx, seg)
+
+			if pl.Map == nil {
+				v := uint64(0)
+				pl.Map = &playlist.MediaMap{
+					URI:             uri,
+					ByteRangeLength: &initSize,
+					ByteRangeStart:  &v,
+				}
+			}
+
+			var cur *playlist.MediaSegment
+			var curFirst *segmentFMP4Part
+
+			for _, part := range parts[firstPart : lastPart+1] {
+				if part.duration == 0 {
+					continue
+				}
+
+				if cur == nil ||
+					(part.randomAccess && (part.start-curFirst.start) >= hlsSegmentMinDuration) ||
+					*cur.ByteRangeStart+*cur.ByteRangeLength != part.offset {
+					dateTime := seg.Start.Add(part.start)
+					byteRangeLength := part.size
+					byteRangeStart := part.offset
+
+					cur = &playlist.MediaSegment{
+						URI:             uri,
+						DateTime:        &dateTime,
+						ByteRangeLength: &byteRangeLength,
+						ByteRangeStart:  &byteRangeStart,
+						Discontinuity:   curFirst == nil && !continuous,
+					}
+					curFirst = part
+					pl.Segments = append(pl.Segments, cur)
+				} else {
+					*cur.ByteRangeLength += part.size
+				}
+
+				cur.Duration = part.start + part.duration - curFirst.start
+			}
+
+			return false, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		if stop {
+			break
+		}
+	}
+
+	return pl, nil
+}
+
+func generateHLSPlaylistMPEGTS(
+	ctx *gin.Context,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+) (*playlist.Media, error) {
+	end := start.Add(duration)
+	pl := &playlist.Media{}
+	var prevInit *fmp4.Init
+	var prevEnd time.Time
+	prevTrimmed := false
+
+	for _, seg := range segments {
+		err := func() error {
+			f, err := seg.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			init, err := segmentMPEGTSReadInit(f)
+			if err != nil {
+				return err
+			}
+
+			_, err = f.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+
+			maxDuration, err := segmentMPEGTSReadMaxDuration(f, nil)
+			if err != nil {
+				return err
+			}
+
+			segEnd := seg.Start.Add(maxDuration)
+
+			if maxDuration <= 0 || !segEnd.After(start) || !seg.Start.Before(end) {
+				return nil
+			}
+
+			// segments that exceed the requested timespan are trimmed by remuxing them.
+			// Since timestamps of remuxed segments are different from the original ones,
+			// a discontinuity is inserted before and after them.
+			segStart := seg.Start
+			uri := segmentURL(ctx, seg)
+			trimmed := false
+
+			if segStart.Before(start) {
+				segStart = start
+				trimmed = true
+			}
+			if segEnd.After(end) {
+				segEnd = end
+				trimmed = true
+			}
+			if trimmed {
+				uri = trimmedSegmentURL(ctx, segStart, segEnd.Sub(segStart))
+			}
+
+			pl.Segments = append(pl.Segments, &playlist.MediaSegment{
+				URI:      uri,
+				DateTime: &segStart,
+				Duration: segEnd.Sub(segStart),
+				Discontinuity: len(pl.Segments) != 0 &&
+					(trimmed || prevTrimmed ||
+						!segmentFMP4CanBeConcatenated(prevInit, prevEnd, init, seg.Start)),
+			})
+
+			prevInit = init
+			prevEnd = seg.Start.Add(maxDuration)
+			prevTrimmed = trimmed
+
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pl, nil
+}
+
+func (s *Server) onGetHLS(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+) {
+	var pl *playlist.Media
+	var err error
+
+	switch pathConf.RecordFormat {
+	case conf.RecordFormatFMP4:
+		pl, err = generateHLSPlaylistFMP4(ctx, segments, start, duration)
+
+	case conf.RecordFormatMPEGTS:
+		pl, err = generateHLSPlaylistMPEGTS(ctx, segments, start, duration)
+
+	default:
+		// segments are served as they are, therefore they must be in a format supported by HLS.
+		err = fmt.Errorf("HLS is not supported with the Matroska and WebM record formats")
+	}
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(pl.Segments) == 0 {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	targetDuration := 0
+	for _, seg := range pl.Segments {
+		v := int(math.Ceil(seg.Duration.Seconds()))
+		if v > targetDuration {
+			targetDuration = v
+		}
+	}
+
+	playlistType := playlist.MediaPlaylistType(playlist.MediaPlaylistTypeVOD)
+	pl.Version = 6
+	pl.TargetDuration = targetDuration
+	pl.PlaylistType = &playlistType
+	pl.Endlist = true
+
+	byts, err := pl.Marshal()
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "max-age=30")
+	ctx.Data(http.StatusOK, "application/vnd.apple.mpegurl", byts)
+}
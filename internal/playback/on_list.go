@@ -3,11 +3,9 @@ package playback
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"time"
 
@@ -24,70 +22,90 @@ func (d listEntryDuration) MarshalJSON() ([]byte, error) {
 }
 
 type listEntry struct {
-	Start    time.Time         `json:"start"`
-	Duration listEntryDuration `json:"duration"`
-	URL      string            `json:"url"`
+	Start    time.Time                    `json:"start"`
+	Duration listEntryDuration            `json:"duration"`
+	URL      string                       `json:"url"`
+	Markers  []*recordstore.SegmentMarker `json:"markers,omitempty"`
+}
+
+// addMarkers adds markers of segments to the entries that contain them.
+func addMarkers(entries []listEntry, segments []*recordstore.Segment) {
+	for _, seg := range segments {
+		md, err := seg.ReadMetadata()
+		if err != nil || len(md.Markers) == 0 {
+			continue
+		}
+
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !seg.Start.Before(entries[i].Start) {
+				entries[i].Markers = append(entries[i].Markers, md.Markers...)
+				break
+			}
+		}
+	}
 }
 
 func computeDurationAndConcatenate(
 	recordFormat conf.RecordFormat,
 	segments []*recordstore.Segment,
 ) ([]listEntry, error) {
-	if recordFormat == conf.RecordFormatFMP4 {
-		out := []listEntry{}
-		var prevInit *fmp4.Init
+	sf := segmentFormatFromRecordFormat(recordFormat)
+	out := []listEntry{}
+	var prevInit *fmp4.Init
 
-		for _, seg := range segments {
-			err := func() error {
-				f, err := os.Open(seg.Fpath)
-				if err != nil {
-					return err
-				}
-				defer f.Close()
+	for _, seg := range segments {
+		err := func() error {
+			f, err := seg.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
 
-				init, err := segmentFMP4ReadInit(f)
-				if err != nil {
-					return err
-				}
+			init, err := sf.readInit(f)
+			if err != nil {
+				return err
+			}
+
+			// durations stored into the index are used in order to avoid reading whole segments
+			maxDuration := seg.Duration
 
+			if maxDuration == 0 {
 				_, err = f.Seek(0, io.SeekStart)
 				if err != nil {
 					return err
 				}
 
-				maxDuration, err := segmentFMP4ReadMaxDuration(f, init)
+				maxDuration, err = sf.readMaxDuration(f, init)
 				if err != nil {
 					return err
 				}
+			}
 
-				if len(out) != 0 && segmentFMP4CanBeConcatenated(
-					prevInit,
-					out[len(out)-1].Start.Add(time.Duration(out[len(out)-1].Duration)),
-					init,
-					seg.Start) {
-					prevStart := out[len(out)-1].Start
-					curEnd := seg.Start.Add(maxDuration)
-					out[len(out)-1].Duration = listEntryDuration(curEnd.Sub(prevStart))
-				} else {
-					out = append(out, listEntry{
-						Start:    seg.Start,
-						Duration: listEntryDuration(maxDuration),
-					})
-				}
+			if len(out) != 0 && segmentFMP4CanBeConcatenated(
+				prevInit,
+				out[len(out)-1].Start.Add(time.Duration(out[len(out)-1].Duration)),
+				init,
+				seg.Start) {
+				prevStart := out[len(out)-1].Start
+				curEnd := seg.Start.Add(maxDuration)
+				out[len(out)-1].Duration = listEntryDuration(curEnd.Sub(prevStart))
+			} else {
+				out = append(out, listEntry{
+					Start:    seg.Start,
+					Duration: listEntryDuration(maxDuration),
+				})
+			}
 
-				prevInit = init
+			prevInit = init
 
-				return nil
-			}()
-			if err != nil {
-				return nil, err
-			}
+			return nil
+		}()
+		if err != nil {
+			return nil, err
 		}
-
-		return out, nil
 	}
 
-	return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	return out, nil
 }
 
 func (s *Server) onList(ctx *gin.Context) {
@@ -103,7 +121,7 @@ func (s *Server) onList(ctx *gin.Context) {
 		return
 	}
 
-	segments, err := recordstore.FindSegments(pathConf, pathName)
+	segments, err := recordstore.FindSegments(pathConf, pathName, s.RecordIndex)
 	if err != nil {
 		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
 			s.writeError(ctx, http.StatusNotFound, err)
@@ -119,6 +137,8 @@ func (s *Server) onList(ctx *gin.Context) {
 		return
 	}
 
+	addMarkers(entries, segments)
+
 	var scheme string
 	if s.Encryption {
 		scheme = "https"
@@ -0,0 +1,94 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// onSegment serves a raw recording segment. It is used by HLS playlists,
+// that point to byte ranges of segments.
+func (s *Server) onSegment(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339Nano, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, s.RecordIndex)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	var segment *recordstore.Segment
+
+	for _, seg := range segments {
+		if seg.Start.Equal(start) {
+			segment = seg
+			break
+		}
+	}
+
+	if segment == nil {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	f, err := segment.Open()
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	var modTime time.Time
+
+	if fi, ok := f.(*os.File); ok {
+		st, err := fi.Stat()
+		if err != nil {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		modTime = st.ModTime()
+	}
+
+	switch pathConf.RecordFormat {
+	case conf.RecordFormatFMP4:
+		ctx.Header("Content-Type", "video/mp4")
+
+	case conf.RecordFormatMatroska:
+		ctx.Header("Content-Type", "video/x-matroska")
+
+	case conf.RecordFormatWebM:
+		ctx.Header("Content-Type", "video/webm")
+
+	default:
+		ctx.Header("Content-Type", "video/MP2T")
+	}
+
+	// Range requests are handled by ServeContent
+	http.ServeContent(ctx.Writer, ctx.Request, "", modTime, f)
+}
@@ -0,0 +1,440 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	timelineMaxBuckets = 10000
+)
+
+type timelineInterval string
+
+const (
+	timelineIntervalHour timelineInterval = "hour"
+	timelineIntervalDay  timelineInterval = "day"
+)
+
+func (i timelineInterval) bucketStart(t time.Time) time.Time {
+	if i == timelineIntervalHour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func (i timelineInterval) next(t time.Time) time.Time {
+	if i == timelineIntervalHour {
+		return t.Add(time.Hour)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+type timelineGapReason string
+
+const (
+	// other paths were being recorded during the gap, therefore the server was running.
+	timelineGapReasonSourceOffline timelineGapReason = "sourceOffline"
+
+	// no path was being recorded during the gap, therefore the server was probably down.
+	timelineGapReasonServerDown timelineGapReason = "serverDown"
+
+	// there are no other recorded paths that can be used as a reference.
+	timelineGapReasonUnknown timelineGapReason = "unknown"
+)
+
+type timelineSpan struct {
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Duration listEntryDuration `json:"duration"`
+}
+
+type timelineGap struct {
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Duration listEntryDuration `json:"duration"`
+	Reason   timelineGapReason `json:"reason,omitempty"`
+}
+
+type timelineCoverage struct {
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Recorded listEntryDuration `json:"recorded"`
+	Ratio    float64           `json:"ratio"`
+}
+
+type timelinePath struct {
+	Name     string             `json:"name"`
+	Recorded listEntryDuration  `json:"recorded"`
+	Missing  listEntryDuration  `json:"missing"`
+	Ratio    float64            `json:"ratio"`
+	Spans    []timelineSpan     `json:"spans"`
+	Gaps     []timelineGap      `json:"gaps"`
+	Coverage []timelineCoverage `json:"coverage"`
+}
+
+type timeline struct {
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Interval timelineInterval  `json:"interval"`
+	Recorded listEntryDuration `json:"recorded"`
+	Missing  listEntryDuration `json:"missing"`
+	Ratio    float64           `json:"ratio"`
+	Paths    []*timelinePath   `json:"paths"`
+}
+
+func durationRatio(a time.Duration, b time.Duration) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// timelineOverlap returns the portion of [start, end) that is covered by spans.
+func timelineOverlap(spans []timelineSpan, start time.Time, end time.Time) time.Duration {
+	var out time.Duration
+
+	for _, span := range spans {
+		s := span.Start
+		if s.Before(start) {
+			s = start
+		}
+		e := span.End
+		if e.After(end) {
+			e = end
+		}
+		if e.After(s) {
+			out += e.Sub(s)
+		}
+	}
+
+	return out
+}
+
+// timelineFindSpans returns recorded spans of a path, clipped to [start, end).
+func timelineFindSpans(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	end time.Time,
+	index *recordstore.Index,
+) ([]timelineSpan, error) {
+	segments, err := recordstore.FindSegments(pathConf, pathName, index)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return []timelineSpan{}, nil
+		}
+		return nil, err
+	}
+
+	// keep segments that may overlap the window,
+	// that are the last one that starts before the window and the ones that start inside it.
+	var filtered []*recordstore.Segment
+	for _, seg := range segments {
+		if !seg.Start.Before(end) {
+			break
+		}
+		if !seg.Start.After(start) {
+			filtered = filtered[:0]
+		}
+		filtered = append(filtered, seg)
+	}
+
+	entries, err := computeDurationAndConcatenate(pathConf.RecordFormat, filtered)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []timelineSpan{}
+
+	for _, entry := range entries {
+		s := entry.Start
+		if s.Before(start) {
+			s = start
+		}
+		e := entry.Start.Add(time.Duration(entry.Duration))
+		if e.After(end) {
+			e = end
+		}
+		if e.After(s) {
+			out = append(out, timelineSpan{
+				Start:    s,
+				End:      e,
+				Duration: listEntryDuration(e.Sub(s)),
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// timelineFindGaps returns the portions of [start, end) that are not covered by spans.
+// When otherSpans is not nil, the reason of each gap is guessed by comparing it with spans of other paths:
+// if at least another path was being recorded, the server was running and the source was offline.
+func timelineFindGaps(
+	spans []timelineSpan,
+	otherSpans [][]timelineSpan,
+	start time.Time,
+	end time.Time,
+) []timelineGap {
+	withReasons := otherSpans != nil
+
+	othersRecorded := false
+	for _, other := range otherSpans {
+		if len(other) != 0 {
+			othersRecorded = true
+			break
+		}
+	}
+
+	out := []timelineGap{}
+
+	addGap := func(gs time.Time, ge time.Time) {
+		if !ge.After(gs) {
+			return
+		}
+
+		var reason timelineGapReason
+
+		if withReasons {
+			reason = timelineGapReasonUnknown
+
+			if othersRecorded {
+				reason = timelineGapReasonServerDown
+				for _, other := range otherSpans {
+					if timelineOverlap(other, gs, ge) > 0 {
+						reason = timelineGapReasonSourceOffline
+						break
+					}
+				}
+			}
+		}
+
+		out = append(out, timelineGap{
+			Start:    gs,
+			End:      ge,
+			Duration: listEntryDuration(ge.Sub(gs)),
+			Reason:   reason,
+		})
+	}
+
+	cur := start
+	for _, span := range spans {
+		addGap(cur, span.Start)
+		cur = span.End
+	}
+	addGap(cur, end)
+
+	return out
+}
+
+func timelineComputeCoverage(
+	spans []timelineSpan,
+	interval timelineInterval,
+	start time.Time,
+	end time.Time,
+) []timelineCoverage {
+	out := []timelineCoverage{}
+
+	for t := interval.bucketStart(start); t.Before(end); t = interval.next(t) {
+		bs := t
+		if bs.Before(start) {
+			bs = start
+		}
+		be := interval.next(t)
+		if be.After(end) {
+			be = end
+		}
+
+		recorded := timelineOverlap(spans, bs, be)
+
+		out = append(out, timelineCoverage{
+			Start:    bs,
+			End:      be,
+			Recorded: listEntryDuration(recorded),
+			Ratio:    durationRatio(recorded, be.Sub(bs)),
+		})
+	}
+
+	return out
+}
+
+func (s *Server) safeFindAllPathsWithSegments() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return recordstore.FindAllPathsWithSegments(s.PathConfs, s.RecordIndex)
+}
+
+func (s *Server) findReferenceSpans(pathName string, start time.Time, end time.Time) ([]timelineSpan, error) {
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	return timelineFindSpans(pathConf, pathName, start, end, s.RecordIndex)
+}
+
+func (s *Server) onTimeline(ctx *gin.Context) {
+	pathNames := ctx.QueryArray("path")
+	if len(pathNames) == 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("missing 'path' parameter"))
+		return
+	}
+
+	for _, pathName := range pathNames {
+		if !s.doAuth(ctx, pathName) {
+			return
+		}
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("'end' must be after 'start'"))
+		return
+	}
+
+	// the future cannot contain recordings nor gaps
+	if now := time.Now().In(end.Location()); end.After(now) {
+		end = now
+		if !end.After(start) {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("'start' is in the future"))
+			return
+		}
+	}
+
+	interval := timelineInterval(ctx.Query("interval"))
+	switch interval {
+	case "":
+		interval = timelineIntervalDay
+
+	case timelineIntervalHour, timelineIntervalDay:
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'interval' parameter"))
+		return
+	}
+
+	gapReasons := false
+	if raw := ctx.Query("gapReasons"); raw != "" {
+		gapReasons, err = strconv.ParseBool(raw)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'gapReasons' parameter"))
+			return
+		}
+	}
+
+	bucketCount := 0
+	for t := interval.bucketStart(start); t.Before(end); t = interval.next(t) {
+		bucketCount++
+		if bucketCount > timelineMaxBuckets {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("time window is too large for the chosen interval"))
+			return
+		}
+	}
+
+	allSpans := make(map[string][]timelineSpan, len(pathNames))
+
+	for _, pathName := range pathNames {
+		var pathConf *conf.Path
+		pathConf, err = s.safeFindPathConf(pathName)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		var spans []timelineSpan
+		spans, err = timelineFindSpans(pathConf, pathName, start, end, s.RecordIndex)
+		if err != nil {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		allSpans[pathName] = spans
+	}
+
+	// spans of other recorded paths are needed to guess the reason of gaps.
+	// Only paths that the client is allowed to read are used, and the ones that can't be read are skipped.
+	if gapReasons {
+		for _, pathName := range s.safeFindAllPathsWithSegments() {
+			if _, ok := allSpans[pathName]; ok {
+				continue
+			}
+
+			if s.isAuthorized(ctx, pathName) != nil {
+				continue
+			}
+
+			spans, err2 := s.findReferenceSpans(pathName, start, end)
+			if err2 != nil {
+				s.Log(logger.Warn, "unable to use path '%s' as timeline reference: %v", pathName, err2)
+				continue
+			}
+
+			allSpans[pathName] = spans
+		}
+	}
+
+	out := &timeline{
+		Start:    start,
+		End:      end,
+		Interval: interval,
+		Paths:    make([]*timelinePath, len(pathNames)),
+	}
+
+	windowDuration := end.Sub(start)
+	var totalRecorded time.Duration
+
+	for i, pathName := range pathNames {
+		spans := allSpans[pathName]
+
+		var otherSpans [][]timelineSpan
+		if gapReasons {
+			otherSpans = [][]timelineSpan{}
+			for otherName, other := range allSpans {
+				if otherName != pathName {
+					otherSpans = append(otherSpans, other)
+				}
+			}
+		}
+
+		recorded := timelineOverlap(spans, start, end)
+		totalRecorded += recorded
+
+		out.Paths[i] = &timelinePath{
+			Name:     pathName,
+			Recorded: listEntryDuration(recorded),
+			Missing:  listEntryDuration(windowDuration - recorded),
+			Ratio:    durationRatio(recorded, windowDuration),
+			Spans:    spans,
+			Gaps:     timelineFindGaps(spans, otherSpans, start, end),
+			Coverage: timelineComputeCoverage(spans, interval, start, end),
+		}
+	}
+
+	totalDuration := windowDuration * time.Duration(len(pathNames))
+	out.Recorded = listEntryDuration(totalRecorded)
+	out.Missing = listEntryDuration(totalDuration - totalRecorded)
+	out.Ratio = durationRatio(totalRecorded, totalDuration)
+
+	ctx.JSON(http.StatusOK, out)
+}
@@ -171,6 +171,17 @@ func segmentFMP4ReadMaxDuration(
 			break
 		}
 
+		// skip emsg boxes, that contain markers
+		if bytes.Equal(buf[4:], []byte{'e', 'm', 's', 'g'}) {
+			emsgSize := uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
+
+			_, err = r.Seek(int64(emsgSize)-8, io.SeekCurrent)
+			if err != nil {
+				break
+			}
+			continue
+		}
+
 		if !bytes.Equal(buf[4:], []byte{'m', 'o', 'o', 'f'}) {
 			return 0, fmt.Errorf("moof box not found")
 		}
@@ -473,6 +484,23 @@ func segmentFMP4SeekAndMuxParts(
 	return maxMuxerDTS, nil
 }
 
+// segmentFMP4Mux writes parts of a fMP4 segment to the muxer.
+// When seek is true, parts that end before segmentStartOffset are skipped
+// and an error is returned if there are no parts after it.
+func segmentFMP4Mux(
+	r readSeekerAt,
+	seek bool,
+	segmentStartOffset time.Duration,
+	duration time.Duration,
+	init *fmp4.Init,
+	m muxer,
+) (time.Duration, error) {
+	if seek {
+		return segmentFMP4SeekAndMuxParts(r, segmentStartOffset, duration, init, m)
+	}
+	return segmentFMP4MuxParts(r, segmentStartOffset, duration, init, m)
+}
+
 func segmentFMP4MuxParts(
 	r readSeekerAt,
 	segmentStartOffset time.Duration,
@@ -592,3 +620,135 @@ func segmentFMP4MuxParts(
 
 	return maxMuxerDTS, nil
 }
+
+type segmentFMP4Part struct {
+	offset       uint64
+	size         uint64
+	start        time.Duration
+	duration     time.Duration
+	randomAccess bool
+}
+
+// segmentFMP4ReadParts returns the size of the initialization section
+// and the position, timing and random access status of every complete part.
+func segmentFMP4ReadParts(
+	r io.ReadSeeker,
+	init *fmp4.Init,
+) (uint64, []*segmentFMP4Part, error) {
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	hasVideo := false
+	for _, track := range init.Tracks {
+		if track.Codec.IsVideo() {
+			hasVideo = true
+			break
+		}
+	}
+
+	var initSize uint64
+	var parts []*segmentFMP4Part
+	var curPart *segmentFMP4Part
+	var curPartEnd time.Duration
+	var tfhd *mp4.Tfhd
+	var tfdt *mp4.Tfdt
+
+	_, err = mp4.ReadBoxStructure(r, func(h *mp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type.String() {
+		case "moov":
+			initSize = h.BoxInfo.Offset + h.BoxInfo.Size
+
+		case "moof":
+			curPart = &segmentFMP4Part{
+				offset:       h.BoxInfo.Offset,
+				start:        -1,
+				randomAccess: !hasVideo,
+			}
+			curPartEnd = 0
+			return h.Expand()
+
+		case "traf":
+			return h.Expand()
+
+		case "tfhd":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfhd = box.(*mp4.Tfhd)
+
+		case "tfdt":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfdt = box.(*mp4.Tfdt)
+
+		case "trun":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			trun := box.(*mp4.Trun)
+
+			track := findInitTrack(init.Tracks, int(tfhd.TrackID))
+			if track == nil {
+				return nil, fmt.Errorf("invalid track ID: %v", tfhd.TrackID)
+			}
+
+			elapsed := int64(tfdt.BaseMediaDecodeTimeV1)
+			start := durationMp4ToGo(elapsed, track.TimeScale)
+
+			if curPart.start < 0 || start < curPart.start {
+				curPart.start = start
+			}
+
+			for _, e := range trun.Entries {
+				elapsed += int64(e.SampleDuration)
+			}
+
+			end := durationMp4ToGo(elapsed, track.TimeScale)
+			if end > curPartEnd {
+				curPartEnd = end
+			}
+
+			if track.Codec.IsVideo() && len(trun.Entries) != 0 &&
+				(trun.Entries[0].SampleFlags&sampleFlagIsNonSyncSample) == 0 {
+				curPart.randomAccess = true
+			}
+
+		case "mdat":
+			if curPart == nil {
+				return nil, fmt.Errorf("mdat box without moof")
+			}
+
+			end := h.BoxInfo.Offset + h.BoxInfo.Size
+
+			// skip incomplete parts
+			if end <= uint64(fileSize) && curPart.start >= 0 {
+				curPart.size = end - curPart.offset
+				curPart.duration = curPartEnd - curPart.start
+				parts = append(parts, curPart)
+			}
+
+			curPart = nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if initSize == 0 {
+		return 0, nil, fmt.Errorf("moov box not found")
+	}
+
+	return initSize, parts, nil
+}
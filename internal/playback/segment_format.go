@@ -0,0 +1,49 @@
+package playback
+
+import (
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// segmentFormat contains functions that read segments of a record format.
+type segmentFormat struct {
+	readInit        func(r io.ReadSeeker) (*fmp4.Init, error)
+	readMaxDuration func(r io.ReadSeeker, init *fmp4.Init) (time.Duration, error)
+	mux             func(
+		r readSeekerAt,
+		seek bool,
+		segmentStartOffset time.Duration,
+		duration time.Duration,
+		init *fmp4.Init,
+		m muxer,
+	) (time.Duration, error)
+}
+
+func segmentFormatFromRecordFormat(recordFormat conf.RecordFormat) *segmentFormat {
+	switch recordFormat {
+	case conf.RecordFormatFMP4:
+		return &segmentFormat{
+			readInit:        segmentFMP4ReadInit,
+			readMaxDuration: segmentFMP4ReadMaxDuration,
+			mux:             segmentFMP4Mux,
+		}
+
+	case conf.RecordFormatMatroska, conf.RecordFormatWebM:
+		return &segmentFormat{
+			readInit:        segmentMatroskaReadInit,
+			readMaxDuration: segmentMatroskaReadMaxDuration,
+			mux:             segmentMatroskaMux,
+		}
+
+	default:
+		return &segmentFormat{
+			readInit:        segmentMPEGTSReadInit,
+			readMaxDuration: segmentMPEGTSReadMaxDuration,
+			mux:             segmentMPEGTSMux,
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/matroska"
+)
+
+const (
+	matroskaVideoTimeScale = 90000
+)
+
+type segmentMatroskaTrack struct {
+	id        int
+	timeScale uint32
+	codec     fmp4.Codec
+
+	h264DTSExtractor *h264.DTSExtractor2
+	h265DTSExtractor *h265.DTSExtractor2
+}
+
+// dts computes the DTS of a sample.
+// Matroska stores PTS only, therefore DTS is extracted from the bitstream
+// of codecs that support B-frames.
+func (t *segmentMatroskaTrack) dts(pts int64, payload []byte) (int64, error) {
+	switch codec := t.codec.(type) {
+	case *fmp4.CodecH264:
+		au, err := h264.AVCCUnmarshal(payload)
+		if err != nil {
+			return 0, err
+		}
+
+		if t.h264DTSExtractor == nil {
+			t.h264DTSExtractor = h264.NewDTSExtractor2()
+			au = append([][]byte{codec.SPS, codec.PPS}, au...)
+		}
+
+		return t.h264DTSExtractor.Extract(au, pts)
+
+	case *fmp4.CodecH265:
+		au, err := h264.AVCCUnmarshal(payload)
+		if err != nil {
+			return 0, err
+		}
+
+		if t.h265DTSExtractor == nil {
+			t.h265DTSExtractor = h265.NewDTSExtractor2()
+			au = append([][]byte{codec.VPS, codec.SPS, codec.PPS}, au...)
+		}
+
+		return t.h265DTSExtractor.Extract(au, pts)
+	}
+
+	return pts, nil
+}
+
+func segmentMatroskaTimeScale(track *matroska.Track, codec fmp4.Codec) uint32 {
+	if codec.IsVideo() {
+		return matroskaVideoTimeScale
+	}
+
+	if _, ok := codec.(*fmp4.CodecOpus); ok {
+		return 48000
+	}
+
+	return uint32(track.SampleRate)
+}
+
+// segmentMatroskaReadTracks reads tracks of a Matroska segment.
+// Tracks that cannot be converted into the fMP4 format are skipped.
+func segmentMatroskaReadTracks(r io.ReadSeeker) (*matroska.Reader, map[int]*segmentMatroskaTrack, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mr := &matroska.Reader{R: r}
+	err = mr.Initialize()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracks := make(map[int]*segmentMatroskaTrack)
+
+	for _, mt := range mr.Tracks {
+		codec, err := mt.FMP4Codec()
+		if err != nil {
+			continue
+		}
+
+		tracks[mt.Number] = &segmentMatroskaTrack{
+			id:        mt.Number,
+			timeScale: segmentMatroskaTimeScale(mt, codec),
+			codec:     codec,
+		}
+	}
+
+	if len(tracks) == 0 {
+		return nil, nil, fmt.Errorf("unable to find parameters of tracks")
+	}
+
+	return mr, tracks, nil
+}
+
+func segmentMatroskaReadInit(r io.ReadSeeker) (*fmp4.Init, error) {
+	mr, tracks, err := segmentMatroskaReadTracks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var init fmp4.Init
+
+	for _, mt := range mr.Tracks {
+		if track, ok := tracks[mt.Number]; ok {
+			init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+				ID:        track.id,
+				TimeScale: track.timeScale,
+				Codec:     track.codec,
+			})
+		}
+	}
+
+	return &init, nil
+}
+
+func segmentMatroskaReadMaxDuration(r io.ReadSeeker, _ *fmp4.Init) (time.Duration, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	mr := &matroska.Reader{R: r}
+	err = mr.Initialize()
+	if err != nil {
+		return 0, err
+	}
+
+	// duration is written when the segment is closed
+	if mr.Duration > 0 {
+		return mr.Duration, nil
+	}
+
+	return mr.MaxTimestamp()
+}
+
+// segmentMatroskaMux writes blocks of a Matroska segment to the muxer.
+// When seek is true, blocks before segmentStartOffset are written with a negative DTS
+// and an error is returned if there are no blocks after it.
+func segmentMatroskaMux(
+	r readSeekerAt,
+	seek bool,
+	segmentStartOffset time.Duration,
+	duration time.Duration,
+	init *fmp4.Init,
+	m muxer,
+) (time.Duration, error) {
+	mr, tracks, err := segmentMatroskaReadTracks(r)
+	if err != nil {
+		return 0, err
+	}
+
+	sm := &segmentSampleMuxer{
+		seek:               seek,
+		segmentStartOffset: segmentStartOffset,
+		duration:           duration,
+		init:               init,
+		m:                  m,
+	}
+	sm.initialize()
+
+	err = mr.ReadBlocks(func(b *matroska.Block) error {
+		track, ok := tracks[b.Track]
+		if !ok {
+			return nil
+		}
+
+		pts := durationGoToMp4(b.Timestamp, track.timeScale)
+
+		dts, err := track.dts(pts, b.Payload)
+		if err != nil {
+			return err
+		}
+
+		return sm.writeSample(track.id, track.timeScale, dts, int32(pts-dts), !b.Keyframe, b.Payload)
+	})
+	if err != nil && !errors.Is(err, errTerminated) {
+		return 0, err
+	}
+
+	return sm.finalize()
+}
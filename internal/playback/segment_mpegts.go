@@ -0,0 +1,568 @@
+package playback
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/mediacommon/pkg/codecs/ac3"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg1audio"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/pkg/codecs/opus"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+)
+
+const (
+	mpegtsPacketSize     = 188
+	mpegtsClockRate      = 90000
+	mpegtsMaxTimestamp   = 0x1FFFFFFFF // 33 bits
+	mpegtsTailScanSize   = 2 * 1024 * 1024
+	mpegtsReadBufferSize = 64 * 1024
+)
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+// mpegtsTimestampDiff returns the difference between two 33-bit MPEG-TS timestamps.
+func mpegtsTimestampDiff(a int64, b int64) int64 {
+	diff := (a - b) & mpegtsMaxTimestamp
+	if diff > mpegtsMaxTimestamp/2 {
+		return diff - mpegtsMaxTimestamp - 1
+	}
+	return diff
+}
+
+func mpeg1audioChannelCount(cm mpeg1audio.ChannelMode) int {
+	switch cm {
+	case mpeg1audio.ChannelModeStereo,
+		mpeg1audio.ChannelModeJointStereo,
+		mpeg1audio.ChannelModeDualChannel:
+		return 2
+
+	default:
+		return 1
+	}
+}
+
+func isMPEGTSEOF(err error) bool {
+	// https://github.com/asticode/go-astits/blob/b0b19247aa31633650c32638fb55f597fa6e2468/packet_buffer.go#L133C1-L133C5
+	return errors.Is(err, astits.ErrNoMorePackets) || strings.Contains(err.Error(), "astits: reading ")
+}
+
+type segmentMPEGTSTrack struct {
+	id        int
+	timeScale uint32
+	codec     fmp4.Codec // nil until codec parameters are found
+}
+
+type segmentMPEGTSOnSampleFunc func(
+	track *segmentMPEGTSTrack,
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payload []byte,
+) error
+
+// segmentMPEGTSReader reads samples of a MPEG-TS segment and converts them into the fMP4 format.
+// DTS are relative to the first DTS of the segment and are expressed in the time scale of the track.
+type segmentMPEGTSReader struct {
+	r        io.Reader
+	onSample segmentMPEGTSOnSampleFunc
+
+	mr          *mpegts.Reader
+	td          *mpegts.TimeDecoder2
+	tracks      []*segmentMPEGTSTrack
+	startDTS    int64
+	startDTSSet bool
+}
+
+func (s *segmentMPEGTSReader) initialize() error {
+	var err error
+	s.mr, err = mpegts.NewReader(bufio.NewReaderSize(s.r, mpegtsReadBufferSize))
+	if err != nil {
+		return err
+	}
+
+	s.td = mpegts.NewTimeDecoder2()
+
+	for i, tsTrack := range s.mr.Tracks() {
+		track := &segmentMPEGTSTrack{
+			id: i + 1,
+		}
+
+		switch tsCodec := tsTrack.Codec.(type) {
+		case *mpegts.CodecH265:
+			track.timeScale = mpegtsClockRate
+
+			s.mr.OnDataH265(tsTrack, func(pts int64, dts int64, au [][]byte) error {
+				if track.codec == nil {
+					var vps, sps, pps []byte
+
+					for _, nalu := range au {
+						switch h265.NALUType((nalu[0] >> 1) & 0b111111) {
+						case h265.NALUType_VPS_NUT:
+							vps = nalu
+						case h265.NALUType_SPS_NUT:
+							sps = nalu
+						case h265.NALUType_PPS_NUT:
+							pps = nalu
+						}
+					}
+
+					if vps != nil && sps != nil && pps != nil {
+						track.codec = &fmp4.CodecH265{
+							VPS: vps,
+							SPS: sps,
+							PPS: pps,
+						}
+					}
+				}
+
+				payload, err := h264.AVCCMarshal(au)
+				if err != nil {
+					return err
+				}
+
+				return s.onSample(
+					track,
+					s.decodeDTS(dts),
+					int32(mpegtsTimestampDiff(pts, dts)),
+					!h265.IsRandomAccess(au),
+					payload)
+			})
+
+		case *mpegts.CodecH264:
+			track.timeScale = mpegtsClockRate
+
+			s.mr.OnDataH264(tsTrack, func(pts int64, dts int64, au [][]byte) error {
+				if track.codec == nil {
+					var sps, pps []byte
+
+					for _, nalu := range au {
+						switch h264.NALUType(nalu[0] & 0x1F) {
+						case h264.NALUTypeSPS:
+							sps = nalu
+						case h264.NALUTypePPS:
+							pps = nalu
+						}
+					}
+
+					if sps != nil && pps != nil {
+						track.codec = &fmp4.CodecH264{
+							SPS: sps,
+							PPS: pps,
+						}
+					}
+				}
+
+				payload, err := h264.AVCCMarshal(au)
+				if err != nil {
+					return err
+				}
+
+				return s.onSample(
+					track,
+					s.decodeDTS(dts),
+					int32(mpegtsTimestampDiff(pts, dts)),
+					!h264.IDRPresent(au),
+					payload)
+			})
+
+		case *mpegts.CodecMPEG4Video:
+			track.timeScale = mpegtsClockRate
+
+			s.mr.OnDataMPEGxVideo(tsTrack, func(pts int64, frame []byte) error {
+				if track.codec == nil &&
+					bytes.HasPrefix(frame, []byte{0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode)}) {
+					end := bytes.Index(frame[4:], []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+					if end >= 0 {
+						track.codec = &fmp4.CodecMPEG4Video{
+							Config: frame[:end+4],
+						}
+					}
+				}
+
+				return s.onSample(
+					track,
+					s.decodeDTS(pts),
+					0,
+					!bytes.Contains(frame, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)}),
+					frame)
+			})
+
+		case *mpegts.CodecMPEG1Video:
+			track.timeScale = mpegtsClockRate
+
+			s.mr.OnDataMPEGxVideo(tsTrack, func(pts int64, frame []byte) error {
+				if track.codec == nil && bytes.HasPrefix(frame, []byte{0, 0, 1, 0xB3}) {
+					end := bytes.Index(frame[4:], []byte{0, 0, 1, 0xB8})
+					if end >= 0 {
+						track.codec = &fmp4.CodecMPEG1Video{
+							Config: frame[:end+4],
+						}
+					}
+				}
+
+				return s.onSample(
+					track,
+					s.decodeDTS(pts),
+					0,
+					!bytes.Contains(frame, []byte{0, 0, 1, 0xB8}),
+					frame)
+			})
+
+		case *mpegts.CodecOpus:
+			track.timeScale = 48000
+			track.codec = &fmp4.CodecOpus{
+				ChannelCount: tsCodec.ChannelCount,
+			}
+
+			s.mr.OnDataOpus(tsTrack, func(pts int64, packets [][]byte) error {
+				dts := s.toTimeScale(s.decodeDTS(pts), track.timeScale)
+
+				for _, packet := range packets {
+					err := s.onSample(track, dts, 0, false, packet)
+					if err != nil {
+						return err
+					}
+
+					dts += durationGoToMp4(opus.PacketDuration(packet), track.timeScale)
+				}
+
+				return nil
+			})
+
+		case *mpegts.CodecMPEG4Audio:
+			track.timeScale = uint32(tsCodec.SampleRate)
+			track.codec = &fmp4.CodecMPEG4Audio{
+				Config: tsCodec.Config,
+			}
+
+			s.mr.OnDataMPEG4Audio(tsTrack, func(pts int64, aus [][]byte) error {
+				dts := s.toTimeScale(s.decodeDTS(pts), track.timeScale)
+
+				for i, au := range aus {
+					err := s.onSample(track, dts+int64(i)*mpeg4audio.SamplesPerAccessUnit, 0, false, au)
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+
+		case *mpegts.CodecMPEG1Audio:
+			track.timeScale = mpegtsClockRate
+
+			s.mr.OnDataMPEG1Audio(tsTrack, func(pts int64, frames [][]byte) error {
+				dts := s.decodeDTS(pts)
+
+				for _, frame := range frames {
+					var h mpeg1audio.FrameHeader
+					err := h.Unmarshal(frame)
+					if err != nil {
+						return err
+					}
+
+					if track.codec == nil {
+						track.codec = &fmp4.CodecMPEG1Audio{
+							SampleRate:   h.SampleRate,
+							ChannelCount: mpeg1audioChannelCount(h.ChannelMode),
+						}
+					}
+
+					err = s.onSample(track, dts, 0, false, frame)
+					if err != nil {
+						return err
+					}
+
+					dts += int64(h.SampleCount()) * mpegtsClockRate / int64(h.SampleRate)
+				}
+
+				return nil
+			})
+
+		case *mpegts.CodecAC3:
+			track.timeScale = uint32(tsCodec.SampleRate)
+
+			s.mr.OnDataAC3(tsTrack, func(pts int64, frame []byte) error {
+				if track.codec == nil {
+					var syncInfo ac3.SyncInfo
+					err := syncInfo.Unmarshal(frame)
+					if err != nil {
+						return fmt.Errorf("invalid AC-3 frame: %w", err)
+					}
+
+					var bsi ac3.BSI
+					err = bsi.Unmarshal(frame[5:])
+					if err != nil {
+						return fmt.Errorf("invalid AC-3 frame: %w", err)
+					}
+
+					track.codec = &fmp4.CodecAC3{
+						SampleRate:   syncInfo.SampleRate(),
+						ChannelCount: bsi.ChannelCount(),
+						Fscod:        syncInfo.Fscod,
+						Bsid:         bsi.Bsid,
+						Bsmod:        bsi.Bsmod,
+						Acmod:        bsi.Acmod,
+						LfeOn:        bsi.LfeOn,
+						BitRateCode:  syncInfo.Frmsizecod >> 1,
+					}
+				}
+
+				return s.onSample(track, s.toTimeScale(s.decodeDTS(pts), track.timeScale), 0, false, frame)
+			})
+
+		default:
+			continue
+		}
+
+		s.tracks = append(s.tracks, track)
+	}
+
+	if s.tracks == nil {
+		return fmt.Errorf("no supported tracks found")
+	}
+
+	return nil
+}
+
+// decodeDTS converts a raw MPEG-TS timestamp into a timestamp relative to the start of the segment.
+func (s *segmentMPEGTSReader) decodeDTS(dts int64) int64 {
+	dts = s.td.Decode(dts)
+
+	if !s.startDTSSet {
+		s.startDTSSet = true
+		s.startDTS = dts
+	}
+
+	return dts - s.startDTS
+}
+
+func (s *segmentMPEGTSReader) toTimeScale(v int64, timeScale uint32) int64 {
+	return multiplyAndDivide(v, int64(timeScale), mpegtsClockRate)
+}
+
+func (s *segmentMPEGTSReader) read() error {
+	for {
+		err := s.mr.Read()
+		if err != nil {
+			if isMPEGTSEOF(err) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (s *segmentMPEGTSReader) init() (*fmp4.Init, error) {
+	var init fmp4.Init
+
+	for _, track := range s.tracks {
+		if track.codec != nil {
+			init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+				ID:        track.id,
+				TimeScale: track.timeScale,
+				Codec:     track.codec,
+			})
+		}
+	}
+
+	if init.Tracks == nil {
+		return nil, fmt.Errorf("unable to find parameters of tracks")
+	}
+
+	return &init, nil
+}
+
+func (s *segmentMPEGTSReader) allCodecsFound() bool {
+	for _, track := range s.tracks {
+		if track.codec == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func segmentMPEGTSReadInit(r io.ReadSeeker) (*fmp4.Init, error) {
+	sr := &segmentMPEGTSReader{r: r}
+	sr.onSample = func(_ *segmentMPEGTSTrack, _ int64, _ int32, _ bool, _ []byte) error {
+		if sr.allCodecsFound() {
+			return errTerminated
+		}
+		return nil
+	}
+
+	err := sr.initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	err = sr.read()
+	if err != nil && !errors.Is(err, errTerminated) {
+		return nil, err
+	}
+
+	return sr.init()
+}
+
+// segmentMPEGTSReadPESTimestamp returns the DTS of a TS packet that contains the beginning of a PES packet.
+func segmentMPEGTSReadPESTimestamp(pkt []byte) (int64, bool) {
+	if pkt[0] != 0x47 || (pkt[1]&0x40) == 0 {
+		return 0, false
+	}
+
+	adaptationFieldControl := (pkt[3] >> 4) & 0x03
+	if (adaptationFieldControl & 0x01) == 0 {
+		return 0, false
+	}
+
+	payload := pkt[4:]
+
+	if (adaptationFieldControl & 0x02) != 0 {
+		afLen := int(payload[0])
+		if (afLen + 1) > len(payload) {
+			return 0, false
+		}
+		payload = payload[1+afLen:]
+	}
+
+	if len(payload) < 19 ||
+		!bytes.Equal(payload[:3], []byte{0, 0, 1}) ||
+		(payload[6]&0xC0) != 0x80 {
+		return 0, false
+	}
+
+	ptsDTSIndicator := payload[7] >> 6
+	buf := payload[9:]
+
+	switch ptsDTSIndicator {
+	case 2: // PTS only
+
+	case 3: // PTS and DTS
+		buf = buf[5:]
+
+	default:
+		return 0, false
+	}
+
+	return int64(buf[0]>>1&0x07)<<30 |
+		int64(buf[1])<<22 |
+		int64(buf[2]>>1)<<15 |
+		int64(buf[3])<<7 |
+		int64(buf[4]>>1), true
+}
+
+func segmentMPEGTSReadMaxDuration(r io.ReadSeeker, _ *fmp4.Init) (time.Duration, error) {
+	pkt := make([]byte, mpegtsPacketSize)
+
+	// find first DTS
+
+	br := bufio.NewReaderSize(r, mpegtsReadBufferSize)
+	var startDTS int64
+
+	for {
+		_, err := io.ReadFull(br, pkt)
+		if err != nil {
+			return 0, fmt.Errorf("no PES packets found")
+		}
+
+		var ok bool
+		startDTS, ok = segmentMPEGTSReadPESTimestamp(pkt)
+		if ok {
+			break
+		}
+	}
+
+	// find greatest DTS in the final part of the segment
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	tailStart := max(0, size-mpegtsTailScanSize)
+	tailStart -= tailStart % mpegtsPacketSize
+
+	_, err = r.Seek(tailStart, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	br.Reset(r)
+	var maxDiff int64
+
+	for {
+		_, err := io.ReadFull(br, pkt)
+		if err != nil {
+			break
+		}
+
+		dts, ok := segmentMPEGTSReadPESTimestamp(pkt)
+		if ok {
+			diff := mpegtsTimestampDiff(dts, startDTS)
+			if diff > maxDiff {
+				maxDiff = diff
+			}
+		}
+	}
+
+	return durationMp4ToGo(maxDiff, mpegtsClockRate), nil
+}
+
+// segmentMPEGTSMux writes samples of a MPEG-TS segment to the muxer.
+// When seek is true, samples before segmentStartOffset are written with a negative DTS
+// and an error is returned if there are no samples after it.
+func segmentMPEGTSMux(
+	r readSeekerAt,
+	seek bool,
+	segmentStartOffset time.Duration,
+	duration time.Duration,
+	init *fmp4.Init,
+	m muxer,
+) (time.Duration, error) {
+	sm := &segmentSampleMuxer{
+		seek:               seek,
+		segmentStartOffset: segmentStartOffset,
+		duration:           duration,
+		init:               init,
+		m:                  m,
+	}
+	sm.initialize()
+
+	sr := &segmentMPEGTSReader{r: r}
+	sr.onSample = func(
+		track *segmentMPEGTSTrack,
+		dts int64,
+		ptsOffset int32,
+		isNonSyncSample bool,
+		payload []byte,
+	) error {
+		return sm.writeSample(track.id, track.timeScale, dts, ptsOffset, isNonSyncSample, payload)
+	}
+
+	err := sr.initialize()
+	if err != nil {
+		return 0, err
+	}
+
+	err = sr.read()
+	if err != nil && !errors.Is(err, errTerminated) {
+		return 0, err
+	}
+
+	return sm.finalize()
+}
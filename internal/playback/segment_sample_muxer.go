@@ -0,0 +1,145 @@
+package playback
+
+import (
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type segmentSampleMuxerTrackState struct {
+	written      bool
+	lastDTS      int64
+	lastDuration int64
+	ended        bool
+}
+
+// segmentSampleMuxer writes samples of segments that are not in the fMP4 format to the muxer.
+// When seek is true, samples before segmentStartOffset are written with a negative DTS
+// and an error is returned if there are no samples after it.
+type segmentSampleMuxer struct {
+	seek               bool
+	segmentStartOffset time.Duration
+	duration           time.Duration
+	init               *fmp4.Init
+	m                  muxer
+
+	states                  map[int]*segmentSampleMuxerTrackState
+	endedCount              int
+	atLeastOneSampleWritten bool
+}
+
+func (s *segmentSampleMuxer) initialize() {
+	s.states = make(map[int]*segmentSampleMuxerTrackState)
+}
+
+// writeSample writes a sample.
+// DTS is relative to the beginning of the segment and is expressed in the time scale of the track.
+// It returns errTerminated when all tracks have reached the requested duration.
+func (s *segmentSampleMuxer) writeSample(
+	trackID int,
+	timeScale uint32,
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payload []byte,
+) error {
+	if findInitTrack(s.init.Tracks, trackID) == nil {
+		return nil
+	}
+
+	if s.seek {
+		dts -= durationGoToMp4(s.segmentStartOffset, timeScale)
+	} else {
+		dts += durationGoToMp4(s.segmentStartOffset, timeScale)
+	}
+
+	st, ok := s.states[trackID]
+
+	if dts >= durationGoToMp4(s.duration, timeScale) {
+		if !ok {
+			st = &segmentSampleMuxerTrackState{}
+			s.states[trackID] = st
+		}
+
+		if !st.ended {
+			st.ended = true
+			s.endedCount++
+
+			if s.endedCount == len(s.init.Tracks) {
+				return errTerminated
+			}
+		}
+
+		return nil
+	}
+
+	if !ok {
+		st = &segmentSampleMuxerTrackState{}
+		s.states[trackID] = st
+	}
+
+	if st.written {
+		st.lastDuration = dts - st.lastDTS
+	}
+	st.written = true
+	st.lastDTS = dts
+
+	if dts >= 0 {
+		s.atLeastOneSampleWritten = true
+	}
+
+	s.m.setTrack(trackID)
+
+	getPayload := func() ([]byte, error) {
+		return payload, nil
+	}
+
+	// payloads can't be read again from the segment, since they are decoded from it,
+	// therefore muxers that keep samples until flush() have to store them.
+	if ps, ok := s.m.(muxerPayloadStorer); ok {
+		var err error
+		getPayload, err = ps.storePayload(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.m.writeSample(
+		dts,
+		ptsOffset,
+		isNonSyncSample,
+		uint32(len(payload)),
+		getPayload)
+}
+
+// finalize writes the final DTS of each track and returns the greatest one.
+func (s *segmentSampleMuxer) finalize() (time.Duration, error) {
+	if s.seek && !s.atLeastOneSampleWritten {
+		return 0, recordstore.ErrNoSegmentsFound
+	}
+
+	var maxMuxerDTS time.Duration
+
+	for _, track := range s.init.Tracks {
+		st, ok := s.states[track.ID]
+		if !ok || !st.written {
+			continue
+		}
+
+		// duration of the last sample is not available, use the one of the previous sample
+		finalDTS := st.lastDTS + max(st.lastDuration, 0)
+
+		s.m.setTrack(track.ID)
+		s.m.writeFinalDTS(finalDTS)
+
+		muxerDTSGo := durationMp4ToGo(finalDTS, track.TimeScale)
+
+		if muxerDTSGo > maxMuxerDTS {
+			maxMuxerDTS = muxerDTSGo
+		}
+	}
+
+	return maxMuxerDTS, nil
+}
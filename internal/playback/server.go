@@ -11,6 +11,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/restrictnetwork"
 	"github.com/gin-gonic/gin"
 )
@@ -29,6 +30,7 @@ type Server struct {
 	TrustedProxies conf.IPNetworks
 	ReadTimeout    conf.StringDuration
 	PathConfs      map[string]*conf.Path
+	RecordIndex    *recordstore.Index
 	AuthManager    serverAuthManager
 	Parent         logger.Writer
 
@@ -45,6 +47,9 @@ func (s *Server) Initialize() error {
 
 	router.GET("/list", s.onList)
 	router.GET("/get", s.onGet)
+	router.GET("/export", s.onExport)
+	router.GET("/segment", s.onSegment)
+	router.GET("/timeline", s.onTimeline)
 
 	network, address := restrictnetwork.Restrict("tcp", s.Address)
 
@@ -110,19 +115,24 @@ func (s *Server) middlewareOrigin(ctx *gin.Context) {
 	if ctx.Request.Method == http.MethodOptions &&
 		ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
 		ctx.Header("Access-Control-Allow-Methods", "OPTIONS, GET")
-		ctx.Header("Access-Control-Allow-Headers", "Authorization")
+		ctx.Header("Access-Control-Allow-Headers", "Authorization, Range")
 		ctx.AbortWithStatus(http.StatusNoContent)
 		return
 	}
 }
 
-func (s *Server) doAuth(ctx *gin.Context, pathName string) bool {
-	err := s.AuthManager.Authenticate(&auth.Request{
+// isAuthorized checks whether the client is allowed to read a path, without replying.
+func (s *Server) isAuthorized(ctx *gin.Context, pathName string) error {
+	return s.AuthManager.Authenticate(&auth.Request{
 		IP:          net.ParseIP(ctx.ClientIP()),
 		Action:      conf.AuthActionPlayback,
 		Path:        pathName,
 		HTTPRequest: ctx.Request,
 	})
+}
+
+func (s *Server) doAuth(ctx *gin.Context, pathName string) bool {
+	err := s.isAuthorized(ctx, pathName)
 	if err != nil {
 		if err.(*auth.Error).AskCredentials { //nolint:errorlint
 			ctx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
@@ -20,6 +20,7 @@ var ErrNoSupportedCodecs = errors.New(
 
 func setupVideoTrack(
 	strea *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	muxer *gohlslib.Muxer,
 	setuppedFormats map[format.Format]struct{},
@@ -36,7 +37,7 @@ func setupVideoTrack(
 	}
 
 	var videoFormatAV1 *format.AV1
-	videoMedia := strea.Desc().FindFormat(&videoFormatAV1)
+	videoMedia := desc.FindFormat(&videoFormatAV1)
 
 	if videoFormatAV1 != nil {
 		track := &gohlslib.Track{
@@ -71,7 +72,7 @@ func setupVideoTrack(
 	}
 
 	var videoFormatVP9 *format.VP9
-	videoMedia = strea.Desc().FindFormat(&videoFormatVP9)
+	videoMedia = desc.FindFormat(&videoFormatVP9)
 
 	if videoFormatVP9 != nil {
 		track := &gohlslib.Track{
@@ -106,7 +107,7 @@ func setupVideoTrack(
 	}
 
 	var videoFormatH265 *format.H265
-	videoMedia = strea.Desc().FindFormat(&videoFormatH265)
+	videoMedia = desc.FindFormat(&videoFormatH265)
 
 	if videoFormatH265 != nil {
 		vps, sps, pps := videoFormatH265.SafeParams()
@@ -146,7 +147,7 @@ func setupVideoTrack(
 	}
 
 	var videoFormatH264 *format.H264
-	videoMedia = strea.Desc().FindFormat(&videoFormatH264)
+	videoMedia = desc.FindFormat(&videoFormatH264)
 
 	if videoFormatH264 != nil {
 		sps, pps := videoFormatH264.SafeParams()
@@ -187,6 +188,7 @@ func setupVideoTrack(
 
 func setupAudioTracks(
 	strea *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	muxer *gohlslib.Muxer,
 	setuppedFormats map[format.Format]struct{},
@@ -202,7 +204,7 @@ func setupAudioTracks(
 		strea.AddReader(reader, medi, forma, readFunc)
 	}
 
-	for _, media := range strea.Desc().Medias {
+	for _, media := range desc.Medias {
 		for _, forma := range media.Formats {
 			switch forma := forma.(type) {
 			case *format.Opus:
@@ -273,6 +275,7 @@ func setupAudioTracks(
 // FromStream maps a MediaMTX stream to a HLS muxer.
 func FromStream(
 	stream *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	muxer *gohlslib.Muxer,
 ) error {
@@ -280,6 +283,7 @@ func FromStream(
 
 	setupVideoTrack(
 		stream,
+		desc,
 		reader,
 		muxer,
 		setuppedFormats,
@@ -287,6 +291,7 @@ func FromStream(
 
 	setupAudioTracks(
 		stream,
+		desc,
 		reader,
 		muxer,
 		setuppedFormats,
@@ -297,7 +302,7 @@ func FromStream(
 	}
 
 	n := 1
-	for _, media := range stream.Desc().Medias {
+	for _, media := range desc.Medias {
 		for _, forma := range media.Formats {
 			if _, ok := setuppedFormats[forma]; !ok {
 				reader.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
@@ -32,7 +32,7 @@ func TestFromStreamNoSupportedCodecs(t *testing.T) {
 
 	m := &gohlslib.Muxer{}
 
-	err = FromStream(stream, l, m)
+	err = FromStream(stream, stream.Desc(), l, m)
 	require.Equal(t, ErrNoSupportedCodecs, err)
 }
 
@@ -74,7 +74,7 @@ func TestFromStreamSkipUnsupportedTracks(t *testing.T) {
 		n++
 	})
 
-	err = FromStream(stream, l, m)
+	err = FromStream(stream, stream.Desc(), l, m)
 	require.NoError(t, err)
 	defer stream.RemoveReader(l)
 
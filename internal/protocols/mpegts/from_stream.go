@@ -27,6 +27,7 @@ func multiplyAndDivide(v, m, d int64) int64 {
 // FromStream maps a MediaMTX stream to a MPEG-TS writer.
 func FromStream(
 	strea *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	bw *bufio.Writer,
 	sconn srt.Conn,
@@ -47,7 +48,7 @@ func FromStream(
 		strea.AddReader(reader, media, forma, readFunc)
 	}
 
-	for _, media := range strea.Desc().Medias {
+	for _, media := range desc.Medias {
 		for _, forma := range media.Formats {
 			clockRate := forma.ClockRate()
 
@@ -319,7 +320,7 @@ func FromStream(
 	}
 
 	n := 1
-	for _, medi := range strea.Desc().Medias {
+	for _, medi := range desc.Medias {
 		for _, forma := range medi.Formats {
 			if _, ok := setuppedFormats[forma]; !ok {
 				reader.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
@@ -29,7 +29,7 @@ func TestFromStreamNoSupportedCodecs(t *testing.T) {
 		t.Error("should not happen")
 	})
 
-	err = FromStream(stream, l, nil, nil, 0)
+	err = FromStream(stream, stream.Desc(), l, nil, nil, 0)
 	require.Equal(t, errNoSupportedCodecs, err)
 }
 
@@ -62,7 +62,7 @@ func TestFromStreamSkipUnsupportedTracks(t *testing.T) {
 		n++
 	})
 
-	err = FromStream(stream, l, nil, nil, 0)
+	err = FromStream(stream, stream.Desc(), l, nil, nil, 0)
 	require.NoError(t, err)
 	defer stream.RemoveReader(l)
 
@@ -6,6 +6,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
 	"github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg1audio"
@@ -18,25 +19,16 @@ import (
 var errNoSupportedCodecsFrom = errors.New(
 	"the stream doesn't contain any supported codec, which are currently H264, MPEG-4 Audio, MPEG-1/2 Audio")
 
-func multiplyAndDivide2(v, m, d time.Duration) time.Duration {
-	secs := v / d
-	dec := v % d
-	return (secs*m + dec*m/d)
-}
-
-func timestampToDuration(t int64, clockRate int) time.Duration {
-	return multiplyAndDivide2(time.Duration(t), time.Second, time.Duration(clockRate))
-}
-
 func setupVideo(
 	strea *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	w **Writer,
 	nconn net.Conn,
 	writeTimeout time.Duration,
 ) format.Format {
 	var videoFormatH264 *format.H264
-	videoMedia := strea.Desc().FindFormat(&videoFormatH264)
+	videoMedia := desc.FindFormat(&videoFormatH264)
 
 	if videoFormatH264 != nil {
 		var videoDTSExtractor *h264.DTSExtractor2
@@ -84,8 +76,8 @@ func setupVideo(
 
 				nconn.SetWriteDeadline(time.Now().Add(writeTimeout))
 				return (*w).WriteH264(
-					timestampToDuration(tunit.PTS, videoFormatH264.ClockRate()),
-					timestampToDuration(dts, videoFormatH264.ClockRate()),
+					unit.TimestampToDuration(tunit.PTS, videoFormatH264.ClockRate()),
+					unit.TimestampToDuration(dts, videoFormatH264.ClockRate()),
 					idrPresent,
 					tunit.AU)
 			})
@@ -98,13 +90,14 @@ func setupVideo(
 
 func setupAudio(
 	strea *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	w **Writer,
 	nconn net.Conn,
 	writeTimeout time.Duration,
 ) format.Format {
 	var audioFormatMPEG4Audio *format.MPEG4Audio
-	audioMedia := strea.Desc().FindFormat(&audioFormatMPEG4Audio)
+	audioMedia := desc.FindFormat(&audioFormatMPEG4Audio)
 
 	if audioMedia != nil {
 		strea.AddReader(
@@ -123,7 +116,7 @@ func setupAudio(
 
 					nconn.SetWriteDeadline(time.Now().Add(writeTimeout))
 					err := (*w).WriteMPEG4Audio(
-						timestampToDuration(pts, audioFormatMPEG4Audio.ClockRate()),
+						unit.TimestampToDuration(pts, audioFormatMPEG4Audio.ClockRate()),
 						au,
 					)
 					if err != nil {
@@ -138,7 +131,7 @@ func setupAudio(
 	}
 
 	var audioFormatMPEG1 *format.MPEG1Audio
-	audioMedia = strea.Desc().FindFormat(&audioFormatMPEG1)
+	audioMedia = desc.FindFormat(&audioFormatMPEG1)
 
 	if audioMedia != nil {
 		strea.AddReader(
@@ -163,7 +156,7 @@ func setupAudio(
 
 					nconn.SetWriteDeadline(time.Now().Add(writeTimeout))
 					err = (*w).WriteMPEG1Audio(
-						timestampToDuration(pts, audioFormatMPEG1.ClockRate()),
+						unit.TimestampToDuration(pts, audioFormatMPEG1.ClockRate()),
 						&h,
 						frame)
 					if err != nil {
@@ -186,6 +179,7 @@ func setupAudio(
 // FromStream maps a MediaMTX stream to a RTMP stream.
 func FromStream(
 	stream *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	conn *Conn,
 	nconn net.Conn,
@@ -195,6 +189,7 @@ func FromStream(
 
 	videoFormat := setupVideo(
 		stream,
+		desc,
 		reader,
 		&w,
 		nconn,
@@ -203,6 +198,7 @@ func FromStream(
 
 	audioFormat := setupAudio(
 		stream,
+		desc,
 		reader,
 		&w,
 		nconn,
@@ -220,7 +216,7 @@ func FromStream(
 	}
 
 	n := 1
-	for _, media := range stream.Desc().Medias {
+	for _, media := range desc.Medias {
 		for _, forma := range media.Formats {
 			if forma != videoFormat && forma != audioFormat {
 				reader.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
@@ -32,7 +32,7 @@ func TestFromStreamNoSupportedCodecs(t *testing.T) {
 		t.Error("should not happen")
 	})
 
-	err = FromStream(stream, l, nil, nil, 0)
+	err = FromStream(stream, stream.Desc(), l, nil, nil, 0)
 	require.Equal(t, errNoSupportedCodecsFrom, err)
 }
 
@@ -76,7 +76,7 @@ func TestFromStreamSkipUnsupportedTracks(t *testing.T) {
 	bc := bytecounter.NewReadWriter(&buf)
 	conn := &Conn{mrw: message.NewReadWriter(&buf, bc, false)}
 
-	err = FromStream(stream, l, conn, nil, 0)
+	err = FromStream(stream, stream.Desc(), l, conn, nil, 0)
 	require.NoError(t, err)
 	defer stream.RemoveReader(l)
 
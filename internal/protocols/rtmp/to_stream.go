@@ -14,16 +14,6 @@ var errNoSupportedCodecsTo = errors.New(
 	"the stream doesn't contain any supported codec, which are currently " +
 		"AV1, VP9, H265, H264, MPEG-4 Audio, MPEG-1/2 Audio, G711, LPCM")
 
-func multiplyAndDivide(v, m, d int64) int64 {
-	secs := v / d
-	dec := v % d
-	return (secs*m + dec*m/d)
-}
-
-func durationToTimestamp(d time.Duration, clockRate int) int64 {
-	return multiplyAndDivide(int64(d), int64(clockRate), int64(time.Second))
-}
-
 // ToStream maps a RTMP stream to a MediaMTX stream.
 func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 	videoFormat, audioFormat := r.Tracks()
@@ -43,7 +33,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, videoFormat, &unit.AV1{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, videoFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, videoFormat.ClockRate()),
 					},
 					TU: tu,
 				})
@@ -54,7 +44,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, videoFormat, &unit.VP9{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, videoFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, videoFormat.ClockRate()),
 					},
 					Frame: frame,
 				})
@@ -65,7 +55,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, videoFormat, &unit.H265{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, videoFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, videoFormat.ClockRate()),
 					},
 					AU: au,
 				})
@@ -76,7 +66,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, videoFormat, &unit.H264{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, videoFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, videoFormat.ClockRate()),
 					},
 					AU: au,
 				})
@@ -100,7 +90,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, audioFormat, &unit.MPEG4Audio{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, audioFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, audioFormat.ClockRate()),
 					},
 					AUs: [][]byte{au},
 				})
@@ -111,7 +101,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, audioFormat, &unit.MPEG1Audio{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, audioFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, audioFormat.ClockRate()),
 					},
 					Frames: [][]byte{frame},
 				})
@@ -122,7 +112,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, audioFormat, &unit.G711{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, audioFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, audioFormat.ClockRate()),
 					},
 					Samples: samples,
 				})
@@ -133,7 +123,7 @@ func ToStream(r *Reader, stream **stream.Stream) ([]*description.Media, error) {
 				(*stream).WriteUnit(medi, audioFormat, &unit.LPCM{
 					Base: unit.Base{
 						NTP: time.Now(),
-						PTS: durationToTimestamp(pts, audioFormat.ClockRate()),
+						PTS: unit.DurationToTimestamp(pts, audioFormat.ClockRate()),
 					},
 					Samples: samples,
 				})
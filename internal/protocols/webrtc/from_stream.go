@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
 	"github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/bluenviron/gortsplib/v4/pkg/format/rtpav1"
 	"github.com/bluenviron/gortsplib/v4/pkg/format/rtph264"
@@ -40,11 +41,12 @@ func randUint32() (uint32, error) {
 
 func setupVideoTrack(
 	stream *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	pc *PeerConnection,
 ) (format.Format, error) {
 	var av1Format *format.AV1
-	media := stream.Desc().FindFormat(&av1Format)
+	media := desc.FindFormat(&av1Format)
 
 	if av1Format != nil {
 		track := &OutgoingTrack{
@@ -92,7 +94,7 @@ func setupVideoTrack(
 	}
 
 	var vp9Format *format.VP9
-	media = stream.Desc().FindFormat(&vp9Format)
+	media = desc.FindFormat(&vp9Format)
 
 	if vp9Format != nil {
 		track := &OutgoingTrack{
@@ -142,7 +144,7 @@ func setupVideoTrack(
 	}
 
 	var vp8Format *format.VP8
-	media = stream.Desc().FindFormat(&vp8Format)
+	media = desc.FindFormat(&vp8Format)
 
 	if vp8Format != nil {
 		track := &OutgoingTrack{
@@ -190,7 +192,7 @@ func setupVideoTrack(
 	}
 
 	var h264Format *format.H264
-	media = stream.Desc().FindFormat(&h264Format)
+	media = desc.FindFormat(&h264Format)
 
 	if h264Format != nil {
 		track := &OutgoingTrack{
@@ -253,11 +255,12 @@ func setupVideoTrack(
 
 func setupAudioTrack(
 	stream *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	pc *PeerConnection,
 ) (format.Format, error) {
 	var opusFormat *format.Opus
-	media := stream.Desc().FindFormat(&opusFormat)
+	media := desc.FindFormat(&opusFormat)
 
 	if opusFormat != nil {
 		var caps webrtc.RTPCodecCapability
@@ -310,7 +313,7 @@ func setupAudioTrack(
 	}
 
 	var g722Format *format.G722
-	media = stream.Desc().FindFormat(&g722Format)
+	media = desc.FindFormat(&g722Format)
 
 	if g722Format != nil {
 		track := &OutgoingTrack{
@@ -337,7 +340,7 @@ func setupAudioTrack(
 	}
 
 	var g711Format *format.G711
-	media = stream.Desc().FindFormat(&g711Format)
+	media = desc.FindFormat(&g711Format)
 
 	if g711Format != nil {
 		// These are the sample rates and channels supported by Chrome.
@@ -473,7 +476,7 @@ func setupAudioTrack(
 	}
 
 	var lpcmFormat *format.LPCM
-	media = stream.Desc().FindFormat(&lpcmFormat)
+	media = desc.FindFormat(&lpcmFormat)
 
 	if lpcmFormat != nil {
 		if lpcmFormat.BitDepth != 16 {
@@ -553,15 +556,16 @@ func setupAudioTrack(
 // FromStream maps a MediaMTX stream to a WebRTC connection
 func FromStream(
 	stream *stream.Stream,
+	desc *description.Session,
 	reader stream.Reader,
 	pc *PeerConnection,
 ) error {
-	videoFormat, err := setupVideoTrack(stream, reader, pc)
+	videoFormat, err := setupVideoTrack(stream, desc, reader, pc)
 	if err != nil {
 		return err
 	}
 
-	audioFormat, err := setupAudioTrack(stream, reader, pc)
+	audioFormat, err := setupAudioTrack(stream, desc, reader, pc)
 	if err != nil {
 		return err
 	}
@@ -571,7 +575,7 @@ func FromStream(
 	}
 
 	n := 1
-	for _, media := range stream.Desc().Medias {
+	for _, media := range desc.Medias {
 		for _, forma := range media.Formats {
 			if forma != videoFormat && forma != audioFormat {
 				reader.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
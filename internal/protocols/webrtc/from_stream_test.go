@@ -29,7 +29,7 @@ func TestFromStreamNoSupportedCodecs(t *testing.T) {
 		t.Error("should not happen")
 	})
 
-	err = FromStream(stream, l, nil)
+	err = FromStream(stream, stream.Desc(), l, nil)
 	require.Equal(t, errNoSupportedCodecsFrom, err)
 }
 
@@ -64,7 +64,7 @@ func TestFromStreamSkipUnsupportedTracks(t *testing.T) {
 
 	pc := &PeerConnection{}
 
-	err = FromStream(stream, l, pc)
+	err = FromStream(stream, stream.Desc(), l, pc)
 	require.NoError(t, err)
 	defer stream.RemoveReader(l)
 
@@ -93,7 +93,7 @@ func TestFromStream(t *testing.T) {
 
 			pc := &PeerConnection{}
 
-			err = FromStream(stream, nil, pc)
+			err = FromStream(stream, stream.Desc(), nil, pc)
 			require.NoError(t, err)
 			defer stream.RemoveReader(nil)
 
@@ -516,6 +516,20 @@ func (co *PeerConnection) RemoteCandidate() string {
 	return ""
 }
 
+// RoundTripTime returns the round trip time of the selected candidate pair,
+// measured through STUN binding requests.
+func (co *PeerConnection) RoundTripTime() (time.Duration, bool) {
+	for _, stats := range co.wr.GetStats() {
+		if tstats, ok := stats.(webrtc.ICECandidatePairStats); ok && tstats.Nominated {
+			if tstats.ResponsesReceived == 0 {
+				return 0, false
+			}
+			return time.Duration(tstats.CurrentRoundTripTime * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
 // BytesReceived returns received bytes.
 func (co *PeerConnection) BytesReceived() uint64 {
 	for _, stats := range co.wr.GetStats() {
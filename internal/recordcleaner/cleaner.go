@@ -4,19 +4,46 @@ package recordcleaner
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
+const (
+	// size-based retention must react quickly, since disks can fill up fast.
+	sizeCleanInterval = 30 * time.Second
+)
+
 var timeNow = time.Now
 
+type purgeReason string
+
+const (
+	purgeReasonMaxSize      purgeReason = "maxSize"
+	purgeReasonMaxTotalSize purgeReason = "maxTotalSize"
+	purgeReasonMinFreeSpace purgeReason = "minFreeSpace"
+)
+
+type cleanerSegment struct {
+	*recordstore.Segment
+	pathName string
+	pathConf *conf.Path
+	size     uint64
+}
+
 // Cleaner removes expired recording segments from disk.
 type Cleaner struct {
-	PathConfs map[string]*conf.Path
-	Parent    logger.Writer
+	PathConfs       map[string]*conf.Path
+	MaxTotalSize    uint64
+	MinFreeSpace    uint64
+	ExternalCmdPool *externalcmd.Pool
+	Index           *recordstore.Index
+	Parent          logger.Writer
 
 	ctx       context.Context
 	ctxCancel func()
@@ -42,7 +69,7 @@ func (c *Cleaner) Close() {
 
 // Log implements logger.Writer.
 func (c *Cleaner) Log(level logger.Level, format string, args ...interface{}) {
-	c.Parent.Log(level, "[record cleaner]"+format, args...)
+	c.Parent.Log(level, "[record cleaner] "+format, args...)
 }
 
 // ReloadPathConfs is called by core.Core.
@@ -74,7 +101,20 @@ func (c *Cleaner) run() {
 
 func (c *Cleaner) atLeastOneRecordDeleteAfter() bool {
 	for _, e := range c.PathConfs {
-		if e.RecordDeleteAfter != 0 {
+		if e.RecordDeleteAfter != 0 || e.RecordThinAfter != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cleaner) sizeRetentionEnabled() bool {
+	if c.MaxTotalSize != 0 || c.MinFreeSpace != 0 {
+		return true
+	}
+
+	for _, e := range c.PathConfs {
+		if e.RecordMaxSize != 0 {
 			return true
 		}
 	}
@@ -82,6 +122,13 @@ func (c *Cleaner) atLeastOneRecordDeleteAfter() bool {
 }
 
 func (c *Cleaner) cleanInterval() time.Duration {
+	if c.sizeRetentionEnabled() {
+		return min(c.ageCleanInterval(), sizeCleanInterval)
+	}
+	return c.ageCleanInterval()
+}
+
+func (c *Cleaner) ageCleanInterval() time.Duration {
 	if !c.atLeastOneRecordDeleteAfter() {
 		return 365 * 24 * time.Hour
 	}
@@ -93,6 +140,10 @@ func (c *Cleaner) cleanInterval() time.Duration {
 			interval > (time.Duration(e.RecordDeleteAfter)/2) {
 			interval = time.Duration(e.RecordDeleteAfter) / 2
 		}
+		if e.RecordThinAfter != 0 &&
+			interval > (time.Duration(e.RecordThinAfter)/2) {
+			interval = time.Duration(e.RecordThinAfter) / 2
+		}
 	}
 
 	return interval
@@ -101,11 +152,15 @@ func (c *Cleaner) cleanInterval() time.Duration {
 func (c *Cleaner) doRun() {
 	now := timeNow()
 
-	pathNames := recordstore.FindAllPathsWithSegments(c.PathConfs)
+	pathNames := recordstore.FindAllPathsWithSegments(c.PathConfs, c.Index)
 
 	for _, pathName := range pathNames {
 		c.processPath(now, pathName) //nolint:errcheck
 	}
+
+	if c.sizeRetentionEnabled() {
+		c.processSize(pathNames)
+	}
 }
 
 func (c *Cleaner) processPath(now time.Time, pathName string) error {
@@ -114,21 +169,221 @@ func (c *Cleaner) processPath(now time.Time, pathName string) error {
 		return err
 	}
 
-	if pathConf.RecordDeleteAfter == 0 {
+	if pathConf.RecordDeleteAfter == 0 && pathConf.RecordThinAfter == 0 {
 		return nil
 	}
 
-	segments, err := recordstore.FindSegments(pathConf, pathName)
+	segments, err := recordstore.FindSegments(pathConf, pathName, c.Index)
 	if err != nil {
 		return err
 	}
 
-	for _, seg := range segments {
-		if now.Sub(seg.Start) > time.Duration(pathConf.RecordDeleteAfter) {
+	thinEnabled := pathConf.RecordThinAfter != 0
+	var encryptionKey *recordstore.EncryptionKey
+
+	// thinned segments are encrypted in the same way as new segments.
+	if thinEnabled && pathConf.RecordEncryptionKey != "" {
+		keys, err := recordstore.LoadEncryptionKeys(pathConf.RecordEncryptionKey)
+		if err != nil {
+			c.Log(logger.Error, "unable to load recordEncryptionKey, segments will not be thinned: %v", err)
+			thinEnabled = false
+		} else {
+			encryptionKey = keys.Current()
+		}
+	}
+
+	for i, seg := range segments {
+		age := now.Sub(seg.Start)
+
+		if pathConf.RecordDeleteAfter != 0 && age > time.Duration(pathConf.RecordDeleteAfter) {
 			c.Log(logger.Debug, "removing %s", seg.Fpath)
-			os.Remove(seg.Fpath)
+			seg.Remove() //nolint:errcheck
+			continue
+		}
+
+		// the newest segment may be in use by the recorder
+		if thinEnabled && age > time.Duration(pathConf.RecordThinAfter) &&
+			i != (len(segments)-1) && !seg.InBucket() {
+			c.thin(seg, encryptionKey)
 		}
 	}
 
 	return nil
 }
+
+// thin rewrites a segment in order to keep keyframes only.
+func (c *Cleaner) thin(seg *recordstore.Segment, encryptionKey *recordstore.EncryptionKey) {
+	md, err := seg.ReadMetadata()
+	if err == nil && md.Thinned {
+		return
+	}
+
+	res, err := recordstore.ThinFMP4(seg, encryptionKey)
+	if err != nil {
+		c.Log(logger.Warn, "unable to thin %s: %v", seg.Fpath, err)
+		return
+	}
+
+	if res != nil {
+		c.Log(logger.Debug, "thinned %s (%d -> %d bytes)", seg.Fpath, res.OriginalSize, res.Size)
+	}
+}
+
+// removableSegments returns segments of a path that can be removed by size-based retention,
+// that are all segments stored on disk except the most recent one, which may be in use by the recorder.
+// It also returns the size of the most recent segment.
+func (c *Cleaner) removableSegments(pathName string) ([]*cleanerSegment, uint64, error) {
+	pathConf, _, err := conf.FindPathConf(c.PathConfs, pathName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	allSegments, err := recordstore.FindSegments(pathConf, pathName, c.Index)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// segments stored in a bucket do not occupy disk space
+	segments := make([]*recordstore.Segment, 0, len(allSegments))
+	for _, seg := range allSegments {
+		if !seg.InBucket() {
+			segments = append(segments, seg)
+		}
+	}
+
+	out := make([]*cleanerSegment, 0, len(segments))
+	var lastSize uint64
+
+	for i, seg := range segments {
+		fi, err := os.Stat(seg.Fpath)
+		if err != nil {
+			continue
+		}
+
+		if i == (len(segments) - 1) {
+			lastSize = uint64(fi.Size())
+			break
+		}
+
+		out = append(out, &cleanerSegment{
+			Segment:  seg,
+			pathName: pathName,
+			pathConf: pathConf,
+			size:     uint64(fi.Size()),
+		})
+	}
+
+	return out, lastSize, nil
+}
+
+func (c *Cleaner) processSize(pathNames []string) {
+	var all []*cleanerSegment
+	var allReserved uint64
+
+	for _, pathName := range pathNames {
+		segments, reserved, err := c.removableSegments(pathName)
+		if err != nil {
+			continue
+		}
+
+		if len(segments) != 0 && segments[0].pathConf.RecordMaxSize != 0 {
+			segments = c.enforceMaxSize(segments, reserved,
+				uint64(segments[0].pathConf.RecordMaxSize), purgeReasonMaxSize)
+		}
+
+		all = append(all, segments...)
+		allReserved += reserved
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Start.Before(all[j].Start)
+	})
+
+	if c.MaxTotalSize != 0 {
+		all = c.enforceMaxSize(all, allReserved, c.MaxTotalSize, purgeReasonMaxTotalSize)
+	}
+
+	if c.MinFreeSpace != 0 {
+		c.enforceMinFreeSpace(all)
+	}
+}
+
+// enforceMaxSize removes the oldest segments until their total size,
+// plus the reserved size, is below maxSize. It returns remaining segments.
+func (c *Cleaner) enforceMaxSize(
+	segments []*cleanerSegment,
+	reserved uint64,
+	maxSize uint64,
+	reason purgeReason,
+) []*cleanerSegment {
+	total := reserved
+	for _, seg := range segments {
+		total += seg.size
+	}
+
+	for len(segments) != 0 && total > maxSize {
+		if c.purge(segments[0], reason) {
+			total -= segments[0].size
+		}
+		segments = segments[1:]
+	}
+
+	return segments
+}
+
+// enforceMinFreeSpace removes the oldest segments until
+// the free space of the disks they are stored into is above the minimum.
+func (c *Cleaner) enforceMinFreeSpace(segments []*cleanerSegment) {
+	freeSpaces := make(map[string]uint64)
+
+	for _, seg := range segments {
+		dir := filepath.Dir(seg.Fpath)
+
+		free, ok := freeSpaces[dir]
+		if !ok {
+			var err error
+			free, err = diskFreeSpace(dir)
+			if err != nil {
+				c.Log(logger.Warn, "unable to get free space of %s: %v", dir, err)
+				continue
+			}
+			freeSpaces[dir] = free
+		}
+
+		if free >= c.MinFreeSpace {
+			continue
+		}
+
+		if c.purge(seg, purgeReasonMinFreeSpace) {
+			// directories may share the same disk, therefore free space must be read again.
+			clear(freeSpaces)
+		}
+	}
+}
+
+// purge removes a segment before its expiration.
+func (c *Cleaner) purge(seg *cleanerSegment, reason purgeReason) bool {
+	c.Log(logger.Warn, "removing %s before expiration (%s)", seg.Fpath, reason)
+
+	err := seg.Remove()
+	if err != nil {
+		c.Log(logger.Error, "unable to remove %s: %v", seg.Fpath, err)
+		return false
+	}
+
+	if seg.pathConf.RunOnRecordSegmentPurge != "" && c.ExternalCmdPool != nil {
+		c.Log(logger.Info, "runOnRecordSegmentPurge command launched")
+		externalcmd.NewCmd(
+			c.ExternalCmdPool,
+			seg.pathConf.RunOnRecordSegmentPurge,
+			false,
+			externalcmd.Environment{
+				"MTX_PATH":                 seg.pathName,
+				"MTX_SEGMENT_PATH":         seg.Fpath,
+				"MTX_SEGMENT_PURGE_REASON": string(reason),
+			},
+			nil)
+	}
+
+	return true
+}
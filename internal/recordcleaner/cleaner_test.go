@@ -1,13 +1,17 @@
 package recordcleaner
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"regexp"
 	"testing"
 	"time"
 
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/stretchr/testify/require"
 )
@@ -105,3 +109,217 @@ func TestCleanerMultipleEntriesSamePath(t *testing.T) {
 	_, err = os.Stat(filepath.Join(dir, "path2", "2009-05-19_22-15-25-000427.mp4"))
 	require.NoError(t, err)
 }
+
+func TestCleanerMaxSize(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, pathName := range []string{"path1", "path2"} {
+		err = os.Mkdir(filepath.Join(dir, pathName), 0o755)
+		require.NoError(t, err)
+	}
+
+	for _, fpath := range []string{
+		filepath.Join("path1", "2009-05-20_22-15-20-000000.mp4"),
+		filepath.Join("path1", "2009-05-20_22-15-21-000000.mp4"),
+		filepath.Join("path1", "2009-05-20_22-15-22-000000.mp4"),
+		filepath.Join("path2", "2009-05-20_22-15-19-000000.mp4"),
+		filepath.Join("path2", "2009-05-20_22-15-23-000000.mp4"),
+		filepath.Join("path2", "2009-05-20_22-15-24-000000.mp4"),
+	} {
+		err = os.WriteFile(filepath.Join(dir, fpath), []byte{1, 2}, 0o644)
+		require.NoError(t, err)
+	}
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"path1": {
+				Name:          "path1",
+				RecordPath:    filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat:  conf.RecordFormatFMP4,
+				RecordMaxSize: 4,
+			},
+			"path2": {
+				Name:         "path2",
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		MaxTotalSize: 8,
+		Parent:       test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	for _, ca := range []struct {
+		fpath  string
+		exists bool
+	}{
+		// removed by recordMaxSize
+		{filepath.Join("path1", "2009-05-20_22-15-20-000000.mp4"), false},
+		// removed by recordMaxTotalSize, since it is the oldest segment
+		{filepath.Join("path2", "2009-05-20_22-15-19-000000.mp4"), false},
+		{filepath.Join("path1", "2009-05-20_22-15-21-000000.mp4"), true},
+		{filepath.Join("path1", "2009-05-20_22-15-22-000000.mp4"), true},
+		{filepath.Join("path2", "2009-05-20_22-15-23-000000.mp4"), true},
+		{filepath.Join("path2", "2009-05-20_22-15-24-000000.mp4"), true},
+	} {
+		_, err = os.Stat(filepath.Join(dir, ca.fpath))
+		if ca.exists {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+	}
+}
+
+func TestCleanerMinFreeSpace(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2009-05-20_22-15-20-000000.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2009-05-20_22-15-21-000000.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:         "mypath",
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		// more than any disk
+		MinFreeSpace: 1 << 62,
+		Parent:       test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2009-05-20_22-15-20-000000.mp4"))
+	require.Error(t, err)
+
+	// the most recent segment is never removed, since it may be in use
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2009-05-20_22-15-21-000000.mp4"))
+	require.NoError(t, err)
+}
+
+func writeTestSegment(t *testing.T, fpath string) {
+	var buf seekablebuffer.Buffer
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &fmp4.CodecH264{
+				SPS: []byte{
+					0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+					0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+					0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+				},
+				PPS: []byte{0x08},
+			},
+		}},
+	}
+	err := init.Marshal(&buf)
+	require.NoError(t, err)
+
+	part := fmp4.Part{
+		Tracks: []*fmp4.PartTrack{{
+			ID: 1,
+			Samples: []*fmp4.PartSample{
+				{Duration: 90000, Payload: []byte{1}},
+				{Duration: 90000, IsNonSyncSample: true, Payload: bytes.Repeat([]byte{2}, 1000)},
+				{Duration: 90000, Payload: []byte{3}},
+				{Duration: 90000, IsNonSyncSample: true, Payload: bytes.Repeat([]byte{4}, 1000)},
+			},
+		}},
+	}
+
+	var partBuf seekablebuffer.Buffer
+	err = part.Marshal(&partBuf)
+	require.NoError(t, err)
+
+	_, err = buf.Write(partBuf.Bytes())
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+}
+
+func TestCleanerThin(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	segPaths := []string{
+		filepath.Join(dir, "mypath", "2009-05-19_22-15-25-000427.mp4"),
+		filepath.Join(dir, "mypath", "2009-05-20_22-15-20-000427.mp4"),
+		filepath.Join(dir, "mypath", "2009-05-20_22-15-24-000427.mp4"),
+	}
+
+	for _, fpath := range segPaths {
+		writeTestSegment(t, fpath)
+	}
+
+	origFi, err := os.Stat(segPaths[0])
+	require.NoError(t, err)
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:            "mypath",
+				RecordPath:      filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat:    conf.RecordFormatFMP4,
+				RecordThinAfter: conf.StringDuration(1 * time.Hour),
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	// old segments are thinned
+	fi, err := os.Stat(segPaths[0])
+	require.NoError(t, err)
+	require.Less(t, fi.Size(), origFi.Size())
+
+	md, err := (&recordstore.Segment{Fpath: segPaths[0]}).ReadMetadata()
+	require.NoError(t, err)
+	require.True(t, md.Thinned)
+
+	// recent segments are not
+	for _, fpath := range segPaths[1:] {
+		fi, err = os.Stat(fpath)
+		require.NoError(t, err)
+		require.Equal(t, origFi.Size(), fi.Size())
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package recordcleaner
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func diskFreeSpace(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	err := unix.Statfs(dir, &st)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(st.Bavail) * uint64(st.Bsize), nil //nolint:unconvert
+}
@@ -4,3 +4,8 @@ type format interface {
 	initialize()
 	close()
 }
+
+// formatTrack is a track that receives samples.
+type formatTrack interface {
+	write(sample *sample) error
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
 	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/bluenviron/mediacommon/pkg/codecs/ac3"
 	"github.com/bluenviron/mediacommon/pkg/codecs/av1"
@@ -106,7 +107,7 @@ func (f *formatFMP4) initialize() {
 	var setuppedFormats []rtspformat.Format
 	setuppedFormatsMap := make(map[rtspformat.Format]struct{})
 
-	addTrack := func(format rtspformat.Format, codec fmp4.Codec) *formatFMP4Track {
+	addTrack := func(format rtspformat.Format, codec fmp4.Codec) formatTrack {
 		initTrack := &fmp4.InitTrack{
 			TimeScale: uint32(format.ClockRate()),
 			Codec:     codec,
@@ -137,747 +138,746 @@ func (f *formatFMP4) initialize() {
 
 	for _, media := range f.ai.agent.Stream.Desc().Medias {
 		for _, forma := range media.Formats {
-			clockRate := forma.ClockRate()
+			setupFMP4Track(f.ai, media, forma, addTrack, updateCodecs)
+		}
+	}
 
-			switch forma := forma.(type) {
-			case *rtspformat.AV1:
-				codec := &fmp4.CodecAV1{
-					SequenceHeader: formatprocessor.AV1DefaultSequenceHeader,
-				}
-				track := addTrack(forma, codec)
+	if len(setuppedFormats) == 0 {
+		f.ai.Log(logger.Warn, "no supported tracks found, skipping recording")
+		return
+	}
 
-				firstReceived := false
+	n := 1
+	for _, medi := range f.ai.agent.Stream.Desc().Medias {
+		for _, forma := range medi.Formats {
+			if _, ok := setuppedFormatsMap[forma]; !ok {
+				f.ai.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
+			}
+			n++
+		}
+	}
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.AV1)
-						if tunit.TU == nil {
-							return nil
-						}
+	f.ai.Log(logger.Info, "recording %s",
+		defs.FormatsInfo(setuppedFormats))
+}
 
-						randomAccess := false
-
-						for _, obu := range tunit.TU {
-							var h av1.OBUHeader
-							err := h.Unmarshal(obu)
-							if err != nil {
-								return err
-							}
-
-							if h.Type == av1.OBUTypeSequenceHeader {
-								if !bytes.Equal(codec.SequenceHeader, obu) {
-									codec.SequenceHeader = obu
-									updateCodecs()
-								}
-								randomAccess = true
-							}
-						}
+func (f *formatFMP4) close() {
+	if f.currentSegment != nil {
+		for _, track := range f.tracks {
+			if track.nextSample != nil &&
+				unit.TimestampToDuration(track.nextSample.dts, int(track.initTrack.TimeScale)) > f.currentSegment.lastDTS {
+				f.currentSegment.lastDTS = unit.TimestampToDuration(track.nextSample.dts, int(track.initTrack.TimeScale))
+			}
+		}
 
-						if !firstReceived {
-							if !randomAccess {
-								return nil
-							}
-							firstReceived = true
-						}
+		f.currentSegment.close() //nolint:errcheck
+	}
+}
 
-						sampl, err := fmp4.NewPartSampleAV1(
-							randomAccess,
-							tunit.TU)
-						if err != nil {
-							return err
-						}
+// setupFMP4Track converts units of a format into fMP4 samples
+// and writes them into a track created with addTrack.
+func setupFMP4Track(
+	ai *recorderInstance,
+	media *description.Media,
+	forma rtspformat.Format,
+	addTrack func(rtspformat.Format, fmp4.Codec) formatTrack,
+	updateCodecs func(),
+) {
+	clockRate := forma.ClockRate()
+
+	switch forma := forma.(type) {
+	case *rtspformat.AV1:
+		codec := &fmp4.CodecAV1{
+			SequenceHeader: formatprocessor.AV1DefaultSequenceHeader,
+		}
+		track := addTrack(forma, codec)
 
-						return track.write(&sample{
-							PartSample: sampl,
-							dts:        tunit.PTS,
-							ntp:        tunit.NTP,
-						})
-					})
+		firstReceived := false
 
-			case *rtspformat.VP9:
-				codec := &fmp4.CodecVP9{
-					Width:             1280,
-					Height:            720,
-					Profile:           1,
-					BitDepth:          8,
-					ChromaSubsampling: 1,
-					ColorRange:        false,
-				}
-				track := addTrack(forma, codec)
-
-				firstReceived := false
-
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.VP9)
-						if tunit.Frame == nil {
-							return nil
-						}
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.AV1)
+				if tunit.TU == nil {
+					return nil
+				}
 
-						var h vp9.Header
-						err := h.Unmarshal(tunit.Frame)
-						if err != nil {
-							return err
-						}
+				randomAccess := false
 
-						randomAccess := false
-
-						if !h.NonKeyFrame {
-							randomAccess = true
-
-							if w := h.Width(); codec.Width != w {
-								codec.Width = w
-								updateCodecs()
-							}
-							if h := h.Width(); codec.Height != h {
-								codec.Height = h
-								updateCodecs()
-							}
-							if codec.Profile != h.Profile {
-								codec.Profile = h.Profile
-								updateCodecs()
-							}
-							if codec.BitDepth != h.ColorConfig.BitDepth {
-								codec.BitDepth = h.ColorConfig.BitDepth
-								updateCodecs()
-							}
-							if c := h.ChromaSubsampling(); codec.ChromaSubsampling != c {
-								codec.ChromaSubsampling = c
-								updateCodecs()
-							}
-							if codec.ColorRange != h.ColorConfig.ColorRange {
-								codec.ColorRange = h.ColorConfig.ColorRange
-								updateCodecs()
-							}
-						}
+				for _, obu := range tunit.TU {
+					var h av1.OBUHeader
+					err := h.Unmarshal(obu)
+					if err != nil {
+						return err
+					}
 
-						if !firstReceived {
-							if !randomAccess {
-								return nil
-							}
-							firstReceived = true
+					if h.Type == av1.OBUTypeSequenceHeader {
+						if !bytes.Equal(codec.SequenceHeader, obu) {
+							codec.SequenceHeader = obu
+							updateCodecs()
 						}
+						randomAccess = true
+					}
+				}
 
-						return track.write(&sample{
-							PartSample: &fmp4.PartSample{
-								IsNonSyncSample: !randomAccess,
-								Payload:         tunit.Frame,
-							},
-							dts: tunit.PTS,
-							ntp: tunit.NTP,
-						})
-					})
+				if !firstReceived {
+					if !randomAccess {
+						return nil
+					}
+					firstReceived = true
+				}
 
-			case *rtspformat.VP8:
-				// TODO
+				sampl, err := fmp4.NewPartSampleAV1(
+					randomAccess,
+					tunit.TU)
+				if err != nil {
+					return err
+				}
 
-			case *rtspformat.H265:
-				vps, sps, pps := forma.SafeParams()
+				return track.write(&sample{
+					PartSample: sampl,
+					dts:        tunit.PTS,
+					ntp:        tunit.NTP,
+				})
+			})
+
+	case *rtspformat.VP9:
+		codec := &fmp4.CodecVP9{
+			Width:             1280,
+			Height:            720,
+			Profile:           1,
+			BitDepth:          8,
+			ChromaSubsampling: 1,
+			ColorRange:        false,
+		}
+		track := addTrack(forma, codec)
+
+		firstReceived := false
 
-				if vps == nil || sps == nil || pps == nil {
-					vps = formatprocessor.H265DefaultVPS
-					sps = formatprocessor.H265DefaultSPS
-					pps = formatprocessor.H265DefaultPPS
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.VP9)
+				if tunit.Frame == nil {
+					return nil
 				}
 
-				codec := &fmp4.CodecH265{
-					VPS: vps,
-					SPS: sps,
-					PPS: pps,
+				var h vp9.Header
+				err := h.Unmarshal(tunit.Frame)
+				if err != nil {
+					return err
 				}
-				track := addTrack(forma, codec)
 
-				var dtsExtractor *h265.DTSExtractor2
+				randomAccess := false
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.H265)
-						if tunit.AU == nil {
-							return nil
-						}
+				if !h.NonKeyFrame {
+					randomAccess = true
 
-						randomAccess := false
-
-						for _, nalu := range tunit.AU {
-							typ := h265.NALUType((nalu[0] >> 1) & 0b111111)
-
-							switch typ {
-							case h265.NALUType_VPS_NUT:
-								if !bytes.Equal(codec.VPS, nalu) {
-									codec.VPS = nalu
-									updateCodecs()
-								}
-
-							case h265.NALUType_SPS_NUT:
-								if !bytes.Equal(codec.SPS, nalu) {
-									codec.SPS = nalu
-									updateCodecs()
-								}
-
-							case h265.NALUType_PPS_NUT:
-								if !bytes.Equal(codec.PPS, nalu) {
-									codec.PPS = nalu
-									updateCodecs()
-								}
-
-							case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
-								randomAccess = true
-							}
-						}
+					if w := h.Width(); codec.Width != w {
+						codec.Width = w
+						updateCodecs()
+					}
+					if h := h.Width(); codec.Height != h {
+						codec.Height = h
+						updateCodecs()
+					}
+					if codec.Profile != h.Profile {
+						codec.Profile = h.Profile
+						updateCodecs()
+					}
+					if codec.BitDepth != h.ColorConfig.BitDepth {
+						codec.BitDepth = h.ColorConfig.BitDepth
+						updateCodecs()
+					}
+					if c := h.ChromaSubsampling(); codec.ChromaSubsampling != c {
+						codec.ChromaSubsampling = c
+						updateCodecs()
+					}
+					if codec.ColorRange != h.ColorConfig.ColorRange {
+						codec.ColorRange = h.ColorConfig.ColorRange
+						updateCodecs()
+					}
+				}
 
-						if dtsExtractor == nil {
-							if !randomAccess {
-								return nil
-							}
-							dtsExtractor = h265.NewDTSExtractor2()
+				if !firstReceived {
+					if !randomAccess {
+						return nil
+					}
+					firstReceived = true
+				}
+
+				return track.write(&sample{
+					PartSample: &fmp4.PartSample{
+						IsNonSyncSample: !randomAccess,
+						Payload:         tunit.Frame,
+					},
+					dts: tunit.PTS,
+					ntp: tunit.NTP,
+				})
+			})
+
+	case *rtspformat.VP8:
+		// TODO
+
+	case *rtspformat.H265:
+		vps, sps, pps := forma.SafeParams()
+
+		if vps == nil || sps == nil || pps == nil {
+			vps = formatprocessor.H265DefaultVPS
+			sps = formatprocessor.H265DefaultSPS
+			pps = formatprocessor.H265DefaultPPS
+		}
+
+		codec := &fmp4.CodecH265{
+			VPS: vps,
+			SPS: sps,
+			PPS: pps,
+		}
+		track := addTrack(forma, codec)
+
+		var dtsExtractor *h265.DTSExtractor2
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.H265)
+				if tunit.AU == nil {
+					return nil
+				}
+
+				randomAccess := false
+
+				for _, nalu := range tunit.AU {
+					typ := h265.NALUType((nalu[0] >> 1) & 0b111111)
+
+					switch typ {
+					case h265.NALUType_VPS_NUT:
+						if !bytes.Equal(codec.VPS, nalu) {
+							codec.VPS = nalu
+							updateCodecs()
 						}
 
-						dts, err := dtsExtractor.Extract(tunit.AU, tunit.PTS)
-						if err != nil {
-							return err
+					case h265.NALUType_SPS_NUT:
+						if !bytes.Equal(codec.SPS, nalu) {
+							codec.SPS = nalu
+							updateCodecs()
 						}
 
-						sampl, err := fmp4.NewPartSampleH26x(
-							int32(tunit.PTS-dts),
-							randomAccess,
-							tunit.AU)
-						if err != nil {
-							return err
+					case h265.NALUType_PPS_NUT:
+						if !bytes.Equal(codec.PPS, nalu) {
+							codec.PPS = nalu
+							updateCodecs()
 						}
 
-						return track.write(&sample{
-							PartSample: sampl,
-							dts:        dts,
-							ntp:        tunit.NTP,
-						})
-					})
+					case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
+						randomAccess = true
+					}
+				}
 
-			case *rtspformat.H264:
-				sps, pps := forma.SafeParams()
+				if dtsExtractor == nil {
+					if !randomAccess {
+						return nil
+					}
+					dtsExtractor = h265.NewDTSExtractor2()
+				}
 
-				if sps == nil || pps == nil {
-					sps = formatprocessor.H264DefaultSPS
-					pps = formatprocessor.H264DefaultPPS
+				dts, err := dtsExtractor.Extract(tunit.AU, tunit.PTS)
+				if err != nil {
+					return err
 				}
 
-				codec := &fmp4.CodecH264{
-					SPS: sps,
-					PPS: pps,
+				sampl, err := fmp4.NewPartSampleH26x(
+					int32(tunit.PTS-dts),
+					randomAccess,
+					tunit.AU)
+				if err != nil {
+					return err
 				}
-				track := addTrack(forma, codec)
 
-				var dtsExtractor *h264.DTSExtractor2
+				return track.write(&sample{
+					PartSample: sampl,
+					dts:        dts,
+					ntp:        tunit.NTP,
+				})
+			})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.H264)
-						if tunit.AU == nil {
-							return nil
-						}
+	case *rtspformat.H264:
+		sps, pps := forma.SafeParams()
 
-						randomAccess := false
-
-						for _, nalu := range tunit.AU {
-							typ := h264.NALUType(nalu[0] & 0x1F)
-							switch typ {
-							case h264.NALUTypeSPS:
-								if !bytes.Equal(codec.SPS, nalu) {
-									codec.SPS = nalu
-									updateCodecs()
-								}
-
-							case h264.NALUTypePPS:
-								if !bytes.Equal(codec.PPS, nalu) {
-									codec.PPS = nalu
-									updateCodecs()
-								}
-
-							case h264.NALUTypeIDR:
-								randomAccess = true
-							}
-						}
+		if sps == nil || pps == nil {
+			sps = formatprocessor.H264DefaultSPS
+			pps = formatprocessor.H264DefaultPPS
+		}
 
-						if dtsExtractor == nil {
-							if !randomAccess {
-								return nil
-							}
-							dtsExtractor = h264.NewDTSExtractor2()
-						}
+		codec := &fmp4.CodecH264{
+			SPS: sps,
+			PPS: pps,
+		}
+		track := addTrack(forma, codec)
 
-						dts, err := dtsExtractor.Extract(tunit.AU, tunit.PTS)
-						if err != nil {
-							return err
+		var dtsExtractor *h264.DTSExtractor2
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.H264)
+				if tunit.AU == nil {
+					return nil
+				}
+
+				randomAccess := false
+
+				for _, nalu := range tunit.AU {
+					typ := h264.NALUType(nalu[0] & 0x1F)
+					switch typ {
+					case h264.NALUTypeSPS:
+						if !bytes.Equal(codec.SPS, nalu) {
+							codec.SPS = nalu
+							updateCodecs()
 						}
 
-						sampl, err := fmp4.NewPartSampleH26x(
-							int32(tunit.PTS-dts),
-							randomAccess,
-							tunit.AU)
-						if err != nil {
-							return err
+					case h264.NALUTypePPS:
+						if !bytes.Equal(codec.PPS, nalu) {
+							codec.PPS = nalu
+							updateCodecs()
 						}
 
-						return track.write(&sample{
-							PartSample: sampl,
-							dts:        dts,
-							ntp:        tunit.NTP,
-						})
-					})
+					case h264.NALUTypeIDR:
+						randomAccess = true
+					}
+				}
 
-			case *rtspformat.MPEG4Video:
-				config := forma.SafeParams()
+				if dtsExtractor == nil {
+					if !randomAccess {
+						return nil
+					}
+					dtsExtractor = h264.NewDTSExtractor2()
+				}
 
-				if config == nil {
-					config = formatprocessor.MPEG4VideoDefaultConfig
+				dts, err := dtsExtractor.Extract(tunit.AU, tunit.PTS)
+				if err != nil {
+					return err
 				}
 
-				codec := &fmp4.CodecMPEG4Video{
-					Config: config,
+				sampl, err := fmp4.NewPartSampleH26x(
+					int32(tunit.PTS-dts),
+					randomAccess,
+					tunit.AU)
+				if err != nil {
+					return err
 				}
-				track := addTrack(forma, codec)
 
-				firstReceived := false
-				var lastPTS int64
+				return track.write(&sample{
+					PartSample: sampl,
+					dts:        dts,
+					ntp:        tunit.NTP,
+				})
+			})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.MPEG4Video)
-						if tunit.Frame == nil {
-							return nil
-						}
+	case *rtspformat.MPEG4Video:
+		config := forma.SafeParams()
 
-						randomAccess := bytes.Contains(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+		if config == nil {
+			config = formatprocessor.MPEG4VideoDefaultConfig
+		}
 
-						if bytes.HasPrefix(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode)}) {
-							end := bytes.Index(tunit.Frame[4:], []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
-							if end >= 0 {
-								config := tunit.Frame[:end+4]
+		codec := &fmp4.CodecMPEG4Video{
+			Config: config,
+		}
+		track := addTrack(forma, codec)
+
+		firstReceived := false
+		var lastPTS int64
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.MPEG4Video)
+				if tunit.Frame == nil {
+					return nil
+				}
 
-								if !bytes.Equal(codec.Config, config) {
-									codec.Config = config
-									updateCodecs()
-								}
-							}
-						}
+				randomAccess := bytes.Contains(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
 
-						if !firstReceived {
-							if !randomAccess {
-								return nil
-							}
-							firstReceived = true
-						} else if tunit.PTS < lastPTS {
-							return fmt.Errorf("MPEG-4 Video streams with B-frames are not supported (yet)")
-						}
-						lastPTS = tunit.PTS
+				if bytes.HasPrefix(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.VisualObjectSequenceStartCode)}) {
+					end := bytes.Index(tunit.Frame[4:], []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+					if end >= 0 {
+						config := tunit.Frame[:end+4]
 
-						return track.write(&sample{
-							PartSample: &fmp4.PartSample{
-								Payload:         tunit.Frame,
-								IsNonSyncSample: !randomAccess,
-							},
-							dts: tunit.PTS,
-							ntp: tunit.NTP,
-						})
-					})
+						if !bytes.Equal(codec.Config, config) {
+							codec.Config = config
+							updateCodecs()
+						}
+					}
+				}
 
-			case *rtspformat.MPEG1Video:
-				codec := &fmp4.CodecMPEG1Video{
-					Config: formatprocessor.MPEG1VideoDefaultConfig,
+				if !firstReceived {
+					if !randomAccess {
+						return nil
+					}
+					firstReceived = true
+				} else if tunit.PTS < lastPTS {
+					return fmt.Errorf("MPEG-4 Video streams with B-frames are not supported (yet)")
+				}
+				lastPTS = tunit.PTS
+
+				return track.write(&sample{
+					PartSample: &fmp4.PartSample{
+						Payload:         tunit.Frame,
+						IsNonSyncSample: !randomAccess,
+					},
+					dts: tunit.PTS,
+					ntp: tunit.NTP,
+				})
+			})
+
+	case *rtspformat.MPEG1Video:
+		codec := &fmp4.CodecMPEG1Video{
+			Config: formatprocessor.MPEG1VideoDefaultConfig,
+		}
+		track := addTrack(forma, codec)
+
+		firstReceived := false
+		var lastPTS int64
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.MPEG1Video)
+				if tunit.Frame == nil {
+					return nil
 				}
-				track := addTrack(forma, codec)
 
-				firstReceived := false
-				var lastPTS int64
+				randomAccess := bytes.Contains(tunit.Frame, []byte{0, 0, 1, 0xB8})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.MPEG1Video)
-						if tunit.Frame == nil {
-							return nil
+				if bytes.HasPrefix(tunit.Frame, []byte{0, 0, 1, 0xB3}) {
+					end := bytes.Index(tunit.Frame[4:], []byte{0, 0, 1, 0xB8})
+					if end >= 0 {
+						config := tunit.Frame[:end+4]
+
+						if !bytes.Equal(codec.Config, config) {
+							codec.Config = config
+							updateCodecs()
 						}
+					}
+				}
 
-						randomAccess := bytes.Contains(tunit.Frame, []byte{0, 0, 1, 0xB8})
+				if !firstReceived {
+					if !randomAccess {
+						return nil
+					}
+					firstReceived = true
+				} else if tunit.PTS < lastPTS {
+					return fmt.Errorf("MPEG-1 Video streams with B-frames are not supported (yet)")
+				}
+				lastPTS = tunit.PTS
+
+				return track.write(&sample{
+					PartSample: &fmp4.PartSample{
+						Payload:         tunit.Frame,
+						IsNonSyncSample: !randomAccess,
+					},
+					dts: tunit.PTS,
+					ntp: tunit.NTP,
+				})
+			})
+
+	case *rtspformat.MJPEG:
+		codec := &fmp4.CodecMJPEG{
+			Width:  800,
+			Height: 600,
+		}
+		track := addTrack(forma, codec)
 
-						if bytes.HasPrefix(tunit.Frame, []byte{0, 0, 1, 0xB3}) {
-							end := bytes.Index(tunit.Frame[4:], []byte{0, 0, 1, 0xB8})
-							if end >= 0 {
-								config := tunit.Frame[:end+4]
+		parsed := false
 
-								if !bytes.Equal(codec.Config, config) {
-									codec.Config = config
-									updateCodecs()
-								}
-							}
-						}
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.MJPEG)
+				if tunit.Frame == nil {
+					return nil
+				}
 
-						if !firstReceived {
-							if !randomAccess {
-								return nil
-							}
-							firstReceived = true
-						} else if tunit.PTS < lastPTS {
-							return fmt.Errorf("MPEG-1 Video streams with B-frames are not supported (yet)")
-						}
-						lastPTS = tunit.PTS
+				if !parsed {
+					parsed = true
+					width, height, err := jpegExtractSize(tunit.Frame)
+					if err != nil {
+						return err
+					}
+					codec.Width = width
+					codec.Height = height
+					updateCodecs()
+				}
 
-						return track.write(&sample{
-							PartSample: &fmp4.PartSample{
-								Payload:         tunit.Frame,
-								IsNonSyncSample: !randomAccess,
-							},
-							dts: tunit.PTS,
-							ntp: tunit.NTP,
-						})
+				return track.write(&sample{
+					PartSample: &fmp4.PartSample{
+						Payload: tunit.Frame,
+					},
+					dts: tunit.PTS,
+					ntp: tunit.NTP,
+				})
+			})
+
+	case *rtspformat.Opus:
+		codec := &fmp4.CodecOpus{
+			ChannelCount: forma.ChannelCount,
+		}
+		track := addTrack(forma, codec)
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.Opus)
+				if tunit.Packets == nil {
+					return nil
+				}
+
+				pts := tunit.PTS
+
+				for _, packet := range tunit.Packets {
+					err := track.write(&sample{
+						PartSample: &fmp4.PartSample{
+							Payload: packet,
+						},
+						dts: pts,
+						ntp: tunit.NTP.Add(unit.TimestampToDuration(pts, clockRate)),
 					})
+					if err != nil {
+						return err
+					}
 
-			case *rtspformat.MJPEG:
-				codec := &fmp4.CodecMJPEG{
-					Width:  800,
-					Height: 600,
+					pts += int64(opus.PacketDuration(packet)) * int64(clockRate) / int64(time.Second)
 				}
-				track := addTrack(forma, codec)
 
-				parsed := false
+				return nil
+			})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.MJPEG)
-						if tunit.Frame == nil {
-							return nil
-						}
+	case *rtspformat.MPEG4Audio:
+		co := forma.GetConfig()
+		if co != nil {
+			codec := &fmp4.CodecMPEG4Audio{
+				Config: *co,
+			}
+			track := addTrack(forma, codec)
+
+			ai.addReader(
+				media,
+				forma,
+				func(u unit.Unit) error {
+					tunit := u.(*unit.MPEG4Audio)
+					if tunit.AUs == nil {
+						return nil
+					}
 
-						if !parsed {
-							parsed = true
-							width, height, err := jpegExtractSize(tunit.Frame)
-							if err != nil {
-								return err
-							}
-							codec.Width = width
-							codec.Height = height
-							updateCodecs()
-						}
+					for i, au := range tunit.AUs {
+						pts := tunit.PTS + int64(i)*mpeg4audio.SamplesPerAccessUnit
 
-						return track.write(&sample{
+						err := track.write(&sample{
 							PartSample: &fmp4.PartSample{
-								Payload: tunit.Frame,
+								Payload: au,
 							},
-							dts: tunit.PTS,
-							ntp: tunit.NTP,
+							dts: pts,
+							ntp: tunit.NTP.Add(unit.TimestampToDuration(pts, clockRate)),
 						})
-					})
-
-			case *rtspformat.Opus:
-				codec := &fmp4.CodecOpus{
-					ChannelCount: forma.ChannelCount,
-				}
-				track := addTrack(forma, codec)
-
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.Opus)
-						if tunit.Packets == nil {
-							return nil
+						if err != nil {
+							return err
 						}
+					}
 
-						pts := tunit.PTS
-
-						for _, packet := range tunit.Packets {
-							err := track.write(&sample{
-								PartSample: &fmp4.PartSample{
-									Payload: packet,
-								},
-								dts: pts,
-								ntp: tunit.NTP.Add(timestampToDuration(pts, clockRate)),
-							})
-							if err != nil {
-								return err
-							}
-
-							pts += int64(opus.PacketDuration(packet)) * int64(clockRate) / int64(time.Second)
-						}
+					return nil
+				})
+		}
 
-						return nil
-					})
+	case *rtspformat.MPEG1Audio:
+		codec := &fmp4.CodecMPEG1Audio{
+			SampleRate:   32000,
+			ChannelCount: 2,
+		}
+		track := addTrack(forma, codec)
 
-			case *rtspformat.MPEG4Audio:
-				co := forma.GetConfig()
-				if co != nil {
-					codec := &fmp4.CodecMPEG4Audio{
-						Config: *co,
-					}
-					track := addTrack(forma, codec)
-
-					f.ai.agent.Stream.AddReader(
-						f.ai,
-						media,
-						forma,
-						func(u unit.Unit) error {
-							tunit := u.(*unit.MPEG4Audio)
-							if tunit.AUs == nil {
-								return nil
-							}
-
-							for i, au := range tunit.AUs {
-								pts := tunit.PTS + int64(i)*mpeg4audio.SamplesPerAccessUnit
-
-								err := track.write(&sample{
-									PartSample: &fmp4.PartSample{
-										Payload: au,
-									},
-									dts: pts,
-									ntp: tunit.NTP.Add(timestampToDuration(pts, clockRate)),
-								})
-								if err != nil {
-									return err
-								}
-							}
-
-							return nil
-						})
-				}
+		parsed := false
 
-			case *rtspformat.MPEG1Audio:
-				codec := &fmp4.CodecMPEG1Audio{
-					SampleRate:   32000,
-					ChannelCount: 2,
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.MPEG1Audio)
+				if tunit.Frames == nil {
+					return nil
 				}
-				track := addTrack(forma, codec)
 
-				parsed := false
+				var dt time.Duration
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.MPEG1Audio)
-						if tunit.Frames == nil {
-							return nil
-						}
+				for _, frame := range tunit.Frames {
+					var h mpeg1audio.FrameHeader
+					err := h.Unmarshal(frame)
+					if err != nil {
+						return err
+					}
 
-						var dt time.Duration
-
-						for _, frame := range tunit.Frames {
-							var h mpeg1audio.FrameHeader
-							err := h.Unmarshal(frame)
-							if err != nil {
-								return err
-							}
-
-							if !parsed {
-								parsed = true
-								codec.SampleRate = h.SampleRate
-								codec.ChannelCount = mpeg1audioChannelCount(h.ChannelMode)
-								updateCodecs()
-							}
-
-							err = track.write(&sample{
-								PartSample: &fmp4.PartSample{
-									Payload: frame,
-								},
-								dts: tunit.PTS + tunit.PTS,
-								ntp: tunit.NTP,
-							})
-							if err != nil {
-								return err
-							}
-
-							dt += time.Duration(h.SampleCount()) *
-								time.Second / time.Duration(h.SampleRate)
-						}
+					if !parsed {
+						parsed = true
+						codec.SampleRate = h.SampleRate
+						codec.ChannelCount = mpeg1audioChannelCount(h.ChannelMode)
+						updateCodecs()
+					}
 
-						return nil
+					err = track.write(&sample{
+						PartSample: &fmp4.PartSample{
+							Payload: frame,
+						},
+						dts: tunit.PTS + tunit.PTS,
+						ntp: tunit.NTP,
 					})
+					if err != nil {
+						return err
+					}
 
-			case *rtspformat.AC3:
-				codec := &fmp4.CodecAC3{
-					SampleRate:   forma.SampleRate,
-					ChannelCount: forma.ChannelCount,
-					Fscod:        0,
-					Bsid:         8,
-					Bsmod:        0,
-					Acmod:        7,
-					LfeOn:        true,
-					BitRateCode:  7,
-				}
-				track := addTrack(forma, codec)
-
-				parsed := false
-
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.AC3)
-						if tunit.Frames == nil {
-							return nil
-						}
+					dt += time.Duration(h.SampleCount()) *
+						time.Second / time.Duration(h.SampleRate)
+				}
 
-						for i, frame := range tunit.Frames {
-							var syncInfo ac3.SyncInfo
-							err := syncInfo.Unmarshal(frame)
-							if err != nil {
-								return fmt.Errorf("invalid AC-3 frame: %w", err)
-							}
-
-							var bsi ac3.BSI
-							err = bsi.Unmarshal(frame[5:])
-							if err != nil {
-								return fmt.Errorf("invalid AC-3 frame: %w", err)
-							}
-
-							if !parsed {
-								parsed = true
-								codec.SampleRate = syncInfo.SampleRate()
-								codec.ChannelCount = bsi.ChannelCount()
-								codec.Fscod = syncInfo.Fscod
-								codec.Bsid = bsi.Bsid
-								codec.Bsmod = bsi.Bsmod
-								codec.Acmod = bsi.Acmod
-								codec.LfeOn = bsi.LfeOn
-								codec.BitRateCode = syncInfo.Frmsizecod >> 1
-								updateCodecs()
-							}
-
-							pts := tunit.PTS + int64(i)*ac3.SamplesPerFrame
-
-							err = track.write(&sample{
-								PartSample: &fmp4.PartSample{
-									Payload: frame,
-								},
-								dts: pts,
-								ntp: tunit.NTP.Add(timestampToDuration(pts, clockRate)),
-							})
-							if err != nil {
-								return err
-							}
-						}
+				return nil
+			})
+
+	case *rtspformat.AC3:
+		codec := &fmp4.CodecAC3{
+			SampleRate:   forma.SampleRate,
+			ChannelCount: forma.ChannelCount,
+			Fscod:        0,
+			Bsid:         8,
+			Bsmod:        0,
+			Acmod:        7,
+			LfeOn:        true,
+			BitRateCode:  7,
+		}
+		track := addTrack(forma, codec)
 
-						return nil
-					})
+		parsed := false
 
-			case *rtspformat.G722:
-				// TODO
-
-			case *rtspformat.G711:
-				codec := &fmp4.CodecLPCM{
-					LittleEndian: false,
-					BitDepth:     16,
-					SampleRate:   forma.SampleRate,
-					ChannelCount: forma.ChannelCount,
-				}
-				track := addTrack(forma, codec)
-
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.G711)
-						if tunit.Samples == nil {
-							return nil
-						}
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.AC3)
+				if tunit.Frames == nil {
+					return nil
+				}
 
-						var out []byte
-						if forma.MULaw {
-							out = g711.DecodeMulaw(tunit.Samples)
-						} else {
-							out = g711.DecodeAlaw(tunit.Samples)
-						}
+				for i, frame := range tunit.Frames {
+					var syncInfo ac3.SyncInfo
+					err := syncInfo.Unmarshal(frame)
+					if err != nil {
+						return fmt.Errorf("invalid AC-3 frame: %w", err)
+					}
 
-						return track.write(&sample{
-							PartSample: &fmp4.PartSample{
-								Payload: out,
-							},
-							dts: tunit.PTS,
-							ntp: tunit.NTP,
-						})
-					})
+					var bsi ac3.BSI
+					err = bsi.Unmarshal(frame[5:])
+					if err != nil {
+						return fmt.Errorf("invalid AC-3 frame: %w", err)
+					}
 
-			case *rtspformat.LPCM:
-				codec := &fmp4.CodecLPCM{
-					LittleEndian: false,
-					BitDepth:     forma.BitDepth,
-					SampleRate:   forma.SampleRate,
-					ChannelCount: forma.ChannelCount,
-				}
-				track := addTrack(forma, codec)
-
-				f.ai.agent.Stream.AddReader(
-					f.ai,
-					media,
-					forma,
-					func(u unit.Unit) error {
-						tunit := u.(*unit.LPCM)
-						if tunit.Samples == nil {
-							return nil
-						}
+					if !parsed {
+						parsed = true
+						codec.SampleRate = syncInfo.SampleRate()
+						codec.ChannelCount = bsi.ChannelCount()
+						codec.Fscod = syncInfo.Fscod
+						codec.Bsid = bsi.Bsid
+						codec.Bsmod = bsi.Bsmod
+						codec.Acmod = bsi.Acmod
+						codec.LfeOn = bsi.LfeOn
+						codec.BitRateCode = syncInfo.Frmsizecod >> 1
+						updateCodecs()
+					}
 
-						return track.write(&sample{
-							PartSample: &fmp4.PartSample{
-								Payload: tunit.Samples,
-							},
-							dts: tunit.PTS,
-							ntp: tunit.NTP,
-						})
+					pts := tunit.PTS + int64(i)*ac3.SamplesPerFrame
+
+					err = track.write(&sample{
+						PartSample: &fmp4.PartSample{
+							Payload: frame,
+						},
+						dts: pts,
+						ntp: tunit.NTP.Add(unit.TimestampToDuration(pts, clockRate)),
 					})
-			}
-		}
-	}
+					if err != nil {
+						return err
+					}
+				}
 
-	if len(setuppedFormats) == 0 {
-		f.ai.Log(logger.Warn, "no supported tracks found, skipping recording")
-		return
-	}
+				return nil
+			})
 
-	n := 1
-	for _, medi := range f.ai.agent.Stream.Desc().Medias {
-		for _, forma := range medi.Formats {
-			if _, ok := setuppedFormatsMap[forma]; !ok {
-				f.ai.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
-			}
-			n++
+	case *rtspformat.G722:
+		// TODO
+
+	case *rtspformat.G711:
+		codec := &fmp4.CodecLPCM{
+			LittleEndian: false,
+			BitDepth:     16,
+			SampleRate:   forma.SampleRate,
+			ChannelCount: forma.ChannelCount,
 		}
-	}
+		track := addTrack(forma, codec)
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.G711)
+				if tunit.Samples == nil {
+					return nil
+				}
 
-	f.ai.Log(logger.Info, "recording %s",
-		defs.FormatsInfo(setuppedFormats))
-}
+				var out []byte
+				if forma.MULaw {
+					out = g711.DecodeMulaw(tunit.Samples)
+				} else {
+					out = g711.DecodeAlaw(tunit.Samples)
+				}
 
-func (f *formatFMP4) close() {
-	if f.currentSegment != nil {
-		for _, track := range f.tracks {
-			if track.nextSample != nil &&
-				timestampToDuration(track.nextSample.dts, int(track.initTrack.TimeScale)) > f.currentSegment.lastDTS {
-				f.currentSegment.lastDTS = timestampToDuration(track.nextSample.dts, int(track.initTrack.TimeScale))
-			}
+				return track.write(&sample{
+					PartSample: &fmp4.PartSample{
+						Payload: out,
+					},
+					dts: tunit.PTS,
+					ntp: tunit.NTP,
+				})
+			})
+
+	case *rtspformat.LPCM:
+		codec := &fmp4.CodecLPCM{
+			LittleEndian: false,
+			BitDepth:     forma.BitDepth,
+			SampleRate:   forma.SampleRate,
+			ChannelCount: forma.ChannelCount,
 		}
+		track := addTrack(forma, codec)
+
+		ai.addReader(
+			media,
+			forma,
+			func(u unit.Unit) error {
+				tunit := u.(*unit.LPCM)
+				if tunit.Samples == nil {
+					return nil
+				}
 
-		f.currentSegment.close() //nolint:errcheck
+				return track.write(&sample{
+					PartSample: &fmp4.PartSample{
+						Payload: tunit.Samples,
+					},
+					dts: tunit.PTS,
+					ntp: tunit.NTP,
+				})
+			})
 	}
 }
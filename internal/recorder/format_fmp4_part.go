@@ -1,11 +1,16 @@
 package recorder
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/abema/go-mp4"
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
 
@@ -13,6 +18,56 @@ import (
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
+// markerSchemeIDURI is the scheme of emsg boxes that contain markers.
+const markerSchemeIDURI = "urn:mediamtx:marker"
+
+// writeMarkers writes markers as emsg boxes.
+// Presentation times are relative to the start of the segment.
+func writeMarkers(
+	f io.Writer,
+	markers []*recordstore.SegmentMarker,
+	firstID uint32,
+	segmentStart time.Time,
+) error {
+	for i, m := range markers {
+		messageData, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+
+		emsg := &mp4.Emsg{
+			FullBox: mp4.FullBox{
+				Version: 1,
+			},
+			SchemeIdUri:      markerSchemeIDURI,
+			Value:            m.Label,
+			Timescale:        1000,
+			PresentationTime: uint64(max(m.Time.Sub(segmentStart), 0) / time.Millisecond),
+			Id:               firstID + uint32(i),
+			MessageData:      messageData,
+		}
+
+		// reserve space for the box header
+		buf := bytes.NewBuffer(make([]byte, 8))
+
+		_, err = mp4.Marshal(buf, emsg, mp4.Context{})
+		if err != nil {
+			return err
+		}
+
+		byts := buf.Bytes()
+		binary.BigEndian.PutUint32(byts, uint32(len(byts)))
+		copy(byts[4:], "emsg")
+
+		_, err = f.Write(byts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func writePart(
 	f io.Writer,
 	sequenceNumber uint32,
@@ -63,12 +118,12 @@ func (p *formatFMP4Part) close() error {
 			return err
 		}
 
-		fi, err := os.Create(p.s.path)
+		fi, err := recordstore.CreateSegment(p.s.path, p.s.f.ai.agent.EncryptionKey)
 		if err != nil {
 			return err
 		}
 
-		p.s.f.ai.agent.OnSegmentCreate(p.s.path)
+		p.s.f.ai.onSegmentCreate(p.s.path, p.s.startNTP)
 
 		err = writeInit(fi, p.s.f.tracks)
 		if err != nil {
@@ -79,6 +134,18 @@ func (p *formatFMP4Part) close() error {
 		p.s.fi = fi
 	}
 
+	// markers added before the start of the segment have been stored in the metadata of previous segments.
+	markers, firstID := p.s.f.ai.agent.takeMarkers()
+	markers = slices.DeleteFunc(markers, func(m *recordstore.SegmentMarker) bool {
+		return m.Time.Before(p.s.startNTP)
+	})
+
+	// emsg boxes precede the moof box of the part they refer to.
+	err := writeMarkers(p.s.fi, markers, firstID, p.s.startNTP)
+	if err != nil {
+		return err
+	}
+
 	return writePart(p.s.fi, p.sequenceNumber, p.partTracks)
 }
 
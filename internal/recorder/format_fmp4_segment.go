@@ -2,13 +2,13 @@ package recorder
 
 import (
 	"io"
-	"os"
 	"time"
 
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
 
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
 func writeInit(f io.Writer, tracks []*formatFMP4Track) error {
@@ -37,13 +37,15 @@ type formatFMP4Segment struct {
 	startNTP time.Time
 
 	path    string
-	fi      *os.File
+	fi      recordstore.SegmentWriter
 	curPart *formatFMP4Part
 	lastDTS time.Duration
+	endDTS  time.Duration
 }
 
 func (s *formatFMP4Segment) initialize() {
 	s.lastDTS = s.startDTS
+	s.endDTS = s.f.ai.segmentEndDTS(s.startDTS, s.startNTP)
 }
 
 func (s *formatFMP4Segment) close() error {
@@ -62,7 +64,7 @@ func (s *formatFMP4Segment) close() error {
 
 		if err2 == nil {
 			duration := s.lastDTS - s.startDTS
-			s.f.ai.agent.OnSegmentComplete(s.path, duration)
+			s.f.ai.onSegmentComplete(s.path, s.startNTP, duration)
 		}
 	}
 
@@ -2,6 +2,8 @@ package recorder
 
 import (
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
 )
 
 type formatFMP4Track struct {
@@ -23,7 +25,7 @@ func (t *formatFMP4Track) write(sample *sample) error {
 	}
 	sample.Duration = uint32(t.nextSample.dts - sample.dts)
 
-	dtsDuration := timestampToDuration(sample.dts, int(t.initTrack.TimeScale))
+	dtsDuration := unit.TimestampToDuration(sample.dts, int(t.initTrack.TimeScale))
 
 	if t.f.currentSegment == nil {
 		t.f.currentSegment = &formatFMP4Segment{
@@ -42,11 +44,11 @@ func (t *formatFMP4Track) write(sample *sample) error {
 		return err
 	}
 
-	nextDTSDuration := timestampToDuration(t.nextSample.dts, int(t.initTrack.TimeScale))
+	nextDTSDuration := unit.TimestampToDuration(t.nextSample.dts, int(t.initTrack.TimeScale))
 
 	if (!t.f.hasVideo || t.initTrack.Codec.IsVideo()) &&
 		!t.nextSample.IsNonSyncSample &&
-		(nextDTSDuration-t.f.currentSegment.startDTS) >= t.f.ai.agent.SegmentDuration {
+		nextDTSDuration >= t.f.currentSegment.endDTS {
 		t.f.currentSegment.lastDTS = nextDTSDuration
 		err := t.f.currentSegment.close()
 		if err != nil {
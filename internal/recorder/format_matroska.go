@@ -0,0 +1,170 @@
+package recorder
+
+import (
+	"encoding/binary"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/matroska"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+func matroskaIsSupported(docType matroska.DocType, forma rtspformat.Format) bool {
+	switch forma.(type) {
+	case *rtspformat.AV1, *rtspformat.VP9, *rtspformat.VP8, *rtspformat.Opus:
+		return true
+
+	case *rtspformat.H265, *rtspformat.H264, *rtspformat.MJPEG,
+		*rtspformat.MPEG4Audio, *rtspformat.AC3, *rtspformat.G711, *rtspformat.LPCM:
+		return docType == matroska.DocTypeMatroska
+	}
+
+	return false
+}
+
+type formatMatroska struct {
+	ai *recorderInstance
+
+	docType        matroska.DocType
+	tracks         []*formatMatroskaTrack
+	hasVideo       bool
+	currentSegment *formatMatroskaSegment
+}
+
+func (f *formatMatroska) initialize() {
+	if f.ai.agent.Format == conf.RecordFormatWebM {
+		f.docType = matroska.DocTypeWebM
+	} else {
+		f.docType = matroska.DocTypeMatroska
+	}
+
+	var setuppedFormats []rtspformat.Format
+	setuppedFormatsMap := make(map[rtspformat.Format]struct{})
+
+	newTrack := func(format rtspformat.Format, codec fmp4.Codec) *formatMatroskaTrack {
+		track := &formatMatroskaTrack{
+			f:         f,
+			number:    len(f.tracks) + 1,
+			clockRate: format.ClockRate(),
+			codec:     codec,
+		}
+
+		f.tracks = append(f.tracks, track)
+		setuppedFormats = append(setuppedFormats, format)
+		setuppedFormatsMap[format] = struct{}{}
+		return track
+	}
+
+	addTrack := func(format rtspformat.Format, codec fmp4.Codec) formatTrack {
+		return newTrack(format, codec)
+	}
+
+	updateCodecs := func() {
+		// if codec parameters have been updated,
+		// and current segment has already written codec parameters on disk,
+		// close current segment.
+		if f.currentSegment != nil && f.currentSegment.fi != nil {
+			f.currentSegment.close() //nolint:errcheck
+			f.currentSegment = nil
+		}
+	}
+
+	for _, media := range f.ai.agent.Stream.Desc().Medias {
+		for _, forma := range media.Formats {
+			if !matroskaIsSupported(f.docType, forma) {
+				continue
+			}
+
+			if forma, ok := forma.(*rtspformat.VP8); ok {
+				f.setupVP8(media, forma, newTrack(forma, nil), updateCodecs)
+				continue
+			}
+
+			setupFMP4Track(f.ai, media, forma, addTrack, updateCodecs)
+		}
+	}
+
+	if len(setuppedFormats) == 0 {
+		f.ai.Log(logger.Warn, "no supported tracks found, skipping recording")
+		return
+	}
+
+	n := 1
+	for _, medi := range f.ai.agent.Stream.Desc().Medias {
+		for _, forma := range medi.Formats {
+			if _, ok := setuppedFormatsMap[forma]; !ok {
+				f.ai.Log(logger.Warn, "skipping track %d (%s)", n, forma.Codec())
+			}
+			n++
+		}
+	}
+
+	f.ai.Log(logger.Info, "recording %s",
+		defs.FormatsInfo(setuppedFormats))
+}
+
+// setupVP8 handles VP8, that is not supported by fMP4.
+func (f *formatMatroska) setupVP8(
+	media *description.Media,
+	forma *rtspformat.VP8,
+	track *formatMatroskaTrack,
+	updateCodecs func(),
+) {
+	track.vp8Width = 1280
+	track.vp8Height = 720
+
+	firstReceived := false
+
+	f.ai.addReader(
+		media,
+		forma,
+		func(u unit.Unit) error {
+			tunit := u.(*unit.VP8)
+			if tunit.Frame == nil {
+				return nil
+			}
+
+			// the first bit of the frame tag is zero in key frames,
+			// that contain the frame size after a start code.
+			// Specification: RFC 6386, 9.1
+			randomAccess := (tunit.Frame[0]&0x01) == 0 && len(tunit.Frame) >= 10
+
+			if randomAccess {
+				if w := int(binary.LittleEndian.Uint16(tunit.Frame[6:]) & 0x3FFF); track.vp8Width != w {
+					track.vp8Width = w
+					updateCodecs()
+				}
+				if h := int(binary.LittleEndian.Uint16(tunit.Frame[8:]) & 0x3FFF); track.vp8Height != h {
+					track.vp8Height = h
+					updateCodecs()
+				}
+			}
+
+			if !firstReceived {
+				if !randomAccess {
+					return nil
+				}
+				firstReceived = true
+			}
+
+			return track.write(&sample{
+				PartSample: &fmp4.PartSample{
+					IsNonSyncSample: !randomAccess,
+					Payload:         tunit.Frame,
+				},
+				dts: tunit.PTS,
+				ntp: tunit.NTP,
+			})
+		})
+}
+
+func (f *formatMatroska) close() {
+	if f.currentSegment != nil {
+		f.currentSegment.close() //nolint:errcheck
+	}
+}
@@ -0,0 +1,135 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/matroska"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type formatMatroskaSegment struct {
+	f        *formatMatroska
+	startDTS time.Duration
+	startNTP time.Time
+
+	path       string
+	fi         recordstore.SegmentWriter
+	w          *matroska.Writer
+	curCluster *matroska.Cluster
+	lastDTS    time.Duration
+	endDTS     time.Duration
+}
+
+func (s *formatMatroskaSegment) initialize() {
+	s.lastDTS = s.startDTS
+	s.endDTS = s.f.ai.segmentEndDTS(s.startDTS, s.startNTP)
+}
+
+func (s *formatMatroskaSegment) close() error {
+	var err error
+
+	if s.curCluster != nil {
+		err = s.writeCluster()
+	}
+
+	if s.fi != nil {
+		s.f.ai.Log(logger.Debug, "closing segment %s", s.path)
+
+		duration := s.lastDTS - s.startDTS
+
+		if err == nil {
+			err = s.w.Close(duration)
+		}
+
+		err2 := s.fi.Close()
+		if err == nil {
+			err = err2
+		}
+
+		if err2 == nil {
+			s.f.ai.onSegmentComplete(s.path, s.startNTP, duration)
+		}
+	}
+
+	return err
+}
+
+// writeCluster writes the current cluster to disk.
+// Since clusters are written when they are complete, when a system failure occurs, only the last cluster gets lost.
+func (s *formatMatroskaSegment) writeCluster() error {
+	cluster := s.curCluster
+	s.curCluster = nil
+
+	if s.fi == nil {
+		s.path = recordstore.Path{Start: s.startNTP}.Encode(s.f.ai.pathFormat)
+		s.f.ai.Log(logger.Debug, "creating segment %s", s.path)
+
+		err := os.MkdirAll(filepath.Dir(s.path), 0o755)
+		if err != nil {
+			return err
+		}
+
+		tracks := make([]*matroska.Track, len(s.f.tracks))
+		for i, track := range s.f.tracks {
+			tracks[i], err = track.matroskaTrack()
+			if err != nil {
+				return err
+			}
+		}
+
+		fi, err := recordstore.CreateSegment(s.path, s.f.ai.agent.EncryptionKey)
+		if err != nil {
+			return err
+		}
+
+		s.f.ai.onSegmentCreate(s.path, s.startNTP)
+
+		w := &matroska.Writer{
+			W:       fi,
+			DocType: s.f.docType,
+			Tracks:  tracks,
+			Date:    s.startNTP,
+		}
+		err = w.Initialize()
+		if err != nil {
+			fi.Close()
+			return err
+		}
+
+		s.fi = fi
+		s.w = w
+	}
+
+	return s.w.WriteCluster(cluster)
+}
+
+func (s *formatMatroskaSegment) write(track *formatMatroskaTrack, sample *sample, dtsDuration time.Duration) error {
+	s.lastDTS = dtsDuration
+
+	if s.curCluster != nil &&
+		(dtsDuration-s.startDTS-s.curCluster.Timestamp) >= min(s.f.ai.agent.PartDuration, matroska.MaxClusterDuration) {
+		err := s.writeCluster()
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.curCluster == nil {
+		s.curCluster = &matroska.Cluster{
+			Timestamp: dtsDuration - s.startDTS,
+		}
+	}
+
+	s.curCluster.Blocks = append(s.curCluster.Blocks, &matroska.Block{
+		Track:     track.number,
+		Timestamp: dtsDuration - s.startDTS + unit.TimestampToDuration(int64(sample.PTSOffset), track.clockRate),
+		Keyframe:  !sample.IsNonSyncSample,
+		Payload:   sample.Payload,
+	})
+
+	return nil
+}
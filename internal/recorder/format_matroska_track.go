@@ -0,0 +1,77 @@
+package recorder
+
+import (
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+
+	"github.com/bluenviron/mediamtx/internal/matroska"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type formatMatroskaTrack struct {
+	f         *formatMatroska
+	number    int
+	clockRate int
+
+	// nil in case of VP8, that is not supported by fMP4.
+	codec     fmp4.Codec
+	vp8Width  int
+	vp8Height int
+}
+
+func (t *formatMatroskaTrack) isVideo() bool {
+	return t.codec == nil || t.codec.IsVideo()
+}
+
+func (t *formatMatroskaTrack) matroskaTrack() (*matroska.Track, error) {
+	if t.codec == nil {
+		return &matroska.Track{
+			Number:  t.number,
+			CodecID: matroska.CodecIDVP8,
+			Width:   t.vp8Width,
+			Height:  t.vp8Height,
+		}, nil
+	}
+
+	return matroska.TrackFromFMP4Codec(t.number, t.codec)
+}
+
+func (t *formatMatroskaTrack) write(sample *sample) error {
+	// wait the first video sample before setting hasVideo
+	if t.isVideo() {
+		t.f.hasVideo = true
+	}
+
+	dtsDuration := unit.TimestampToDuration(sample.dts, t.clockRate)
+
+	switch {
+	case t.f.currentSegment == nil:
+		t.f.currentSegment = &formatMatroskaSegment{
+			f:        t.f,
+			startDTS: dtsDuration,
+			startNTP: sample.ntp,
+		}
+		t.f.currentSegment.initialize()
+
+	// timestamps of clusters are unsigned. Reject the sample silently.
+	case (dtsDuration - t.f.currentSegment.startDTS) < 0:
+		return nil
+
+	case (!t.f.hasVideo || t.isVideo()) &&
+		!sample.IsNonSyncSample &&
+		dtsDuration >= t.f.currentSegment.endDTS:
+		t.f.currentSegment.lastDTS = dtsDuration
+		err := t.f.currentSegment.close()
+		if err != nil {
+			return err
+		}
+
+		t.f.currentSegment = &formatMatroskaSegment{
+			f:        t.f,
+			startDTS: dtsDuration,
+			startNTP: sample.ntp,
+		}
+		t.f.currentSegment.initialize()
+	}
+
+	return t.f.currentSegment.write(t, sample, dtsDuration)
+}
@@ -29,16 +29,6 @@ func multiplyAndDivide(v, m, d int64) int64 {
 	return (secs*m + dec*m/d)
 }
 
-func multiplyAndDivide2(v, m, d time.Duration) time.Duration {
-	secs := v / d
-	dec := v % d
-	return (secs*m + dec*m/d)
-}
-
-func timestampToDuration(t int64, clockRate int) time.Duration {
-	return multiplyAndDivide2(time.Duration(t), time.Second, time.Duration(clockRate))
-}
-
 type dynamicWriter struct {
 	w io.Writer
 }
@@ -87,8 +77,7 @@ func (f *formatMPEGTS) initialize() {
 
 				var dtsExtractor *h265.DTSExtractor2
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -112,7 +101,7 @@ func (f *formatMPEGTS) initialize() {
 						}
 
 						return f.write(
-							timestampToDuration(dts, clockRate),
+							unit.TimestampToDuration(dts, clockRate),
 							tunit.NTP,
 							true,
 							randomAccess,
@@ -132,8 +121,7 @@ func (f *formatMPEGTS) initialize() {
 
 				var dtsExtractor *h264.DTSExtractor2
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -157,7 +145,7 @@ func (f *formatMPEGTS) initialize() {
 						}
 
 						return f.write(
-							timestampToDuration(dts, clockRate),
+							unit.TimestampToDuration(dts, clockRate),
 							tunit.NTP,
 							true,
 							randomAccess,
@@ -178,8 +166,7 @@ func (f *formatMPEGTS) initialize() {
 				firstReceived := false
 				var lastPTS int64
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -198,7 +185,7 @@ func (f *formatMPEGTS) initialize() {
 						randomAccess := bytes.Contains(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
 
 						return f.write(
-							timestampToDuration(tunit.PTS, clockRate),
+							unit.TimestampToDuration(tunit.PTS, clockRate),
 							tunit.NTP,
 							true,
 							randomAccess,
@@ -217,8 +204,7 @@ func (f *formatMPEGTS) initialize() {
 				firstReceived := false
 				var lastPTS int64
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -237,7 +223,7 @@ func (f *formatMPEGTS) initialize() {
 						randomAccess := bytes.Contains(tunit.Frame, []byte{0, 0, 1, 0xB8})
 
 						return f.write(
-							timestampToDuration(tunit.PTS, clockRate),
+							unit.TimestampToDuration(tunit.PTS, clockRate),
 							tunit.NTP,
 							true,
 							randomAccess,
@@ -255,8 +241,7 @@ func (f *formatMPEGTS) initialize() {
 					ChannelCount: forma.ChannelCount,
 				})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -266,7 +251,7 @@ func (f *formatMPEGTS) initialize() {
 						}
 
 						return f.write(
-							timestampToDuration(tunit.PTS, clockRate),
+							unit.TimestampToDuration(tunit.PTS, clockRate),
 							tunit.NTP,
 							false,
 							true,
@@ -288,8 +273,7 @@ func (f *formatMPEGTS) initialize() {
 						Config: *co,
 					})
 
-					f.ai.agent.Stream.AddReader(
-						f.ai,
+					f.ai.addReader(
 						media,
 						forma,
 						func(u unit.Unit) error {
@@ -299,7 +283,7 @@ func (f *formatMPEGTS) initialize() {
 							}
 
 							return f.write(
-								timestampToDuration(tunit.PTS, clockRate),
+								unit.TimestampToDuration(tunit.PTS, clockRate),
 								tunit.NTP,
 								false,
 								true,
@@ -316,8 +300,7 @@ func (f *formatMPEGTS) initialize() {
 			case *rtspformat.MPEG1Audio:
 				track := addTrack(forma, &mpegts.CodecMPEG1Audio{})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -327,7 +310,7 @@ func (f *formatMPEGTS) initialize() {
 						}
 
 						return f.write(
-							timestampToDuration(tunit.PTS, clockRate),
+							unit.TimestampToDuration(tunit.PTS, clockRate),
 							tunit.NTP,
 							false,
 							true,
@@ -343,8 +326,7 @@ func (f *formatMPEGTS) initialize() {
 			case *rtspformat.AC3:
 				track := addTrack(forma, &mpegts.CodecAC3{})
 
-				f.ai.agent.Stream.AddReader(
-					f.ai,
+				f.ai.addReader(
 					media,
 					forma,
 					func(u unit.Unit) error {
@@ -354,7 +336,7 @@ func (f *formatMPEGTS) initialize() {
 						}
 
 						return f.write(
-							timestampToDuration(tunit.PTS, clockRate),
+							unit.TimestampToDuration(tunit.PTS, clockRate),
 							tunit.NTP,
 							false,
 							true,
@@ -429,7 +411,7 @@ func (f *formatMPEGTS) write(
 		f.currentSegment.initialize()
 	case (!f.hasVideo || isVideo) &&
 		randomAccess &&
-		(dtsDuration-f.currentSegment.startDTS) >= f.ai.agent.SegmentDuration:
+		dtsDuration >= f.currentSegment.endDTS:
 		f.currentSegment.lastDTS = dtsDuration
 		err := f.currentSegment.close()
 		if err != nil {
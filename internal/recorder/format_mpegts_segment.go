@@ -15,14 +15,16 @@ type formatMPEGTSSegment struct {
 	startNTP time.Time
 
 	path      string
-	fi        *os.File
+	fi        recordstore.SegmentWriter
 	lastFlush time.Duration
 	lastDTS   time.Duration
+	endDTS    time.Duration
 }
 
 func (s *formatMPEGTSSegment) initialize() {
 	s.lastFlush = s.startDTS
 	s.lastDTS = s.startDTS
+	s.endDTS = s.f.ai.segmentEndDTS(s.startDTS, s.startNTP)
 	s.f.dw.setTarget(s)
 }
 
@@ -38,7 +40,7 @@ func (s *formatMPEGTSSegment) close() error {
 
 		if err2 == nil {
 			duration := s.lastDTS - s.startDTS
-			s.f.ai.agent.OnSegmentComplete(s.path, duration)
+			s.f.ai.onSegmentComplete(s.path, s.startNTP, duration)
 		}
 	}
 
@@ -55,12 +57,12 @@ func (s *formatMPEGTSSegment) Write(p []byte) (int, error) {
 			return 0, err
 		}
 
-		fi, err := os.Create(s.path)
+		fi, err := recordstore.CreateSegment(s.path, s.f.ai.agent.EncryptionKey)
 		if err != nil {
 			return 0, err
 		}
 
-		s.f.ai.agent.OnSegmentCreate(s.path)
+		s.f.ai.onSegmentCreate(s.path, s.startNTP)
 
 		s.fi = fi
 	}
@@ -0,0 +1,100 @@
+package recorder
+
+import (
+	"bytes"
+	"time"
+
+	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/pkg/codecs/vp9"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// isKeyframe checks whether a unit is a video random access point,
+// with the same criteria used by formats.
+func isKeyframe(u unit.Unit) bool {
+	switch tunit := u.(type) {
+	case *unit.H264:
+		return tunit.AU != nil && h264.IDRPresent(tunit.AU)
+
+	case *unit.H265:
+		return tunit.AU != nil && h265.IsRandomAccess(tunit.AU)
+
+	case *unit.AV1:
+		for _, obu := range tunit.TU {
+			var h av1.OBUHeader
+			if h.Unmarshal(obu) == nil && h.Type == av1.OBUTypeSequenceHeader {
+				return true
+			}
+		}
+		return false
+
+	case *unit.VP9:
+		if tunit.Frame == nil {
+			return false
+		}
+		var h vp9.Header
+		return h.Unmarshal(tunit.Frame) == nil && !h.NonKeyFrame
+
+	case *unit.MPEG4Video:
+		return bytes.Contains(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+
+	case *unit.MPEG1Video:
+		return bytes.Contains(tunit.Frame, []byte{0, 0, 1, 0xB8})
+
+	case *unit.MJPEG:
+		return tunit.Frame != nil
+
+	default:
+		return false
+	}
+}
+
+type preRollEntry struct {
+	forma      rtspformat.Format
+	u          unit.Unit
+	receivedAt time.Time
+	keyframe   bool
+}
+
+// preRollBuffer stores the most recent units of a stream,
+// in order to write them when an event is triggered.
+type preRollBuffer struct {
+	duration time.Duration
+
+	entries []*preRollEntry
+}
+
+func (b *preRollBuffer) push(e *preRollEntry) {
+	e.keyframe = isKeyframe(e.u)
+	b.entries = append(b.entries, e)
+
+	i := 0
+	for i < len(b.entries) && e.receivedAt.Sub(b.entries[i].receivedAt) > b.duration {
+		i++
+	}
+
+	// units that precede the first keyframe are discarded by formats,
+	// therefore the last keyframe before the cutoff is kept,
+	// in order to provide the whole duration.
+	for j := min(i, len(b.entries)-1); j >= 0; j-- {
+		if b.entries[j].keyframe {
+			i = j
+			break
+		}
+	}
+
+	if i != 0 {
+		b.entries = b.entries[i:]
+	}
+}
+
+func (b *preRollBuffer) pull() []*preRollEntry {
+	entries := b.entries
+	b.entries = nil
+	return entries
+}
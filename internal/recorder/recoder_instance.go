@@ -1,14 +1,20 @@
 package recorder
 
 import (
+	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
 )
 
 type sample struct {
@@ -23,6 +29,11 @@ type recorderInstance struct {
 	pathFormat string
 	format     format
 
+	// event mode
+	preRoll     *preRollBuffer
+	readers     map[rtspformat.Format]stream.ReadFunc
+	eventLabels []string
+
 	terminate chan struct{}
 	done      chan struct{}
 }
@@ -43,18 +54,28 @@ func (ai *recorderInstance) initialize() {
 	ai.terminate = make(chan struct{})
 	ai.done = make(chan struct{})
 
-	switch ai.agent.Format {
-	case conf.RecordFormatMPEGTS:
-		ai.format = &formatMPEGTS{
-			ai: ai,
+	if ai.agent.Mode == conf.RecordModeEvent {
+		// read the whole stream and write units to the format
+		// only when an event is in progress.
+		ai.preRoll = &preRollBuffer{
+			duration: ai.agent.PreRoll,
 		}
-		ai.format.initialize()
 
-	default:
-		ai.format = &formatFMP4{
-			ai: ai,
+		for _, media := range ai.agent.Stream.Desc().Medias {
+			for _, forma := range media.Formats {
+				ai.agent.Stream.AddReader(
+					ai,
+					media,
+					forma,
+					func(u unit.Unit) error {
+						return ai.onEventUnit(forma, u)
+					})
+			}
 		}
-		ai.format.initialize()
+
+		ai.Log(logger.Info, "waiting for events")
+	} else {
+		ai.format = ai.newFormat()
 	}
 
 	ai.agent.Stream.StartReader(ai)
@@ -79,5 +100,163 @@ func (ai *recorderInstance) run() {
 
 	ai.agent.Stream.RemoveReader(ai)
 
-	ai.format.close()
+	if ai.format != nil {
+		ai.format.close()
+	}
+}
+
+func (ai *recorderInstance) newFormat() format {
+	var f format
+
+	switch ai.agent.Format {
+	case conf.RecordFormatMPEGTS:
+		f = &formatMPEGTS{
+			ai: ai,
+		}
+
+	case conf.RecordFormatMatroska, conf.RecordFormatWebM:
+		f = &formatMatroska{
+			ai: ai,
+		}
+
+	default:
+		f = &formatFMP4{
+			ai: ai,
+		}
+	}
+
+	f.initialize()
+	return f
+}
+
+// addReader is called by formats in order to read a stream format.
+func (ai *recorderInstance) addReader(media *description.Media, forma rtspformat.Format, cb stream.ReadFunc) {
+	if ai.agent.Mode == conf.RecordModeEvent {
+		ai.readers[forma] = cb
+		return
+	}
+
+	ai.agent.Stream.AddReader(ai, media, forma, cb)
+}
+
+func (ai *recorderInstance) onEventUnit(forma rtspformat.Format, u unit.Unit) error {
+	now := time.Now()
+	active, labels := ai.agent.eventState(now)
+
+	if ai.format == nil {
+		if !active {
+			ai.preRoll.push(&preRollEntry{
+				forma:      forma,
+				u:          u,
+				receivedAt: now,
+			})
+			return nil
+		}
+
+		ai.Log(logger.Info, "event started")
+
+		ai.eventLabels = labels
+		ai.readers = make(map[rtspformat.Format]stream.ReadFunc)
+		ai.format = ai.newFormat()
+
+		for _, entry := range ai.preRoll.pull() {
+			err := ai.writeEventUnit(entry.forma, entry.u)
+			if err != nil {
+				return err
+			}
+		}
+	} else if !active {
+		ai.format.close()
+		ai.format = nil
+		ai.readers = nil
+		ai.eventLabels = nil
+
+		ai.Log(logger.Info, "event ended")
+
+		ai.preRoll.push(&preRollEntry{
+			forma:      forma,
+			u:          u,
+			receivedAt: now,
+		})
+		return nil
+	}
+
+	ai.eventLabels = labels
+
+	return ai.writeEventUnit(forma, u)
+}
+
+func (ai *recorderInstance) writeEventUnit(forma rtspformat.Format, u unit.Unit) error {
+	cb, ok := ai.readers[forma]
+	if !ok {
+		return nil
+	}
+	return cb(u)
+}
+
+// segmentEndDTS returns the DTS after which a segment is closed,
+// at the first random access point.
+func (ai *recorderInstance) segmentEndDTS(startDTS time.Duration, startNTP time.Time) time.Duration {
+	if !ai.agent.SegmentAlign {
+		return startDTS + ai.agent.SegmentDuration
+	}
+	return startDTS + nextSegmentBoundary(startNTP, ai.agent.SegmentDuration).Sub(startNTP)
+}
+
+func (ai *recorderInstance) updateIndex(path string, start time.Time, duration time.Duration) {
+	if ai.agent.Index == nil {
+		return
+	}
+
+	var size int64
+	if fi, err := os.Stat(path); err == nil {
+		size = fi.Size()
+	}
+
+	err := ai.agent.Index.Put(recordstore.IndexEntry{
+		PathName: ai.agent.PathName,
+		Fpath:    path,
+		Start:    start,
+		Duration: duration,
+		Size:     size,
+	})
+	if err != nil {
+		ai.Log(logger.Warn, "unable to update index: %v", err)
+	}
+}
+
+func (ai *recorderInstance) onSegmentCreate(path string, start time.Time) {
+	// segments are indexed as soon as they are created,
+	// in order to allow the playback of the current one.
+	ai.updateIndex(path, start, 0)
+
+	ai.agent.OnSegmentCreate(path)
+}
+
+func (ai *recorderInstance) onSegmentComplete(path string, start time.Time, duration time.Duration) {
+	ai.updateIndex(path, start, duration)
+
+	labels := append([]string(nil), ai.agent.Labels...)
+	for _, label := range ai.eventLabels {
+		if !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+
+	if len(labels) != 0 {
+		// markers may have already been added to the metadata.
+		err := recordstore.UpdateSegmentMetadata(path, func(md *recordstore.SegmentMetadata) {
+			md.Labels = labels
+		})
+		if err != nil {
+			ai.Log(logger.Warn, "unable to write segment metadata: %v", err)
+		}
+	}
+
+	// segments are signed in a dedicated routine, since they are read back from disk.
+	if ai.agent.signer != nil {
+		ai.agent.signer.push(path, duration)
+	} else {
+		ai.agent.onSegmentSigned(path, duration)
+	}
 }
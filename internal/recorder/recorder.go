@@ -2,10 +2,16 @@
 package recorder
 
 import (
+	"crypto/ed25519"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -21,6 +27,16 @@ type Recorder struct {
 	Format            conf.RecordFormat
 	PartDuration      time.Duration
 	SegmentDuration   time.Duration
+	SegmentAlign      bool
+	Mode              conf.RecordMode
+	PreRoll           time.Duration
+	PostRoll          time.Duration
+	S3                *recordstore.S3
+	S3Key             string
+	SignKey           ed25519.PrivateKey
+	EncryptionKey     *recordstore.EncryptionKey
+	Index             *recordstore.Index
+	Labels            []string
 	PathName          string
 	Stream            *stream.Stream
 	OnSegmentCreate   OnSegmentCreateFunc
@@ -30,6 +46,16 @@ type Recorder struct {
 	restartPause time.Duration
 
 	currentInstance *recorderInstance
+	signer          *signer
+	uploader        *uploader
+
+	eventMutex  sync.Mutex
+	eventUntil  time.Time
+	eventLabels []string
+
+	markersMutex   sync.Mutex
+	pendingMarkers []*recordstore.SegmentMarker
+	nextMarkerID   uint32
 
 	terminate chan struct{}
 	done      chan struct{}
@@ -52,6 +78,45 @@ func (w *Recorder) Initialize() {
 	w.terminate = make(chan struct{})
 	w.done = make(chan struct{})
 
+	pathFormat := recordstore.PathAddExtension(
+		strings.ReplaceAll(w.PathFormat, "%path", w.PathName),
+		w.Format,
+	)
+
+	if w.S3 != nil {
+		w.uploader = &uploader{
+			s3:         w.S3,
+			pathFormat: pathFormat,
+			keyFormat: recordstore.PathAddExtension(
+				strings.ReplaceAll(w.S3Key, "%path", w.PathName),
+				w.Format,
+			),
+			pathName: w.PathName,
+			index:    w.Index,
+			parent:   w,
+		}
+		w.uploader.initialize()
+	}
+
+	if w.SignKey != nil {
+		w.signer = &signer{
+			key:        w.SignKey,
+			pathFormat: pathFormat,
+			pathName:   w.PathName,
+			index:      w.Index,
+			onSigned:   w.onSegmentSigned,
+			parent:     w,
+		}
+
+		// the previous segment may have been uploaded to a bucket.
+		if w.uploader != nil {
+			w.signer.s3 = w.S3
+			w.signer.keyFormat = w.uploader.keyFormat
+		}
+
+		w.signer.initialize()
+	}
+
 	w.currentInstance = &recorderInstance{
 		agent: w,
 	}
@@ -70,6 +135,85 @@ func (w *Recorder) Close() {
 	w.Log(logger.Info, "recording stopped")
 	close(w.terminate)
 	<-w.done
+
+	if w.signer != nil {
+		w.signer.close()
+	}
+
+	if w.uploader != nil {
+		w.uploader.close()
+	}
+}
+
+// Trigger starts an event recording, or extends the current one.
+// It can be called only when Mode is RecordModeEvent.
+func (w *Recorder) Trigger(labels []string) {
+	w.eventMutex.Lock()
+	defer w.eventMutex.Unlock()
+
+	now := time.Now()
+
+	// a new event is starting
+	if !now.Before(w.eventUntil) {
+		w.eventLabels = nil
+	}
+
+	w.eventUntil = now.Add(w.PostRoll)
+
+	// labels are copied in order to allow readers to use them without locking
+	newLabels := append([]string(nil), w.eventLabels...)
+	for _, label := range labels {
+		if !slices.Contains(newLabels, label) {
+			newLabels = append(newLabels, label)
+		}
+	}
+	w.eventLabels = newLabels
+}
+
+// AddMarker embeds a marker into the recording.
+// Markers are written as emsg boxes into the next part of fMP4 segments,
+// while other formats can't contain them and an error is returned.
+func (w *Recorder) AddMarker(m *recordstore.SegmentMarker) error {
+	if w.Format != conf.RecordFormatFMP4 {
+		return fmt.Errorf("markers can only be embedded into fMP4 segments")
+	}
+
+	w.markersMutex.Lock()
+	defer w.markersMutex.Unlock()
+
+	w.pendingMarkers = append(w.pendingMarkers, m)
+
+	return nil
+}
+
+func (w *Recorder) takeMarkers() ([]*recordstore.SegmentMarker, uint32) {
+	w.markersMutex.Lock()
+	defer w.markersMutex.Unlock()
+
+	markers := w.pendingMarkers
+	w.pendingMarkers = nil
+
+	firstID := w.nextMarkerID
+	w.nextMarkerID += uint32(len(markers))
+
+	return markers, firstID
+}
+
+// onSegmentSigned is called when a segment is complete and,
+// if signing is enabled, after it has been signed.
+func (w *Recorder) onSegmentSigned(segmentPath string, duration time.Duration) {
+	w.OnSegmentComplete(segmentPath, duration)
+
+	if w.uploader != nil {
+		w.uploader.push(segmentPath)
+	}
+}
+
+func (w *Recorder) eventState(now time.Time) (bool, []string) {
+	w.eventMutex.Lock()
+	defer w.eventMutex.Unlock()
+
+	return now.Before(w.eventUntil), w.eventLabels
 }
 
 func (w *Recorder) run() {
@@ -1,12 +1,19 @@
 package recorder
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/abema/go-mp4"
 	"github.com/bluenviron/gortsplib/v4/pkg/description"
 	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
@@ -16,6 +23,8 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/matroska"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/bluenviron/mediamtx/internal/unit"
@@ -122,7 +131,7 @@ func TestRecorder(t *testing.T) {
 		}
 	}
 
-	for _, ca := range []string{"fmp4", "mpegts"} {
+	for _, ca := range []string{"fmp4", "mpegts", "matroska"} {
 		t.Run(ca, func(t *testing.T) {
 			stream, err := stream.New(
 				512,
@@ -144,17 +153,20 @@ func TestRecorder(t *testing.T) {
 			segDone := make(chan struct{}, 4)
 
 			var f conf.RecordFormat
-			if ca == "fmp4" {
-				f = conf.RecordFormatFMP4
-			} else {
-				f = conf.RecordFormatMPEGTS
-			}
-
 			var ext string
-			if ca == "fmp4" {
+
+			switch ca {
+			case "fmp4":
+				f = conf.RecordFormatFMP4
 				ext = "mp4"
-			} else {
+
+			case "mpegts":
+				f = conf.RecordFormatMPEGTS
 				ext = "ts"
+
+			default:
+				f = conf.RecordFormatMatroska
+				ext = "mkv"
 			}
 
 			n := 0
@@ -220,7 +232,8 @@ func TestRecorder(t *testing.T) {
 				<-segDone
 			}
 
-			if ca == "fmp4" {
+			switch ca {
+			case "fmp4":
 				var init fmp4.Init
 
 				func() {
@@ -285,7 +298,43 @@ func TestRecorder(t *testing.T) {
 
 				_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000."+ext))
 				require.NoError(t, err)
-			} else {
+
+			case "matroska":
+				func() {
+					f, err2 := os.Open(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000."+ext))
+					require.NoError(t, err2)
+					defer f.Close()
+
+					r := &matroska.Reader{R: f}
+					err2 = r.Initialize()
+					require.NoError(t, err2)
+
+					require.Equal(t, matroska.DocTypeMatroska, r.DocType)
+					require.Equal(t, 2*time.Second, r.Duration)
+					require.Equal(t, time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC), r.Date.UTC())
+
+					var codecIDs []string
+					for _, track := range r.Tracks {
+						codecIDs = append(codecIDs, track.CodecID)
+					}
+					require.Equal(t, []string{
+						matroska.CodecIDH264,
+						matroska.CodecIDH265,
+						matroska.CodecIDAAC,
+						matroska.CodecIDLPCMBig,
+						matroska.CodecIDLPCMBig,
+					}, codecIDs)
+
+					var blocks []*matroska.Block
+					err2 = r.ReadBlocks(func(b *matroska.Block) error {
+						blocks = append(blocks, b)
+						return nil
+					})
+					require.NoError(t, err2)
+					require.Equal(t, 10, len(blocks))
+				}()
+
+			default:
 				_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000."+ext))
 				require.NoError(t, err)
 
@@ -410,66 +459,903 @@ func TestRecorderFMP4NegativeDTS(t *testing.T) {
 	require.Equal(t, true, found)
 }
 
-func TestRecorderSkipTracks(t *testing.T) {
-	for _, ca := range []string{"fmp4", "mpegts"} {
-		t.Run(ca, func(t *testing.T) {
-			desc := &description.Session{Medias: []*description.Media{
-				{
-					Type:    description.MediaTypeVideo,
-					Formats: []rtspformat.Format{&rtspformat.H264{}},
-				},
-				{
-					Type:    description.MediaTypeVideo,
-					Formats: []rtspformat.Format{&rtspformat.VP8{}},
-				},
-			}}
+func TestRecorderMarkers(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
 
-			stream, err := stream.New(
-				512,
-				1460,
-				desc,
-				true,
-				test.NilLogger,
-			)
-			require.NoError(t, err)
-			defer stream.Close()
+	stream, err := stream.New(
+		512,
+		1460,
+		desc,
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer stream.Close()
 
-			dir, err := os.MkdirTemp("", "mediamtx-agent")
-			require.NoError(t, err)
-			defer os.RemoveAll(dir)
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
 
-			recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
 
-			n := 0
+	w := &Recorder{
+		PathFormat:      recordPath,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		SegmentDuration: 1 * time.Hour,
+		PathName:        "mypath",
+		Stream:          stream,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
 
-			l := test.Logger(func(l logger.Level, format string, args ...interface{}) {
-				if n == 0 {
-					require.Equal(t, logger.Warn, l)
-					require.Equal(t, "[recorder] skipping track 2 (VP8)", fmt.Sprintf(format, args...))
-				}
-				n++
-			})
+	startNTP := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
 
-			var fo conf.RecordFormat
-			if ca == "fmp4" {
-				fo = conf.RecordFormatFMP4
-			} else {
-				fo = conf.RecordFormatMPEGTS
-			}
+	err = w.AddMarker(&recordstore.SegmentMarker{
+		Time:  startNTP.Add(150 * time.Millisecond),
+		Label: "incident 42",
+		Data:  []byte(`{"id":42}`),
+	})
+	require.NoError(t, err)
 
-			w := &Recorder{
-				PathFormat:      recordPath,
-				Format:          fo,
-				PartDuration:    100 * time.Millisecond,
-				SegmentDuration: 1 * time.Second,
-				PathName:        "mypath",
-				Stream:          stream,
-				Parent:          l,
-			}
-			w.Initialize()
-			defer w.Close()
+	// markers that precede the segment are not embedded
+	err = w.AddMarker(&recordstore.SegmentMarker{
+		Time:  startNTP.Add(-time.Hour),
+		Label: "old",
+	})
+	require.NoError(t, err)
 
-			require.Equal(t, 2, n)
+	for i := 0; i < 3; i++ {
+		stream.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				PTS: int64(i) * 200 * 90000 / 1000,
+				NTP: startNTP.Add(time.Duration(i) * 200 * time.Millisecond),
+			},
+			AU: [][]byte{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			},
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	f, err := os.Open(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var emsgs []*mp4.Emsg
+
+	_, err = mp4.ReadBoxStructure(f, func(h *mp4.ReadHandle) (interface{}, error) {
+		if h.BoxInfo.Type.String() == "emsg" {
+			box, _, err2 := h.ReadPayload()
+			require.NoError(t, err2)
+			emsgs = append(emsgs, box.(*mp4.Emsg))
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(emsgs))
+	require.Equal(t, "urn:mediamtx:marker", emsgs[0].SchemeIdUri)
+	require.Equal(t, "incident 42", emsgs[0].Value)
+	require.Equal(t, uint32(1000), emsgs[0].Timescale)
+	require.Equal(t, uint64(150), emsgs[0].PresentationTime)
+	require.Equal(t, `{"time":"2008-05-20T22:15:25.15Z","label":"incident 42","data":{"id":42}}`,
+		string(emsgs[0].MessageData))
+}
+
+func TestRecorderSign(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	stream, err := stream.New(
+		512,
+		1460,
+		desc,
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer stream.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	w := &Recorder{
+		PathFormat:      recordPath,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		SegmentDuration: 1 * time.Second,
+		SignKey:         key,
+		PathName:        "mypath",
+		Stream:          stream,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
+
+	startNTP := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		stream.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				PTS: int64(i) * 90000,
+				NTP: startNTP.Add(time.Duration(i) * time.Second),
+			},
+			AU: [][]byte{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			},
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	segments, err := recordstore.FindLocalSegments(
+		recordstore.PathAddExtension(filepath.Join(dir, "mypath/%Y-%m-%d_%H-%M-%S-%f"), conf.RecordFormatFMP4))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(segments))
+
+	results, err := recordstore.VerifySegments(segments, "mypath", key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+
+	for i, res := range results {
+		require.Equal(t, recordstore.SegmentVerifyStatusValid, res.Status)
+
+		md, err2 := res.Segment.ReadMetadata()
+		require.NoError(t, err2)
+
+		if i == 0 {
+			require.Equal(t, "", md.Signature.PrevSHA256)
+		} else {
+			require.Equal(t, results[i-1].SHA256, md.Signature.PrevSHA256)
+		}
+	}
+
+	// the chain is continued after a restart
+	w = &Recorder{
+		PathFormat:      recordPath,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		SegmentDuration: 1 * time.Second,
+		SignKey:         key,
+		PathName:        "mypath",
+		Stream:          stream,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
+
+	for i := 10; i < 12; i++ {
+		stream.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				PTS: int64(i) * 90000,
+				NTP: startNTP.Add(time.Duration(i) * time.Second),
+			},
+			AU: [][]byte{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			},
 		})
 	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	segments, err = recordstore.FindLocalSegments(
+		recordstore.PathAddExtension(filepath.Join(dir, "mypath/%Y-%m-%d_%H-%M-%S-%f"), conf.RecordFormatFMP4))
+	require.NoError(t, err)
+	require.Equal(t, 4, len(segments))
+
+	md, err := segments[3].ReadMetadata()
+	require.NoError(t, err)
+	require.Equal(t, results[2].SHA256, md.Signature.PrevSHA256)
+}
+
+func TestRecorderSignResetChain(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	var signed []string
+
+	s := &signer{
+		key:        key,
+		pathFormat: filepath.Join(dir, "%Y-%m-%d_%H-%M-%S-%f.mp4"),
+		pathName:   "mypath",
+		onSigned: func(segmentPath string, _ time.Duration) {
+			signed = append(signed, segmentPath)
+		},
+		parent: test.NilLogger,
+	}
+	s.initialize()
+
+	paths := []string{
+		filepath.Join(dir, "2008-05-20_22-15-25-000000.mp4"),
+		filepath.Join(dir, "2008-05-20_22-15-26-000000.mp4"),
+		filepath.Join(dir, "2008-05-20_22-15-27-000000.mp4"),
+	}
+
+	// the second segment is missing, therefore it can't be signed.
+	for i, fpath := range paths {
+		if i != 1 {
+			err = os.WriteFile(fpath, []byte{byte(i), 2, 3, 4}, 0o644)
+			require.NoError(t, err)
+		}
+		s.push(fpath, time.Second)
+	}
+
+	s.close()
+
+	require.Equal(t, paths, signed)
+
+	md, err := (&recordstore.Segment{Fpath: paths[0]}).ReadMetadata()
+	require.NoError(t, err)
+	require.NotNil(t, md.Signature)
+
+	// the segment after the failed one starts a new chain.
+	md, err = (&recordstore.Segment{Fpath: paths[2]}).ReadMetadata()
+	require.NoError(t, err)
+	require.NotNil(t, md.Signature)
+	require.Equal(t, "", md.Signature.PrevSHA256)
+}
+
+func TestRecorderEncryption(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	stream, err := stream.New(
+		512,
+		1460,
+		desc,
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer stream.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "encryption.key")
+
+	err = os.WriteFile(keyPath, []byte("000102030405060708090a0b0c0d0e0f\n"), 0o644)
+	require.NoError(t, err)
+
+	keys, err := recordstore.LoadEncryptionKeys(keyPath)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:                "mypath",
+		RecordPath:          filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:        conf.RecordFormatFMP4,
+		RecordEncryptionKey: keyPath,
+	}
+
+	w := &Recorder{
+		PathFormat:      pathConf.RecordPath,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		SegmentDuration: 1 * time.Hour,
+		EncryptionKey:   keys.Current(),
+		PathName:        "mypath",
+		Stream:          stream,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
+
+	startNTP := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		stream.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				PTS: int64(i) * 200 * 90000 / 1000,
+				NTP: startNTP.Add(time.Duration(i) * 200 * time.Millisecond),
+			},
+			AU: [][]byte{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			},
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	raw, err := os.ReadFile(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"))
+	require.NoError(t, err)
+	require.Equal(t, "MTXENC01", string(raw[:8]))
+
+	segments, err := recordstore.FindSegments(pathConf, "mypath", nil)
+	require.NoError(t, err)
+
+	r, err := segments[0].Open()
+	require.NoError(t, err)
+	defer r.Close()
+
+	byts, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, len(raw)-32, len(byts))
+
+	var init fmp4.Init
+	err = init.Unmarshal(bytes.NewReader(byts))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(init.Tracks))
+}
+
+func TestRecorderSkipTracks(t *testing.T) {
+	for _, ca := range []string{"fmp4", "mpegts", "webm"} {
+		t.Run(ca, func(t *testing.T) {
+			desc := &description.Session{Medias: []*description.Media{
+				{
+					Type:    description.MediaTypeVideo,
+					Formats: []rtspformat.Format{&rtspformat.H264{}},
+				},
+				{
+					Type:    description.MediaTypeVideo,
+					Formats: []rtspformat.Format{&rtspformat.VP8{}},
+				},
+			}}
+
+			stream, err := stream.New(
+				512,
+				1460,
+				desc,
+				true,
+				test.NilLogger,
+			)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			dir, err := os.MkdirTemp("", "mediamtx-agent")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+			n := 0
+
+			l := test.Logger(func(l logger.Level, format string, args ...interface{}) {
+				if n == 0 {
+					require.Equal(t, logger.Warn, l)
+					if ca == "webm" {
+						require.Equal(t, "[recorder] skipping track 1 (H264)", fmt.Sprintf(format, args...))
+					} else {
+						require.Equal(t, "[recorder] skipping track 2 (VP8)", fmt.Sprintf(format, args...))
+					}
+				}
+				n++
+			})
+
+			var fo conf.RecordFormat
+			switch ca {
+			case "fmp4":
+				fo = conf.RecordFormatFMP4
+
+			case "mpegts":
+				fo = conf.RecordFormatMPEGTS
+
+			default:
+				fo = conf.RecordFormatWebM
+			}
+
+			w := &Recorder{
+				PathFormat:      recordPath,
+				Format:          fo,
+				PartDuration:    100 * time.Millisecond,
+				SegmentDuration: 1 * time.Second,
+				PathName:        "mypath",
+				Stream:          stream,
+				Parent:          l,
+			}
+			w.Initialize()
+			defer w.Close()
+
+			require.Equal(t, 2, n)
+		})
+	}
+}
+
+func TestNextSegmentBoundary(t *testing.T) {
+	for _, ca := range []struct {
+		name     string
+		t        time.Time
+		duration time.Duration
+		next     time.Time
+	}{
+		{
+			"quarter hour",
+			time.Date(2026, 10, 17, 10, 7, 12, 500, time.UTC),
+			15 * time.Minute,
+			time.Date(2026, 10, 17, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			"on boundary",
+			time.Date(2026, 10, 17, 10, 15, 0, 0, time.UTC),
+			15 * time.Minute,
+			time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			"next day",
+			time.Date(2026, 10, 17, 23, 59, 59, 0, time.UTC),
+			time.Hour,
+			time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.next, nextSegmentBoundary(ca.t, ca.duration))
+		})
+	}
+}
+
+func TestRecorderSegmentAlign(t *testing.T) {
+	for _, ca := range []string{"fmp4", "mpegts"} {
+		t.Run(ca, func(t *testing.T) {
+			desc := &description.Session{Medias: []*description.Media{{
+				Type: description.MediaTypeVideo,
+				Formats: []rtspformat.Format{&rtspformat.H264{
+					PayloadTyp:        96,
+					PacketizationMode: 1,
+				}},
+			}}}
+
+			stream, err := stream.New(
+				512,
+				1460,
+				desc,
+				true,
+				test.NilLogger,
+			)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			dir, err := os.MkdirTemp("", "mediamtx-agent")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			var fo conf.RecordFormat
+			var ext string
+			if ca == "fmp4" {
+				fo = conf.RecordFormatFMP4
+				ext = "mp4"
+			} else {
+				fo = conf.RecordFormatMPEGTS
+				ext = "ts"
+			}
+
+			segCreated := make(chan string, 4)
+			segDone := make(chan time.Duration, 4)
+
+			w := &Recorder{
+				PathFormat:      filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				Format:          fo,
+				PartDuration:    100 * time.Millisecond,
+				SegmentDuration: 15 * time.Minute,
+				SegmentAlign:    true,
+				PathName:        "mypath",
+				Stream:          stream,
+				OnSegmentCreate: func(segPath string) {
+					segCreated <- segPath
+				},
+				OnSegmentComplete: func(_ string, du time.Duration) {
+					segDone <- du
+				},
+				Parent: test.NilLogger,
+			}
+			w.Initialize()
+
+			startNTP := time.Date(2026, 10, 17, 10, 14, 59, 500000000, time.UTC)
+
+			for i := 0; i < 6; i++ {
+				stream.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+					Base: unit.Base{
+						PTS: int64(i) * 90000 / 4,
+						NTP: startNTP.Add(time.Duration(i) * 250 * time.Millisecond),
+					},
+					AU: [][]byte{
+						test.FormatH264.SPS,
+						test.FormatH264.PPS,
+						{5}, // IDR
+					},
+				})
+			}
+
+			require.Equal(t, filepath.Join(dir, "mypath", "2026-10-17_10-14-59-500000."+ext), <-segCreated)
+			require.Equal(t, 500*time.Millisecond, <-segDone)
+			require.Equal(t, filepath.Join(dir, "mypath", "2026-10-17_10-15-00-000000."+ext), <-segCreated)
+
+			w.Close()
+		})
+	}
+}
+
+func TestRecorderEvent(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	writeToStream := func(stream *stream.Stream, pts int64, ntp time.Time) {
+		stream.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				PTS: pts,
+				NTP: ntp,
+			},
+			AU: [][]byte{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			},
+		})
+	}
+
+	for _, ca := range []string{"fmp4", "mpegts"} {
+		t.Run(ca, func(t *testing.T) {
+			stream, err := stream.New(
+				512,
+				1460,
+				desc,
+				true,
+				test.NilLogger,
+			)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			dir, err := os.MkdirTemp("", "mediamtx-agent")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+			var f conf.RecordFormat
+			var ext string
+			if ca == "fmp4" {
+				f = conf.RecordFormatFMP4
+				ext = "mp4"
+			} else {
+				f = conf.RecordFormatMPEGTS
+				ext = "ts"
+			}
+
+			segCreated := make(chan string, 4)
+			segDone := make(chan time.Duration, 4)
+
+			w := &Recorder{
+				PathFormat:      recordPath,
+				Format:          f,
+				PartDuration:    100 * time.Millisecond,
+				SegmentDuration: 1 * time.Hour,
+				Mode:            conf.RecordModeEvent,
+				PreRoll:         1 * time.Hour,
+				PostRoll:        200 * time.Millisecond,
+				PathName:        "mypath",
+				Stream:          stream,
+				OnSegmentCreate: func(segPath string) {
+					segCreated <- segPath
+				},
+				OnSegmentComplete: func(_ string, du time.Duration) {
+					segDone <- du
+				},
+				Parent:       test.NilLogger,
+				restartPause: 1 * time.Millisecond,
+			}
+			w.Initialize()
+			defer w.Close()
+
+			ntp := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+			// pre-roll
+			writeToStream(stream, 0, ntp)
+			writeToStream(stream, 90000/10, ntp.Add(100*time.Millisecond))
+
+			time.Sleep(50 * time.Millisecond)
+
+			select {
+			case <-segCreated:
+				t.Errorf("segment created before trigger")
+			default:
+			}
+
+			w.Trigger([]string{"motion", "door"})
+			w.Trigger([]string{"motion"})
+
+			writeToStream(stream, 2*90000/10, ntp.Add(200*time.Millisecond))
+			writeToStream(stream, 3*90000/10, ntp.Add(300*time.Millisecond))
+
+			segPath := <-segCreated
+			require.Equal(t, filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000."+ext), segPath)
+
+			// wait for the post-roll to expire
+			time.Sleep(300 * time.Millisecond)
+
+			writeToStream(stream, 4*90000/10, ntp.Add(400*time.Millisecond))
+
+			du := <-segDone
+			require.Equal(t, 300*time.Millisecond, du)
+
+			md, err := recordstore.ReadSegmentMetadata(segPath)
+			require.NoError(t, err)
+			require.Equal(t, &recordstore.SegmentMetadata{
+				Labels: []string{"motion", "door"},
+			}, md)
+		})
+	}
+}
+
+func TestPreRollBuffer(t *testing.T) {
+	b := &preRollBuffer{
+		duration: 2 * time.Second,
+	}
+
+	start := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	// one keyframe every 3 seconds, one audio unit every second
+	for i := 0; i < 10; i++ {
+		nalu := []byte{1} // non-IDR
+		if (i % 3) == 0 {
+			nalu = []byte{5} // IDR
+		}
+
+		b.push(&preRollEntry{
+			u:          &unit.H264{AU: [][]byte{nalu}},
+			receivedAt: start.Add(time.Duration(i) * time.Second),
+		})
+		b.push(&preRollEntry{
+			u:          &unit.MPEG4Audio{AUs: [][]byte{{1, 2}}},
+			receivedAt: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	// the buffer starts from the last keyframe before the cutoff (7s)
+	entries := b.pull()
+	require.Equal(t, start.Add(6*time.Second), entries[0].receivedAt)
+	require.Equal(t, true, entries[0].keyframe)
+	require.Equal(t, 8, len(entries))
+}
+
+func TestRecorderUploadLeftovers(t *testing.T) {
+	var mutex sync.Mutex
+	uploaded := make(map[string][]byte)
+	failed := false
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		byts, _ := io.ReadAll(r.Body)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// the first upload fails and is retried
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		uploaded[r.URL.Path] = byts
+	}))
+	defer hs.Close()
+
+	desc := &description.Session{Medias: []*description.Media{test.MediaH264}}
+
+	stream, err := stream.New(
+		512,
+		1460,
+		desc,
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer stream.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	leftover := filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")
+
+	err = os.WriteFile(leftover, []byte{1, 2, 3}, 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(recordstore.SegmentMetadataPath(leftover), []byte(`{"labels":["motion"]}`), 0o644)
+	require.NoError(t, err)
+
+	index := &recordstore.Index{Path: filepath.Join(dir, "index")}
+	err = index.Initialize()
+	require.NoError(t, err)
+	defer index.Close()
+
+	err = index.Put(recordstore.IndexEntry{
+		PathName: "mypath",
+		Fpath:    leftover,
+		Start:    time.Date(2008, 5, 20, 22, 15, 25, 0, time.Local),
+		Duration: 3 * time.Second,
+		Size:     3,
+	})
+	require.NoError(t, err)
+
+	w := &Recorder{
+		PathFormat:      filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		SegmentDuration: 1 * time.Hour,
+		S3: &recordstore.S3{
+			Endpoint:        hs.URL,
+			Region:          "us-east-1",
+			Bucket:          "mybucket",
+			AccessKeyID:     "myuser",
+			SecretAccessKey: "mypass",
+			PathStyle:       true,
+		},
+		S3Key:    "records/%path/%Y-%m-%d_%H-%M-%S-%f",
+		Index:    index,
+		PathName: "mypath",
+		Stream:   stream,
+		Parent:   test.NilLogger,
+	}
+	w.Initialize()
+	defer w.Close()
+
+	for {
+		_, err = os.Stat(leftover)
+		if os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = os.Stat(recordstore.SegmentMetadataPath(leftover))
+	require.True(t, os.IsNotExist(err))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Equal(t, map[string][]byte{
+		"/mybucket/records/mypath/2008-05-20_22-15-25-000000.mp4":  {1, 2, 3},
+		"/mybucket/records/mypath/2008-05-20_22-15-25-000000.json": []byte(`{"labels":["motion"]}`),
+	}, uploaded)
+
+	// the segment is indexed as stored in the bucket
+	require.Equal(t, []*recordstore.IndexEntry{{
+		PathName: "mypath",
+		Fpath:    "s3://mybucket/records/mypath/2008-05-20_22-15-25-000000.mp4",
+		S3Key:    "records/mypath/2008-05-20_22-15-25-000000.mp4",
+		Start:    time.Date(2008, 5, 20, 22, 15, 25, 0, time.Local),
+		Duration: 3 * time.Second,
+		Size:     3,
+	}}, index.Entries("mypath"))
+}
+
+func TestRecorderUploadRescan(t *testing.T) {
+	var mutex sync.Mutex
+	uploaded := make(map[string][]byte)
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		byts, _ := io.ReadAll(r.Body)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		uploaded[r.URL.Path] = byts
+	}))
+	defer hs.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	u := &uploader{
+		s3: &recordstore.S3{
+			Endpoint:        hs.URL,
+			Region:          "us-east-1",
+			Bucket:          "mybucket",
+			AccessKeyID:     "myuser",
+			SecretAccessKey: "mypass",
+			PathStyle:       true,
+		},
+		pathFormat:   filepath.Join(dir, "mypath", "%Y-%m-%d_%H-%M-%S-%f.mp4"),
+		keyFormat:    "mypath/%Y-%m-%d_%H-%M-%S-%f.mp4",
+		pathName:     "mypath",
+		parent:       test.NilLogger,
+		rescanPeriod: 50 * time.Millisecond,
+	}
+	u.initialize()
+	defer u.close()
+
+	// segment that was dropped since the queue was full
+	dropped := filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")
+	err = os.WriteFile(dropped, []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	completed := filepath.Join(dir, "mypath", "2008-05-20_22-15-28-000000.mp4")
+	err = os.WriteFile(completed, []byte{2}, 0o644)
+	require.NoError(t, err)
+
+	// segment that is being written
+	current := filepath.Join(dir, "mypath", "2008-05-20_22-15-31-000000.mp4")
+	err = os.WriteFile(current, []byte{3}, 0o644)
+	require.NoError(t, err)
+
+	u.push(completed)
+
+	for {
+		_, err = os.Stat(dropped)
+		if os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = os.Stat(current)
+	require.NoError(t, err)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Equal(t, map[string][]byte{
+		"/mybucket/mypath/2008-05-20_22-15-25-000000.mp4": {1},
+		"/mybucket/mypath/2008-05-20_22-15-28-000000.mp4": {2},
+	}, uploaded)
 }
@@ -0,0 +1,183 @@
+package recorder
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// ReplayClip is a clip saved from a ReplayBuffer.
+type ReplayClip struct {
+	Path     string
+	Duration time.Duration
+}
+
+// ReplayBuffer keeps the most recent units of a stream in memory,
+// in order to save them into a clip on demand.
+type ReplayBuffer struct {
+	Duration      time.Duration
+	PartDuration  time.Duration
+	EncryptionKey *recordstore.EncryptionKey
+	PathName      string
+	Stream        *stream.Stream
+	Parent        logger.Writer
+
+	mutex  sync.Mutex
+	buffer *preRollBuffer
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+// Initialize initializes ReplayBuffer.
+func (b *ReplayBuffer) Initialize() {
+	b.buffer = &preRollBuffer{
+		duration: b.Duration,
+	}
+
+	b.terminate = make(chan struct{})
+	b.done = make(chan struct{})
+
+	for _, media := range b.Stream.Desc().Medias {
+		for _, forma := range media.Formats {
+			b.Stream.AddReader(
+				b,
+				media,
+				forma,
+				func(u unit.Unit) error {
+					b.mutex.Lock()
+					defer b.mutex.Unlock()
+
+					b.buffer.push(&preRollEntry{
+						forma:      forma,
+						u:          u,
+						receivedAt: time.Now(),
+					})
+					return nil
+				})
+		}
+	}
+
+	b.Stream.StartReader(b)
+
+	b.Log(logger.Info, "buffering the last %v", b.Duration)
+
+	go b.run()
+}
+
+// Log implements logger.Writer.
+func (b *ReplayBuffer) Log(level logger.Level, format string, args ...interface{}) {
+	b.Parent.Log(level, "[replay buffer] "+format, args...)
+}
+
+// Close closes the ReplayBuffer.
+func (b *ReplayBuffer) Close() {
+	close(b.terminate)
+	<-b.done
+}
+
+func (b *ReplayBuffer) run() {
+	defer close(b.done)
+
+	select {
+	case err := <-b.Stream.ReaderError(b):
+		b.Log(logger.Error, err.Error())
+
+	case <-b.terminate:
+	}
+
+	b.Stream.RemoveReader(b)
+}
+
+// ReplaySnapshot is the content of a ReplayBuffer at a certain time.
+// It can be saved after the buffer has been closed.
+type ReplaySnapshot struct {
+	buffer  *ReplayBuffer
+	entries []*preRollEntry
+}
+
+// Snapshot returns the current content of the buffer.
+func (b *ReplayBuffer) Snapshot() *ReplaySnapshot {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return &ReplaySnapshot{
+		buffer:  b,
+		entries: append([]*preRollEntry(nil), b.buffer.entries...),
+	}
+}
+
+// Save writes the snapshot into a fMP4 file.
+// The file path is obtained by encoding pathFormat with the start time of the clip.
+// When encrypt is true, the file is encrypted with EncryptionKey of the buffer, if set.
+func (s *ReplaySnapshot) Save(pathFormat string, encrypt bool) (*ReplayClip, error) {
+	b := s.buffer
+
+	var encryptionKey *recordstore.EncryptionKey
+	if encrypt {
+		encryptionKey = b.EncryptionKey
+	}
+
+	var clip *ReplayClip
+
+	// units are written by a recorder instance in event mode,
+	// that is detached from the stream.
+	agent := &Recorder{
+		PathFormat:      pathFormat,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    b.PartDuration,
+		SegmentDuration: b.Duration + 1*time.Hour,
+		Mode:            conf.RecordModeEvent,
+		EncryptionKey:   encryptionKey,
+		PathName:        b.PathName,
+		Stream:          b.Stream,
+		OnSegmentCreate: func(string) {},
+		OnSegmentComplete: func(path string, duration time.Duration) {
+			clip = &ReplayClip{
+				Path:     path,
+				Duration: duration,
+			}
+		},
+		Parent: b,
+	}
+
+	ai := &recorderInstance{
+		agent: agent,
+		pathFormat: recordstore.PathAddExtension(
+			strings.ReplaceAll(pathFormat, "%path", b.PathName),
+			conf.RecordFormatFMP4,
+		),
+		readers: make(map[rtspformat.Format]stream.ReadFunc),
+	}
+	ai.format = ai.newFormat()
+
+	var err error
+
+	for _, entry := range s.entries {
+		err = ai.writeEventUnit(entry.forma, entry.u)
+		if err != nil {
+			break
+		}
+	}
+
+	ai.format.close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if clip == nil {
+		return nil, fmt.Errorf("buffer does not contain any random access point")
+	}
+
+	return clip, nil
+}
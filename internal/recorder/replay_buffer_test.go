@@ -0,0 +1,115 @@
+package recorder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	rtspformat "github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []rtspformat.Format{&rtspformat.H264{
+				PayloadTyp:        96,
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	strm, err := stream.New(
+		512,
+		1460,
+		desc,
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "encryption.key")
+	err = os.WriteFile(keyPath, []byte("000102030405060708090a0b0c0d0e0f\n"), 0o644)
+	require.NoError(t, err)
+
+	keys, err := recordstore.LoadEncryptionKeys(keyPath)
+	require.NoError(t, err)
+
+	b := &ReplayBuffer{
+		Duration:      1 * time.Hour,
+		PartDuration:  1 * time.Second,
+		EncryptionKey: keys.Current(),
+		PathName:      "mypath",
+		Stream:        strm,
+		Parent:        test.NilLogger,
+	}
+	b.Initialize()
+
+	_, err = b.Snapshot().Save(filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"), false)
+	require.EqualError(t, err, "buffer does not contain any random access point")
+
+	ntp := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		au := [][]byte{{1}} // non-IDR
+		if i == 1 {
+			au = [][]byte{
+				test.FormatH264.SPS,
+				test.FormatH264.PPS,
+				{5}, // IDR
+			}
+		}
+
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				PTS: int64(i) * 90000 / 10,
+				NTP: ntp.Add(time.Duration(i) * 100 * time.Millisecond),
+			},
+			AU: au,
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	clip, err := b.Snapshot().Save(filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"), false)
+	require.NoError(t, err)
+	require.Equal(t, &ReplayClip{
+		Path:     filepath.Join(dir, "mypath", "2008-05-20_22-15-25-100000.mp4"),
+		Duration: 200 * time.Millisecond,
+	}, clip)
+
+	buf, err := os.ReadFile(clip.Path)
+	require.NoError(t, err)
+
+	var init fmp4.Init
+	err = init.Unmarshal(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(init.Tracks))
+
+	// the buffer is not emptied by Save() and snapshots can be saved after the buffer is closed.
+	snapshot := b.Snapshot()
+	b.Close()
+
+	clip2, err := snapshot.Save(filepath.Join(dir, "%path/second"), true)
+	require.NoError(t, err)
+	require.Equal(t, 200*time.Millisecond, clip2.Duration)
+
+	buf, err = os.ReadFile(clip2.Path)
+	require.NoError(t, err)
+	require.Equal(t, "MTXENC01", string(buf[:8]))
+}
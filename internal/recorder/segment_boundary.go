@@ -0,0 +1,24 @@
+package recorder
+
+import (
+	"time"
+)
+
+// nextSegmentBoundary returns the first multiple of duration, counted from midnight
+// of the day of t, that is after t.
+// Multiples are computed on the wall clock, in order to keep segments aligned
+// when daylight saving time changes.
+func nextSegmentBoundary(t time.Time, duration time.Duration) time.Time {
+	year, month, day := t.Date()
+
+	elapsed := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+
+	next := (elapsed/duration + 1) * duration
+
+	// time.Date() normalizes values out of range,
+	// therefore a boundary at 24h is converted into midnight of the next day.
+	return time.Date(year, month, day, 0, 0, int(next/time.Second), int(next%time.Second), t.Location())
+}
@@ -0,0 +1,169 @@
+package recorder
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	signerQueueSize = 256
+)
+
+type signReq struct {
+	segmentPath string
+	duration    time.Duration
+	resetChain  bool
+}
+
+// signer signs completed segments and links them to previous ones.
+// Segments are read back from disk in order to compute their hash,
+// therefore this is performed in a dedicated routine, that doesn't block the stream.
+type signer struct {
+	key        ed25519.PrivateKey
+	pathFormat string
+	keyFormat  string
+	s3         *recordstore.S3
+	pathName   string
+	index      *recordstore.Index
+	onSigned   func(segmentPath string, duration time.Duration)
+	parent     logger.Writer
+
+	prevSHA256       string
+	prevSHA256Loaded bool
+	resetChain       bool
+
+	queue     chan signReq
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+func (s *signer) initialize() {
+	s.queue = make(chan signReq, signerQueueSize)
+	s.terminate = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run()
+}
+
+// Log implements logger.Writer.
+func (s *signer) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, format, args...)
+}
+
+// close waits for segments in the queue to be signed.
+func (s *signer) close() {
+	close(s.terminate)
+	<-s.done
+}
+
+func (s *signer) push(segmentPath string, duration time.Duration) {
+	select {
+	case s.queue <- signReq{segmentPath: segmentPath, duration: duration, resetChain: s.resetChain}:
+		s.resetChain = false
+
+	default:
+		// the next segment can't be linked to this one, therefore it starts a new chain.
+		s.Log(logger.Error, "sign queue is full, %s will not be signed", segmentPath)
+		s.resetChain = true
+		s.onSigned(segmentPath, duration)
+	}
+}
+
+func (s *signer) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case req := <-s.queue:
+			s.process(req)
+
+		case <-s.terminate:
+			for {
+				select {
+				case req := <-s.queue:
+					s.process(req)
+
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *signer) process(req signReq) {
+	if req.resetChain {
+		s.prevSHA256 = ""
+		s.prevSHA256Loaded = true
+	}
+
+	err := s.sign(req.segmentPath)
+	if err != nil {
+		// the next segment can't be linked to this one, therefore it starts a new chain.
+		s.Log(logger.Error, "unable to sign segment %s: %v", req.segmentPath, err)
+		s.prevSHA256 = ""
+		s.prevSHA256Loaded = true
+	}
+
+	s.onSigned(req.segmentPath, req.duration)
+}
+
+func (s *signer) sign(segmentPath string) error {
+	// the signed start is the one encoded into the path, that is the one used during verification.
+	var pa recordstore.Path
+	if !pa.Decode(s.pathFormat, segmentPath) {
+		return errors.New("unable to decode segment path")
+	}
+
+	if !s.prevSHA256Loaded {
+		s.prevSHA256 = s.findPrevSHA256(pa.Start)
+		s.prevSHA256Loaded = true
+	}
+
+	sha256, err := (&recordstore.Segment{Fpath: segmentPath}).Hash()
+	if err != nil {
+		return err
+	}
+
+	sig := recordstore.SignSegment(s.key, s.pathName, pa.Start, sha256, s.prevSHA256)
+
+	err = recordstore.UpdateSegmentMetadata(segmentPath, func(md *recordstore.SegmentMetadata) {
+		md.Signature = sig
+	})
+	if err != nil {
+		return err
+	}
+
+	s.prevSHA256 = sha256
+	return nil
+}
+
+// findPrevSHA256 returns the hash of the segment that precedes the first one
+// written by the recorder, in order to continue the chain after a restart.
+// The previous segment may have been uploaded to a bucket.
+func (s *signer) findPrevSHA256(start time.Time) string {
+	segments, err := recordstore.FindSegmentsWithFormats(
+		s.pathFormat, s.s3, s.keyFormat, s.pathName, s.index)
+	if err != nil {
+		return ""
+	}
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if !seg.Start.Before(start) {
+			continue
+		}
+
+		md, err := seg.ReadMetadata()
+		if err != nil || md.Signature == nil {
+			return ""
+		}
+		return md.Signature.SHA256
+	}
+
+	return ""
+}
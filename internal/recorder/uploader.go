@@ -0,0 +1,257 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	uploaderQueueSize      = 256
+	uploaderMaxAttempts    = 10
+	uploaderInitialBackoff = 1 * time.Second
+	uploaderMaxBackoff     = 5 * time.Minute
+	uploaderRescanPeriod   = 1 * time.Minute
+)
+
+type uploadReq struct {
+	segmentPath string
+	attempt     int
+}
+
+// uploader moves completed segments into a bucket.
+type uploader struct {
+	s3         *recordstore.S3
+	pathFormat string
+	keyFormat  string
+	pathName   string
+	index      *recordstore.Index
+	parent     logger.Writer
+
+	rescanPeriod time.Duration
+
+	ctx       context.Context
+	ctxCancel func()
+	mutex     sync.Mutex
+	pending   map[string]struct{}
+	lastStart time.Time
+	queue     chan uploadReq
+	done      chan struct{}
+}
+
+func (u *uploader) initialize() {
+	u.ctx, u.ctxCancel = context.WithCancel(context.Background())
+	u.pending = make(map[string]struct{})
+	u.queue = make(chan uploadReq, uploaderQueueSize)
+	u.done = make(chan struct{})
+
+	if u.rescanPeriod == 0 {
+		u.rescanPeriod = uploaderRescanPeriod
+	}
+
+	// segments that were not uploaded before a restart are uploaded now.
+	// This must happen before the recorder starts,
+	// otherwise the segment that is being written would be uploaded too.
+	leftovers, _ := recordstore.FindLocalSegments(u.pathFormat)
+	for _, seg := range leftovers {
+		u.push(seg.Fpath)
+	}
+
+	go u.run()
+}
+
+// Log implements logger.Writer.
+func (u *uploader) Log(level logger.Level, format string, args ...interface{}) {
+	u.parent.Log(level, format, args...)
+}
+
+func (u *uploader) close() {
+	u.ctxCancel()
+	<-u.done
+}
+
+// push enqueues a completed segment.
+func (u *uploader) push(segmentPath string) {
+	absPath, _ := filepath.Abs(segmentPath)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var pa recordstore.Path
+	if pa.Decode(u.absPathFormat(), absPath) && pa.Start.After(u.lastStart) {
+		u.lastStart = pa.Start
+	}
+
+	if _, ok := u.pending[absPath]; ok {
+		return
+	}
+
+	select {
+	case u.queue <- uploadReq{segmentPath: segmentPath}:
+		u.pending[absPath] = struct{}{}
+	default:
+		u.Log(logger.Warn, "upload queue is full, %s will be uploaded later", segmentPath)
+	}
+}
+
+func (u *uploader) enqueue(req uploadReq) {
+	select {
+	case u.queue <- req:
+	default:
+		u.Log(logger.Warn, "upload queue is full, %s will be uploaded later", req.segmentPath)
+		u.release(req.segmentPath)
+	}
+}
+
+// release allows a segment to be enqueued again by rescan.
+func (u *uploader) release(segmentPath string) {
+	absPath, _ := filepath.Abs(segmentPath)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	delete(u.pending, absPath)
+}
+
+// rescan enqueues local segments that were not uploaded since the queue was full
+// or attempts were exhausted. Segments that are more recent than the last completed one
+// are skipped, since they are being written or signed.
+func (u *uploader) rescan() {
+	segments, _ := recordstore.FindLocalSegments(u.pathFormat)
+
+	u.mutex.Lock()
+	lastStart := u.lastStart
+	u.mutex.Unlock()
+
+	for _, seg := range segments {
+		if seg.Start.After(lastStart) {
+			break
+		}
+		u.push(seg.Fpath)
+	}
+}
+
+func (u *uploader) absPathFormat() string {
+	// paths are converted to absolute paths, since the ones of leftover segments are absolute
+	pathFormat, _ := filepath.Abs(u.pathFormat)
+	return pathFormat
+}
+
+// retry puts a failed upload back into the queue after a delay that doubles at every attempt.
+func (u *uploader) retry(req uploadReq, err error) {
+	req.attempt++
+
+	if req.attempt >= uploaderMaxAttempts {
+		u.Log(logger.Error, "unable to upload %s: %v. It will be uploaded later", req.segmentPath, err)
+		u.release(req.segmentPath)
+		return
+	}
+
+	backoff := min(uploaderInitialBackoff<<(req.attempt-1), uploaderMaxBackoff)
+
+	u.Log(logger.Warn, "unable to upload %s: %v. Retrying in %v", req.segmentPath, err, backoff)
+
+	go func() {
+		select {
+		case <-time.After(backoff):
+			u.enqueue(req)
+
+		case <-u.ctx.Done():
+		}
+	}()
+}
+
+func (u *uploader) run() {
+	defer close(u.done)
+
+	rescanTicker := time.NewTicker(u.rescanPeriod)
+	defer rescanTicker.Stop()
+
+	for {
+		select {
+		case req := <-u.queue:
+			err := u.upload(req.segmentPath)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				u.retry(req, err)
+			} else {
+				u.release(req.segmentPath)
+			}
+
+		case <-rescanTicker.C:
+			u.rescan()
+
+		case <-u.ctx.Done():
+			return
+		}
+	}
+}
+
+func (u *uploader) upload(segmentPath string) error {
+	absPath, _ := filepath.Abs(segmentPath)
+
+	var pa recordstore.Path
+	ok := pa.Decode(u.absPathFormat(), absPath)
+	if !ok {
+		return errors.New("unable to decode segment path")
+	}
+
+	key := recordstore.Path{Start: pa.Start}.Encode(u.keyFormat)
+
+	f, err := os.Open(segmentPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = u.s3.PutObject(u.ctx, key, f, fi.Size())
+	if err != nil {
+		return err
+	}
+
+	metadata, err := os.ReadFile(recordstore.SegmentMetadataPath(segmentPath))
+	if err == nil {
+		err = u.s3.PutObject(u.ctx, recordstore.SegmentMetadataPath(key),
+			bytes.NewReader(metadata), int64(len(metadata)))
+		if err != nil {
+			return err
+		}
+	}
+
+	f.Close()
+
+	// the index is updated before removing the local segment,
+	// in order to keep the segment reachable.
+	if u.index != nil {
+		u.index.Move(segmentPath, recordstore.IndexEntry{ //nolint:errcheck
+			PathName: u.pathName,
+			Fpath:    u.s3.URL(key),
+			S3Key:    key,
+			Start:    pa.Start,
+			Size:     fi.Size(),
+		})
+	}
+
+	err = recordstore.RemoveSegment(segmentPath)
+	if err != nil {
+		return err
+	}
+
+	u.Log(logger.Info, "segment %s moved to %s", segmentPath, u.s3.URL(key))
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package recordstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	encryptionMagic      = "MTXENC01"
+	encryptionHeaderSize = 32
+)
+
+// EncryptionKey is a key used to encrypt and decrypt segments.
+type EncryptionKey struct {
+	// first 8 bytes of the SHA-256 hash of the key.
+	// It is stored into segments in order to find the key that decrypts them.
+	ID [8]byte
+
+	block cipher.Block
+}
+
+// EncryptionKeys is a list of encryption keys.
+type EncryptionKeys []*EncryptionKey
+
+// Current returns the key used to encrypt new segments.
+func (keys EncryptionKeys) Current() *EncryptionKey {
+	return keys[len(keys)-1]
+}
+
+func (keys EncryptionKeys) find(id []byte) *EncryptionKey {
+	for _, key := range keys {
+		if bytes.Equal(key.ID[:], id) {
+			return key
+		}
+	}
+	return nil
+}
+
+// LoadEncryptionKeys loads encryption keys from a file that contains
+// one or more AES keys (128, 192 or 256 bits) in hexadecimal format, one per line.
+// The last key is used to encrypt new segments, while previous ones
+// are used to decrypt older segments, in order to allow keys to be rotated.
+func LoadEncryptionKeys(fpath string) (EncryptionKeys, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys EncryptionKeys
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		byts, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+
+		block, err := aes.NewCipher(byts)
+		if err != nil {
+			return nil, err
+		}
+
+		key := &EncryptionKey{
+			block: block,
+		}
+		sum := sha256.Sum256(byts)
+		copy(key.ID[:], sum[:8])
+
+		keys = append(keys, key)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if keys == nil {
+		return nil, fmt.Errorf("no keys found")
+	}
+
+	return keys, nil
+}
+
+// xorKeyStream encrypts or decrypts data that is located at given offset of a segment, with AES-CTR.
+// CTR mode allows to encrypt and decrypt any portion of a segment independently.
+func xorKeyStream(key *EncryptionKey, iv []byte, dst []byte, src []byte, off int64) {
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, iv)
+
+	// add the block index to the 128-bit counter
+	lo := binary.BigEndian.Uint64(counter[8:])
+	hi := binary.BigEndian.Uint64(counter[:8])
+	newLo := lo + uint64(off/aes.BlockSize)
+	if newLo < lo {
+		hi++
+	}
+	binary.BigEndian.PutUint64(counter[8:], newLo)
+	binary.BigEndian.PutUint64(counter[:8], hi)
+
+	stream := cipher.NewCTR(key.block, counter)
+
+	if skip := off % aes.BlockSize; skip != 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+
+	stream.XORKeyStream(dst, src)
+}
+
+// SegmentWriter is a writer of a segment.
+type SegmentWriter interface {
+	io.WriteSeeker
+	io.Closer
+}
+
+// encryptedWriter is a SegmentWriter that encrypts data.
+type encryptedWriter struct {
+	f   *os.File
+	key *EncryptionKey
+	iv  []byte
+	pos int64
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (w *encryptedWriter) Write(p []byte) (int, error) {
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+	buf := w.buf[:len(p)]
+
+	xorKeyStream(w.key, w.iv, buf, p, w.pos)
+
+	n, err := w.f.Write(buf)
+	w.pos += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (w *encryptedWriter) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += encryptionHeaderSize
+	}
+
+	pos, err := w.f.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+
+	w.pos = pos - encryptionHeaderSize
+	return w.pos, nil
+}
+
+// Close implements io.Closer.
+func (w *encryptedWriter) Close() error {
+	return w.f.Close()
+}
+
+// CreateSegment creates a segment on disk.
+// If key is not nil, the segment is encrypted with AES-CTR.
+func CreateSegment(fpath string, key *EncryptionKey) (SegmentWriter, error) {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return f, nil
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	header := make([]byte, 0, encryptionHeaderSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, key.ID[:]...)
+	header = append(header, iv...)
+
+	_, err = f.Write(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &encryptedWriter{
+		f:   f,
+		key: key,
+		iv:  iv,
+	}, nil
+}
+
+// encryptedReader is a SegmentReader that decrypts data.
+type encryptedReader struct {
+	r    SegmentReader
+	key  *EncryptionKey
+	iv   []byte
+	size int64
+	pos  int64
+}
+
+// Read implements io.Reader.
+func (r *encryptedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *encryptedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	n, err := r.r.ReadAt(p, off+encryptionHeaderSize)
+	xorKeyStream(r.key, r.iv, p[:n], p[:n], off)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *encryptedReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		r.pos = offset
+
+	case io.SeekCurrent:
+		r.pos += offset
+
+	case io.SeekEnd:
+		r.pos = r.size + offset
+	}
+
+	if r.pos < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+
+	return r.pos, nil
+}
+
+// Close implements io.Closer.
+func (r *encryptedReader) Close() error {
+	return r.r.Close()
+}
+
+// errSegmentNotEncrypted is returned when a segment is not encrypted.
+var errSegmentNotEncrypted = errors.New("segment is not encrypted")
+
+// newEncryptedReader reads the encryption header of a segment
+// and returns a reader that decrypts the segment.
+// keysErr is the error that prevented keys from being loaded.
+func newEncryptedReader(r SegmentReader, size int64, keys EncryptionKeys, keysErr error) (*encryptedReader, error) {
+	header := make([]byte, encryptionHeaderSize)
+	_, err := r.ReadAt(header, 0)
+	if err != nil || string(header[:8]) != encryptionMagic {
+		return nil, errSegmentNotEncrypted
+	}
+
+	if keysErr != nil {
+		return nil, keysErr
+	}
+
+	if keys == nil {
+		return nil, fmt.Errorf("segment is encrypted but 'recordEncryptionKey' is not set")
+	}
+
+	key := keys.find(header[8:16])
+	if key == nil {
+		return nil, fmt.Errorf("key %x, that is needed to decrypt the segment, is not available", header[8:16])
+	}
+
+	return &encryptedReader{
+		r:    r,
+		key:  key,
+		iv:   header[16:32],
+		size: size - encryptionHeaderSize,
+	}, nil
+}
@@ -0,0 +1,184 @@
+package recordstore
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "encryption.key")
+
+	err = os.WriteFile(keyPath, []byte("000102030405060708090a0b0c0d0e0f\n"), 0o644)
+	require.NoError(t, err)
+
+	keys, err := LoadEncryptionKeys(keyPath)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:                "mypath",
+		RecordPath:          filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:        conf.RecordFormatFMP4,
+		RecordEncryptionKey: keyPath,
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath", "2008-11-07_11-22-00-000000.mp4")
+
+	payload := make([]byte, 1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	w, err := CreateSegment(fpath, keys.Current())
+	require.NoError(t, err)
+
+	_, err = w.Write(payload[:500])
+	require.NoError(t, err)
+
+	_, err = w.Write(payload[500:])
+	require.NoError(t, err)
+
+	// overwrite a portion that is not aligned to the AES block size
+	_, err = w.Seek(21, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte{1, 2, 3})
+	require.NoError(t, err)
+	copy(payload[21:], []byte{1, 2, 3})
+
+	err = w.Close()
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.Equal(t, encryptionHeaderSize+len(payload), len(raw))
+	require.False(t, bytes.Contains(raw, payload[100:200]))
+
+	segments, err := FindSegments(pathConf, "mypath", nil)
+	require.NoError(t, err)
+
+	r, err := segments[0].Open()
+	require.NoError(t, err)
+	defer r.Close()
+
+	dec, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, payload, dec)
+
+	size, err := r.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(payload)), size)
+
+	buf := make([]byte, 50)
+	_, err = r.ReadAt(buf, 333)
+	require.NoError(t, err)
+	require.Equal(t, payload[333:383], buf)
+
+	// keys are rotated
+	err = os.WriteFile(keyPath, []byte("000102030405060708090a0b0c0d0e0f\n"+
+		"0f0e0d0c0b0a09080706050403020100\n"), 0o644)
+	require.NoError(t, err)
+
+	keys2, err := LoadEncryptionKeys(keyPath)
+	require.NoError(t, err)
+	require.NotEqual(t, keys.Current().ID, keys2.Current().ID)
+
+	// keys are loaded when segments are found
+	segments, err = FindSegments(pathConf, "mypath", nil)
+	require.NoError(t, err)
+
+	r2, err := segments[0].Open()
+	require.NoError(t, err)
+	defer r2.Close()
+
+	dec, err = io.ReadAll(r2)
+	require.NoError(t, err)
+	require.Equal(t, payload, dec)
+
+	// the key that encrypted the segment is not available anymore
+	err = os.WriteFile(keyPath, []byte("0f0e0d0c0b0a09080706050403020100\n"), 0o644)
+	require.NoError(t, err)
+
+	segments, err = FindSegments(pathConf, "mypath", nil)
+	require.NoError(t, err)
+
+	_, err = segments[0].Open()
+	require.Error(t, err)
+
+	// the key file can't be loaded
+	err = os.Remove(keyPath)
+	require.NoError(t, err)
+
+	segments, err = FindSegments(pathConf, "mypath", nil)
+	require.NoError(t, err)
+
+	_, err = segments[0].Open()
+	require.Error(t, err)
+}
+
+func TestRepairFMP4Encrypted(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "encryption.key")
+
+	err = os.WriteFile(keyPath, []byte("000102030405060708090a0b0c0d0e0f\n"), 0o644)
+	require.NoError(t, err)
+
+	keys, err := LoadEncryptionKeys(keyPath)
+	require.NoError(t, err)
+
+	plainPath := filepath.Join(dir, "plain.mp4")
+	completeSize := writeTestFMP4(t, plainPath, 2)
+
+	plain, err := os.ReadFile(plainPath)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "2015-05-19_22-15-25-000427.mp4")
+
+	w, err := CreateSegment(fpath, keys.Current())
+	require.NoError(t, err)
+
+	_, err = w.Write(plain)
+	require.NoError(t, err)
+
+	// header of a moof box that is bigger than the remaining data
+	_, err = w.Write([]byte{0x00, 0x00, 0x01, 0x00, 'm', 'o', 'o', 'f', 1, 2, 3})
+	require.NoError(t, err)
+
+	err = w.Close()
+	require.NoError(t, err)
+
+	seg := &Segment{
+		Fpath: fpath,
+		Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+		keys:  keys,
+	}
+
+	res, err := RepairFMP4(seg)
+	require.NoError(t, err)
+	require.Equal(t, completeSize, res.Size)
+	require.Equal(t, 2, res.PartCount)
+
+	r, err := seg.Open()
+	require.NoError(t, err)
+	defer r.Close()
+
+	dec, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, plain, dec)
+}
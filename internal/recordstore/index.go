@@ -0,0 +1,499 @@
+package recordstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const (
+	// the journal is compacted when it contains this number of records
+	// more than the number of entries.
+	indexCompactThreshold = 1000
+
+	indexMaxLineSize = 64 * 1024
+)
+
+type indexOp string
+
+const (
+	indexOpPut    indexOp = "put"
+	indexOpRemove indexOp = "remove"
+)
+
+// IndexEntry is a segment stored in the index.
+// Segments stored in a bucket have a s3://bucket/key Fpath and a S3Key.
+type IndexEntry struct {
+	PathName string        `json:"path"`
+	Fpath    string        `json:"fpath"`
+	S3Key    string        `json:"s3Key,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Size     int64         `json:"size"`
+}
+
+// indexFpath normalizes the path of a segment, in order to use it as key.
+func indexFpath(fpath string) string {
+	if strings.HasPrefix(fpath, "s3://") {
+		return fpath
+	}
+	fpath, _ = filepath.Abs(fpath)
+	return fpath
+}
+
+type indexRecord struct {
+	Op    indexOp     `json:"op"`
+	Entry *IndexEntry `json:"entry,omitempty"`
+	Fpath string      `json:"fpath,omitempty"`
+}
+
+// Index is a persistent index of segments stored on disk or in buckets,
+// that allows to find segments without walking recording directories and listing buckets.
+// It is stored into a journal that is compacted when it grows too much.
+type Index struct {
+	Path string
+
+	mutex      sync.RWMutex
+	entries    map[string]*IndexEntry
+	byPath     map[string]map[string]*IndexEntry
+	f          *os.File
+	journalLen int
+	missing    bool
+}
+
+// Initialize initializes Index.
+func (i *Index) Initialize() error {
+	i.entries = make(map[string]*IndexEntry)
+	i.byPath = make(map[string]map[string]*IndexEntry)
+
+	err := os.MkdirAll(filepath.Dir(i.Path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = i.load()
+	if err != nil {
+		return err
+	}
+
+	return i.compact()
+}
+
+// Missing checks whether the journal didn't exist when the index was initialized,
+// therefore the index must be rebuilt in order to contain existing segments.
+func (i *Index) Missing() bool {
+	return i.missing
+}
+
+// Close closes Index.
+func (i *Index) Close() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.f.Close()
+}
+
+func (i *Index) load() error {
+	f, err := os.Open(i.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			i.missing = true
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, indexMaxLineSize), indexMaxLineSize)
+
+	for scanner.Scan() {
+		var rec indexRecord
+		err = json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			// the last record may be truncated in case of a crash
+			continue
+		}
+
+		switch rec.Op {
+		case indexOpPut:
+			if rec.Entry != nil {
+				i.set(rec.Entry)
+			}
+
+		case indexOpRemove:
+			i.unset(rec.Fpath)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (i *Index) set(e *IndexEntry) {
+	i.unset(e.Fpath)
+
+	i.entries[e.Fpath] = e
+
+	m, ok := i.byPath[e.PathName]
+	if !ok {
+		m = make(map[string]*IndexEntry)
+		i.byPath[e.PathName] = m
+	}
+	m[e.Fpath] = e
+}
+
+func (i *Index) unset(fpath string) {
+	e, ok := i.entries[fpath]
+	if !ok {
+		return
+	}
+
+	delete(i.entries, fpath)
+
+	m := i.byPath[e.PathName]
+	delete(m, fpath)
+	if len(m) == 0 {
+		delete(i.byPath, e.PathName)
+	}
+}
+
+// compact rewrites the journal, in order to contain current entries only.
+func (i *Index) compact() error {
+	tmpPath := i.Path + ".tmp"
+
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(bw)
+
+	for _, e := range i.entries {
+		err = enc.Encode(&indexRecord{Op: indexOpPut, Entry: e})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	tmp.Close()
+
+	if i.f != nil {
+		i.f.Close()
+		i.f = nil
+	}
+
+	err = os.Rename(tmpPath, i.Path)
+	if err != nil {
+		return err
+	}
+
+	i.f, err = os.OpenFile(i.Path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	i.journalLen = len(i.entries)
+
+	return nil
+}
+
+func (i *Index) append(rec *indexRecord) error {
+	byts, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.f.Write(append(byts, '\n'))
+	if err != nil {
+		return err
+	}
+
+	i.journalLen++
+
+	if i.journalLen > (len(i.entries) + indexCompactThreshold) {
+		return i.compact()
+	}
+
+	return nil
+}
+
+// Put adds or updates a segment.
+func (i *Index) Put(e IndexEntry) error {
+	e.Fpath = indexFpath(e.Fpath)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.set(&e)
+
+	return i.append(&indexRecord{Op: indexOpPut, Entry: &e})
+}
+
+// Remove removes a segment.
+func (i *Index) Remove(fpath string) error {
+	fpath = indexFpath(fpath)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if _, ok := i.entries[fpath]; !ok {
+		return nil
+	}
+
+	i.unset(fpath)
+
+	return i.append(&indexRecord{Op: indexOpRemove, Fpath: fpath})
+}
+
+// Move replaces a segment with another one, that is the same segment in another place.
+// The duration of the segment is kept when the new one doesn't have it.
+func (i *Index) Move(fpath string, e IndexEntry) error {
+	fpath = indexFpath(fpath)
+	e.Fpath = indexFpath(e.Fpath)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if prev, ok := i.entries[fpath]; ok {
+		if e.Duration == 0 {
+			e.Duration = prev.Duration
+		}
+
+		i.unset(fpath)
+
+		err := i.append(&indexRecord{Op: indexOpRemove, Fpath: fpath})
+		if err != nil {
+			return err
+		}
+	}
+
+	i.set(&e)
+
+	return i.append(&indexRecord{Op: indexOpPut, Entry: &e})
+}
+
+// setSize updates the size of a segment.
+func (i *Index) setSize(fpath string, size int64) error {
+	fpath = indexFpath(fpath)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	prev, ok := i.entries[fpath]
+	if !ok {
+		return nil
+	}
+
+	e := *prev
+	e.Size = size
+	i.set(&e)
+
+	return i.append(&indexRecord{Op: indexOpPut, Entry: &e})
+}
+
+// Entries returns all segments of a path, sorted by start.
+func (i *Index) Entries(pathName string) []*IndexEntry {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	m := i.byPath[pathName]
+
+	out := make([]*IndexEntry, 0, len(m))
+	for _, e := range m {
+		ec := *e
+		out = append(out, &ec)
+	}
+
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Start.Before(out[b].Start)
+	})
+
+	return out
+}
+
+// PathNames returns names of all paths that have segments.
+func (i *Index) PathNames() []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	out := make([]string, 0, len(i.byPath))
+	for name := range i.byPath {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Rebuild replaces the content of the index with segments found on disk and in buckets.
+// It returns the number of indexed segments.
+func (i *Index) Rebuild(pathConfs map[string]*conf.Path) (int, error) {
+	entries := make(map[string]*IndexEntry)
+
+	for _, pathConf := range pathConfs {
+		var recordPath string
+		if pathConf.Regexp == nil {
+			recordPath = strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name)
+		} else {
+			recordPath = pathConf.RecordPath
+		}
+		recordPath = PathAddExtension(recordPath, pathConf.RecordFormat)
+
+		walkLocalSegments(recordPath, func(fpath string, pa *Path) error { //nolint:errcheck
+			pathName := pathConf.Name
+			if pathConf.Regexp != nil {
+				if pathConf.Regexp.FindStringSubmatch(pa.Path) == nil {
+					return nil
+				}
+				pathName = pa.Path
+			}
+
+			// paths with a fixed name have precedence over regular expressions
+			if _, ok := entries[fpath]; ok && pathConf.Regexp != nil {
+				return nil
+			}
+
+			fi, err := os.Stat(fpath)
+			if err != nil {
+				return nil
+			}
+
+			entries[fpath] = &IndexEntry{
+				PathName: pathName,
+				Fpath:    fpath,
+				Start:    pa.Start,
+				Size:     fi.Size(),
+			}
+			return nil
+		})
+
+		if s3 := S3FromPathConf(pathConf); s3 != nil {
+			var keyFormat string
+			if pathConf.Regexp == nil {
+				keyFormat = strings.ReplaceAll(pathConf.RecordS3Key, "%path", pathConf.Name)
+			} else {
+				keyFormat = pathConf.RecordS3Key
+			}
+			keyFormat = PathAddExtension(keyFormat, pathConf.RecordFormat)
+
+			err := walkBucketSegments(s3, keyFormat, func(obj *S3Object, pa *Path) error {
+				pathName := pathConf.Name
+				if pathConf.Regexp != nil {
+					if pathConf.Regexp.FindStringSubmatch(pa.Path) == nil {
+						return nil
+					}
+					pathName = pa.Path
+				}
+
+				fpath := s3.URL(obj.Key)
+
+				if _, ok := entries[fpath]; ok && pathConf.Regexp != nil {
+					return nil
+				}
+
+				entries[fpath] = &IndexEntry{
+					PathName: pathName,
+					Fpath:    fpath,
+					S3Key:    obj.Key,
+					Start:    pa.Start,
+					Size:     obj.Size,
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// durations are not computed by the walk, therefore they are kept when available
+	for fpath, e := range entries {
+		if prev, ok := i.entries[fpath]; ok && prev.Size == e.Size {
+			e.Duration = prev.Duration
+		}
+	}
+
+	i.entries = make(map[string]*IndexEntry)
+	i.byPath = make(map[string]map[string]*IndexEntry)
+
+	for _, e := range entries {
+		i.set(e)
+	}
+
+	return len(entries), i.compact()
+}
+
+// findSegments returns segments of a path that match the recording path.
+func (i *Index) findSegments(recordPath string, pathName string) []*Segment {
+	recordPath, _ = filepath.Abs(recordPath)
+
+	var segments []*Segment
+
+	for _, e := range i.Entries(pathName) {
+		if e.S3Key != "" {
+			continue
+		}
+
+		var pa Path
+		if pa.Decode(recordPath, e.Fpath) {
+			segments = append(segments, &Segment{
+				Fpath:    e.Fpath,
+				Start:    e.Start,
+				Duration: e.Duration,
+				index:    i,
+			})
+		}
+	}
+
+	return segments
+}
+
+// findBucketSegments returns segments of a path that are stored in a bucket and match the key format.
+func (i *Index) findBucketSegments(s3 *S3, keyFormat string, pathName string) []*Segment {
+	var segments []*Segment
+
+	for _, e := range i.Entries(pathName) {
+		if e.S3Key == "" || e.Fpath != s3.URL(e.S3Key) {
+			continue
+		}
+
+		var pa Path
+		if pa.Decode(keyFormat, e.S3Key) {
+			segments = append(segments, &Segment{
+				Fpath:    e.Fpath,
+				Start:    e.Start,
+				Duration: e.Duration,
+				index:    i,
+				s3:       s3,
+				s3Key:    e.S3Key,
+				s3Size:   e.Size,
+			})
+		}
+	}
+
+	return segments
+}
@@ -0,0 +1,142 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:         "~^.*$",
+		Regexp:       regexp.MustCompile("^.*$"),
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	idx := &Index{Path: filepath.Join(dir, "index")}
+	err = idx.Initialize()
+	require.NoError(t, err)
+	require.True(t, idx.Missing())
+
+	err = idx.Put(IndexEntry{
+		PathName: "path1",
+		Fpath:    filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+		Start:    time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+	})
+	require.NoError(t, err)
+
+	err = idx.Put(IndexEntry{
+		PathName: "path1",
+		Fpath:    filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+		Start:    time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+		Duration: 3 * time.Second,
+		Size:     10,
+	})
+	require.NoError(t, err)
+
+	err = idx.Put(IndexEntry{
+		PathName: "path1",
+		Fpath:    filepath.Join(dir, "path1", "2016-05-19_22-15-25-000427.mp4"),
+		Start:    time.Date(2016, 5, 19, 22, 15, 25, 427000, time.Local),
+	})
+	require.NoError(t, err)
+
+	err = idx.Put(IndexEntry{
+		PathName: "path2",
+		Fpath:    filepath.Join(dir, "path2", "2015-05-19_22-15-25-000427.mp4"),
+		Start:    time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+	})
+	require.NoError(t, err)
+
+	err = idx.Remove(filepath.Join(dir, "path2", "2015-05-19_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+
+	idx.Close()
+
+	// entries are restored from the journal
+	idx = &Index{Path: filepath.Join(dir, "index")}
+	err = idx.Initialize()
+	require.NoError(t, err)
+	defer idx.Close()
+	require.False(t, idx.Missing())
+
+	require.Equal(t, []string{"path1"}, idx.PathNames())
+
+	entries := idx.Entries("path1")
+	require.Len(t, entries, 2)
+	require.Equal(t, 3*time.Second, entries[0].Duration)
+	require.Equal(t, int64(10), entries[0].Size)
+
+	paths := FindAllPathsWithSegments(map[string]*conf.Path{"~^.*$": pathConf}, idx)
+	require.Equal(t, []string{"path1"}, paths)
+
+	segments, err := FindSegments(pathConf, "path1", idx)
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+	require.Equal(t, 3*time.Second, segments[0].Duration)
+	require.Equal(t, filepath.Join(dir, "path1", "2016-05-19_22-15-25-000427.mp4"), segments[1].Fpath)
+
+	// segments on disk are different from the ones in the index
+	err = os.Mkdir(filepath.Join(dir, "path3"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "path3", "2017-05-19_22-15-25-000427.mp4"), []byte{1, 2}, 0o644)
+	require.NoError(t, err)
+
+	n, err := idx.Rebuild(map[string]*conf.Path{"~^.*$": pathConf})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	require.Equal(t, []string{"path3"}, idx.PathNames())
+	require.Equal(t, []*IndexEntry{{
+		PathName: "path3",
+		Fpath:    filepath.Join(dir, "path3", "2017-05-19_22-15-25-000427.mp4"),
+		Start:    time.Date(2017, 5, 19, 22, 15, 25, 427000, time.Local),
+		Size:     2,
+	}}, idx.Entries("path3"))
+
+	segments, err = FindSegments(pathConf, "path3", idx)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	err = segments[0].Remove()
+	require.NoError(t, err)
+	require.Equal(t, []string{}, idx.PathNames())
+}
+
+func TestIndexCompact(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	idx := &Index{Path: filepath.Join(dir, "index")}
+	err = idx.Initialize()
+	require.NoError(t, err)
+	defer idx.Close()
+
+	for i := 0; i < indexCompactThreshold*2; i++ {
+		err = idx.Put(IndexEntry{
+			PathName: "path1",
+			Fpath:    filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+			Start:    time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+			Size:     int64(i),
+		})
+		require.NoError(t, err)
+	}
+
+	require.LessOrEqual(t, idx.journalLen, indexCompactThreshold+1)
+
+	byts, err := os.ReadFile(filepath.Join(dir, "index"))
+	require.NoError(t, err)
+	require.Less(t, len(byts), 200*(indexCompactThreshold+1))
+}
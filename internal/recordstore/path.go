@@ -29,6 +29,12 @@ func PathAddExtension(path string, format conf.RecordFormat) string {
 	case conf.RecordFormatMPEGTS:
 		return path + ".ts"
 
+	case conf.RecordFormatMatroska:
+		return path + ".mkv"
+
+	case conf.RecordFormatWebM:
+		return path + ".webm"
+
 	default:
 		return path + ".mp4"
 	}
@@ -0,0 +1,217 @@
+package recordstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// RepairResult describes a segment that has been repaired.
+type RepairResult struct {
+	Fpath        string
+	Start        time.Time
+	OriginalSize int64
+	Size         int64
+	PartCount    int
+
+	// the segment did not contain any complete part and has been removed.
+	Removed bool
+
+	// the segment did not contain a complete initialization section
+	// and has been renamed by adding RepairQuarantineSuffix, in order to allow its inspection.
+	Quarantined bool
+}
+
+// RepairQuarantineSuffix is the suffix added to segments that can't be repaired.
+const RepairQuarantineSuffix = ".corrupted"
+
+// errRepairNoInit is returned when a segment does not contain a complete initialization section.
+var errRepairNoInit = errors.New("initialization section is missing or incomplete")
+
+// fmp4CompleteSize returns the size of the portion of a fMP4 file
+// that contains the initialization section and complete parts only,
+// together with the number of complete parts.
+func fmp4CompleteSize(r io.ReaderAt, size int64) (int64, int, error) {
+	var pos int64
+	var completeSize int64
+	partCount := 0
+	initDone := false
+	moofPending := false
+	buf := make([]byte, 16)
+
+	for pos < size {
+		if (size - pos) < 8 {
+			break
+		}
+
+		_, err := r.ReadAt(buf[:8], pos)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(buf[:4]))
+		boxType := string(buf[4:8])
+
+		if boxSize == 1 {
+			if (size - pos) < 16 {
+				break
+			}
+
+			_, err = r.ReadAt(buf[8:16], pos+8)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			boxSize = int64(binary.BigEndian.Uint64(buf[8:16]))
+		}
+
+		// boxes with size zero extend until the end of the file and are never written by the recorder.
+		// They are usually caused by a file system that filled the last blocks with zeros.
+		if boxSize < 8 || (size-pos) < boxSize {
+			break
+		}
+
+		pos += boxSize
+
+		switch boxType {
+		case "moov":
+			initDone = true
+			completeSize = pos
+
+		case "moof":
+			moofPending = true
+
+		case "mdat":
+			if moofPending {
+				moofPending = false
+				partCount++
+				completeSize = pos
+			}
+		}
+	}
+
+	if !initDone {
+		return 0, 0, errRepairNoInit
+	}
+
+	return completeSize, partCount, nil
+}
+
+// RepairFMP4 checks whether a fMP4 segment ends with an incomplete part,
+// that happens when the server or the host is stopped abruptly,
+// and in that case cuts the segment back to the last complete part.
+// Segments without complete parts are removed, while segments without
+// a complete initialization section are quarantined, since they can't be parsed.
+// It returns nil if the segment is intact.
+func RepairFMP4(seg *Segment) (*RepairResult, error) {
+	if seg.InBucket() {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(seg.Fpath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := fi.Size()
+
+	// encrypted segments are checked after decryption
+	var r io.ReaderAt = f
+	var headerSize int64
+
+	er, err := newEncryptedReader(f, size, seg.keys, seg.keysErr)
+	if err == nil {
+		r = er
+		headerSize = encryptionHeaderSize
+		size = er.size
+	} else if !errors.Is(err, errSegmentNotEncrypted) {
+		return nil, err
+	}
+
+	completeSize, partCount, err := fmp4CompleteSize(r, size)
+	if err != nil && !errors.Is(err, errRepairNoInit) {
+		return nil, err
+	}
+
+	if err == nil && completeSize == size && partCount != 0 {
+		return nil, nil
+	}
+
+	res := &RepairResult{
+		Fpath:        seg.Fpath,
+		Start:        seg.Start,
+		OriginalSize: size,
+		Size:         completeSize,
+		PartCount:    partCount,
+	}
+
+	if errors.Is(err, errRepairNoInit) {
+		f.Close()
+
+		err = quarantineSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Size = 0
+		res.Quarantined = true
+		return res, nil
+	}
+
+	if partCount == 0 {
+		f.Close()
+
+		err = seg.Remove()
+		if err != nil {
+			return nil, err
+		}
+
+		res.Size = 0
+		res.Removed = true
+		return res, nil
+	}
+
+	err = f.Truncate(headerSize + completeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	if seg.index != nil {
+		seg.index.setSize(seg.Fpath, headerSize+completeSize) //nolint:errcheck
+	}
+
+	return res, nil
+}
+
+// quarantineSegment renames a segment and its metadata, in order to exclude them from recordings.
+func quarantineSegment(seg *Segment) error {
+	err := os.Rename(seg.Fpath, seg.Fpath+RepairQuarantineSuffix)
+	if err != nil {
+		return err
+	}
+
+	mdPath := SegmentMetadataPath(seg.Fpath)
+	err = os.Rename(mdPath, mdPath+RepairQuarantineSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if seg.index != nil {
+		seg.index.Remove(seg.Fpath) //nolint:errcheck
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFMP4(t *testing.T, fpath string, partCount int) int64 {
+	var buf seekablebuffer.Buffer
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &fmp4.CodecH264{
+				SPS: []byte{
+					0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+					0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+					0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+				},
+				PPS: []byte{0x08},
+			},
+		}},
+	}
+	err := init.Marshal(&buf)
+	require.NoError(t, err)
+
+	for i := 0; i < partCount; i++ {
+		var partBuf seekablebuffer.Buffer
+
+		part := fmp4.Part{
+			SequenceNumber: uint32(i),
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: uint64(i) * 90000,
+				Samples: []*fmp4.PartSample{{
+					Duration: 90000,
+					Payload:  []byte{1, 2, 3, 4},
+				}},
+			}},
+		}
+		err = part.Marshal(&partBuf)
+		require.NoError(t, err)
+
+		_, err = buf.Write(partBuf.Bytes())
+		require.NoError(t, err)
+	}
+
+	err = os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	return int64(len(buf.Bytes()))
+}
+
+func TestRepairFMP4(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	idx := &Index{Path: filepath.Join(dir, "index")}
+	err = idx.Initialize()
+	require.NoError(t, err)
+	defer idx.Close()
+
+	fpath := filepath.Join(dir, "2015-05-19_22-15-25-000427.mp4")
+	seg := &Segment{
+		Fpath: fpath,
+		Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+		index: idx,
+	}
+
+	t.Run("intact", func(t *testing.T) {
+		writeTestFMP4(t, fpath, 2)
+
+		res, err2 := RepairFMP4(seg)
+		require.NoError(t, err2)
+		require.Nil(t, res)
+	})
+
+	for _, ca := range []string{"truncated part", "zero-filled tail"} {
+		t.Run(ca, func(t *testing.T) {
+			completeSize := writeTestFMP4(t, fpath, 2)
+
+			f, err2 := os.OpenFile(fpath, os.O_WRONLY|os.O_APPEND, 0o644)
+			require.NoError(t, err2)
+
+			if ca == "truncated part" {
+				// header of a moof box that is bigger than the remaining data
+				_, err2 = f.Write([]byte{0x00, 0x00, 0x01, 0x00, 'm', 'o', 'o', 'f', 1, 2, 3})
+			} else {
+				_, err2 = f.Write(make([]byte, 4096))
+			}
+			require.NoError(t, err2)
+			f.Close()
+
+			err2 = idx.Put(IndexEntry{
+				PathName: "mypath",
+				Fpath:    fpath,
+				Start:    seg.Start,
+			})
+			require.NoError(t, err2)
+
+			res, err2 := RepairFMP4(seg)
+			require.NoError(t, err2)
+			require.Equal(t, &RepairResult{
+				Fpath:        fpath,
+				Start:        seg.Start,
+				OriginalSize: res.OriginalSize,
+				Size:         completeSize,
+				PartCount:    2,
+			}, res)
+
+			fi, err2 := os.Stat(fpath)
+			require.NoError(t, err2)
+			require.Equal(t, completeSize, fi.Size())
+			require.Equal(t, completeSize, idx.Entries("mypath")[0].Size)
+		})
+	}
+
+	t.Run("no parts", func(t *testing.T) {
+		writeTestFMP4(t, fpath, 0)
+
+		res, err2 := RepairFMP4(seg)
+		require.NoError(t, err2)
+		require.True(t, res.Removed)
+
+		_, err2 = os.Stat(fpath)
+		require.True(t, os.IsNotExist(err2))
+	})
+
+	t.Run("no init", func(t *testing.T) {
+		err2 := os.WriteFile(fpath, []byte{0x00, 0x00, 0x01, 0x00, 'm', 'o', 'o', 'v', 1, 2, 3}, 0o644)
+		require.NoError(t, err2)
+
+		res, err2 := RepairFMP4(seg)
+		require.NoError(t, err2)
+		require.True(t, res.Quarantined)
+
+		_, err2 = os.Stat(fpath)
+		require.True(t, os.IsNotExist(err2))
+
+		_, err2 = os.Stat(fpath + RepairQuarantineSuffix)
+		require.NoError(t, err2)
+	})
+}
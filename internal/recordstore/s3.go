@@ -0,0 +1,400 @@
+package recordstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// maximum duration of a request, including the transfer of the body,
+	// that must be enough to upload a segment.
+	s3RequestTimeout = 10 * time.Minute
+)
+
+// s3HTTPClient is shared between buckets, in order to reuse connections.
+var s3HTTPClient = &http.Client{
+	Timeout: s3RequestTimeout,
+}
+
+// S3Error is an error returned by a S3-compatible server.
+type S3Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// Error implements error.
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("bad status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is allows to compare the error with fs.ErrNotExist.
+func (e *S3Error) Is(target error) bool {
+	return target == fs.ErrNotExist && e.StatusCode == http.StatusNotFound
+}
+
+// S3Object is an object stored in a bucket.
+type S3Object struct {
+	Key  string
+	Size int64
+}
+
+// S3 is a client of a S3-compatible object storage.
+type S3 struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+	HTTPClient      *http.Client
+
+	now func() time.Time
+}
+
+// S3FromPathConf returns the bucket of a path, or nil if it is not set.
+func S3FromPathConf(pathConf *conf.Path) *S3 {
+	if pathConf.RecordS3Bucket == "" {
+		return nil
+	}
+
+	return &S3{
+		Endpoint:        pathConf.RecordS3Endpoint,
+		Region:          pathConf.RecordS3Region,
+		Bucket:          pathConf.RecordS3Bucket,
+		AccessKeyID:     pathConf.RecordS3AccessKeyID,
+		SecretAccessKey: pathConf.RecordS3SecretAccessKey,
+		PathStyle:       pathConf.RecordS3PathStyle,
+		HTTPClient:      s3HTTPClient,
+	}
+}
+
+// URL returns the URL of an object, in the s3://bucket/key form.
+func (s *S3) URL(key string) string {
+	return "s3://" + s.Bucket + "/" + key
+}
+
+func s3Escape(v string, escapeSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// sign signs a request with the AWS Signature Version 4.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, vals := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "range" || lk == "content-type" || lk == "content-md5" {
+			headers[lk] = strings.TrimSpace(strings.Join(vals, ","))
+		}
+	}
+
+	headerNames := make([]string, 0, len(headers))
+	for k := range headers {
+		headerNames = append(headerNames, k)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range headerNames {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", s3Algorithm+
+		" Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+func (s *S3) objectURL(key string, query url.Values) (string, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+
+	if s.PathStyle {
+		path += "/" + s3Escape(s.Bucket, true)
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+	}
+
+	path += "/" + s3Escape(key, false)
+
+	out := u.Scheme + "://" + u.Host + path
+	if len(query) != 0 {
+		out += "?" + s3CanonicalQuery(query)
+	}
+
+	return out, nil
+}
+
+func (s *S3) do(
+	ctx context.Context,
+	method string,
+	key string,
+	query url.Values,
+	header http.Header,
+	body io.Reader,
+	size int64,
+) (*http.Response, error) {
+	u, err := s.objectURL(key, query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+
+	payloadHash := s3EmptyPayload
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = s3UnsignedPayload
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	s.sign(req, payloadHash, now())
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = s3HTTPClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+
+		s3Err := &S3Error{StatusCode: res.StatusCode}
+
+		var body struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.NewDecoder(res.Body).Decode(&body) == nil {
+			s3Err.Code = body.Code
+			s3Err.Message = body.Message
+		}
+
+		return nil, s3Err
+	}
+
+	return res, nil
+}
+
+// PutObject uploads an object.
+func (s *S3) PutObject(ctx context.Context, key string, r io.Reader, size int64) error {
+	res, err := s.do(ctx, http.MethodPut, key, nil, nil, r, size)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// GetObject downloads an object, or a part of it.
+// If end is negative, the object is read until its end.
+func (s *S3) GetObject(ctx context.Context, key string, start int64, end int64) (io.ReadCloser, error) {
+	header := http.Header{}
+
+	if start > 0 || end >= 0 {
+		rang := "bytes=" + strconv.FormatInt(start, 10) + "-"
+		if end >= 0 {
+			rang += strconv.FormatInt(end, 10)
+		}
+		header.Set("Range", rang)
+	}
+
+	res, err := s.do(ctx, http.MethodGet, key, nil, header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// DeleteObject deletes an object.
+func (s *S3) DeleteObject(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// ListObjects returns all objects whose key starts with prefix.
+func (s *S3) ListObjects(ctx context.Context, prefix string) ([]S3Object, error) {
+	var out []S3Object
+	continuationToken := ""
+
+	for {
+		query := url.Values{
+			"list-type": []string{"2"},
+			"prefix":    []string{prefix},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		res, err := s.do(ctx, http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key  string `xml:"Key"`
+				Size int64  `xml:"Size"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			out = append(out, S3Object{
+				Key:  c.Key,
+				Size: c.Size,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return out, nil
+		}
+
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// s3CommonPrefix returns the common prefix between all keys with given format.
+func s3CommonPrefix(format string) string {
+	i := strings.Index(format, "%")
+	if i >= 0 {
+		format = format[:i]
+	}
+
+	i = strings.LastIndex(format, "/")
+	if i < 0 {
+		return ""
+	}
+
+	return format[:i+1]
+}
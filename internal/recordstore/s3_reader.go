@@ -0,0 +1,130 @@
+package recordstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	s3ReaderChunkSize = 1024 * 1024
+	s3ReaderTimeout   = 30 * time.Second
+)
+
+// s3Reader is a SegmentReader that reads an object with ranged requests.
+// Data is downloaded in chunks, in order to reduce the number of requests
+// caused by small reads and seeks.
+type s3Reader struct {
+	s3   *S3
+	key  string
+	size int64
+
+	pos        int64
+	chunk      []byte
+	chunkStart int64
+}
+
+func (r *s3Reader) chunkContains(pos int64) bool {
+	return r.chunk != nil && pos >= r.chunkStart && pos < (r.chunkStart+int64(len(r.chunk)))
+}
+
+func (r *s3Reader) fetchChunk(pos int64) error {
+	end := pos + s3ReaderChunkSize
+	if end > r.size {
+		end = r.size
+	}
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), s3ReaderTimeout)
+	defer ctxCancel()
+
+	body, err := r.s3.GetObject(ctx, r.key, pos, end-1)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	buf := make([]byte, end-pos)
+	_, err = io.ReadFull(body, buf)
+	if err != nil {
+		return err
+	}
+
+	r.chunk = buf
+	r.chunkStart = pos
+	return nil
+}
+
+// Read implements io.Reader.
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if !r.chunkContains(r.pos) {
+		err := r.fetchChunk(r.pos)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.chunk[r.pos-r.chunkStart:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *s3Reader) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+
+		if !r.chunkContains(pos) {
+			err := r.fetchChunk(pos)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		n += copy(p[n:], r.chunk[pos-r.chunkStart:])
+	}
+
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+
+	case io.SeekCurrent:
+		pos = r.pos + offset
+
+	case io.SeekEnd:
+		pos = r.size + offset
+
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	r.pos = pos
+	return pos, nil
+}
+
+// Close implements io.Closer.
+func (r *s3Reader) Close() error {
+	r.chunk = nil
+	return nil
+}
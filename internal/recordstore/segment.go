@@ -1,8 +1,13 @@
 package recordstore
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -16,25 +21,178 @@ var ErrNoSegmentsFound = errors.New("no recording segments found")
 
 var errFound = errors.New("found")
 
+// SegmentReader is a reader of a segment.
+type SegmentReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
 // Segment is a recording segment.
 type Segment struct {
 	Fpath string
 	Start time.Time
+
+	// duration of the segment, when it is stored into the index.
+	// It is zero when unknown.
+	Duration time.Duration
+
+	// index the segment has been found into
+	index *Index
+
+	// encryption keys of the path, that are loaded once by FindSegments,
+	// or the error that prevented them from being loaded.
+	keys    EncryptionKeys
+	keysErr error
+
+	// fields of segments stored in a bucket
+	s3     *S3
+	s3Key  string
+	s3Size int64
 }
 
-func fixedPathHasSegments(pathConf *conf.Path) bool {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name),
-		pathConf.RecordFormat,
-	)
+// InBucket checks whether the segment is stored in a bucket instead of on disk.
+func (s *Segment) InBucket() bool {
+	return s.s3 != nil
+}
+
+// Open opens the segment.
+// Encrypted segments are decrypted transparently.
+func (s *Segment) Open() (SegmentReader, error) {
+	r, size, err := s.openRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	er, err := newEncryptedReader(r, size, s.keys, s.keysErr)
+	if err != nil {
+		if errors.Is(err, errSegmentNotEncrypted) {
+			return r, nil
+		}
+		r.Close()
+		return nil, err
+	}
+
+	return er, nil
+}
+
+// openRaw opens the segment without decrypting it.
+func (s *Segment) openRaw() (SegmentReader, int64, error) {
+	if s.s3 != nil {
+		return &s3Reader{
+			s3:   s.s3,
+			key:  s.s3Key,
+			size: s.s3Size,
+		}, s.s3Size, nil
+	}
+
+	f, err := os.Open(s.Fpath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, fi.Size(), nil
+}
+
+// Remove removes the segment and its metadata.
+func (s *Segment) Remove() error {
+	if s.s3 != nil {
+		ctx, ctxCancel := context.WithTimeout(context.Background(), s3ReaderTimeout)
+		defer ctxCancel()
+
+		err := s.s3.DeleteObject(ctx, s.s3Key)
+		if err != nil {
+			return err
+		}
+
+		s.s3.DeleteObject(ctx, SegmentMetadataPath(s.s3Key)) //nolint:errcheck
+
+		if s.index != nil {
+			s.index.Remove(s.Fpath) //nolint:errcheck
+		}
+
+		return nil
+	}
+
+	err := RemoveSegment(s.Fpath)
+	if err != nil {
+		return err
+	}
+
+	if s.index != nil {
+		s.index.Remove(s.Fpath) //nolint:errcheck
+	}
+
+	return nil
+}
+
+// ReadMetadata reads the metadata of the segment.
+func (s *Segment) ReadMetadata() (*SegmentMetadata, error) {
+	if s.s3 != nil {
+		ctx, ctxCancel := context.WithTimeout(context.Background(), s3ReaderTimeout)
+		defer ctxCancel()
+
+		body, err := s.s3.GetObject(ctx, SegmentMetadataPath(s.s3Key), 0, -1)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		var md SegmentMetadata
+		err = json.NewDecoder(body).Decode(&md)
+		if err != nil {
+			return nil, err
+		}
+
+		return &md, nil
+	}
+
+	return ReadSegmentMetadata(s.Fpath)
+}
+
+// UpdateMetadata reads the metadata of the segment, edits it and writes it back.
+func (s *Segment) UpdateMetadata(cb func(md *SegmentMetadata)) error {
+	if s.s3 != nil {
+		metadataMutex.Lock()
+		defer metadataMutex.Unlock()
+
+		md, err := s.ReadMetadata()
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			md = &SegmentMetadata{}
+		}
+
+		cb(md)
 
+		byts, err := json.Marshal(md)
+		if err != nil {
+			return err
+		}
+
+		ctx, ctxCancel := context.WithTimeout(context.Background(), s3ReaderTimeout)
+		defer ctxCancel()
+
+		return s.s3.PutObject(ctx, SegmentMetadataPath(s.s3Key), bytes.NewReader(byts), int64(len(byts)))
+	}
+
+	return UpdateSegmentMetadata(s.Fpath, cb)
+}
+
+func walkLocalSegments(recordPath string, cb func(fpath string, pa *Path) error) error {
 	// we have to convert to absolute paths
 	// otherwise, recordPath and fpath inside Walk() won't have common elements
 	recordPath, _ = filepath.Abs(recordPath)
 
 	commonPath := CommonPath(recordPath)
 
-	err := filepath.Walk(commonPath, func(fpath string, info fs.FileInfo, err error) error {
+	return filepath.Walk(commonPath, func(fpath string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -43,63 +201,139 @@ func fixedPathHasSegments(pathConf *conf.Path) bool {
 			var pa Path
 			ok := pa.Decode(recordPath, fpath)
 			if ok {
-				return errFound
+				return cb(fpath, &pa)
 			}
 		}
 
 		return nil
 	})
-	if err != nil && !errors.Is(err, errFound) {
-		return false
+}
+
+func walkBucketSegments(s3 *S3, keyFormat string, cb func(obj *S3Object, pa *Path) error) error {
+	ctx, ctxCancel := context.WithTimeout(context.Background(), s3ReaderTimeout)
+	defer ctxCancel()
+
+	objects, err := s3.ListObjects(ctx, s3CommonPrefix(keyFormat))
+	if err != nil {
+		return err
 	}
 
-	return errors.Is(err, errFound)
+	for i := range objects {
+		var pa Path
+		ok := pa.Decode(keyFormat, objects[i].Key)
+		if ok {
+			err = cb(&objects[i], &pa)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
-func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
+func fixedPathHasSegments(pathConf *conf.Path, index *Index) bool {
 	recordPath := PathAddExtension(
-		pathConf.RecordPath,
+		strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name),
 		pathConf.RecordFormat,
 	)
 
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
+	if index != nil {
+		if len(index.findSegments(recordPath, pathConf.Name)) != 0 {
+			return true
+		}
+	} else {
+		err := walkLocalSegments(recordPath, func(_ string, _ *Path) error {
+			return errFound
+		})
+		if errors.Is(err, errFound) {
+			return true
+		}
+	}
 
-	commonPath := CommonPath(recordPath)
+	if s3 := S3FromPathConf(pathConf); s3 != nil {
+		keyFormat := PathAddExtension(
+			strings.ReplaceAll(pathConf.RecordS3Key, "%path", pathConf.Name),
+			pathConf.RecordFormat,
+		)
 
-	ret := make(map[string]struct{})
+		if index != nil {
+			return len(index.findBucketSegments(s3, keyFormat, pathConf.Name)) != 0
+		}
 
-	filepath.Walk(commonPath, func(fpath string, info fs.FileInfo, err error) error { //nolint:errcheck
-		if err != nil {
-			return err
+		err := walkBucketSegments(s3, keyFormat, func(_ *S3Object, _ *Path) error {
+			return errFound
+		})
+		if errors.Is(err, errFound) {
+			return true
 		}
+	}
 
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-			if ok && pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
-				ret[pa.Path] = struct{}{}
+	return false
+}
+
+func regexpPathFindPathsWithSegments(pathConf *conf.Path, index *Index) map[string]struct{} {
+	recordPath := PathAddExtension(
+		pathConf.RecordPath,
+		pathConf.RecordFormat,
+	)
+
+	ret := make(map[string]struct{})
+
+	if index != nil {
+		for _, pathName := range index.PathNames() {
+			if pathConf.Regexp.FindStringSubmatch(pathName) != nil &&
+				len(index.findSegments(strings.ReplaceAll(recordPath, "%path", pathName), pathName)) != 0 {
+				ret[pathName] = struct{}{}
 			}
 		}
+	} else {
+		walkLocalSegments(recordPath, func(_ string, pa *Path) error { //nolint:errcheck
+			if pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
+				ret[pa.Path] = struct{}{}
+			}
+			return nil
+		})
+	}
 
-		return nil
-	})
+	if s3 := S3FromPathConf(pathConf); s3 != nil {
+		keyFormat := PathAddExtension(
+			pathConf.RecordS3Key,
+			pathConf.RecordFormat,
+		)
+
+		if index != nil {
+			for _, pathName := range index.PathNames() {
+				if pathConf.Regexp.FindStringSubmatch(pathName) != nil &&
+					len(index.findBucketSegments(s3, strings.ReplaceAll(keyFormat, "%path", pathName), pathName)) != 0 {
+					ret[pathName] = struct{}{}
+				}
+			}
+		} else {
+			walkBucketSegments(s3, keyFormat, func(_ *S3Object, pa *Path) error { //nolint:errcheck
+				if pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
+					ret[pa.Path] = struct{}{}
+				}
+				return nil
+			})
+		}
+	}
 
 	return ret
 }
 
 // FindAllPathsWithSegments returns all paths that do have segments.
-func FindAllPathsWithSegments(pathConfs map[string]*conf.Path) []string {
+// If index is not nil, it is used in place of recording directories.
+func FindAllPathsWithSegments(pathConfs map[string]*conf.Path, index *Index) []string {
 	pathNames := make(map[string]struct{})
 
 	for _, pathConf := range pathConfs {
 		if pathConf.Regexp == nil {
-			if fixedPathHasSegments(pathConf) {
+			if fixedPathHasSegments(pathConf, index) {
 				pathNames[pathConf.Name] = struct{}{}
 			}
 		} else {
-			for name := range regexpPathFindPathsWithSegments(pathConf) {
+			for name := range regexpPathFindPathsWithSegments(pathConf, index) {
 				pathNames[name] = struct{}{}
 			}
 		}
@@ -116,53 +350,135 @@ func FindAllPathsWithSegments(pathConfs map[string]*conf.Path) []string {
 	return out
 }
 
+// FindLocalSegments returns all segments stored on disk with given recording path,
+// in which %path has already been replaced.
+func FindLocalSegments(recordPath string) ([]*Segment, error) {
+	var segments []*Segment
+
+	err := walkLocalSegments(recordPath, func(fpath string, pa *Path) error {
+		segments = append(segments, &Segment{
+			Fpath: fpath,
+			Start: pa.Start,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if segments == nil {
+		return nil, ErrNoSegmentsFound
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Start.Before(segments[j].Start)
+	})
+
+	return segments, nil
+}
+
 // FindSegments returns all segments of a path.
+// If index is not nil, it is used in place of recording directories.
 func FindSegments(
 	pathConf *conf.Path,
 	pathName string,
+	index *Index,
 ) ([]*Segment, error) {
 	recordPath := PathAddExtension(
 		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
 		pathConf.RecordFormat,
 	)
 
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
+	keyFormat := PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordS3Key, "%path", pathName),
+		pathConf.RecordFormat,
+	)
 
-	commonPath := CommonPath(recordPath)
+	segments, err := FindSegmentsWithFormats(recordPath, S3FromPathConf(pathConf), keyFormat, pathName, index)
+	if err != nil {
+		return nil, err
+	}
+
+	// keys are needed by encrypted segments only, therefore errors are reported when they are opened.
+	if pathConf.RecordEncryptionKey != "" {
+		keys, keysErr := LoadEncryptionKeys(pathConf.RecordEncryptionKey)
+
+		for _, seg := range segments {
+			seg.keys = keys
+			seg.keysErr = keysErr
+		}
+	}
+
+	return segments, nil
+}
+
+// FindSegmentsWithFormats returns all segments of a path that match a recording path
+// and, when s3 is not nil, a key format.
+func FindSegmentsWithFormats(
+	recordPath string,
+	s3 *S3,
+	keyFormat string,
+	pathName string,
+	index *Index,
+) ([]*Segment, error) {
 	var segments []*Segment
 
-	err := filepath.Walk(commonPath, func(fpath string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
+	if index != nil {
+		segments = index.findSegments(recordPath, pathName)
+	} else {
+		var err error
+		segments, err = FindLocalSegments(recordPath)
+		if err != nil && !errors.Is(err, ErrNoSegmentsFound) {
+			// when a bucket is in use, all segments may have been moved there.
+			if s3 == nil || !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
 		}
+	}
 
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-			if ok {
-				segments = append(segments, &Segment{
-					Fpath: fpath,
-					Start: pa.Start,
+	if s3 != nil {
+		// segments that are being uploaded are present in both places,
+		// local ones are preferred.
+		localStarts := make(map[int64]struct{}, len(segments))
+		for _, seg := range segments {
+			localStarts[seg.Start.UnixNano()] = struct{}{}
+		}
+
+		var bucketSegments []*Segment
+
+		if index != nil {
+			bucketSegments = index.findBucketSegments(s3, keyFormat, pathName)
+		} else {
+			err := walkBucketSegments(s3, keyFormat, func(obj *S3Object, pa *Path) error {
+				bucketSegments = append(bucketSegments, &Segment{
+					Fpath:  s3.URL(obj.Key),
+					Start:  pa.Start,
+					s3:     s3,
+					s3Key:  obj.Key,
+					s3Size: obj.Size,
 				})
+				return nil
+			})
+			if err != nil {
+				return nil, err
 			}
 		}
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		for _, seg := range bucketSegments {
+			if _, ok := localStarts[seg.Start.UnixNano()]; !ok {
+				segments = append(segments, seg)
+			}
+		}
+
+		sort.Slice(segments, func(i, j int) bool {
+			return segments[i].Start.Before(segments[j].Start)
+		})
 	}
 
 	if segments == nil {
 		return nil, ErrNoSegmentsFound
 	}
 
-	sort.Slice(segments, func(i, j int) bool {
-		return segments[i].Start.Before(segments[j].Start)
-	})
-
 	return segments, nil
 }
 
@@ -172,52 +488,27 @@ func FindSegmentsInTimespan(
 	pathName string,
 	start time.Time,
 	duration time.Duration,
+	index *Index,
 ) ([]*Segment, error) {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
-		pathConf.RecordFormat,
-	)
-
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
+	allSegments, err := FindSegments(pathConf, pathName, index)
+	if err != nil {
+		return nil, err
+	}
 
-	commonPath := CommonPath(recordPath)
 	end := start.Add(duration)
 	var segments []*Segment
 
-	err := filepath.Walk(commonPath, func(fpath string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-
-			// gather all segments that starts before the end of the playback
-			if ok && !end.Before(pa.Start) {
-				segments = append(segments, &Segment{
-					Fpath: fpath,
-					Start: pa.Start,
-				})
-			}
+	// gather all segments that starts before the end of the playback
+	for _, seg := range allSegments {
+		if !end.Before(seg.Start) {
+			segments = append(segments, seg)
 		}
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	if segments == nil {
 		return nil, ErrNoSegmentsFound
 	}
 
-	sort.Slice(segments, func(i, j int) bool {
-		return segments[i].Start.Before(segments[j].Start)
-	})
-
 	// find the segment that may contain the start of the playback and remove all previous ones
 	found := false
 	for i := 0; i < len(segments)-1; i++ {
@@ -0,0 +1,104 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadata files are written by both the recorder and the API.
+var metadataMutex sync.Mutex
+
+// SegmentMarker is a named point in time of a recording.
+type SegmentMarker struct {
+	Time  time.Time       `json:"time"`
+	Label string          `json:"label"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// SegmentMetadata contains additional informations about a segment.
+// It is stored in a sidecar file next to the segment.
+type SegmentMetadata struct {
+	Labels    []string          `json:"labels,omitempty"`
+	Markers   []*SegmentMarker  `json:"markers,omitempty"`
+	Signature *SegmentSignature `json:"signature,omitempty"`
+	Thinned   bool              `json:"thinned,omitempty"`
+}
+
+// AddMarker adds a marker, keeping markers sorted by time.
+func (md *SegmentMetadata) AddMarker(m *SegmentMarker) {
+	md.Markers = append(md.Markers, m)
+
+	sort.SliceStable(md.Markers, func(i, j int) bool {
+		return md.Markers[i].Time.Before(md.Markers[j].Time)
+	})
+}
+
+// SegmentMetadataPath returns the path of the metadata file of a segment.
+// The segment extension is replaced, in order to prevent the file from being
+// detected as a segment.
+func SegmentMetadataPath(segmentPath string) string {
+	return strings.TrimSuffix(segmentPath, filepath.Ext(segmentPath)) + ".json"
+}
+
+// ReadSegmentMetadata reads the metadata of a segment.
+func ReadSegmentMetadata(segmentPath string) (*SegmentMetadata, error) {
+	byts, err := os.ReadFile(SegmentMetadataPath(segmentPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var md SegmentMetadata
+	err = json.Unmarshal(byts, &md)
+	if err != nil {
+		return nil, err
+	}
+
+	return &md, nil
+}
+
+// WriteSegmentMetadata writes the metadata of a segment.
+func WriteSegmentMetadata(segmentPath string, md *SegmentMetadata) error {
+	byts, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(SegmentMetadataPath(segmentPath), byts, 0o644)
+}
+
+// UpdateSegmentMetadata reads the metadata of a segment, edits it and writes it back.
+// The metadata file is created if it doesn't exist.
+func UpdateSegmentMetadata(segmentPath string, cb func(md *SegmentMetadata)) error {
+	metadataMutex.Lock()
+	defer metadataMutex.Unlock()
+
+	md, err := ReadSegmentMetadata(segmentPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		md = &SegmentMetadata{}
+	}
+
+	cb(md)
+
+	return WriteSegmentMetadata(segmentPath, md)
+}
+
+// RemoveSegment removes a segment and its metadata.
+func RemoveSegment(segmentPath string) error {
+	err := os.Remove(segmentPath)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(SegmentMetadataPath(segmentPath)) //nolint:errcheck
+
+	return nil
+}
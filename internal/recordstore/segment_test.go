@@ -40,7 +40,7 @@ func TestFindAllPathsWithSegments(t *testing.T) {
 			RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
 			RecordFormat: conf.RecordFormatFMP4,
 		},
-	})
+	}, nil)
 	require.Equal(t, []string{"path1", "path2"}, paths)
 }
 
@@ -69,6 +69,7 @@ func TestFindSegments(t *testing.T) {
 			RecordFormat: conf.RecordFormatFMP4,
 		},
 		"path1",
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -111,6 +112,7 @@ func TestFindSegmentsInTimespan(t *testing.T) {
 		"path1",
 		time.Date(2015, 5, 19, 22, 18, 25, 427000, time.Local),
 		60*time.Minute,
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -0,0 +1,259 @@
+package recordstore
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const signatureVersion = "mediamtx-segment-v1"
+
+// SegmentSignature allows to detect whether a segment has been modified,
+// replaced or removed. Each signature contains the hash of the previous segment,
+// therefore signatures of a path form a chain.
+type SegmentSignature struct {
+	SHA256     string `json:"sha256"`
+	PrevSHA256 string `json:"prevSHA256,omitempty"`
+	Signature  string `json:"signature"`
+}
+
+// message returns the signed content, that includes path and start of the segment,
+// in order to detect segments that have been renamed or moved to another path.
+func (sig *SegmentSignature) message(pathName string, start time.Time) []byte {
+	return []byte(signatureVersion + "\n" + pathName + "\n" + start.UTC().Format(time.RFC3339Nano) + "\n" +
+		sig.SHA256 + "\n" + sig.PrevSHA256)
+}
+
+// LoadSignKey loads a Ed25519 private key from a PEM file in PKCS #8 format.
+func LoadSignKey(fpath string) (ed25519.PrivateKey, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(byts)
+	if block == nil {
+		return nil, fmt.Errorf("PEM block not found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not a Ed25519 key")
+	}
+
+	return edKey, nil
+}
+
+// LoadVerifyKey loads a Ed25519 public key from a PEM file in PKIX format.
+func LoadVerifyKey(fpath string) (ed25519.PublicKey, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(byts)
+	if block == nil {
+		return nil, fmt.Errorf("PEM block not found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not a Ed25519 key")
+	}
+
+	return edKey, nil
+}
+
+// verifyKeyFromPathConf returns the key used to verify signatures of a path.
+// The public key is used when available, in order to allow verification
+// without access to the private key.
+func verifyKeyFromPathConf(pathConf *conf.Path) (ed25519.PublicKey, error) {
+	if pathConf.RecordVerifyKey != "" {
+		return LoadVerifyKey(pathConf.RecordVerifyKey)
+	}
+
+	if pathConf.RecordSignKey != "" {
+		key, err := LoadSignKey(pathConf.RecordSignKey)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+
+	return nil, fmt.Errorf("'recordVerifyKey' is not set")
+}
+
+// SignSegment signs a segment.
+// start must be the one encoded into the segment path.
+func SignSegment(
+	key ed25519.PrivateKey,
+	pathName string,
+	start time.Time,
+	sha256 string,
+	prevSHA256 string,
+) *SegmentSignature {
+	sig := &SegmentSignature{
+		SHA256:     sha256,
+		PrevSHA256: prevSHA256,
+	}
+	sig.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, sig.message(pathName, start)))
+	return sig
+}
+
+// Hash computes the SHA-256 hash of the segment, in hexadecimal format.
+// Encrypted segments are not decrypted.
+func (s *Segment) Hash() (string, error) {
+	r, _, err := s.openRaw()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SegmentVerifyStatus is the verification status of a segment.
+type SegmentVerifyStatus string
+
+// verification statuses.
+const (
+	SegmentVerifyStatusValid            SegmentVerifyStatus = "valid"
+	SegmentVerifyStatusUnsigned         SegmentVerifyStatus = "unsigned"
+	SegmentVerifyStatusModified         SegmentVerifyStatus = "modified"
+	SegmentVerifyStatusInvalidSignature SegmentVerifyStatus = "invalidSignature"
+	SegmentVerifyStatusBrokenLink       SegmentVerifyStatus = "brokenLink"
+)
+
+// SegmentVerifyResult is the verification result of a segment.
+type SegmentVerifyResult struct {
+	Segment *Segment
+	SHA256  string
+	Status  SegmentVerifyStatus
+}
+
+// VerifySegments verifies signatures of segments, that must be sorted by start.
+// A segment has a broken link when it points to a previous segment
+// that is different from the one that precedes it.
+// Links of the first segment are not checked, since the previous segment
+// may have been deleted by retention.
+// A segment without a link starts a new chain, that happens when
+// the recorder restarts and the previous segment is not available.
+func VerifySegments(
+	segments []*Segment,
+	pathName string,
+	key ed25519.PublicKey,
+) ([]*SegmentVerifyResult, error) {
+	results := make([]*SegmentVerifyResult, len(segments))
+	var prevSig *SegmentSignature
+
+	for i, seg := range segments {
+		res := &SegmentVerifyResult{
+			Segment: seg,
+		}
+		results[i] = res
+
+		var err error
+		res.SHA256, err = seg.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		var sig *SegmentSignature
+		if md, err2 := seg.ReadMetadata(); err2 == nil {
+			sig = md.Signature
+		}
+
+		switch {
+		case sig == nil:
+			res.Status = SegmentVerifyStatusUnsigned
+
+		case !verifySignature(key, pathName, seg.Start, sig):
+			res.Status = SegmentVerifyStatusInvalidSignature
+
+		case sig.SHA256 != res.SHA256:
+			res.Status = SegmentVerifyStatusModified
+
+		case i != 0 && sig.PrevSHA256 != "" && (prevSig == nil || prevSig.SHA256 != sig.PrevSHA256):
+			res.Status = SegmentVerifyStatusBrokenLink
+
+		default:
+			res.Status = SegmentVerifyStatusValid
+		}
+
+		prevSig = sig
+	}
+
+	return results, nil
+}
+
+// VerifyRecordings verifies signatures of segments of a path
+// that start between start and end. Zero values disable bounds.
+// Signatures are verified with recordVerifyKey or, when it is not set,
+// with the public part of recordSignKey.
+func VerifyRecordings(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	end time.Time,
+	index *Index,
+) ([]*SegmentVerifyResult, error) {
+	key, err := verifyKeyFromPathConf(pathConf)
+	if err != nil {
+		return nil, err
+	}
+
+	allSegments, err := FindSegments(pathConf, pathName, index)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []*Segment
+
+	for _, seg := range allSegments {
+		if (start.IsZero() || !seg.Start.Before(start)) &&
+			(end.IsZero() || seg.Start.Before(end)) {
+			segments = append(segments, seg)
+		}
+	}
+
+	if segments == nil {
+		return nil, ErrNoSegmentsFound
+	}
+
+	return VerifySegments(segments, pathName, key)
+}
+
+func verifySignature(key ed25519.PublicKey, pathName string, start time.Time, sig *SegmentSignature) bool {
+	byts, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return false
+	}
+
+	return ed25519.Verify(key, sig.message(pathName, start), byts)
+}
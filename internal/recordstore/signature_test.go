@@ -0,0 +1,160 @@
+package recordstore
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSignKey(t *testing.T, fpath string) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	byts, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: byts}), 0o644)
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestVerifyRecordings(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := writeTestSignKey(t, filepath.Join(dir, "sign.key"))
+
+	loaded, err := LoadSignKey(filepath.Join(dir, "sign.key"))
+	require.NoError(t, err)
+	require.Equal(t, key, loaded)
+
+	pathConf := &conf.Path{
+		Name:          "mypath",
+		RecordPath:    filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:  conf.RecordFormatFMP4,
+		RecordSignKey: filepath.Join(dir, "sign.key"),
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	names := []string{
+		"2008-11-07_11-22-00-000000.mp4",
+		"2008-11-07_11-23-00-000000.mp4",
+		"2008-11-07_11-24-00-000000.mp4",
+		"2008-11-07_11-25-00-000000.mp4",
+	}
+
+	prevSHA256 := ""
+
+	for i, name := range names {
+		fpath := filepath.Join(dir, "mypath", name)
+
+		err = os.WriteFile(fpath, []byte{byte(i), 2, 3, 4}, 0o644)
+		require.NoError(t, err)
+
+		var sha256 string
+		sha256, err = (&Segment{Fpath: fpath}).Hash()
+		require.NoError(t, err)
+
+		err = UpdateSegmentMetadata(fpath, func(md *SegmentMetadata) {
+			md.Signature = SignSegment(key, "mypath", time.Date(2008, 11, 7, 11, 22+i, 0, 0, time.Local), sha256, prevSHA256)
+		})
+		require.NoError(t, err)
+
+		prevSHA256 = sha256
+	}
+
+	statuses := func(start time.Time, end time.Time) []SegmentVerifyStatus {
+		results, err2 := VerifyRecordings(pathConf, "mypath", start, end, nil)
+		require.NoError(t, err2)
+
+		out := make([]SegmentVerifyStatus, len(results))
+		for i, res := range results {
+			out[i] = res.Status
+		}
+		return out
+	}
+
+	require.Equal(t, []SegmentVerifyStatus{
+		SegmentVerifyStatusValid,
+		SegmentVerifyStatusValid,
+		SegmentVerifyStatusValid,
+		SegmentVerifyStatusValid,
+	}, statuses(time.Time{}, time.Time{}))
+
+	// signatures are bound to the path name
+	segments, err := FindSegments(pathConf, "mypath", nil)
+	require.NoError(t, err)
+
+	results, err := VerifySegments(segments, "otherpath", key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	require.Equal(t, SegmentVerifyStatusInvalidSignature, results[0].Status)
+
+	// signatures are bound to the segment start
+	segments[0].Start = segments[0].Start.Add(time.Hour)
+
+	results, err = VerifySegments(segments[:1], "mypath", key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	require.Equal(t, SegmentVerifyStatusInvalidSignature, results[0].Status)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", names[1]), []byte{9, 9, 9}, 0o644)
+	require.NoError(t, err)
+
+	err = RemoveSegment(filepath.Join(dir, "mypath", names[2]))
+	require.NoError(t, err)
+
+	require.Equal(t, []SegmentVerifyStatus{
+		SegmentVerifyStatusValid,
+		SegmentVerifyStatusModified,
+		SegmentVerifyStatusBrokenLink,
+	}, statuses(time.Time{}, time.Time{}))
+
+	// links of the first segment are not checked
+	require.Equal(t, []SegmentVerifyStatus{
+		SegmentVerifyStatusValid,
+	}, statuses(
+		time.Date(2008, 11, 7, 11, 24, 0, 0, time.Local),
+		time.Date(2008, 11, 7, 11, 30, 0, 0, time.Local)))
+
+	// signatures can be verified with the public key
+	byts, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "verify.key"),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: byts}), 0o644)
+	require.NoError(t, err)
+
+	pathConf.RecordSignKey = ""
+	pathConf.RecordVerifyKey = filepath.Join(dir, "verify.key")
+
+	require.Equal(t, []SegmentVerifyStatus{
+		SegmentVerifyStatusValid,
+		SegmentVerifyStatusModified,
+		SegmentVerifyStatusBrokenLink,
+	}, statuses(time.Time{}, time.Time{}))
+
+	pathConf.RecordVerifyKey = ""
+
+	_, err = VerifyRecordings(pathConf, "mypath", time.Time{}, time.Time{}, nil)
+	require.EqualError(t, err, "'recordVerifyKey' is not set")
+
+	// signatures made with another key are rejected
+	pathConf.RecordSignKey = filepath.Join(dir, "sign.key")
+	writeTestSignKey(t, filepath.Join(dir, "sign.key"))
+
+	require.Equal(t, []SegmentVerifyStatus{
+		SegmentVerifyStatusInvalidSignature,
+		SegmentVerifyStatusInvalidSignature,
+		SegmentVerifyStatusInvalidSignature,
+	}, statuses(time.Time{}, time.Time{}))
+}
@@ -0,0 +1,312 @@
+package recordstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
+)
+
+// ThinResult describes a segment that has been thinned.
+type ThinResult struct {
+	OriginalSize int64
+	Size         int64
+}
+
+type thinTrack struct {
+	id int
+
+	// keyframe that is waiting for the next one, in order to compute its duration.
+	pending    *fmp4.PartSample
+	pendingDTS uint64
+
+	endDTS uint64
+}
+
+type thinWriter struct {
+	w                  io.Writer
+	tracks             map[int]*thinTrack
+	nextSequenceNumber uint32
+	buf                seekablebuffer.Buffer
+}
+
+func (w *thinWriter) writeInit(init *fmp4.Init) error {
+	err := init.Marshal(&w.buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// writePending writes the pending keyframe of a track, that lasts until dts.
+func (w *thinWriter) writePending(track *thinTrack, dts uint64) error {
+	if track.pending == nil {
+		return nil
+	}
+
+	track.pending.Duration = uint32(dts - track.pendingDTS)
+
+	part := fmp4.Part{
+		SequenceNumber: w.nextSequenceNumber,
+		Tracks: []*fmp4.PartTrack{{
+			ID:       track.id,
+			BaseTime: track.pendingDTS,
+			Samples:  []*fmp4.PartSample{track.pending},
+		}},
+	}
+	w.nextSequenceNumber++
+	track.pending = nil
+
+	err := part.Marshal(&w.buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *thinWriter) processPart(part *fmp4.Part) error {
+	for _, partTrack := range part.Tracks {
+		track, ok := w.tracks[partTrack.ID]
+		if !ok {
+			continue
+		}
+
+		dts := partTrack.BaseTime
+
+		for _, sample := range partTrack.Samples {
+			if !sample.IsNonSyncSample {
+				err := w.writePending(track, dts)
+				if err != nil {
+					return err
+				}
+
+				track.pending = sample
+				track.pendingDTS = dts
+			}
+
+			dts += uint64(sample.Duration)
+		}
+
+		track.endDTS = dts
+	}
+
+	return nil
+}
+
+// readBoxHeader reads type and size of the box at given position.
+func readBoxHeader(r io.ReaderAt, pos int64) (string, int64, error) {
+	buf := make([]byte, 16)
+
+	_, err := r.ReadAt(buf[:8], pos)
+	if err != nil {
+		return "", 0, err
+	}
+
+	boxSize := int64(binary.BigEndian.Uint32(buf[:4]))
+	boxType := string(buf[4:8])
+
+	if boxSize == 1 {
+		_, err = r.ReadAt(buf[8:16], pos+8)
+		if err != nil {
+			return "", 0, err
+		}
+		boxSize = int64(binary.BigEndian.Uint64(buf[8:16]))
+	}
+
+	if boxSize < 8 {
+		return "", 0, fmt.Errorf("invalid box size")
+	}
+
+	return boxType, boxSize, nil
+}
+
+// ThinFMP4 rewrites a fMP4 segment in order to keep only keyframes of video tracks.
+// Each keyframe lasts until the next one, therefore the segment keeps its duration
+// and can still be played back, at a reduced temporal resolution.
+// Audio tracks and emsg boxes, that contain markers, are removed; markers are still available in
+// the segment metadata. The rewritten segment is encrypted with key, if not nil.
+// It returns nil if the segment doesn't contain video tracks.
+func ThinFMP4(seg *Segment, key *EncryptionKey) (*ThinResult, error) {
+	if seg.InBucket() {
+		return nil, fmt.Errorf("segments stored in a bucket can't be thinned")
+	}
+
+	r, err := seg.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(seg.Fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var init fmp4.Init
+	var initEnd int64
+	var pos int64
+
+	for pos < size {
+		var boxType string
+		var boxSize int64
+		boxType, boxSize, err = readBoxHeader(r, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		pos += boxSize
+
+		if boxType == "moov" {
+			initEnd = pos
+			break
+		}
+	}
+
+	if initEnd == 0 {
+		return nil, fmt.Errorf("initialization section not found")
+	}
+
+	err = init.Unmarshal(io.NewSectionReader(r, 0, initEnd))
+	if err != nil {
+		return nil, err
+	}
+
+	videoInit := &fmp4.Init{}
+	tracks := make(map[int]*thinTrack)
+
+	for _, track := range init.Tracks {
+		if track.Codec.IsVideo() {
+			videoInit.Tracks = append(videoInit.Tracks, track)
+			tracks[track.ID] = &thinTrack{id: track.ID}
+		}
+	}
+
+	if len(videoInit.Tracks) == 0 {
+		return nil, nil
+	}
+
+	tmpPath := seg.Fpath + ".tmp"
+
+	out, err := CreateSegment(tmpPath, key)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := &thinWriter{
+		w:      out,
+		tracks: tracks,
+	}
+
+	err = func() error {
+		err2 := tw.writeInit(videoInit)
+		if err2 != nil {
+			return err2
+		}
+
+		moofPos := int64(-1)
+
+		for pos < size {
+			boxType, boxSize, err2 := readBoxHeader(r, pos)
+			if err2 != nil {
+				return err2
+			}
+
+			// incomplete parts are discarded
+			if (size - pos) < boxSize {
+				break
+			}
+
+			switch boxType {
+			case "moof":
+				moofPos = pos
+
+			case "mdat":
+				if moofPos >= 0 {
+					byts := make([]byte, pos+boxSize-moofPos)
+					_, err2 = r.ReadAt(byts, moofPos)
+					if err2 != nil {
+						return err2
+					}
+
+					var parts fmp4.Parts
+					err2 = parts.Unmarshal(byts)
+					if err2 != nil {
+						return err2
+					}
+
+					for _, part := range parts {
+						err2 = tw.processPart(part)
+						if err2 != nil {
+							return err2
+						}
+					}
+
+					moofPos = -1
+				}
+			}
+
+			pos += boxSize
+		}
+
+		for _, initTrack := range videoInit.Tracks {
+			track := tracks[initTrack.ID]
+			err2 = tw.writePending(track, track.endDTS)
+			if err2 != nil {
+				return err2
+			}
+		}
+
+		return nil
+	}()
+
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+		return nil, err
+	}
+
+	newFi, err := os.Stat(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+		return nil, err
+	}
+
+	err = os.Rename(tmpPath, seg.Fpath)
+	if err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+		return nil, err
+	}
+
+	if seg.index != nil {
+		seg.index.setSize(seg.Fpath, newFi.Size()) //nolint:errcheck
+	}
+
+	err = UpdateSegmentMetadata(seg.Fpath, func(md *SegmentMetadata) {
+		md.Thinned = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThinResult{
+		OriginalSize: fi.Size(),
+		Size:         newFi.Size(),
+	}, nil
+}
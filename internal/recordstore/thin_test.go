@@ -0,0 +1,138 @@
+package recordstore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThinFMP4(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "2015-05-19_22-15-25-000427.mp4")
+
+	var buf seekablebuffer.Buffer
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{
+			{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &fmp4.CodecH264{
+					SPS: []byte{
+						0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+						0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+						0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+					},
+					PPS: []byte{0x08},
+				},
+			},
+			{
+				ID:        2,
+				TimeScale: 48000,
+				Codec: &fmp4.CodecMPEG4Audio{
+					Config: mpeg4audio.Config{
+						Type:         mpeg4audio.ObjectTypeAACLC,
+						SampleRate:   48000,
+						ChannelCount: 2,
+					},
+				},
+			},
+		},
+	}
+	err = init.Marshal(&buf)
+	require.NoError(t, err)
+
+	// 2 parts of 3 seconds, with a keyframe every 2 seconds
+	for i := 0; i < 2; i++ {
+		var samples []*fmp4.PartSample
+		for j := 0; j < 3; j++ {
+			n := i*3 + j
+			samples = append(samples, &fmp4.PartSample{
+				Duration:        90000,
+				IsNonSyncSample: (n % 2) != 0,
+				Payload:         []byte{byte(n)},
+			})
+		}
+
+		part := fmp4.Part{
+			SequenceNumber: uint32(i),
+			Tracks: []*fmp4.PartTrack{
+				{
+					ID:       1,
+					BaseTime: uint64(i) * 3 * 90000,
+					Samples:  samples,
+				},
+				{
+					ID:       2,
+					BaseTime: uint64(i) * 3 * 48000,
+					Samples: []*fmp4.PartSample{{
+						Duration: 3 * 48000,
+						Payload:  []byte{1, 2, 3, 4},
+					}},
+				},
+			},
+		}
+
+		var partBuf seekablebuffer.Buffer
+		err = part.Marshal(&partBuf)
+		require.NoError(t, err)
+
+		_, err = buf.Write(partBuf.Bytes())
+		require.NoError(t, err)
+	}
+
+	err = os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	seg := &Segment{
+		Fpath: fpath,
+		Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+	}
+
+	res, err := ThinFMP4(seg, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(buf.Bytes())), res.OriginalSize)
+	require.Less(t, res.Size, res.OriginalSize)
+
+	byts, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	var thinInit fmp4.Init
+	err = thinInit.Unmarshal(bytes.NewReader(byts))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(thinInit.Tracks))
+	require.Equal(t, 1, thinInit.Tracks[0].ID)
+
+	var parts fmp4.Parts
+	err = parts.Unmarshal(byts)
+	require.NoError(t, err)
+
+	var baseTimes []uint64
+	var samples []*fmp4.PartSample
+	for _, part := range parts {
+		require.Equal(t, 1, len(part.Tracks))
+		baseTimes = append(baseTimes, part.Tracks[0].BaseTime)
+		samples = append(samples, part.Tracks[0].Samples...)
+	}
+
+	require.Equal(t, []uint64{0, 2 * 90000, 4 * 90000}, baseTimes)
+	require.Equal(t, []*fmp4.PartSample{
+		{Duration: 2 * 90000, Payload: []byte{0}},
+		{Duration: 2 * 90000, Payload: []byte{2}},
+		{Duration: 2 * 90000, Payload: []byte{4}},
+	}, samples)
+
+	md, err := seg.ReadMetadata()
+	require.NoError(t, err)
+	require.True(t, md.Thinned)
+}
@@ -60,6 +60,7 @@ type muxer struct {
 	pathManager     serverPathManager
 	parent          *Server
 	query           string
+	trackFilter     conf.TrackFilter
 
 	ctx             context.Context
 	ctxCancel       func()
@@ -67,6 +68,8 @@ type muxer struct {
 	path            defs.Path
 	lastRequestTime *int64
 	bytesSent       *uint64
+	instanceMutex   sync.RWMutex
+	instance        *muxerInstance
 
 	// in
 	chGetInstance chan muxerGetInstanceReq
@@ -107,6 +110,10 @@ func (m *muxer) PathName() string {
 	return m.pathName
 }
 
+func (m *muxer) key() string {
+	return muxerKey(m.pathName, m.trackFilter)
+}
+
 func (m *muxer) run() {
 	defer m.wg.Done()
 
@@ -147,6 +154,7 @@ func (m *muxer) runInner() error {
 		segmentMaxSize:  m.segmentMaxSize,
 		directory:       m.directory,
 		pathName:        m.pathName,
+		trackFilter:     m.trackFilter,
 		stream:          stream,
 		bytesSent:       m.bytesSent,
 		parent:          m,
@@ -162,11 +170,13 @@ func (m *muxer) runInner() error {
 		instanceError = make(chan error)
 		recreateTimer = time.NewTimer(recreatePause)
 	} else {
+		m.setInstance(mi)
 		instanceError = mi.errorChan()
 		recreateTimer = emptyTimer()
 	}
 
 	defer func() {
+		m.setInstance(nil)
 		if mi != nil {
 			mi.close()
 		}
@@ -190,6 +200,7 @@ func (m *muxer) runInner() error {
 			}
 
 			m.Log(logger.Error, err.Error())
+			m.setInstance(nil)
 			mi.close()
 			mi = nil
 			instanceError = make(chan error)
@@ -204,6 +215,7 @@ func (m *muxer) runInner() error {
 				segmentMaxSize:  m.segmentMaxSize,
 				directory:       m.directory,
 				pathName:        m.pathName,
+				trackFilter:     m.trackFilter,
 				stream:          stream,
 				bytesSent:       m.bytesSent,
 				parent:          m,
@@ -214,6 +226,7 @@ func (m *muxer) runInner() error {
 				mi = nil
 				recreateTimer = time.NewTimer(recreatePause)
 			} else {
+				m.setInstance(mi)
 				instanceError = mi.errorChan()
 			}
 
@@ -230,6 +243,13 @@ func (m *muxer) runInner() error {
 	}
 }
 
+// setInstance stores the instance, in order to read its statistics from other goroutines.
+func (m *muxer) setInstance(mi *muxerInstance) {
+	m.instanceMutex.Lock()
+	defer m.instanceMutex.Unlock()
+	m.instance = mi
+}
+
 func (m *muxer) getInstance() *muxerInstance {
 	atomic.StoreInt64(m.lastRequestTime, time.Now().UnixNano())
 
@@ -258,5 +278,14 @@ func (m *muxer) apiItem() *defs.APIHLSMuxer {
 		Created:     m.created,
 		LastRequest: time.Unix(0, atomic.LoadInt64(m.lastRequestTime)),
 		BytesSent:   atomic.LoadUint64(m.bytesSent),
+		Latency: func() *defs.APILatency {
+			m.instanceMutex.RLock()
+			defer m.instanceMutex.RUnlock()
+
+			if m.instance == nil {
+				return nil
+			}
+			return defs.NewAPILatency(m.instance.stream.ReaderLatency(m.instance), nil)
+		}(),
 	}
 }
@@ -1,8 +1,11 @@
 package hls
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/bluenviron/gohlslib/v2"
@@ -22,6 +25,7 @@ type muxerInstance struct {
 	segmentMaxSize  conf.StringSize
 	directory       string
 	pathName        string
+	trackFilter     conf.TrackFilter
 	stream          *stream.Stream
 	bytesSent       *uint64
 	parent          logger.Writer
@@ -33,6 +37,10 @@ func (mi *muxerInstance) initialize() error {
 	var muxerDirectory string
 	if mi.directory != "" {
 		muxerDirectory = filepath.Join(mi.directory, mi.pathName)
+		if len(mi.trackFilter) != 0 {
+			muxerDirectory = filepath.Join(muxerDirectory,
+				"tracks="+url.PathEscape(strings.Join(mi.trackFilter, ",")))
+		}
 		os.MkdirAll(muxerDirectory, 0o755)
 	}
 
@@ -48,7 +56,12 @@ func (mi *muxerInstance) initialize() error {
 		},
 	}
 
-	err := hls.FromStream(mi.stream, mi, mi.hmuxer)
+	desc := mi.trackFilter.Filter(mi.stream.Desc())
+	if len(desc.Medias) == 0 {
+		return fmt.Errorf("no tracks match the requested filter")
+	}
+
+	err := hls.FromStream(mi.stream, desc, mi, mi.hmuxer)
 	if err != nil {
 		return err
 	}
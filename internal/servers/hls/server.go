@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
@@ -49,6 +50,15 @@ type serverAPIMuxersGetReq struct {
 	res  chan serverAPIMuxersGetRes
 }
 
+// muxerKey returns the key of a muxer.
+// Muxers that read a subset of tracks are stored separately from the main one.
+func muxerKey(pathName string, trackFilter conf.TrackFilter) string {
+	if len(trackFilter) == 0 {
+		return pathName
+	}
+	return pathName + "?tracks=" + strings.Join(trackFilter, ",")
+}
+
 type serverPathManager interface {
 	FindPathConf(req defs.PathFindPathConfReq) (*conf.Path, error)
 	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
@@ -153,7 +163,7 @@ outer:
 		case pa := <-s.chPathReady:
 			if s.AlwaysRemux && !pa.SafeConf().SourceOnDemand {
 				if _, ok := s.muxers[pa.Name()]; !ok {
-					s.createMuxer(pa.Name(), "", "")
+					s.createMuxer(pa.Name(), "", "", nil)
 				}
 			}
 
@@ -165,19 +175,25 @@ outer:
 			}
 
 		case req := <-s.chGetMuxer:
-			mux, ok := s.muxers[req.path]
+			trackFilter, err := defs.ReaderTrackFilter(req.query)
+			if err != nil {
+				req.res <- serverGetMuxerRes{err: err}
+				continue
+			}
+
+			mux, ok := s.muxers[muxerKey(req.path, trackFilter)]
 			switch {
 			case ok:
 				req.res <- serverGetMuxerRes{muxer: mux}
-			case s.AlwaysRemux && !req.sourceOnDemand:
+			case s.AlwaysRemux && !req.sourceOnDemand && len(trackFilter) == 0:
 				req.res <- serverGetMuxerRes{err: fmt.Errorf("muxer is waiting to be created")}
 			default:
-				req.res <- serverGetMuxerRes{muxer: s.createMuxer(req.path, req.remoteAddr, req.query)}
+				req.res <- serverGetMuxerRes{muxer: s.createMuxer(req.path, req.remoteAddr, req.query, trackFilter)}
 			}
 
 		case c := <-s.chCloseMuxer:
-			if c2, ok := s.muxers[c.PathName()]; ok && c2 == c {
-				delete(s.muxers, c.PathName())
+			if c2, ok := s.muxers[c.key()]; ok && c2 == c {
+				delete(s.muxers, c.key())
 			}
 
 		case req := <-s.chAPIMuxerList:
@@ -216,7 +232,12 @@ outer:
 	s.httpServer.close()
 }
 
-func (s *Server) createMuxer(pathName string, remoteAddr string, query string) *muxer {
+func (s *Server) createMuxer(
+	pathName string,
+	remoteAddr string,
+	query string,
+	trackFilter conf.TrackFilter,
+) *muxer {
 	r := &muxer{
 		parentCtx:       s.ctx,
 		remoteAddr:      remoteAddr,
@@ -231,10 +252,11 @@ func (s *Server) createMuxer(pathName string, remoteAddr string, query string) *
 		pathManager:     s.PathManager,
 		parent:          s,
 		query:           query,
+		trackFilter:     trackFilter,
 		closeAfter:      s.MuxerCloseAfter,
 	}
 	r.initialize()
-	s.muxers[pathName] = r
+	s.muxers[r.key()] = r
 	return r
 }
 
@@ -62,6 +62,7 @@ type conn struct {
 	state     connState
 	pathName  string
 	query     string
+	stream    *stream.Stream
 }
 
 func (c *conn) initialize() {
@@ -183,9 +184,15 @@ func (c *conn) runRead(conn *rtmp.Conn, u *url.URL) error {
 	c.state = connStateRead
 	c.pathName = pathName
 	c.query = rawQuery
+	c.stream = stream
 	c.mutex.Unlock()
 
-	err = rtmp.FromStream(stream, c, conn, c.nconn, time.Duration(c.writeTimeout))
+	desc, err := defs.ReaderDesc(stream.Desc(), rawQuery)
+	if err != nil {
+		return err
+	}
+
+	err = rtmp.FromStream(stream, desc, c, conn, c.nconn, time.Duration(c.writeTimeout))
 	if err != nil {
 		return err
 	}
@@ -335,5 +342,11 @@ func (c *conn) apiItem() *defs.APIRTMPConn {
 		Query:         c.query,
 		BytesReceived: bytesReceived,
 		BytesSent:     bytesSent,
+		Latency: func() *defs.APILatency {
+			if c.state != connStateRead {
+				return nil
+			}
+			return defs.NewAPILatency(c.stream.ReaderLatency(c), nil)
+		}(),
 	}
 }
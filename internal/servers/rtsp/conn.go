@@ -166,11 +166,18 @@ func (c *conn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 		}, nil, nil
 	}
 
+	desc, err := defs.ReaderDesc(res.Stream.Desc(), ctx.Query)
+	if err != nil {
+		return &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, nil, err
+	}
+
 	var stream *gortsplib.ServerStream
 	if !c.isTLS {
-		stream = res.Stream.RTSPStream(c.rserver)
+		stream = res.Stream.RTSPStream(c.rserver, desc)
 	} else {
-		stream = res.Stream.RTSPSStream(c.rserver)
+		stream = res.Stream.RTSPSStream(c.rserver, desc)
 	}
 
 	return &base.Response{
@@ -11,7 +11,9 @@ import (
 	"github.com/bluenviron/gortsplib/v4"
 	rtspauth "github.com/bluenviron/gortsplib/v4/pkg/auth"
 	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
 	"github.com/google/uuid"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 
 	"github.com/bluenviron/mediamtx/internal/auth"
@@ -23,6 +25,13 @@ import (
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
+// ntpCompact returns the middle 32 bits of the NTP timestamp of t, as used in RTCP receiver reports.
+func ntpCompact(t time.Time) uint32 {
+	s := uint64(t.Unix()) + 2208988800
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return uint32((s<<32 | frac) >> 16)
+}
+
 type session struct {
 	isTLS           bool
 	protocols       map[conf.Protocol]struct{}
@@ -43,6 +52,8 @@ type session struct {
 	transport       *gortsplib.Transport
 	pathName        string
 	query           string
+	rttEstimate     time.Duration
+	rttEstimateSet  bool
 	decodeErrLogger logger.Writer
 	writeErrLogger  logger.Writer
 }
@@ -87,7 +98,10 @@ func (s *session) onClose(err error) {
 	}
 
 	s.path = nil
+
+	s.mutex.Lock()
 	s.stream = nil
+	s.mutex.Unlock()
 
 	s.Log(logger.Info, "destroyed: %v", err)
 }
@@ -213,10 +227,18 @@ func (s *session) onSetup(c *conn, ctx *gortsplib.ServerHandlerOnSetupCtx,
 			}, nil, err
 		}
 
+		desc, err := defs.ReaderDesc(stream.Desc(), ctx.Query)
+		if err != nil {
+			path.RemoveReader(defs.PathRemoveReaderReq{Author: s})
+			return &base.Response{
+				StatusCode: base.StatusBadRequest,
+			}, nil, err
+		}
+
 		s.path = path
-		s.stream = stream
 
 		s.mutex.Lock()
+		s.stream = stream
 		s.state = gortsplib.ServerSessionStatePrePlay
 		s.pathName = ctx.Path
 		s.query = ctx.Query
@@ -224,9 +246,9 @@ func (s *session) onSetup(c *conn, ctx *gortsplib.ServerHandlerOnSetupCtx,
 
 		var rstream *gortsplib.ServerStream
 		if !s.isTLS {
-			rstream = stream.RTSPStream(s.rserver)
+			rstream = stream.RTSPStream(s.rserver, desc)
 		} else {
-			rstream = stream.RTSPSStream(s.rserver)
+			rstream = stream.RTSPSStream(s.rserver, desc)
 		}
 
 		return &base.Response{
@@ -259,6 +281,12 @@ func (s *session) onPlay(_ *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, e
 			Query:           s.rsession.SetuppedQuery(),
 		})
 
+		s.rsession.OnPacketRTCPAny(func(_ *description.Media, pkt rtcp.Packet) {
+			if rr, ok := pkt.(*rtcp.ReceiverReport); ok {
+				s.onReceiverReport(rr)
+			}
+		})
+
 		s.mutex.Lock()
 		s.state = gortsplib.ServerSessionStatePlay
 		s.transport = s.rsession.SetuppedTransport()
@@ -368,6 +396,39 @@ func (s *session) onStreamWriteError(ctx *gortsplib.ServerHandlerOnStreamWriteEr
 	s.writeErrLogger.Log(logger.Warn, ctx.Error.Error())
 }
 
+// onReceiverReport estimates the round trip time from a RTCP receiver report.
+// The estimate is not accurate, since the time at which sender reports are sent is unknown
+// and is approximated with their NTP timestamp plus the average latency of the stream.
+func (s *session) onReceiverReport(rr *rtcp.ReceiverReport) {
+	for _, report := range rr.Reports {
+		if report.LastSenderReport == 0 {
+			continue
+		}
+
+		// time elapsed between the NTP timestamp of the sender report and the reception of the receiver report,
+		// in units of 1/65536 seconds.
+		v := ntpCompact(time.Now()) - report.LastSenderReport - report.Delay
+		if v >= (1 << 31) {
+			continue
+		}
+		elapsed := time.Duration(v) * time.Second / 65536
+
+		s.mutex.Lock()
+
+		// NTP timestamps of sender reports are the ones of the stream, therefore
+		// they are delayed by the latency of the stream, that must be subtracted.
+		if s.stream != nil {
+			if l := s.stream.Latency(); l != nil {
+				elapsed -= l.Average
+			}
+		}
+
+		s.rttEstimate = max(elapsed, 0)
+		s.rttEstimateSet = true
+		s.mutex.Unlock()
+	}
+}
+
 func (s *session) apiItem() *defs.APIRTSPSession {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -399,5 +460,20 @@ func (s *session) apiItem() *defs.APIRTSPSession {
 		}(),
 		BytesReceived: s.rsession.BytesReceived(),
 		BytesSent:     s.rsession.BytesSent(),
+		Latency: func() *defs.APILatency {
+			if s.state != gortsplib.ServerSessionStatePlay || s.stream == nil {
+				return nil
+			}
+			var rtt *time.Duration
+			if s.rttEstimateSet {
+				v := s.rttEstimate
+				rtt = &v
+			}
+			ret := defs.NewAPILatency(s.stream.Latency(), rtt)
+			if ret != nil && rtt != nil {
+				ret.RoundTripTimeEstimated = true
+			}
+			return ret
+		}(),
 	}
 }
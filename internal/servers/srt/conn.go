@@ -72,6 +72,7 @@ type conn struct {
 	pathName  string
 	query     string
 	sconn     srt.Conn
+	stream    *stream.Stream
 }
 
 func (c *conn) initialize() {
@@ -269,6 +270,12 @@ func (c *conn) runRead(streamID *streamID) error {
 		return err
 	}
 
+	desc, err := defs.ReaderDesc(stream.Desc(), streamID.query)
+	if err != nil {
+		c.connReq.Reject(srt.REJ_PEER)
+		return err
+	}
+
 	sconn, err := c.connReq.Accept()
 	if err != nil {
 		return err
@@ -280,11 +287,12 @@ func (c *conn) runRead(streamID *streamID) error {
 	c.pathName = streamID.path
 	c.query = streamID.query
 	c.sconn = sconn
+	c.stream = stream
 	c.mutex.Unlock()
 
 	bw := bufio.NewWriterSize(sconn, srtMaxPayloadSize(c.udpMaxPayloadSize))
 
-	err = mpegts.FromStream(stream, c, bw, sconn, time.Duration(c.writeTimeout))
+	err = mpegts.FromStream(stream, desc, c, bw, sconn, time.Duration(c.writeTimeout))
 	if err != nil {
 		return err
 	}
@@ -411,6 +419,16 @@ func (c *conn) apiItem() *defs.APISRTConn {
 		item.PacketsReceivedAvgBelatedTime = s.Instantaneous.PktRecvAvgBelatedTime
 		item.PacketsSendLossRate = s.Instantaneous.PktSendLossRate
 		item.PacketsReceivedLossRate = s.Instantaneous.PktRecvLossRate
+
+		if c.state == connStateRead {
+			rtt := time.Duration(s.Instantaneous.MsRTT * float64(time.Millisecond))
+			item.Latency = defs.NewAPILatency(c.stream.ReaderLatency(c), &rtt)
+
+			// readers of SRT streams are delayed by the latency buffer too.
+			if item.Latency != nil {
+				*item.Latency.GlassToGlass += float64(s.Instantaneous.MsSendTsbPdDelay) / 1000
+			}
+		}
 	}
 
 	return item
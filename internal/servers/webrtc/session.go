@@ -52,6 +52,7 @@ type session struct {
 	secret    uuid.UUID
 	mutex     sync.RWMutex
 	pc        *webrtc.PeerConnection
+	stream    *stream.Stream
 
 	chNew           chan webRTCNewSessionReq
 	chAddCandidates chan webRTCAddSessionCandidatesReq
@@ -258,6 +259,11 @@ func (s *session) runRead() (int, error) {
 
 	defer path.RemoveReader(defs.PathRemoveReaderReq{Author: s})
 
+	desc, err := defs.ReaderDesc(stream.Desc(), s.req.httpRequest.URL.RawQuery)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
 	iceServers, err := s.parent.generateICEServers(false)
 	if err != nil {
 		return http.StatusInternalServerError, err
@@ -276,7 +282,7 @@ func (s *session) runRead() (int, error) {
 		Log:                   s,
 	}
 
-	err = webrtc.FromStream(stream, s, pc)
+	err = webrtc.FromStream(stream, desc, s, pc)
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
@@ -308,6 +314,7 @@ func (s *session) runRead() (int, error) {
 
 	s.mutex.Lock()
 	s.pc = pc
+	s.stream = stream
 	s.mutex.Unlock()
 
 	s.Log(logger.Info, "is reading from path '%s', %s",
@@ -409,6 +416,7 @@ func (s *session) apiItem() *defs.APIWebRTCSession {
 	remoteCandidate := ""
 	bytesReceived := uint64(0)
 	bytesSent := uint64(0)
+	var latency *defs.APILatency
 
 	if s.pc != nil {
 		peerConnectionEstablished = true
@@ -416,6 +424,14 @@ func (s *session) apiItem() *defs.APIWebRTCSession {
 		remoteCandidate = s.pc.RemoteCandidate()
 		bytesReceived = s.pc.BytesReceived()
 		bytesSent = s.pc.BytesSent()
+
+		if s.stream != nil {
+			var rtt *time.Duration
+			if v, ok := s.pc.RoundTripTime(); ok {
+				rtt = &v
+			}
+			latency = defs.NewAPILatency(s.stream.ReaderLatency(s), rtt)
+		}
 	}
 
 	return &defs.APIWebRTCSession{
@@ -435,5 +451,6 @@ func (s *session) apiItem() *defs.APIWebRTCSession {
 		Query:         s.req.httpRequest.URL.RawQuery,
 		BytesReceived: bytesReceived,
 		BytesSent:     bytesSent,
+		Latency:       latency,
 	}
 }
@@ -0,0 +1,228 @@
+// Package path contains the path static source.
+package path
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type input struct {
+	pathName string
+	query    string
+
+	path   defs.Path
+	stream *stream.Stream
+	desc   *description.Session
+
+	// offset between the timeline of the input and the one of the output
+	offset    time.Duration
+	offsetSet bool
+}
+
+func parseInputs(source string) ([]*input, error) {
+	var inputs []*input //nolint:prealloc
+
+	for _, part := range strings.Split(strings.TrimPrefix(source, "path://"), "|") {
+		name, query, _ := strings.Cut(part, "?")
+		if name == "" {
+			return nil, fmt.Errorf("invalid source: '%s'", source)
+		}
+
+		inputs = append(inputs, &input{
+			pathName: name,
+			query:    query,
+		})
+	}
+
+	return inputs, nil
+}
+
+// PathManager is the path manager used to read from other paths.
+type PathManager interface {
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
+// reader is the entity that reads from other paths on behalf of the source.
+type reader struct {
+	parent    *Source
+	ctxCancel func()
+}
+
+// Close implements defs.Reader.
+func (r *reader) Close() {
+	r.ctxCancel()
+}
+
+// APIReaderDescribe implements defs.Reader.
+func (r *reader) APIReaderDescribe() defs.APIPathSourceOrReader {
+	return r.parent.APISourceDescribe()
+}
+
+// Log implements logger.Writer.
+func (r *reader) Log(level logger.Level, format string, args ...interface{}) {
+	r.parent.Log(level, format, args...)
+}
+
+// Source is a path static source.
+// It reads tracks from one or more paths of the server and merges them into a single stream.
+type Source struct {
+	PathManager PathManager
+	Parent      defs.StaticSourceParent
+}
+
+// Log implements logger.Writer.
+func (s *Source) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[path source] "+format, args...)
+}
+
+// Run implements StaticSource.
+func (s *Source) Run(params defs.StaticSourceRunParams) error {
+	s.Log(logger.Debug, "connecting")
+
+	inputs, err := parseInputs(params.ResolvedSource)
+	if err != nil {
+		return err
+	}
+
+	ctx, ctxCancel := context.WithCancel(params.Context)
+	defer ctxCancel()
+
+	r := &reader{
+		parent:    s,
+		ctxCancel: ctxCancel,
+	}
+
+	defer func() {
+		for _, in := range inputs {
+			if in.path != nil {
+				in.path.RemoveReader(defs.PathRemoveReaderReq{Author: r})
+			}
+		}
+	}()
+
+	var medias []*description.Media
+	usedMedias := make(map[*description.Media]struct{})
+
+	for _, in := range inputs {
+		in.path, in.stream, err = s.PathManager.AddReader(defs.PathAddReaderReq{
+			Author: r,
+			AccessRequest: defs.PathAccessRequest{
+				Name:     in.pathName,
+				Query:    in.query,
+				SkipAuth: true,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		in.desc, err = defs.ReaderDesc(in.stream.Desc(), in.query)
+		if err != nil {
+			return fmt.Errorf("path '%s': %w", in.pathName, err)
+		}
+
+		for _, medi := range in.desc.Medias {
+			if _, ok := usedMedias[medi]; ok {
+				return fmt.Errorf("a track of path '%s' is used more than once", in.pathName)
+			}
+			usedMedias[medi] = struct{}{}
+			medias = append(medias, medi)
+		}
+	}
+
+	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
+		Desc:               &description.Session{Medias: medias},
+		GenerateRTPPackets: true,
+	})
+	if res.Err != nil {
+		return res.Err
+	}
+
+	defer s.Parent.SetNotReady(defs.PathSourceStaticSetNotReadyReq{})
+
+	// all inputs are aligned to a common timeline, that is computed from NTP timestamps.
+	var startNTP time.Time
+	var startNTPMutex sync.Mutex
+
+	for _, in := range inputs {
+		cin := in
+
+		for _, medi := range cin.desc.Medias {
+			for _, forma := range medi.Formats {
+				cmedi := medi
+				cforma := forma
+
+				cin.stream.AddReader(r, cmedi, cforma, func(u unit.Unit) error {
+					if !cin.offsetSet {
+						startNTPMutex.Lock()
+						if startNTP.IsZero() {
+							startNTP = u.GetNTP()
+						}
+						cin.offset = u.GetNTP().Sub(startNTP) - unit.TimestampToDuration(u.GetPTS(), cforma.ClockRate())
+						startNTPMutex.Unlock()
+						cin.offsetSet = true
+					}
+
+					pts := u.GetPTS() + unit.DurationToTimestamp(cin.offset, cforma.ClockRate())
+
+					// units are shared with other readers of the input.
+					cu := u.Clone()
+					cu.SetPTS(pts)
+					res.Stream.WriteUnit(cmedi, cforma, cu)
+					return nil
+				})
+			}
+		}
+	}
+
+	readerErr := make(chan error, len(inputs))
+
+	for _, in := range inputs {
+		in.stream.StartReader(r)
+		defer in.stream.RemoveReader(r)
+
+		s.Log(logger.Info, "reading from path '%s', %s",
+			in.pathName, defs.FormatsInfo(in.stream.ReaderFormats(r)))
+
+		go func(pathName string, errChan chan error) {
+			select {
+			case err := <-errChan:
+				readerErr <- fmt.Errorf("path '%s': %w", pathName, err)
+			case <-ctx.Done():
+			}
+		}(in.pathName, in.stream.ReaderError(r))
+	}
+
+	for {
+		select {
+		case err := <-readerErr:
+			return err
+
+		case <-params.ReloadConf:
+
+		case <-ctx.Done():
+			if params.Context.Err() == nil {
+				return fmt.Errorf("source path is not available anymore")
+			}
+			return fmt.Errorf("terminated")
+		}
+	}
+}
+
+// APISourceDescribe implements StaticSource.
+func (*Source) APISourceDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "pathSource",
+		ID:   "",
+	}
+}
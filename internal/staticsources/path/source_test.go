@@ -0,0 +1,95 @@
+package path
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type dummyPath struct {
+	defs.Path
+}
+
+func (dummyPath) RemoveReader(_ defs.PathRemoveReaderReq) {
+}
+
+type dummyPathManager struct {
+	streams map[string]*stream.Stream
+}
+
+func (pm *dummyPathManager) AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+	return &dummyPath{}, pm.streams[req.AccessRequest.Name], nil
+}
+
+func TestSource(t *testing.T) {
+	videoStream, err := stream.New(
+		512,
+		1460,
+		&description.Session{Medias: []*description.Media{
+			test.UniqueMediaH264(),
+			test.UniqueMediaMPEG4Audio(),
+		}},
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer videoStream.Close()
+
+	audioStream, err := stream.New(
+		512,
+		1460,
+		&description.Session{Medias: []*description.Media{test.UniqueMediaMPEG4Audio()}},
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer audioStream.Close()
+
+	pm := &dummyPathManager{
+		streams: map[string]*stream.Stream{
+			"cam1": videoStream,
+			"mic1": audioStream,
+		},
+	}
+
+	te := test.NewSourceTester(
+		func(p defs.StaticSourceParent) defs.StaticSource {
+			return &Source{
+				PathManager: pm,
+				Parent:      p,
+			}
+		},
+		"path://cam1?tracks=video|mic1",
+		&conf.Path{},
+	)
+	defer te.Close()
+
+	videoStream.WaitRunningReader()
+	audioStream.WaitRunningReader()
+
+	videoStream.WriteUnit(videoStream.Desc().Medias[0], videoStream.Desc().Medias[0].Formats[0], &unit.H264{
+		Base: unit.Base{
+			NTP: time.Now(),
+			PTS: 90000,
+		},
+		AU: [][]byte{
+			{5, 1}, // IDR
+		},
+	})
+
+	u := <-te.Unit
+	require.Equal(t, [][]byte{
+		test.FormatH264.SPS,
+		test.FormatH264.PPS,
+		{5, 1},
+	}, u.(*unit.H264).AU)
+	require.Equal(t, int64(0), u.GetPTS())
+}
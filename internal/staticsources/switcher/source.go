@@ -0,0 +1,638 @@
+// Package switcher contains the switcher static source.
+package switcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4video"
+	"github.com/bluenviron/mediacommon/pkg/codecs/vp9"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// cloneMedia returns a copy of a media that doesn't share formats with the original one.
+func cloneMedia(medi *description.Media) (*description.Media, error) {
+	var out description.Media
+	err := out.Unmarshal(medi.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func isRandomAccess(u unit.Unit) bool {
+	switch tunit := u.(type) {
+	case *unit.H264:
+		return h264.IDRPresent(tunit.AU)
+
+	case *unit.H265:
+		return h265.IsRandomAccess(tunit.AU)
+
+	case *unit.AV1:
+		for _, obu := range tunit.TU {
+			var h av1.OBUHeader
+			err := h.Unmarshal(obu)
+			if err == nil && h.Type == av1.OBUTypeSequenceHeader {
+				return true
+			}
+		}
+		return false
+
+	case *unit.VP9:
+		var h vp9.Header
+		err := h.Unmarshal(tunit.Frame)
+		return err == nil && !h.NonKeyFrame
+
+	case *unit.VP8:
+		return len(tunit.Frame) != 0 && (tunit.Frame[0]&0x01) == 0
+
+	case *unit.MPEG4Video:
+		return bytes.Contains(tunit.Frame, []byte{0, 0, 1, byte(mpeg4video.GroupOfVOPStartCode)})
+
+	case *unit.MPEG1Video:
+		return bytes.Contains(tunit.Frame, []byte{0, 0, 1, 0xB8})
+
+	default:
+		return true
+	}
+}
+
+func isEmpty(u unit.Unit) bool {
+	switch tunit := u.(type) {
+	case *unit.H264:
+		return tunit.AU == nil
+
+	case *unit.H265:
+		return tunit.AU == nil
+
+	case *unit.AV1:
+		return tunit.TU == nil
+
+	case *unit.VP9:
+		return tunit.Frame == nil
+
+	case *unit.VP8:
+		return tunit.Frame == nil
+
+	case *unit.MPEG4Video:
+		return tunit.Frame == nil
+
+	case *unit.MPEG1Video:
+		return tunit.Frame == nil
+
+	default:
+		return false
+	}
+}
+
+// paramsCanBeCopied checks whether codec parameters of a format can be updated
+// when switching input, since they are also sent in-band.
+func paramsCanBeCopied(forma format.Format) bool {
+	switch forma.(type) {
+	case *format.H264, *format.H265, *format.MPEG4Video:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// formatsAreCompatible checks whether units of an input format can be routed to an output format.
+// Parameters of most codecs cannot change during a stream, therefore they must be equal.
+func formatsAreCompatible(dest format.Format, src format.Format) bool {
+	if dest.Codec() != src.Codec() || dest.ClockRate() != src.ClockRate() {
+		return false
+	}
+
+	if paramsCanBeCopied(dest) {
+		return true
+	}
+
+	return maps.Equal(dest.FMTP(), src.FMTP())
+}
+
+// copyParams copies codec parameters from a input format to an output format,
+// in order to keep them consistent with the content of the active input.
+func copyParams(dest format.Format, src format.Format) {
+	switch dest := dest.(type) {
+	case *format.H264:
+		sps, pps := src.(*format.H264).SafeParams()
+		if sps != nil && pps != nil {
+			dest.SafeSetParams(sps, pps)
+		}
+
+	case *format.H265:
+		vps, sps, pps := src.(*format.H265).SafeParams()
+		if vps != nil && sps != nil && pps != nil {
+			dest.SafeSetParams(vps, sps, pps)
+		}
+
+	case *format.MPEG4Video:
+		config := src.(*format.MPEG4Video).SafeParams()
+		if config != nil {
+			dest.SafeSetParams(config)
+		}
+	}
+}
+
+func parseInputs(source string) ([]string, error) {
+	var names []string //nolint:prealloc
+
+	for _, name := range strings.Split(strings.TrimPrefix(source, "switcher://"), "|") {
+		if name == "" {
+			return nil, fmt.Errorf("invalid source: '%s'", source)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// PathManager is the path manager used to read from other paths.
+type PathManager interface {
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
+type outputTrack struct {
+	media  *description.Media
+	format format.Format
+}
+
+// input is a path that can be routed to the output.
+// It's also the entity that reads from the path.
+type input struct {
+	name     string
+	parent   *Source
+	chClosed chan *input
+	ctx      context.Context
+
+	path     defs.Path
+	stream   *stream.Stream
+	tracks   map[format.Format]outputTrack
+	hasVideo bool
+	offset   time.Duration
+	detached bool
+}
+
+// Close implements defs.Reader.
+func (in *input) Close() {
+	// Close() is called by the path of the input, that can be busy
+	// serving a request of the source. Therefore do not block.
+	go func() {
+		select {
+		case in.chClosed <- in:
+		case <-in.ctx.Done():
+		}
+	}()
+}
+
+// APIReaderDescribe implements defs.Reader.
+func (in *input) APIReaderDescribe() defs.APIPathSourceOrReader {
+	return in.parent.APISourceDescribe()
+}
+
+// Log implements logger.Writer.
+func (in *input) Log(level logger.Level, format string, args ...interface{}) {
+	in.parent.Log(level, format, args...)
+}
+
+type switchReq struct {
+	input string
+	res   chan error
+}
+
+// Source is a switcher static source.
+// It routes one among several paths to a single stream, that is kept
+// open when the routed path changes.
+type Source struct {
+	PathManager PathManager
+	Parent      defs.StaticSourceParent
+
+	mutex    sync.Mutex
+	chSwitch chan switchReq
+	done     chan struct{}
+}
+
+// Log implements logger.Writer.
+func (s *Source) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[switcher source] "+format, args...)
+}
+
+// Switch routes another input to the output.
+// The switch happens on the next key frame of the input.
+func (s *Source) Switch(name string) error {
+	s.mutex.Lock()
+	chSwitch := s.chSwitch
+	done := s.done
+	s.mutex.Unlock()
+
+	if chSwitch == nil {
+		return fmt.Errorf("source is not ready")
+	}
+
+	req := switchReq{
+		input: name,
+		res:   make(chan error),
+	}
+
+	select {
+	case chSwitch <- req:
+		return <-req.res
+
+	case <-done:
+		return fmt.Errorf("terminated")
+	}
+}
+
+// Run implements StaticSource.
+func (s *Source) Run(params defs.StaticSourceRunParams) error {
+	s.Log(logger.Debug, "connecting")
+
+	names, err := parseInputs(params.ResolvedSource)
+	if err != nil {
+		return err
+	}
+
+	ctx, ctxCancel := context.WithCancel(params.Context)
+	defer ctxCancel()
+
+	r := &runner{
+		source:   s,
+		ctx:      ctx,
+		names:    names,
+		inputs:   make(map[*input]struct{}),
+		chClosed: make(chan *input),
+		chDetach: make(chan *input),
+		lastPTS:  make(map[format.Format]int64),
+	}
+
+	return r.run(params.ReloadConf)
+}
+
+// APISourceDescribe implements StaticSource.
+func (*Source) APISourceDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "switcherSource",
+		ID:   "",
+	}
+}
+
+type runner struct {
+	source   *Source
+	ctx      context.Context
+	names    []string
+	inputs   map[*input]struct{}
+	chClosed chan *input
+	chDetach chan *input
+
+	outMedias []*description.Media
+	stream    *stream.Stream
+
+	// fields shared with reader callbacks
+	mutex    sync.Mutex
+	active   *input
+	pending  *input
+	startNTP time.Time
+	lastPTS  map[format.Format]int64
+}
+
+func (r *runner) run(reloadConf chan *conf.Path) error {
+	chSwitch := make(chan switchReq)
+	done := make(chan struct{})
+
+	r.source.mutex.Lock()
+	r.source.chSwitch = chSwitch
+	r.source.done = done
+	r.source.mutex.Unlock()
+
+	defer func() {
+		r.source.mutex.Lock()
+		r.source.chSwitch = nil
+		r.source.done = nil
+		r.source.mutex.Unlock()
+		close(done)
+	}()
+
+	// the output is modeled on the first input that is available.
+	var first *input
+	var err error
+	for _, name := range r.names {
+		first, err = r.attach(name)
+		if err == nil {
+			break
+		}
+		r.source.Log(logger.Debug, "input '%s' is not available: %v", name, err)
+	}
+	if first == nil {
+		return fmt.Errorf("none of the inputs is available")
+	}
+
+	ready := false
+
+	defer func() {
+		for in := range r.inputs {
+			r.detach(in)
+		}
+
+		if ready {
+			r.source.Parent.SetNotReady(defs.PathSourceStaticSetNotReadyReq{})
+		}
+	}()
+
+	for _, medi := range first.stream.Desc().Medias {
+		var out *description.Media
+		out, err = cloneMedia(medi)
+		if err != nil {
+			return err
+		}
+		r.outMedias = append(r.outMedias, out)
+	}
+
+	res := r.source.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
+		Desc:               &description.Session{Medias: r.outMedias},
+		GenerateRTPPackets: true,
+	})
+	if res.Err != nil {
+		return res.Err
+	}
+
+	ready = true
+	r.stream = res.Stream
+
+	r.mutex.Lock()
+	r.pending = first
+	r.mutex.Unlock()
+
+	err = r.route(first)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case req := <-chSwitch:
+			req.res <- r.doSwitch(req.input)
+
+		case in := <-r.chClosed:
+			r.mutex.Lock()
+			if in == r.active {
+				r.active = nil
+				r.source.Log(logger.Warn, "input '%s' is not available anymore", in.name)
+			}
+			if in == r.pending {
+				r.pending = nil
+			}
+			r.mutex.Unlock()
+
+			r.detach(in)
+
+		case in := <-r.chDetach:
+			r.detach(in)
+
+		case <-reloadConf:
+
+		case <-r.ctx.Done():
+			return fmt.Errorf("terminated")
+		}
+	}
+}
+
+func (r *runner) attach(name string) (*input, error) {
+	in := &input{
+		name:     name,
+		parent:   r.source,
+		chClosed: r.chClosed,
+		ctx:      r.ctx,
+		tracks:   make(map[format.Format]outputTrack),
+	}
+
+	var err error
+	in.path, in.stream, err = r.source.PathManager.AddReader(defs.PathAddReaderReq{
+		Author: in,
+		AccessRequest: defs.PathAccessRequest{
+			Name:     name,
+			SkipAuth: true,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.inputs[in] = struct{}{}
+
+	return in, nil
+}
+
+func (r *runner) detach(in *input) {
+	if in.detached {
+		return
+	}
+	in.detached = true
+
+	delete(r.inputs, in)
+
+	in.stream.RemoveReader(in)
+	in.path.RemoveReader(defs.PathRemoveReaderReq{Author: in})
+}
+
+// route maps the tracks of an input to the tracks of the output and starts reading.
+func (r *runner) route(in *input) error {
+	used := make(map[format.Format]struct{})
+
+	for _, medi := range in.stream.Desc().Medias {
+		for _, forma := range medi.Formats {
+			track, ok := func() (outputTrack, bool) {
+				for _, outMedia := range r.outMedias {
+					if outMedia.Type != medi.Type {
+						continue
+					}
+
+					for _, outFormat := range outMedia.Formats {
+						if _, ok := used[outFormat]; ok || !formatsAreCompatible(outFormat, forma) {
+							continue
+						}
+
+						used[outFormat] = struct{}{}
+						return outputTrack{media: outMedia, format: outFormat}, true
+					}
+				}
+				return outputTrack{}, false
+			}()
+			if !ok {
+				in.Log(logger.Warn, "input '%s': skipping track with codec %s, that is not compatible with the output",
+					in.name, forma.Codec())
+				continue
+			}
+
+			in.tracks[forma] = track
+
+			if medi.Type == description.MediaTypeVideo {
+				in.hasVideo = true
+			}
+
+			cforma := forma
+			in.stream.AddReader(in, medi, forma, func(u unit.Unit) error {
+				r.onUnit(in, cforma, u)
+				return nil
+			})
+		}
+	}
+
+	if len(in.tracks) == 0 {
+		return fmt.Errorf("path '%s' doesn't contain any track compatible with the switcher", in.name)
+	}
+
+	in.stream.StartReader(in)
+
+	go func(errChan chan error) {
+		select {
+		case err := <-errChan:
+			in.Log(logger.Warn, "input '%s': %v", in.name, err)
+			select {
+			case r.chClosed <- in:
+			case <-r.ctx.Done():
+			}
+		case <-r.ctx.Done():
+		}
+	}(in.stream.ReaderError(in))
+
+	return nil
+}
+
+func (r *runner) doSwitch(name string) error {
+	found := false
+	for _, n := range r.names {
+		if n == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("'%s' is not an input of the switcher", name)
+	}
+
+	r.mutex.Lock()
+	prevPending := r.pending
+	if prevPending != nil && prevPending.name == name {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.pending = nil
+	isActive := r.active != nil && r.active.name == name
+	r.mutex.Unlock()
+
+	if prevPending != nil {
+		r.detach(prevPending)
+	}
+
+	if isActive {
+		return nil
+	}
+
+	in, err := r.attach(name)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.pending = in
+	r.mutex.Unlock()
+
+	err = r.route(in)
+	if err != nil {
+		r.mutex.Lock()
+		r.pending = nil
+		r.mutex.Unlock()
+
+		r.detach(in)
+		return err
+	}
+
+	r.source.Log(logger.Info, "switching to '%s'", name)
+
+	return nil
+}
+
+func (r *runner) onUnit(in *input, forma format.Format, u unit.Unit) {
+	if isEmpty(u) {
+		return
+	}
+
+	track := in.tracks[forma]
+	isVideo := track.media.Type == description.MediaTypeVideo
+	clockRate := forma.ClockRate()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if in == r.pending {
+		if in.hasVideo && (!isVideo || !isRandomAccess(u)) {
+			return
+		}
+
+		if r.startNTP.IsZero() {
+			r.startNTP = u.GetNTP()
+		}
+
+		// align the input with the output timeline, keeping timestamps monotonic.
+		in.offset = u.GetNTP().Sub(r.startNTP) - unit.TimestampToDuration(u.GetPTS(), clockRate)
+		pts := u.GetPTS() + unit.DurationToTimestamp(in.offset, clockRate)
+		if last, ok := r.lastPTS[track.format]; ok && pts <= last {
+			in.offset += unit.TimestampToDuration(last-pts, clockRate) + time.Millisecond
+		}
+
+		for inFormat, inTrack := range in.tracks {
+			copyParams(inTrack.format, inFormat)
+		}
+
+		if prev := r.active; prev != nil {
+			go func() {
+				select {
+				case r.chDetach <- prev:
+				case <-r.ctx.Done():
+				}
+			}()
+		}
+
+		r.active = in
+		r.pending = nil
+
+		r.source.Log(logger.Info, "switched to '%s'", in.name)
+	}
+
+	if in != r.active {
+		return
+	}
+
+	pts := u.GetPTS() + unit.DurationToTimestamp(in.offset, clockRate)
+
+	last, ok := r.lastPTS[track.format]
+	if ok && pts <= last {
+		// drop overlapping samples of non-video tracks
+		if !isVideo {
+			return
+		}
+	} else {
+		r.lastPTS[track.format] = pts
+	}
+
+	// units are shared with other readers of the input.
+	cu := u.Clone()
+	cu.SetPTS(pts)
+	r.stream.WriteUnit(track.media, track.format, cu)
+}
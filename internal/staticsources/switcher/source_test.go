@@ -0,0 +1,175 @@
+package switcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type dummyPath struct {
+	defs.Path
+}
+
+func (dummyPath) RemoveReader(_ defs.PathRemoveReaderReq) {
+}
+
+type dummyPathManager struct {
+	streams map[string]*stream.Stream
+}
+
+func (pm *dummyPathManager) AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+	strm, ok := pm.streams[req.AccessRequest.Name]
+	if !ok {
+		return nil, nil, fmt.Errorf("no stream is available on path '%s'", req.AccessRequest.Name)
+	}
+	return &dummyPath{}, strm, nil
+}
+
+func TestSource(t *testing.T) {
+	pm := &dummyPathManager{
+		streams: make(map[string]*stream.Stream),
+	}
+
+	for _, name := range []string{"cam1", "cam2"} {
+		strm, err := stream.New(
+			512,
+			1460,
+			&description.Session{Medias: []*description.Media{test.UniqueMediaH264()}},
+			true,
+			test.NilLogger,
+		)
+		require.NoError(t, err)
+		defer strm.Close()
+
+		pm.streams[name] = strm
+	}
+
+	var source *Source
+
+	te := test.NewSourceTester(
+		func(p defs.StaticSourceParent) defs.StaticSource {
+			source = &Source{
+				PathManager: pm,
+				Parent:      p,
+			}
+			return source
+		},
+		"switcher://cam1|cam2",
+		&conf.Path{},
+	)
+	defer te.Close()
+
+	strm := pm.streams["cam1"]
+	strm.WaitRunningReader()
+
+	for _, au := range [][][]byte{
+		{{1, 1}}, // non-IDR, discarded
+		{{5, 1}}, // IDR
+	} {
+		strm.WriteUnit(strm.Desc().Medias[0], strm.Desc().Medias[0].Formats[0], &unit.H264{
+			Base: unit.Base{
+				NTP: time.Now(),
+				PTS: 90000,
+			},
+			AU: au,
+		})
+	}
+
+	u := <-te.Unit
+	require.Equal(t, [][]byte{
+		test.FormatH264.SPS,
+		test.FormatH264.PPS,
+		{5, 1},
+	}, u.(*unit.H264).AU)
+
+	err := source.Switch("cam2")
+	require.NoError(t, err)
+
+	err = source.Switch("cam3")
+	require.EqualError(t, err, "'cam3' is not an input of the switcher")
+}
+
+func TestSourceFirstInputNotAvailable(t *testing.T) {
+	strm, err := stream.New(
+		512,
+		1460,
+		&description.Session{Medias: []*description.Media{test.UniqueMediaH264()}},
+		true,
+		test.NilLogger,
+	)
+	require.NoError(t, err)
+	defer strm.Close()
+
+	pm := &dummyPathManager{
+		streams: map[string]*stream.Stream{
+			"cam2": strm,
+		},
+	}
+
+	te := test.NewSourceTester(
+		func(p defs.StaticSourceParent) defs.StaticSource {
+			return &Source{
+				PathManager: pm,
+				Parent:      p,
+			}
+		},
+		"switcher://cam1|cam2",
+		&conf.Path{},
+	)
+	defer te.Close()
+
+	strm.WaitRunningReader()
+
+	strm.WriteUnit(strm.Desc().Medias[0], strm.Desc().Medias[0].Formats[0], &unit.H264{
+		Base: unit.Base{
+			NTP: time.Now(),
+			PTS: 90000,
+		},
+		AU: [][]byte{{5, 1}},
+	})
+
+	u := <-te.Unit
+	require.Equal(t, [][]byte{
+		test.FormatH264.SPS,
+		test.FormatH264.PPS,
+		{5, 1},
+	}, u.(*unit.H264).AU)
+}
+
+func TestFormatsAreCompatible(t *testing.T) {
+	newAAC := func(sampleRate int) format.Format {
+		return &format.MPEG4Audio{
+			PayloadTyp: 96,
+			Config: &mpeg4audio.Config{
+				Type:         2,
+				SampleRate:   sampleRate,
+				ChannelCount: 2,
+			},
+			SizeLength:       13,
+			IndexLength:      3,
+			IndexDeltaLength: 3,
+		}
+	}
+
+	require.True(t, formatsAreCompatible(newAAC(44100), newAAC(44100)))
+	require.False(t, formatsAreCompatible(newAAC(44100), newAAC(48000)))
+
+	// H264 parameters are copied when switching.
+	require.True(t, formatsAreCompatible(test.FormatH264, &format.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+	}))
+
+	require.False(t, formatsAreCompatible(test.FormatH264, newAAC(44100)))
+}
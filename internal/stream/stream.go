@@ -2,6 +2,8 @@
 package stream
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/bluenviron/gortsplib/v4/pkg/format"
 	"github.com/pion/rtp"
 
+	"github.com/bluenviron/mediamtx/internal/latency"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/unit"
 )
@@ -23,6 +26,15 @@ type Reader interface {
 // ReadFunc is the callback passed to AddReader().
 type ReadFunc func(unit.Unit) error
 
+func serverStreamHasMedia(st *gortsplib.ServerStream, medi *description.Media) bool {
+	for _, m := range st.Description().Medias {
+		if m == medi {
+			return true
+		}
+	}
+	return false
+}
+
 // Stream is a media stream.
 // It stores tracks, readers and allows to write data to readers.
 type Stream struct {
@@ -33,10 +45,14 @@ type Stream struct {
 	bytesSent     *uint64
 	streamMedias  map[*description.Media]*streamMedia
 	mutex         sync.RWMutex
-	rtspStream    *gortsplib.ServerStream
-	rtspsStream   *gortsplib.ServerStream
+	rtspStreams   map[string]*gortsplib.ServerStream
+	rtspsStreams  map[string]*gortsplib.ServerStream
 	streamReaders map[Reader]*streamReader
 
+	normalizerStatsMutex sync.Mutex
+	normalizerStats      *TimestampNormalizerStats
+	latency              latency.Meter
+
 	readerRunning chan struct{}
 }
 
@@ -56,6 +72,8 @@ func New(
 	}
 
 	s.streamMedias = make(map[*description.Media]*streamMedia)
+	s.rtspStreams = make(map[string]*gortsplib.ServerStream)
+	s.rtspsStreams = make(map[string]*gortsplib.ServerStream)
 	s.streamReaders = make(map[Reader]*streamReader)
 	s.readerRunning = make(chan struct{})
 
@@ -70,13 +88,50 @@ func New(
 	return s, nil
 }
 
+// EnableTimestampNormalizer enables the normalization of timestamps of incoming data.
+// Discontinuities are detected when timestamps move backwards by more than maxJump,
+// or forwards by more than maxJump plus the elapsed time.
+// It must be called before writing any data.
+func (s *Stream) EnableTimestampNormalizer(maxJump time.Duration, smooth bool, parent logger.Writer) {
+	s.normalizerStats = &TimestampNormalizerStats{}
+	rebaser := &timestampRebaser{}
+
+	for _, sm := range s.streamMedias {
+		for forma, sf := range sm.formats {
+			sf.normalizer = &timestampNormalizer{
+				format:   forma,
+				maxJump:  maxJump,
+				smooth:   smooth,
+				logger:   parent,
+				statsMux: &s.normalizerStatsMutex,
+				stats:    s.normalizerStats,
+				rebaser:  rebaser,
+			}
+		}
+	}
+}
+
+// TimestampNormalizerStats returns statistics of the timestamp normalizer,
+// or nil if the timestamp normalizer is disabled.
+func (s *Stream) TimestampNormalizerStats() *TimestampNormalizerStats {
+	if s.normalizerStats == nil {
+		return nil
+	}
+
+	s.normalizerStatsMutex.Lock()
+	defer s.normalizerStatsMutex.Unlock()
+
+	v := *s.normalizerStats
+	return &v
+}
+
 // Close closes all resources of the stream.
 func (s *Stream) Close() {
-	if s.rtspStream != nil {
-		s.rtspStream.Close()
+	for _, st := range s.rtspStreams {
+		st.Close()
 	}
-	if s.rtspsStream != nil {
-		s.rtspsStream.Close()
+	for _, st := range s.rtspsStreams {
+		st.Close()
 	}
 }
 
@@ -96,35 +151,89 @@ func (s *Stream) BytesSent() uint64 {
 	defer s.mutex.RUnlock()
 
 	bytesSent := atomic.LoadUint64(s.bytesSent)
-	if s.rtspStream != nil {
-		bytesSent += s.rtspStream.BytesSent()
+	for _, st := range s.rtspStreams {
+		bytesSent += st.BytesSent()
 	}
-	if s.rtspsStream != nil {
-		bytesSent += s.rtspsStream.BytesSent()
+	for _, st := range s.rtspsStreams {
+		bytesSent += st.BytesSent()
 	}
 	return bytesSent
 }
 
-// RTSPStream returns the RTSP stream.
-func (s *Stream) RTSPStream(server *gortsplib.Server) *gortsplib.ServerStream {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// Latency returns the delay between the NTP timestamp of incoming units and the time they are
+// written to the stream, or nil if no unit has been written yet.
+// It is also the latency of RTSP readers, since they are fed directly by the stream.
+func (s *Stream) Latency() *latency.Latency {
+	return s.latency.Get()
+}
+
+// ReaderLatency returns the delay between the NTP timestamp of units and the time they are
+// written by a reader, or nil if no unit has been written yet.
+func (s *Stream) ReaderLatency(reader Reader) *latency.Latency {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	if s.rtspStream == nil {
-		s.rtspStream = gortsplib.NewServerStream(server, s.desc)
+	sr, ok := s.streamReaders[reader]
+	if !ok {
+		return nil
+	}
+	return sr.latency.Get()
+}
+
+// descKey returns a key that identifies a subset of the medias of the stream.
+func (s *Stream) descKey(desc *description.Session) string {
+	var indexes []string
+	for i, medi := range s.desc.Medias {
+		for _, m := range desc.Medias {
+			if m == medi {
+				indexes = append(indexes, strconv.FormatInt(int64(i), 10))
+				break
+			}
+		}
 	}
-	return s.rtspStream
+	return strings.Join(indexes, ",")
 }
 
-// RTSPSStream returns the RTSPS stream.
-func (s *Stream) RTSPSStream(server *gortsplib.Server) *gortsplib.ServerStream {
+func (s *Stream) serverStream(
+	streams map[string]*gortsplib.ServerStream,
+	server *gortsplib.Server,
+	desc *description.Session,
+) *gortsplib.ServerStream {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.rtspsStream == nil {
-		s.rtspsStream = gortsplib.NewServerStream(server, s.desc)
+	key := s.descKey(desc)
+
+	st, ok := streams[key]
+	if !ok {
+		// use medias in the same order of the stream, in order to share server streams between readers.
+		sub := *s.desc
+		sub.Medias = nil
+		for _, medi := range s.desc.Medias {
+			for _, m := range desc.Medias {
+				if m == medi {
+					sub.Medias = append(sub.Medias, medi)
+					break
+				}
+			}
+		}
+
+		st = gortsplib.NewServerStream(server, &sub)
+		streams[key] = st
 	}
-	return s.rtspsStream
+	return st
+}
+
+// RTSPStream returns a RTSP stream that contains the medias of desc,
+// that must be a subset of the stream description.
+func (s *Stream) RTSPStream(server *gortsplib.Server, desc *description.Session) *gortsplib.ServerStream {
+	return s.serverStream(s.rtspStreams, server, desc)
+}
+
+// RTSPSStream returns a RTSPS stream that contains the medias of desc,
+// that must be a subset of the stream description.
+func (s *Stream) RTSPSStream(server *gortsplib.Server, desc *description.Session) *gortsplib.ServerStream {
+	return s.serverStream(s.rtspsStreams, server, desc)
 }
 
 // AddReader adds a reader.
@@ -224,8 +333,12 @@ func (s *Stream) WaitRunningReader() {
 }
 
 // WriteUnit writes a Unit.
+// Units that belong to medias that are not part of the stream are discarded.
 func (s *Stream) WriteUnit(medi *description.Media, forma format.Format, u unit.Unit) {
-	sm := s.streamMedias[medi]
+	sm, ok := s.streamMedias[medi]
+	if !ok {
+		return
+	}
 	sf := sm.formats[forma]
 
 	s.mutex.RLock()
@@ -235,6 +348,7 @@ func (s *Stream) WriteUnit(medi *description.Media, forma format.Format, u unit.
 }
 
 // WriteRTPPacket writes a RTP packet.
+// Packets that belong to medias that are not part of the stream are discarded.
 func (s *Stream) WriteRTPPacket(
 	medi *description.Media,
 	forma format.Format,
@@ -242,7 +356,10 @@ func (s *Stream) WriteRTPPacket(
 	ntp time.Time,
 	pts int64,
 ) {
-	sm := s.streamMedias[medi]
+	sm, ok := s.streamMedias[medi]
+	if !ok {
+		return
+	}
 	sf := sm.formats[forma]
 
 	s.mutex.RLock()
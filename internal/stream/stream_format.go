@@ -28,6 +28,7 @@ type streamFormat struct {
 	decodeErrLogger    logger.Writer
 
 	proc           formatprocessor.Processor
+	normalizer     *timestampNormalizer
 	pausedReaders  map[*streamReader]ReadFunc
 	runningReaders map[*streamReader]ReadFunc
 }
@@ -62,6 +63,10 @@ func (sf *streamFormat) startReader(sr *streamReader) {
 }
 
 func (sf *streamFormat) writeUnit(s *Stream, medi *description.Media, u unit.Unit) {
+	if sf.normalizer != nil {
+		u.SetPTS(sf.normalizer.process(u.GetPTS(), u.GetNTP()))
+	}
+
 	err := sf.proc.ProcessUnit(u)
 	if err != nil {
 		sf.decodeErrLogger.Log(logger.Warn, err.Error())
@@ -78,6 +83,12 @@ func (sf *streamFormat) writeRTPPacket(
 	ntp time.Time,
 	pts int64,
 ) {
+	if sf.normalizer != nil {
+		newPTS := sf.normalizer.process(pts, ntp)
+		pkt.Timestamp += uint32(newPTS - pts)
+		pts = newPTS
+	}
+
 	hasNonRTSPReaders := len(sf.pausedReaders) > 0 || len(sf.runningReaders) > 0
 
 	u, err := sf.proc.ProcessRTPPacket(pkt, ntp, pts, hasNonRTSPReaders)
@@ -93,24 +104,32 @@ func (sf *streamFormat) writeUnitInner(s *Stream, medi *description.Media, u uni
 	size := unitSize(u)
 
 	atomic.AddUint64(s.bytesReceived, size)
+	s.latency.Update(u.GetNTP())
 
-	if s.rtspStream != nil {
-		for _, pkt := range u.GetRTPPackets() {
-			s.rtspStream.WritePacketRTPWithNTP(medi, pkt, u.GetNTP()) //nolint:errcheck
+	for _, st := range s.rtspStreams {
+		if serverStreamHasMedia(st, medi) {
+			for _, pkt := range u.GetRTPPackets() {
+				st.WritePacketRTPWithNTP(medi, pkt, u.GetNTP()) //nolint:errcheck
+			}
 		}
 	}
 
-	if s.rtspsStream != nil {
-		for _, pkt := range u.GetRTPPackets() {
-			s.rtspsStream.WritePacketRTPWithNTP(medi, pkt, u.GetNTP()) //nolint:errcheck
+	for _, st := range s.rtspsStreams {
+		if serverStreamHasMedia(st, medi) {
+			for _, pkt := range u.GetRTPPackets() {
+				st.WritePacketRTPWithNTP(medi, pkt, u.GetNTP()) //nolint:errcheck
+			}
 		}
 	}
 
 	for sr, cb := range sf.runningReaders {
 		ccb := cb
+		csr := sr
 		sr.push(func() error {
 			atomic.AddUint64(s.bytesSent, size)
-			return ccb(u)
+			err := ccb(u)
+			csr.latency.Update(u.GetNTP())
+			return err
 		})
 	}
 }
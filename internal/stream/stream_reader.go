@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/bluenviron/gortsplib/v4/pkg/ringbuffer"
+	"github.com/bluenviron/mediamtx/internal/latency"
 	"github.com/bluenviron/mediamtx/internal/logger"
 )
 
@@ -14,6 +15,7 @@ type streamReader struct {
 	writeErrLogger logger.Writer
 	buffer         *ringbuffer.RingBuffer
 	started        bool
+	latency        latency.Meter
 
 	// out
 	err chan error
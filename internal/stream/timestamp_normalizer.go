@@ -0,0 +1,180 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// weight of new samples in the estimate of the frame duration and in the smoothing filter.
+const smoothingFactor = 16
+
+// roundDurationToTimestamp converts a duration into the nearest timestamp with given clock rate.
+func roundDurationToTimestamp(d time.Duration, clockRate int) int64 {
+	half := time.Second / time.Duration(2*clockRate)
+	if d < 0 {
+		return unit.DurationToTimestamp(d-half, clockRate)
+	}
+	return unit.DurationToTimestamp(d+half, clockRate)
+}
+
+// timestampRebaser stores the offset applied to timestamps of all formats of a stream,
+// in order to keep them aligned after a discontinuity.
+// The offset is computed by the first format that detects a discontinuity,
+// and is adopted by the other formats when they detect the same discontinuity.
+type timestampRebaser struct {
+	mutex      sync.Mutex
+	offset     time.Duration
+	generation uint64
+	ntp        time.Time
+}
+
+// TimestampNormalizerStats are statistics about corrections performed by the timestamp normalizer.
+type TimestampNormalizerStats struct {
+	Discontinuities       uint64
+	LastDiscontinuityTime time.Time
+	SmoothedUnits         uint64
+}
+
+// timestampNormalizer detects timestamp discontinuities (jumps, wraps, negative steps),
+// rebases timestamps in order to keep them monotonic and optionally removes jitter
+// from constant frame rate sources.
+type timestampNormalizer struct {
+	format   format.Format
+	maxJump  time.Duration
+	smooth   bool
+	logger   logger.Writer
+	statsMux *sync.Mutex
+	stats    *TimestampNormalizerStats
+	rebaser  *timestampRebaser
+
+	initialized bool
+	generation  uint64
+	offset      int64
+	lastIn      int64
+	lastNTP     time.Time
+	lastRebased int64
+	lastOut     int64
+	lastDelta   int64
+	duration    int64
+	reordered   bool
+}
+
+// process returns the normalized version of a PTS.
+func (n *timestampNormalizer) process(pts int64, ntp time.Time) int64 {
+	if !n.initialized {
+		n.initialized = true
+		n.lastIn = pts
+		n.lastNTP = ntp
+		n.lastRebased = pts
+		n.lastOut = pts
+		return pts
+	}
+
+	clockRate := n.format.ClockRate()
+	maxJump := unit.DurationToTimestamp(n.maxJump, clockRate)
+	delta := pts - n.lastIn
+
+	// a discontinuity is a step backwards or a step forwards that is not justified by elapsed time.
+	if delta < -maxJump ||
+		(delta > maxJump && delta-unit.DurationToTimestamp(ntp.Sub(n.lastNTP), clockRate) > maxJump) {
+		prevOffset := n.offset
+		n.offset = n.rebase(pts, ntp)
+
+		n.statsMux.Lock()
+		n.stats.Discontinuities++
+		n.stats.LastDiscontinuityTime = time.Now()
+		n.statsMux.Unlock()
+
+		n.logger.Log(logger.Warn, "%s: timestamp discontinuity of %v detected, shifting timestamps by %v",
+			n.format.Codec(),
+			unit.TimestampToDuration(delta, clockRate),
+			unit.TimestampToDuration(n.offset-prevOffset, clockRate))
+	} else if delta > 0 {
+		n.lastDelta = delta
+	}
+
+	n.lastIn = pts
+	n.lastNTP = ntp
+
+	rebased := pts + n.offset
+	rebasedDelta := rebased - n.lastRebased
+	n.lastRebased = rebased
+
+	// units that share the same timestamp (i.e. RTP packets of the same frame) keep sharing it.
+	if rebasedDelta == 0 {
+		return n.lastOut
+	}
+
+	// timestamps of streams with reordered frames can't be smoothed.
+	if rebasedDelta < 0 {
+		n.reordered = true
+	}
+
+	if !n.smooth || n.reordered {
+		n.lastOut = rebased
+		return rebased
+	}
+
+	if n.duration == 0 {
+		n.duration = rebasedDelta
+		n.lastOut = rebased
+		return rebased
+	}
+
+	predicted := n.lastOut + n.duration
+	diff := rebased - predicted
+
+	// difference is too big to be jitter: resynchronize.
+	if diff > 2*n.duration || diff < -2*n.duration {
+		n.lastOut = rebased
+		return rebased
+	}
+
+	n.duration += (rebasedDelta - n.duration) / smoothingFactor
+	out := predicted + diff/smoothingFactor
+
+	if out != rebased {
+		n.statsMux.Lock()
+		n.stats.SmoothedUnits++
+		n.statsMux.Unlock()
+	}
+
+	n.lastOut = out
+	return out
+}
+
+// rebase returns the offset to apply after a discontinuity.
+func (n *timestampNormalizer) rebase(pts int64, ntp time.Time) int64 {
+	clockRate := n.format.ClockRate()
+
+	n.rebaser.mutex.Lock()
+	defer n.rebaser.mutex.Unlock()
+
+	// another format of the stream already detected the same discontinuity:
+	// use its offset, in order to keep formats aligned.
+	if n.rebaser.generation != n.generation {
+		since := ntp.Sub(n.rebaser.ntp)
+		if since <= n.maxJump && since >= -n.maxJump {
+			offset := roundDurationToTimestamp(n.rebaser.offset, clockRate)
+
+			if pts+offset > n.lastRebased {
+				n.generation = n.rebaser.generation
+				return offset
+			}
+		}
+	}
+
+	offset := n.lastRebased + max(n.lastDelta, 1) - pts
+
+	n.rebaser.offset = unit.TimestampToDuration(offset, clockRate)
+	n.rebaser.generation++
+	n.rebaser.ntp = ntp
+	n.generation = n.rebaser.generation
+
+	return offset
+}
@@ -0,0 +1,151 @@
+package stream
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(_ logger.Level, _ string, _ ...interface{}) {
+}
+
+func TestTimestampNormalizer(t *testing.T) {
+	for _, ca := range []string{
+		"backward jump",
+		"forward jump",
+		"gap",
+		"reordered frames",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			n := &timestampNormalizer{
+				format:   &format.H264{},
+				maxJump:  1 * time.Second,
+				logger:   nilLogger{},
+				statsMux: &sync.Mutex{},
+				stats:    &TimestampNormalizerStats{},
+				rebaser:  &timestampRebaser{},
+			}
+
+			ntp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			var in []int64
+			var out []int64
+			var ntpSteps []time.Duration
+			var discontinuities uint64
+
+			switch ca {
+			case "backward jump":
+				in = []int64{900000, 903000, 906000, 3000, 6000}
+				out = []int64{900000, 903000, 906000, 909000, 912000}
+				discontinuities = 1
+
+			case "forward jump":
+				in = []int64{3000, 6000, 9000000, 9003000}
+				out = []int64{3000, 6000, 9000, 12000}
+				discontinuities = 1
+
+			case "gap":
+				in = []int64{3000, 6000, 906000, 909000}
+				ntpSteps = []time.Duration{0, 0, 10 * time.Second, 0}
+				out = []int64{3000, 6000, 906000, 909000}
+
+			case "reordered frames":
+				in = []int64{3000, 12000, 6000, 9000, 6000}
+				out = []int64{3000, 12000, 6000, 9000, 6000}
+			}
+
+			for i, pts := range in {
+				if ntpSteps != nil {
+					ntp = ntp.Add(ntpSteps[i])
+				}
+				require.Equal(t, out[i], n.process(pts, ntp))
+			}
+
+			require.Equal(t, discontinuities, n.stats.Discontinuities)
+		})
+	}
+}
+
+func TestTimestampNormalizerSmoothing(t *testing.T) {
+	n := &timestampNormalizer{
+		format:   &format.H264{},
+		maxJump:  1 * time.Second,
+		smooth:   true,
+		logger:   nilLogger{},
+		statsMux: &sync.Mutex{},
+		stats:    &TimestampNormalizerStats{},
+		rebaser:  &timestampRebaser{},
+	}
+
+	ntp := time.Now()
+
+	prev := n.process(0, ntp)
+
+	for i := int64(1); i < 100; i++ {
+		jitter := int64(300)
+		if i%2 == 0 {
+			jitter = -300
+		}
+
+		pts := n.process(i*3000+jitter, ntp)
+
+		if i > 10 {
+			require.InDelta(t, 3000, pts-prev, 100)
+		}
+		prev = pts
+	}
+
+	require.NotZero(t, n.stats.SmoothedUnits)
+}
+
+func TestTimestampNormalizerMultipleFormats(t *testing.T) {
+	rebaser := &timestampRebaser{}
+	stats := &TimestampNormalizerStats{}
+
+	video := &timestampNormalizer{
+		format:   &format.H264{},
+		maxJump:  1 * time.Second,
+		logger:   nilLogger{},
+		statsMux: &sync.Mutex{},
+		stats:    stats,
+		rebaser:  rebaser,
+	}
+
+	audio := &timestampNormalizer{
+		format:   &format.Opus{ChannelCount: 2},
+		maxJump:  1 * time.Second,
+		logger:   nilLogger{},
+		statsMux: video.statsMux,
+		stats:    stats,
+		rebaser:  rebaser,
+	}
+
+	ntp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// both formats jump backwards together after 4 units.
+	for i := int64(0); i < 8; i++ {
+		videoIn := 900000 + i*3000
+		audioIn := 480000 + i*1600
+		if i >= 4 {
+			videoIn = (i - 4) * 3000
+			audioIn = (i - 4) * 1600
+		}
+
+		videoOut := video.process(videoIn, ntp)
+		audioOut := audio.process(audioIn, ntp)
+
+		require.Equal(t, 900000+i*3000, videoOut)
+		require.Equal(t, 480000+i*1600, audioOut)
+
+		ntp = ntp.Add(time.Second / 30)
+	}
+
+	require.Equal(t, uint64(2), stats.Discontinuities)
+}
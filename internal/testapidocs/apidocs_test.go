@@ -48,6 +48,14 @@ func TestAPIDocs(t *testing.T) {
 			"PathConfList",
 			defs.APIPathConfList{},
 		},
+		{
+			"PathConfRecordScheduleException",
+			conf.RecordScheduleException{},
+		},
+		{
+			"PathConfRecordScheduleWindow",
+			conf.RecordScheduleWindow{},
+		},
 		{
 			"Path",
 			defs.APIPath{},
@@ -56,6 +64,26 @@ func TestAPIDocs(t *testing.T) {
 			"PathList",
 			defs.APIPathList{},
 		},
+		{
+			"PathRecording",
+			defs.APIPathRecording{},
+		},
+		{
+			"PathRecordSchedule",
+			defs.APIPathRecordSchedule{},
+		},
+		{
+			"PathTimestampNormalizer",
+			defs.APIPathTimestampNormalizer{},
+		},
+		{
+			"Latency",
+			defs.APILatency{},
+		},
+		{
+			"PathSwitch",
+			defs.APIPathSwitchReq{},
+		},
 		{
 			"PathSource",
 			defs.APIPathSourceOrReader{},
@@ -76,14 +104,54 @@ func TestAPIDocs(t *testing.T) {
 			"Recording",
 			defs.APIRecording{},
 		},
+		{
+			"RecordingAddMarker",
+			defs.APIRecordingAddMarkerReq{},
+		},
+		{
+			"RecordingAddMarkerRes",
+			defs.APIRecordingAddMarkerRes{},
+		},
 		{
 			"RecordingList",
 			defs.APIRecordingList{},
 		},
+		{
+			"RecordingMarker",
+			defs.APIRecordingMarker{},
+		},
+		{
+			"RecordingRepair",
+			defs.APIRecordingRepair{},
+		},
+		{
+			"RecordingRepairedSegment",
+			defs.APIRecordingRepairedSegment{},
+		},
 		{
 			"RecordingSegment",
 			defs.APIRecordingSegment{},
 		},
+		{
+			"RecordingStart",
+			defs.APIRecordingStartReq{},
+		},
+		{
+			"RecordingTrigger",
+			defs.APIRecordingTriggerReq{},
+		},
+		{
+			"RecordingVerifiedSegment",
+			defs.APIRecordingVerifiedSegment{},
+		},
+		{
+			"RecordingVerify",
+			defs.APIRecordingVerify{},
+		},
+		{
+			"ReplayClip",
+			defs.APIReplayClip{},
+		},
 		{
 			"RTMPConn",
 			defs.APIRTMPConn{},
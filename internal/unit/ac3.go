@@ -5,3 +5,9 @@ type AC3 struct {
 	Base
 	Frames [][]byte
 }
+
+// Clone implements Unit.
+func (u *AC3) Clone() Unit {
+	c := *u
+	return &c
+}
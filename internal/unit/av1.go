@@ -5,3 +5,9 @@ type AV1 struct {
 	Base
 	TU [][]byte
 }
+
+// Clone implements Unit.
+func (u *AV1) Clone() Unit {
+	c := *u
+	return &c
+}
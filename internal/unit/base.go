@@ -27,3 +27,8 @@ func (u *Base) GetNTP() time.Time {
 func (u *Base) GetPTS() int64 {
 	return u.PTS
 }
+
+// SetPTS implements Unit.
+func (u *Base) SetPTS(pts int64) {
+	u.PTS = pts
+}
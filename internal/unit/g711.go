@@ -5,3 +5,9 @@ type G711 struct {
 	Base
 	Samples []byte
 }
+
+// Clone implements Unit.
+func (u *G711) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -4,3 +4,9 @@ package unit
 type Generic struct {
 	Base
 }
+
+// Clone implements Unit.
+func (u *Generic) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type H264 struct {
 	Base
 	AU [][]byte
 }
+
+// Clone implements Unit.
+func (u *H264) Clone() Unit {
+	c := *u
+	return &c
+}
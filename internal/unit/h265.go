@@ -5,3 +5,9 @@ type H265 struct {
 	Base
 	AU [][]byte
 }
+
+// Clone implements Unit.
+func (u *H265) Clone() Unit {
+	c := *u
+	return &c
+}
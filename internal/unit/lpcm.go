@@ -5,3 +5,9 @@ type LPCM struct {
 	Base
 	Samples []byte
 }
+
+// Clone implements Unit.
+func (u *LPCM) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type MJPEG struct {
 	Base
 	Frame []byte
 }
+
+// Clone implements Unit.
+func (u *MJPEG) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type MPEG1Audio struct {
 	Base
 	Frames [][]byte
 }
+
+// Clone implements Unit.
+func (u *MPEG1Audio) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type MPEG1Video struct {
 	Base
 	Frame []byte
 }
+
+// Clone implements Unit.
+func (u *MPEG1Video) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type MPEG4Audio struct {
 	Base
 	AUs [][]byte
 }
+
+// Clone implements Unit.
+func (u *MPEG4Audio) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type MPEG4Video struct {
 	Base
 	Frame []byte
 }
+
+// Clone implements Unit.
+func (u *MPEG4Video) Clone() Unit {
+	c := *u
+	return &c
+}
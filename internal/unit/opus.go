@@ -5,3 +5,9 @@ type Opus struct {
 	Base
 	Packets [][]byte
 }
+
+// Clone implements Unit.
+func (u *Opus) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -0,0 +1,21 @@
+package unit
+
+import (
+	"time"
+)
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+// DurationToTimestamp converts a duration into a timestamp with given clock rate.
+func DurationToTimestamp(d time.Duration, clockRate int) int64 {
+	return multiplyAndDivide(int64(d), int64(clockRate), int64(time.Second))
+}
+
+// TimestampToDuration converts a timestamp with given clock rate into a duration.
+func TimestampToDuration(t int64, clockRate int) time.Duration {
+	return time.Duration(multiplyAndDivide(t, int64(time.Second), int64(clockRate)))
+}
@@ -0,0 +1,40 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationToTimestamp(t *testing.T) {
+	require.Equal(t, int64(90000), DurationToTimestamp(1*time.Second, 90000))
+
+	// no overflow after a long time
+	require.Equal(t, int64(90000*3600*24*365), DurationToTimestamp(24*365*time.Hour, 90000))
+}
+
+func TestTimestampToDuration(t *testing.T) {
+	require.Equal(t, 1*time.Second, TimestampToDuration(90000, 90000))
+	require.Equal(t, 24*365*time.Hour, TimestampToDuration(90000*3600*24*365, 90000))
+}
+
+func TestClone(t *testing.T) {
+	u := &H264{
+		Base: Base{
+			PTS: 1000,
+		},
+		AU: [][]byte{{1, 2}},
+	}
+
+	c := u.Clone()
+	c.SetPTS(2000)
+
+	require.Equal(t, int64(1000), u.PTS)
+	require.Equal(t, &H264{
+		Base: Base{
+			PTS: 2000,
+		},
+		AU: [][]byte{{1, 2}},
+	}, c)
+}
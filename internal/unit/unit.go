@@ -17,4 +17,10 @@ type Unit interface {
 
 	// returns the PTS of the unit.
 	GetPTS() int64
+
+	// sets the PTS of the unit.
+	SetPTS(int64)
+
+	// returns a shallow copy of the unit.
+	Clone() Unit
 }
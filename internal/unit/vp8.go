@@ -5,3 +5,9 @@ type VP8 struct {
 	Base
 	Frame []byte
 }
+
+// Clone implements Unit.
+func (u *VP8) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -5,3 +5,9 @@ type VP9 struct {
 	Base
 	Frame []byte
 }
+
+// Clone implements Unit.
+func (u *VP9) Clone() Unit {
+	c := *u
+	return &c
+}
@@ -12,5 +12,8 @@ func main() {
 	if !ok {
 		os.Exit(1)
 	}
+	if s == nil {
+		return
+	}
 	s.Wait()
 }